@@ -0,0 +1,34 @@
+package adapter
+
+import (
+	"time"
+
+	"github.com/sagernet/sing/common/observable"
+)
+
+// DNSQueryLog is a structured record of a DNS query handled by the router.
+type DNSQueryLog struct {
+	Time      time.Time `json:"time"`
+	Client    string    `json:"client,omitempty"`
+	Inbound   string    `json:"inbound,omitempty"`
+	Domain    string    `json:"domain"`
+	Type      string    `json:"type"`
+	RuleIndex int       `json:"rule_index"`
+	Rule      string    `json:"rule,omitempty"`
+	Transport string    `json:"transport,omitempty"`
+	RCode     string    `json:"rcode"`
+	Answers   []string  `json:"answers,omitempty"`
+	Latency   int64     `json:"latency"`
+	Cached    bool      `json:"cached,omitempty"`
+	FakeIP    bool      `json:"fakeip,omitempty"`
+	Fallback  bool      `json:"fallback,omitempty"`
+	Error     string    `json:"error,omitempty"`
+}
+
+type DNSQueryLogger interface {
+	Service
+	observable.Observable[DNSQueryLog]
+	// Enabled reports whether records are written or subscribed, callers skip building records otherwise.
+	Enabled() bool
+	Log(record DNSQueryLog)
+}
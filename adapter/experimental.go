@@ -9,9 +9,13 @@ import (
 	"time"
 
 	"github.com/sagernet/sing-box/common/urltest"
+	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common/logger"
 	N "github.com/sagernet/sing/common/network"
 	"github.com/sagernet/sing/common/rw"
+
+	mdns "github.com/miekg/dns"
 )
 
 type ClashServer interface {
@@ -22,6 +26,8 @@ type ClashServer interface {
 	HistoryStorage() *urltest.HistoryStorage
 	RoutedConnection(ctx context.Context, conn net.Conn, metadata InboundContext, matchedRule Rule) (net.Conn, Tracker)
 	RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext, matchedRule Rule) (N.PacketConn, Tracker)
+	// Reload updates the server with the options of a reloaded instance.
+	Reload(options option.Options) error
 }
 
 type CacheFile interface {
@@ -34,6 +40,11 @@ type CacheFile interface {
 	StoreRDRC() bool
 	dns.RDRCStore
 
+	StoreDNS() bool
+	LoadDNSResponses(staleTimeout time.Duration) []*SavedDNSResponse
+	SaveDNSResponseAsync(response *SavedDNSResponse, logger logger.Logger)
+	ClearDNSResponses() error
+
 	LoadMode() string
 	StoreMode(mode string) error
 	LoadSelected(group string) string
@@ -42,6 +53,12 @@ type CacheFile interface {
 	StoreGroupExpand(group string, expand bool) error
 	LoadRuleSet(tag string) *SavedRuleSet
 	SaveRuleSet(tag string, set *SavedRuleSet) error
+	LoadProviderInfo(tag string) *SavedProviderInfo
+	SaveProviderInfo(tag string, info *SavedProviderInfo) error
+	LoadProviderHistory(tag string) SavedProviderHistory
+	SaveProviderHistory(tag string, history SavedProviderHistory) error
+	LoadTrafficUsage(key string) *SavedTrafficUsage
+	SaveTrafficUsage(key string, usage *SavedTrafficUsage) error
 }
 
 type SavedRuleSet struct {
@@ -101,6 +118,213 @@ func (s *SavedRuleSet) UnmarshalBinary(data []byte) error {
 	return nil
 }
 
+type SavedProviderInfo struct {
+	LastUpdated time.Time
+	Upload      int64
+	Download    int64
+	Total       int64
+	Expire      int64
+}
+
+func (s *SavedProviderInfo) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, uint8(1))
+	if err != nil {
+		return nil, err
+	}
+	for _, value := range []int64{s.LastUpdated.Unix(), s.Upload, s.Download, s.Total, s.Expire} {
+		err = binary.Write(&buffer, binary.BigEndian, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func (s *SavedProviderInfo) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	var version uint8
+	err := binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return err
+	}
+	var lastUpdated int64
+	for _, value := range []*int64{&lastUpdated, &s.Upload, &s.Download, &s.Total, &s.Expire} {
+		err = binary.Read(reader, binary.BigEndian, value)
+		if err != nil {
+			return err
+		}
+	}
+	s.LastUpdated = time.Unix(lastUpdated, 0)
+	return nil
+}
+
+// SavedTrafficUsage is the quota usage of an inbound or user within the current quota period.
+type SavedTrafficUsage struct {
+	PeriodStart time.Time
+	Usage       uint64
+}
+
+func (s *SavedTrafficUsage) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, uint8(1))
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Write(&buffer, binary.BigEndian, s.PeriodStart.Unix())
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Write(&buffer, binary.BigEndian, s.Usage)
+	if err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+func (s *SavedTrafficUsage) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	var version uint8
+	err := binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return err
+	}
+	var periodStart int64
+	err = binary.Read(reader, binary.BigEndian, &periodStart)
+	if err != nil {
+		return err
+	}
+	s.PeriodStart = time.Unix(periodStart, 0)
+	return binary.Read(reader, binary.BigEndian, &s.Usage)
+}
+
+// SavedDNSResponse is a cached DNS response, Transport is empty unless the cache is independent per server.
+type SavedDNSResponse struct {
+	Transport string
+	Response  *mdns.Msg
+	ExpireAt  time.Time
+}
+
+func (s *SavedDNSResponse) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, uint8(1))
+	if err != nil {
+		return nil, err
+	}
+	err = rw.WriteVString(&buffer, s.Transport)
+	if err != nil {
+		return nil, err
+	}
+	err = binary.Write(&buffer, binary.BigEndian, s.ExpireAt.Unix())
+	if err != nil {
+		return nil, err
+	}
+	content, err := s.Response.Pack()
+	if err != nil {
+		return nil, err
+	}
+	err = rw.WriteUVariant(&buffer, uint64(len(content)))
+	if err != nil {
+		return nil, err
+	}
+	buffer.Write(content)
+	return buffer.Bytes(), nil
+}
+
+func (s *SavedDNSResponse) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	var version uint8
+	err := binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return err
+	}
+	s.Transport, err = rw.ReadVString(reader)
+	if err != nil {
+		return err
+	}
+	var expireAt int64
+	err = binary.Read(reader, binary.BigEndian, &expireAt)
+	if err != nil {
+		return err
+	}
+	s.ExpireAt = time.Unix(expireAt, 0)
+	contentLen, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return err
+	}
+	content := make([]byte, contentLen)
+	_, err = io.ReadFull(reader, content)
+	if err != nil {
+		return err
+	}
+	s.Response = new(mdns.Msg)
+	return s.Response.Unpack(content)
+}
+
+// SavedProviderHistory maps outbound tags of a provider to their last successful health check.
+type SavedProviderHistory map[string]*urltest.History
+
+func (s SavedProviderHistory) MarshalBinary() ([]byte, error) {
+	var buffer bytes.Buffer
+	err := binary.Write(&buffer, binary.BigEndian, uint8(1))
+	if err != nil {
+		return nil, err
+	}
+	err = rw.WriteUVariant(&buffer, uint64(len(s)))
+	if err != nil {
+		return nil, err
+	}
+	for tag, history := range s {
+		err = rw.WriteVString(&buffer, tag)
+		if err != nil {
+			return nil, err
+		}
+		err = binary.Write(&buffer, binary.BigEndian, history.Time.Unix())
+		if err != nil {
+			return nil, err
+		}
+		err = binary.Write(&buffer, binary.BigEndian, history.Delay)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
+
+func (s SavedProviderHistory) UnmarshalBinary(data []byte) error {
+	reader := bytes.NewReader(data)
+	var version uint8
+	err := binary.Read(reader, binary.BigEndian, &version)
+	if err != nil {
+		return err
+	}
+	historyLen, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < historyLen; i++ {
+		tag, err := rw.ReadVString(reader)
+		if err != nil {
+			return err
+		}
+		var (
+			testTime int64
+			history  urltest.History
+		)
+		err = binary.Read(reader, binary.BigEndian, &testTime)
+		if err != nil {
+			return err
+		}
+		err = binary.Read(reader, binary.BigEndian, &history.Delay)
+		if err != nil {
+			return err
+		}
+		history.Time = time.Unix(testTime, 0)
+		s[tag] = &history
+	}
+	return nil
+}
+
 type Tracker interface {
 	Leave()
 }
@@ -145,3 +369,12 @@ type V2RayStatsService interface {
 	RoutedConnection(inbound string, outbound string, user string, conn net.Conn) net.Conn
 	RoutedPacketConnection(inbound string, outbound string, user string, conn N.PacketConn) N.PacketConn
 }
+
+type MetricsServer interface {
+	Service
+	RoutedConnection(inbound string, outbound string, user string, conn net.Conn) net.Conn
+	RoutedPacketConnection(inbound string, outbound string, user string, conn N.PacketConn) N.PacketConn
+	// RuleMatched is called for every routed connection, index is -1 for the final outbound.
+	RuleMatched(index int, target string)
+	DNSExchanged(transport string, duration time.Duration, err error)
+}
@@ -0,0 +1,11 @@
+package adapter
+
+import (
+	mdns "github.com/miekg/dns"
+)
+
+type DNSHostsStore interface {
+	Service
+	// Resolve returns the answers to question, or false if the domain is not configured.
+	Resolve(question mdns.Question) ([]mdns.RR, bool)
+}
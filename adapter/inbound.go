@@ -24,6 +24,36 @@ type InjectableInbound interface {
 	NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata InboundContext) error
 }
 
+// InboundUser is a user of a multi-user inbound, only the fields used by its protocol are set.
+type InboundUser struct {
+	Name     string `json:"name"`
+	UUID     string `json:"uuid,omitempty"`
+	Password string `json:"password,omitempty"`
+	AlterId  int    `json:"alterId,omitempty"`
+	Flow     string `json:"flow,omitempty"`
+}
+
+type ManagedUserInbound interface {
+	Inbound
+	Users() []InboundUser
+	// UpdateUsers replaces all users, connections of remaining users are not affected.
+	UpdateUsers(users []InboundUser) error
+}
+
+// Authenticator checks the credentials of inbound users against an external backend.
+type Authenticator interface {
+	Authenticate(ctx context.Context, metadata InboundContext, username string, password string) (*AuthResult, error)
+	// AuthenticateKey checks a key derived from the password by the protocol instead of the password itself.
+	AuthenticateKey(ctx context.Context, metadata InboundContext, key string) (*AuthResult, error)
+}
+
+type AuthResult struct {
+	User  string
+	Limit *option.TrafficLimitOptions
+	// Password is the password of the user checked by key, for protocols needing it to serve the user.
+	Password string
+}
+
 type InboundContext struct {
 	Inbound     string
 	InboundType string
@@ -34,6 +64,7 @@ type InboundContext struct {
 	Domain      string
 	Protocol    string
 	User        string
+	UserLimit   *option.TrafficLimitOptions
 	Outbound    string
 	SniffDomain string
 
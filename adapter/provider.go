@@ -3,6 +3,8 @@ package adapter
 import (
 	"context"
 	"time"
+
+	"github.com/sagernet/sing-box/option"
 )
 
 type OutboundProvider interface {
@@ -11,6 +13,7 @@ type OutboundProvider interface {
 	Type() string
 	Outbounds() []Outbound
 	Outbound(tag string) (Outbound, bool)
+	OutboundOptions() []option.Outbound
 	UpdateTime() time.Time
 
 	Start() error
@@ -18,6 +21,8 @@ type OutboundProvider interface {
 	PostStart() error
 	Healthcheck(ctx context.Context, link string, force bool) (map[string]uint16, error)
 	SubInfo() map[string]int64
+	SubStatus() string
+	Excluded() bool
 	UpdateProvider(ctx context.Context, router Router, force bool) error
 	UpdateOutboundByTag()
 }
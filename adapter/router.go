@@ -21,6 +21,7 @@ type Router interface {
 	PreStarter
 	PostStarter
 
+	Inbound(tag string) (Inbound, bool)
 	Outbounds() []Outbound
 	Outbound(tag string) (Outbound, bool)
 	OutboundsWithProvider() []Outbound
@@ -33,6 +34,8 @@ type Router interface {
 	Transport(tag string) (dns.Transport, bool)
 
 	FakeIPStore() FakeIPStore
+	DNSHosts() DNSHostsStore
+	DNSQueryLogger() DNSQueryLogger
 
 	ConnectionRouter
 
@@ -71,6 +74,9 @@ type Router interface {
 	V2RayServer() V2RayServer
 	SetV2RayServer(server V2RayServer)
 
+	MetricsServer() MetricsServer
+	SetMetricsServer(server MetricsServer)
+
 	ResetNetwork() error
 }
 
@@ -98,6 +104,8 @@ type Rule interface {
 	UpdateGeosite() error
 	SkipResolve() bool
 	Outbound() string
+	Action() string
+	RejectMethod() string
 	String() string
 }
 
@@ -110,6 +118,12 @@ type DNSRule interface {
 	WithAddressLimit() bool
 	MatchAddressLimit(metadata *InboundContext) bool
 	Servers() []string
+	ServerStrategy() string
+	ServerTimeout() time.Duration
+	LocalResponse(message *mdns.Msg) (*mdns.Msg, bool)
+	WithResponseFilter() bool
+	FilterResponse(response *mdns.Msg)
+	FilterAddresses(addresses []netip.Addr) []netip.Addr
 }
 
 type RuleSet interface {
@@ -6,14 +6,17 @@ import (
 	"io"
 	"os"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/taskmonitor"
+	"github.com/sagernet/sing-box/common/urltest"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/experimental"
 	"github.com/sagernet/sing-box/experimental/cachefile"
 	"github.com/sagernet/sing-box/experimental/libbox/platform"
+	"github.com/sagernet/sing-box/experimental/metrics"
 	"github.com/sagernet/sing-box/inbound"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
@@ -30,17 +33,21 @@ import (
 var _ adapter.Service = (*Box)(nil)
 
 type Box struct {
-	createdAt    time.Time
-	router       adapter.Router
-	inbounds     []adapter.Inbound
-	outbounds    []adapter.Outbound
-	providers    []adapter.OutboundProvider
-	logFactory   log.Factory
-	logger       log.ContextLogger
-	preServices1 map[string]adapter.Service
-	preServices2 map[string]adapter.Service
-	postServices map[string]adapter.Service
-	done         chan struct{}
+	ctx               context.Context
+	createdAt         time.Time
+	options           option.Options
+	platformInterface platform.Interface
+	router            *route.Router
+	inbounds          []adapter.Inbound
+	outbounds         []adapter.Outbound
+	providers         []adapter.OutboundProvider
+	logFactory        log.Factory
+	logger            log.ContextLogger
+	preServices1      map[string]adapter.Service
+	preServices2      map[string]adapter.Service
+	postServices      map[string]adapter.Service
+	done              chan struct{}
+	reloadAccess      sync.Mutex
 }
 
 type Options struct {
@@ -63,6 +70,7 @@ func New(options Options) (*Box, error) {
 	var needCacheFile bool
 	var needClashAPI bool
 	var needV2RayAPI bool
+	var needMetrics bool
 	if experimentalOptions.CacheFile != nil && experimentalOptions.CacheFile.Enabled || options.PlatformLogWriter != nil {
 		needCacheFile = true
 	}
@@ -72,6 +80,13 @@ func New(options Options) (*Box, error) {
 	if experimentalOptions.V2RayAPI != nil && experimentalOptions.V2RayAPI.Listen != "" {
 		needV2RayAPI = true
 	}
+	if experimentalOptions.Metrics != nil && experimentalOptions.Metrics.Listen != "" {
+		needMetrics = true
+		if service.PtrFromContext[urltest.HistoryStorage](ctx) == nil {
+			// share URL test results between groups, providers and the metrics server
+			ctx = service.ContextWithPtr(ctx, urltest.NewHistoryStorage())
+		}
+	}
 	var defaultLogWriter io.Writer
 	if options.PlatformInterface != nil {
 		defaultLogWriter = io.Discard
@@ -191,6 +206,7 @@ func New(options Options) (*Box, error) {
 	if needClashAPI {
 		clashAPIOptions := common.PtrValueOrDefault(experimentalOptions.ClashAPI)
 		clashAPIOptions.ModeList = experimental.CalculateClashModeList(options.Options)
+		clashAPIOptions.Outbounds = options.Outbounds
 		clashServer, err := experimental.NewClashServer(ctx, router, logFactory.(log.ObservableFactory), clashAPIOptions)
 		if err != nil {
 			return nil, E.Cause(err, "create clash api server")
@@ -206,18 +222,29 @@ func New(options Options) (*Box, error) {
 		router.SetV2RayServer(v2rayServer)
 		preServices2["v2ray api"] = v2rayServer
 	}
+	if needMetrics {
+		metricsServer, err := metrics.NewServer(ctx, router, logFactory.NewLogger("metrics"), common.PtrValueOrDefault(experimentalOptions.Metrics))
+		if err != nil {
+			return nil, E.Cause(err, "create metrics server")
+		}
+		router.SetMetricsServer(metricsServer)
+		preServices2["metrics"] = metricsServer
+	}
 	return &Box{
-		router:       router,
-		inbounds:     inbounds,
-		outbounds:    outbounds,
-		providers:    providers,
-		createdAt:    createdAt,
-		logFactory:   logFactory,
-		logger:       logFactory.Logger(),
-		preServices1: preServices1,
-		preServices2: preServices2,
-		postServices: postServices,
-		done:         make(chan struct{}),
+		ctx:               ctx,
+		options:           options.Options,
+		platformInterface: options.PlatformInterface,
+		router:            router,
+		inbounds:          inbounds,
+		outbounds:         outbounds,
+		providers:         providers,
+		createdAt:         createdAt,
+		logFactory:        logFactory,
+		logger:            logFactory.Logger(),
+		preServices1:      preServices1,
+		preServices2:      preServices2,
+		postServices:      postServices,
+		done:              make(chan struct{}),
 	}, nil
 }
 
@@ -399,12 +426,14 @@ func (s *Box) Close() error {
 		monitor.Finish()
 	}
 	for i, prov := range s.providers {
-		for j, out := range prov.Outbounds() {
-			monitor.Start("closing provider/", prov.Type(), "[", i, "]", " outbound/", out.Type(), "[", j, "]")
-			errors = E.Append(errors, common.Close(out), func(err error) error {
-				return E.Cause(err, "close provider/", prov.Type(), "[", i, "]", " outbound/", out.Type(), "[", j, "]")
-			})
-			monitor.Finish()
+		if ownsOutbounds(prov) {
+			for j, out := range prov.Outbounds() {
+				monitor.Start("closing provider/", prov.Type(), "[", i, "]", " outbound/", out.Type(), "[", j, "]")
+				errors = E.Append(errors, common.Close(out), func(err error) error {
+					return E.Cause(err, "close provider/", prov.Type(), "[", i, "]", " outbound/", out.Type(), "[", j, "]")
+				})
+				monitor.Finish()
+			}
 		}
 		monitor.Start("closing provider/", prov.Type(), "[", i, "]")
 		errors = E.Append(errors, common.Close(prov), func(err error) error {
@@ -13,71 +13,107 @@ import (
 )
 
 func (s *Box) startProviderOutbounds() error {
-	monitor := taskmonitor.New(s.logger, C.DefaultStartTimeout)
 	outboundTag := make(map[string]int)
 	for _, out := range s.outbounds {
 		tag := out.Tag()
 		outboundTag[tag] = 0
 	}
 	for i, p := range s.providers {
-		var pTag string
-		if p.Tag() == "" {
-			pTag = F.ToString(i)
-		} else {
-			pTag = p.Tag()
+		err := s.startProviderOutbound(i, p, outboundTag)
+		if err != nil {
+			return err
 		}
-		for j, out := range p.Outbounds() {
-			var tag string
-			if out.Tag() == "" {
-				out.SetTag(fmt.Sprint("[", pTag, "]", F.ToString(j)))
-			}
-			tag = out.Tag()
-			if _, exists := outboundTag[tag]; exists {
-				count := outboundTag[tag] + 1
-				tag = fmt.Sprint(tag, "[", count, "]")
-				out.SetTag(tag)
-				outboundTag[tag] = count
-			}
-			outboundTag[tag] = 0
-			if starter, isStarter := out.(common.Starter); isStarter {
-				monitor.Start("initialize outbound provider[", pTag, "]", " outbound/", out.Type(), "[", tag, "]")
-				err := starter.Start()
-				monitor.Finish()
-				if err != nil {
-					return E.Cause(err, "initialize outbound provider[", pTag, "]", " outbound/", out.Type(), "[", tag, "]")
-				}
+	}
+	updateListedOutbounds(s.providers)
+	return nil
+}
+
+// ownsOutbounds reports whether the outbounds of the provider are started and closed with it,
+// merge providers only list outbounds of other providers.
+func ownsOutbounds(p adapter.OutboundProvider) bool {
+	return p.Type() != C.TypeMergeProvider
+}
+
+// updateListedOutbounds indexes the outbounds listed by merge providers again,
+// once the providers owning them have set their final tags.
+func updateListedOutbounds(providers []adapter.OutboundProvider) {
+	for _, p := range providers {
+		if !ownsOutbounds(p) {
+			p.UpdateOutboundByTag()
+		}
+	}
+}
+
+func (s *Box) startProviderOutbound(index int, p adapter.OutboundProvider, outboundTag map[string]int) error {
+	if !ownsOutbounds(p) {
+		return nil
+	}
+	monitor := taskmonitor.New(s.logger, C.DefaultStartTimeout)
+	var pTag string
+	if p.Tag() == "" {
+		pTag = F.ToString(index)
+	} else {
+		pTag = p.Tag()
+	}
+	for j, out := range p.Outbounds() {
+		var tag string
+		if out.Tag() == "" {
+			out.SetTag(fmt.Sprint("[", pTag, "]", F.ToString(j)))
+		}
+		tag = out.Tag()
+		if _, exists := outboundTag[tag]; exists {
+			count := outboundTag[tag] + 1
+			tag = fmt.Sprint(tag, "[", count, "]")
+			out.SetTag(tag)
+			outboundTag[tag] = count
+		}
+		outboundTag[tag] = 0
+		if starter, isStarter := out.(common.Starter); isStarter {
+			monitor.Start("initialize outbound provider[", pTag, "]", " outbound/", out.Type(), "[", tag, "]")
+			err := starter.Start()
+			monitor.Finish()
+			if err != nil {
+				return E.Cause(err, "initialize outbound provider[", pTag, "]", " outbound/", out.Type(), "[", tag, "]")
 			}
 		}
-		p.UpdateOutboundByTag()
 	}
+	p.UpdateOutboundByTag()
 	return nil
 }
 
 func (s *Box) startOutbounds() error {
-	monitor := taskmonitor.New(s.logger, C.DefaultStartTimeout)
-	outboundTags := make(map[adapter.Outbound]string)
-	outbounds := make(map[string]adapter.Outbound)
+	outbounds := make(map[string]bool)
 	for i, outboundToStart := range s.outbounds {
 		var outboundTag string
 		if outboundToStart.Tag() == "" {
 			outboundToStart.SetTag(F.ToString(i))
 		}
 		outboundTag = outboundToStart.Tag()
-		if _, exists := outbounds[outboundTag]; exists {
+		if outbounds[outboundTag] {
 			return E.New("outbound tag ", outboundTag, " duplicated")
 		}
-		outboundTags[outboundToStart] = outboundTag
-		outbounds[outboundTag] = outboundToStart
+		outbounds[outboundTag] = true
 	}
 	err := s.startProviderOutbounds()
 	if err != nil {
 		return nil
 	}
-	started := make(map[string]bool)
+	return s.startOutboundList(s.outbounds, make(map[string]bool))
+}
+
+func (s *Box) startOutboundList(outboundList []adapter.Outbound, started map[string]bool) error {
+	monitor := taskmonitor.New(s.logger, C.DefaultStartTimeout)
+	outboundTags := make(map[adapter.Outbound]string)
+	outbounds := make(map[string]adapter.Outbound)
+	for _, outboundToStart := range outboundList {
+		outboundTag := outboundToStart.Tag()
+		outboundTags[outboundToStart] = outboundTag
+		outbounds[outboundTag] = outboundToStart
+	}
 	for {
 		canContinue := false
 	startOne:
-		for _, outboundToStart := range s.outbounds {
+		for _, outboundToStart := range outboundList {
 			outboundTag := outboundTags[outboundToStart]
 			if started[outboundTag] {
 				continue
@@ -99,13 +135,13 @@ func (s *Box) startOutbounds() error {
 				}
 			}
 		}
-		if len(started) == len(s.outbounds) {
+		if len(started) == len(outboundList) {
 			break
 		}
 		if canContinue {
 			continue
 		}
-		currentOutbound := common.Find(s.outbounds, func(it adapter.Outbound) bool {
+		currentOutbound := common.Find(outboundList, func(it adapter.Outbound) bool {
 			return !started[outboundTags[it]]
 		})
 		var lintOutbound func(oTree []string, oCurrent adapter.Outbound) error
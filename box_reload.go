@@ -0,0 +1,400 @@
+package box
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/inbound"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/outbound"
+	P "github.com/sagernet/sing-box/provider"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+// Reload applies options to the running instance without closing it.
+// Inbounds, outbounds, outbound providers, rule-sets, rules and DNS servers whose options
+// did not change are kept running with the connections they carry.
+// An error wrapping C.ErrRestartRequired is returned if the change can only be applied by a restart.
+func (s *Box) Reload(options option.Options) error {
+	s.reloadAccess.Lock()
+	defer s.reloadAccess.Unlock()
+	reloadAt := time.Now()
+	err := checkReloadOptions(s.options, options)
+	if err != nil {
+		return err
+	}
+
+	oldInbounds := make(map[string]adapter.Inbound)
+	oldInboundOptions := make(map[string]option.Inbound)
+	for i, inboundOptions := range s.options.Inbounds {
+		tag := tagOrIndex(inboundOptions.Tag, i)
+		oldInbounds[tag] = s.inbounds[i]
+		oldInboundOptions[tag] = inboundOptions
+	}
+	oldProviders := make(map[string]adapter.OutboundProvider)
+	oldProviderOptions := make(map[string]option.OutboundProvider)
+	for i, providerOptions := range s.options.OutboundProviders {
+		tag := tagOrIndex(providerOptions.Tag, i)
+		oldProviders[tag] = s.providers[i]
+		oldProviderOptions[tag] = providerOptions
+	}
+	oldOutbounds := make(map[string]adapter.Outbound)
+	oldOutboundOptions := make(map[string]option.Outbound)
+	for i, outboundOptions := range s.options.Outbounds {
+		tag := tagOrIndex(outboundOptions.Tag, i)
+		oldOutbounds[tag] = s.outbounds[i+1]
+		oldOutboundOptions[tag] = outboundOptions
+	}
+
+	reloadedProviders := make(map[string]bool)
+	addedOrRemovedProvider := len(options.OutboundProviders) != len(s.options.OutboundProviders)
+	for i, providerOptions := range options.OutboundProviders {
+		tag := tagOrIndex(providerOptions.Tag, i)
+		oldOptions, loaded := oldProviderOptions[tag]
+		if !loaded {
+			addedOrRemovedProvider = true
+		}
+		if !loaded || !reflect.DeepEqual(oldOptions, providerOptions) {
+			reloadedProviders[tag] = true
+		}
+	}
+	reloadedOutbounds := make(map[string]bool)
+	for tag, provider := range oldProviders {
+		if _, loaded := reloadedProviders[tag]; loaded || !common.Any(options.OutboundProviders, func(it option.OutboundProvider) bool {
+			return it.Tag == provider.Tag()
+		}) {
+			for _, providerOutbound := range provider.Outbounds() {
+				reloadedOutbounds[providerOutbound.Tag()] = true
+			}
+		}
+	}
+	for i, outboundOptions := range options.Outbounds {
+		tag := tagOrIndex(outboundOptions.Tag, i)
+		oldOptions, loaded := oldOutboundOptions[tag]
+		if !loaded || !reflect.DeepEqual(oldOptions, outboundOptions) {
+			reloadedOutbounds[tag] = true
+		}
+	}
+	for tag := range oldOutboundOptions {
+		if !common.Any(options.Outbounds, func(it option.Outbound) bool {
+			return it.Tag == tag
+		}) {
+			reloadedOutbounds[tag] = true
+		}
+	}
+	for {
+		var changed bool
+		for i, outboundOptions := range options.Outbounds {
+			tag := tagOrIndex(outboundOptions.Tag, i)
+			if reloadedOutbounds[tag] {
+				continue
+			}
+			if common.Any(oldOutbounds[tag].Dependencies(), func(dependency string) bool {
+				return reloadedOutbounds[dependency]
+			}) || usesRemovedProvider(outboundOptions, options.OutboundProviders, addedOrRemovedProvider) {
+				reloadedOutbounds[tag] = true
+				changed = true
+			}
+		}
+		for i, providerOptions := range options.OutboundProviders {
+			tag := tagOrIndex(providerOptions.Tag, i)
+			if reloadedProviders[tag] {
+				continue
+			}
+			if reloadedOutbounds[providerOptions.HTTPOptions.Detour] || providerOptions.OverrideDialer != nil && providerOptions.OverrideDialer.Detour != nil && reloadedOutbounds[*providerOptions.OverrideDialer.Detour] || common.Any(providerOptions.MergeOptions.Providers, func(it string) bool {
+				return reloadedProviders[it]
+			}) {
+				reloadedProviders[tag] = true
+				for _, providerOutbound := range oldProviders[tag].Outbounds() {
+					reloadedOutbounds[providerOutbound.Tag()] = true
+				}
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+	}
+
+	var (
+		inbounds           = make([]adapter.Inbound, 0, len(options.Inbounds))
+		providers          = make([]adapter.OutboundProvider, 0, len(options.OutboundProviders))
+		outbounds          = []adapter.Outbound{s.outbounds[0]}
+		createdInbounds    []adapter.Inbound
+		createdProviders   []adapter.OutboundProvider
+		createdOutbounds   []adapter.Outbound
+		closeCreatedOnFail = func() {
+			for _, out := range createdOutbounds {
+				common.Close(out)
+			}
+			for _, provider := range createdProviders {
+				if ownsOutbounds(provider) {
+					for _, out := range provider.Outbounds() {
+						common.Close(out)
+					}
+				}
+				common.Close(provider)
+			}
+		}
+	)
+	for i, providerOptions := range options.OutboundProviders {
+		tag := tagOrIndex(providerOptions.Tag, i)
+		if !reloadedProviders[tag] {
+			providers = append(providers, oldProviders[tag])
+			continue
+		}
+		provider, err := P.New(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("provider", "[", tag, "]")),
+			providerOptions,
+		)
+		if err != nil {
+			closeCreatedOnFail()
+			return E.Cause(err, "parse outbound provider[", i, "]")
+		}
+		providers = append(providers, provider)
+		createdProviders = append(createdProviders, provider)
+	}
+	for i, outboundOptions := range options.Outbounds {
+		tag := tagOrIndex(outboundOptions.Tag, i)
+		if !reloadedOutbounds[tag] {
+			outbounds = append(outbounds, oldOutbounds[tag])
+			continue
+		}
+		out, err := outbound.New(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("outbound/", outboundOptions.Type, "[", tag, "]")),
+			tag,
+			outboundOptions)
+		if err != nil {
+			closeCreatedOnFail()
+			return E.Cause(err, "parse outbound[", i, "]")
+		}
+		outbounds = append(outbounds, out)
+		createdOutbounds = append(createdOutbounds, out)
+	}
+	for i, inboundOptions := range options.Inbounds {
+		tag := tagOrIndex(inboundOptions.Tag, i)
+		if oldOptions, loaded := oldInboundOptions[tag]; loaded && reflect.DeepEqual(oldOptions, inboundOptions) {
+			inbounds = append(inbounds, oldInbounds[tag])
+			continue
+		}
+		in, err := inbound.New(
+			s.ctx,
+			s.router,
+			s.logFactory.NewLogger(F.ToString("inbound/", inboundOptions.Type, "[", tag, "]")),
+			inboundOptions,
+			s.platformInterface,
+		)
+		if err != nil {
+			closeCreatedOnFail()
+			return E.Cause(err, "parse inbound[", i, "]")
+		}
+		inbounds = append(inbounds, in)
+		createdInbounds = append(createdInbounds, in)
+	}
+
+	// Running components are replaced from here, so failures can only be recovered by a restart.
+	err = s.applyReload(options, inbounds, providers, outbounds, createdInbounds, createdProviders, createdOutbounds, reloadedOutbounds)
+	if err != nil {
+		return E.Extend(C.ErrRestartRequired, err.Error())
+	}
+	s.logger.Info("sing-box reloaded: ", len(createdInbounds), " inbounds, ", len(createdOutbounds), " outbounds, ", len(createdProviders), " outbound providers replaced (", F.Seconds(time.Since(reloadAt).Seconds()), "s)")
+	return nil
+}
+
+func (s *Box) applyReload(
+	options option.Options,
+	inbounds []adapter.Inbound,
+	providers []adapter.OutboundProvider,
+	outbounds []adapter.Outbound,
+	createdInbounds []adapter.Inbound,
+	createdProviders []adapter.OutboundProvider,
+	createdOutbounds []adapter.Outbound,
+	reloadedOutbounds map[string]bool,
+) error {
+	routeOptions := common.PtrValueOrDefault(options.Route)
+	// New outbounds are staged in the router for lookups while they start,
+	// and only become routable when the router publishes the reloaded state.
+	err := s.router.ReloadOutbounds(routeOptions.Final, inbounds, providers, outbounds)
+	if err != nil {
+		return err
+	}
+	defer s.router.CancelReload()
+	outboundTag := make(map[string]int)
+	for _, out := range outbounds {
+		outboundTag[out.Tag()] = 0
+	}
+	for _, provider := range providers {
+		if common.Contains(createdProviders, provider) {
+			continue
+		}
+		for _, out := range provider.Outbounds() {
+			outboundTag[out.Tag()] = 0
+		}
+	}
+	for i, provider := range providers {
+		if !common.Contains(createdProviders, provider) {
+			continue
+		}
+		err = provider.Start()
+		if err != nil {
+			return E.Cause(err, "initialize outbound provider/", provider.Type(), "[", provider.Tag(), "]")
+		}
+		err = s.startProviderOutbound(i, provider, outboundTag)
+		if err != nil {
+			return err
+		}
+	}
+	updateListedOutbounds(createdProviders)
+	started := make(map[string]bool)
+	for _, out := range outbounds {
+		if !common.Contains(createdOutbounds, out) {
+			started[out.Tag()] = true
+		}
+	}
+	err = s.startOutboundList(outbounds, started)
+	if err != nil {
+		return err
+	}
+	for _, provider := range createdProviders {
+		for _, out := range outbounds {
+			if common.Contains(createdOutbounds, out) {
+				continue
+			}
+			if group, isGroup := out.(adapter.OutboundGroup); isGroup {
+				err = group.UpdateOutbounds(provider.Tag())
+				if err != nil {
+					return E.Cause(err, "update outbound group[", group.Tag(), "] with outbound provider[", provider.Tag(), "]")
+				}
+			}
+		}
+	}
+
+	err = s.router.Reload(routeOptions, common.PtrValueOrDefault(options.DNS), options.Inbounds, reloadedOutbounds)
+	if err != nil {
+		return err
+	}
+
+	for _, out := range s.outbounds {
+		if !common.Contains(outbounds, out) {
+			common.Close(out)
+		}
+	}
+	for _, provider := range s.providers {
+		if common.Contains(providers, provider) {
+			continue
+		}
+		if ownsOutbounds(provider) {
+			for _, out := range provider.Outbounds() {
+				common.Close(out)
+			}
+		}
+		common.Close(provider)
+	}
+	for _, in := range s.inbounds {
+		if !common.Contains(inbounds, in) {
+			err = in.Close()
+			if err != nil {
+				s.logger.Error(E.Cause(err, "close inbound/", in.Type(), "[", in.Tag(), "]"))
+			}
+		}
+	}
+	s.inbounds = inbounds
+	s.outbounds = outbounds
+	s.providers = providers
+	s.options = options
+	if clashServer := s.router.ClashServer(); clashServer != nil {
+		err = clashServer.Reload(options)
+		if err != nil {
+			return E.Cause(err, "reload clash api")
+		}
+	}
+	for _, in := range createdInbounds {
+		err = in.Start()
+		if err != nil {
+			return E.Cause(err, "initialize inbound/", in.Type(), "[", in.Tag(), "]")
+		}
+	}
+	for _, out := range createdOutbounds {
+		if lateOutbound, isLateOutbound := out.(adapter.PostStarter); isLateOutbound {
+			err = lateOutbound.PostStart()
+			if err != nil {
+				return E.Cause(err, "post-start outbound/", out.Tag())
+			}
+		}
+	}
+	for _, provider := range createdProviders {
+		err = provider.PostStart()
+		if err != nil {
+			return E.Cause(err, "post-start outbound provider/", provider.Tag())
+		}
+	}
+	return nil
+}
+
+func checkReloadOptions(oldOptions option.Options, newOptions option.Options) error {
+	if !reflect.DeepEqual(oldOptions.Log, newOptions.Log) {
+		return E.Extend(C.ErrRestartRequired, "log options changed")
+	}
+	if !reflect.DeepEqual(oldOptions.NTP, newOptions.NTP) {
+		return E.Extend(C.ErrRestartRequired, "ntp options changed")
+	}
+	if !reflect.DeepEqual(oldOptions.Experimental, newOptions.Experimental) {
+		return E.Extend(C.ErrRestartRequired, "experimental options changed")
+	}
+	oldRoute := common.PtrValueOrDefault(oldOptions.Route)
+	newRoute := common.PtrValueOrDefault(newOptions.Route)
+	oldRoute.Rules, newRoute.Rules = nil, nil
+	oldRoute.RuleSet, newRoute.RuleSet = nil, nil
+	oldRoute.Final, newRoute.Final = "", ""
+	if !reflect.DeepEqual(oldRoute, newRoute) {
+		return E.Extend(C.ErrRestartRequired, "route options changed")
+	}
+	oldDNS := common.PtrValueOrDefault(oldOptions.DNS)
+	newDNS := common.PtrValueOrDefault(newOptions.DNS)
+	oldDNS.Servers, newDNS.Servers = nil, nil
+	oldDNS.Rules, newDNS.Rules = nil, nil
+	oldDNS.Final, newDNS.Final = nil, nil
+	if !reflect.DeepEqual(oldDNS, newDNS) {
+		return E.Extend(C.ErrRestartRequired, "dns options changed")
+	}
+	return nil
+}
+
+func usesRemovedProvider(outboundOptions option.Outbound, providers []option.OutboundProvider, addedOrRemovedProvider bool) bool {
+	var groupOptions option.GroupOutboundOptions
+	switch outboundOptions.Type {
+	case C.TypeSelector:
+		groupOptions = outboundOptions.SelectorOptions.GroupOutboundOptions
+	case C.TypeURLTest:
+		groupOptions = outboundOptions.URLTestOptions.GroupOutboundOptions
+	case C.TypeLoadBalance:
+		groupOptions = outboundOptions.LoadBalanceOptions.GroupOutboundOptions
+	case C.TypeFallback:
+		groupOptions = outboundOptions.FallbackOptions.GroupOutboundOptions
+	default:
+		return false
+	}
+	if groupOptions.UseAllProviders {
+		return addedOrRemovedProvider
+	}
+	return common.Any(groupOptions.Providers, func(tag string) bool {
+		return !common.Any(providers, func(it option.OutboundProvider) bool {
+			return it.Tag == tag
+		})
+	})
+}
+
+func tagOrIndex(tag string, index int) string {
+	if tag != "" {
+		return tag
+	}
+	return F.ToString(index)
+}
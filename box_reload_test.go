@@ -0,0 +1,128 @@
+package box
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+const reloadTestConfig = `{
+  "log": {"disabled": true},
+  "outbounds": [
+    {"type": "direct", "tag": "a"},
+    {"type": "direct", "tag": "b"}
+  ],
+  "route": {
+    "rules": [
+      {"domain": "a.example", "outbound": "a"},
+      {"domain": "b.example", "outbound": "b"}
+    ],
+    "final": "a"
+  }
+}`
+
+func parseReloadTestConfig(t *testing.T, content string) option.Options {
+	var options option.Options
+	require.NoError(t, options.UnmarshalJSON([]byte(content)))
+	return options
+}
+
+func startReloadTestBox(t *testing.T) *Box {
+	instance, err := New(Options{
+		Context: context.Background(),
+		Options: parseReloadTestConfig(t, reloadTestConfig),
+	})
+	require.NoError(t, err)
+	require.NoError(t, instance.Start())
+	t.Cleanup(func() {
+		instance.Close()
+	})
+	return instance
+}
+
+func loadReloadTestOutbound(t *testing.T, instance *Box, tag string) adapter.Outbound {
+	out, loaded := instance.Router().Outbound(tag)
+	require.True(t, loaded)
+	return out
+}
+
+func requireSameRules(t *testing.T, expected []adapter.Rule, actual []adapter.Rule) {
+	require.Len(t, actual, len(expected))
+	for i := range expected {
+		require.Same(t, expected[i], actual[i])
+	}
+}
+
+func TestReloadUnchanged(t *testing.T) {
+	t.Parallel()
+	instance := startReloadTestBox(t)
+	rules := instance.Router().Rules()
+	outboundA := loadReloadTestOutbound(t, instance, "a")
+	outboundB := loadReloadTestOutbound(t, instance, "b")
+
+	require.NoError(t, instance.Reload(parseReloadTestConfig(t, reloadTestConfig)))
+	requireSameRules(t, rules, instance.Router().Rules())
+	require.Same(t, outboundA, loadReloadTestOutbound(t, instance, "a"))
+	require.Same(t, outboundB, loadReloadTestOutbound(t, instance, "b"))
+}
+
+func TestReloadChangedRule(t *testing.T) {
+	t.Parallel()
+	instance := startReloadTestBox(t)
+	rules := instance.Router().Rules()
+	outboundB := loadReloadTestOutbound(t, instance, "b")
+
+	require.NoError(t, instance.Reload(parseReloadTestConfig(t, `{
+  "log": {"disabled": true},
+  "outbounds": [
+    {"type": "direct", "tag": "a"},
+    {"type": "direct", "tag": "b"}
+  ],
+  "route": {
+    "rules": [
+      {"domain": "a.example", "outbound": "a"},
+      {"domain": "b.example", "outbound": "a"}
+    ],
+    "final": "a"
+  }
+}`)))
+	reloadedRules := instance.Router().Rules()
+	require.Len(t, reloadedRules, 2)
+	require.Same(t, rules[0], reloadedRules[0])
+	require.NotSame(t, rules[1], reloadedRules[1])
+	require.Equal(t, "a", reloadedRules[1].Outbound())
+	require.Same(t, outboundB, loadReloadTestOutbound(t, instance, "b"))
+}
+
+func TestReloadFailureRollback(t *testing.T) {
+	t.Parallel()
+	instance := startReloadTestBox(t)
+	rules := instance.Router().Rules()
+	outboundB := loadReloadTestOutbound(t, instance, "b")
+
+	err := instance.Reload(parseReloadTestConfig(t, `{
+  "log": {"disabled": true},
+  "outbounds": [
+    {"type": "direct", "tag": "a"},
+    {"type": "direct", "tag": "b", "override_port": 53}
+  ],
+  "route": {
+    "rules": [
+      {"domain": "a.example", "outbound": "a"},
+      {"domain_regex": "[", "outbound": "b"}
+    ],
+    "final": "a"
+  }
+}`))
+	require.ErrorContains(t, err, "parse rule[1]")
+	requireSameRules(t, rules, instance.Router().Rules())
+	require.Same(t, outboundB, loadReloadTestOutbound(t, instance, "b"))
+
+	require.NoError(t, instance.Reload(parseReloadTestConfig(t, reloadTestConfig)))
+	requireSameRules(t, rules, instance.Router().Rules())
+	require.Same(t, outboundB, loadReloadTestOutbound(t, instance, "b"))
+}
@@ -1,6 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
 	"github.com/spf13/cobra"
 )
 
@@ -12,3 +24,88 @@ var commandRuleSet = &cobra.Command{
 func init() {
 	mainCommand.AddCommand(commandRuleSet)
 }
+
+// readRuleSet reads a rule-set in source or binary format, binary rule-set is detected by its magic bytes.
+func readRuleSet(path string) (option.PlainRuleSet, error) {
+	var (
+		content []byte
+		err     error
+	)
+	if path == "stdin" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return option.PlainRuleSet{}, err
+	}
+	if bytes.HasPrefix(content, srs.MagicBytes[:]) {
+		ruleSet, err := srs.ReadBytes(content, true)
+		if err != nil {
+			return option.PlainRuleSet{}, E.Cause(err, "read binary rule-set ", path)
+		}
+		return ruleSet, nil
+	}
+	compat, err := json.UnmarshalExtended[option.PlainRuleSetCompat](content)
+	if err != nil {
+		return option.PlainRuleSet{}, E.Cause(err, "read source rule-set ", path)
+	}
+	return compat.Upgrade(), nil
+}
+
+// writeRuleSet writes a rule-set in binary format of the lowest version able to store it if the path ends with .srs,
+// or in source format otherwise.
+func writeRuleSet(path string, ruleSet option.PlainRuleSet) error {
+	buffer := new(bytes.Buffer)
+	if strings.HasSuffix(path, ".srs") {
+		err := srs.Write(buffer, ruleSet, srs.RequiredVersion(ruleSet))
+		if err != nil {
+			return err
+		}
+	} else {
+		encoder := json.NewEncoder(buffer)
+		encoder.SetIndent("", "  ")
+		err := encoder.Encode(option.PlainRuleSetCompat{
+			Version: C.RuleSetVersion1,
+			Options: ruleSet,
+		})
+		if err != nil {
+			return E.Cause(err, "encode rule-set")
+		}
+	}
+	return writeOutput(path, buffer.Bytes())
+}
+
+// writeOutput writes content to stdout if the path is empty or -, or to the file otherwise.
+func writeOutput(path string, content []byte) error {
+	if path == "" || path == "-" {
+		_, err := os.Stdout.Write(content)
+		return err
+	}
+	return writeFileAtomic(path, content)
+}
+
+// writeFileAtomic writes content to a temporary file next to path and renames it to path,
+// so that a running instance reloading the file never reads it partially written.
+func writeFileAtomic(path string, content []byte) error {
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	tempPath := file.Name()
+	_, err = file.Write(content)
+	if err == nil {
+		err = file.Chmod(0o644)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tempPath, path)
+	}
+	if err != nil {
+		os.Remove(tempPath)
+		return err
+	}
+	return nil
+}
@@ -1,25 +1,35 @@
 package main
 
 import (
+	"bytes"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 
+	"github.com/sagernet/sing-box/common/ruleprovider"
 	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
 
 	"github.com/spf13/cobra"
 )
 
-var flagRuleSetCompileOutput string
+var (
+	flagRuleSetCompileOutput   string
+	flagRuleSetCompileFormat   string
+	flagRuleSetCompileBehavior string
+	flagRuleSetCompileVersion  uint8
+)
 
 const flagRuleSetCompileDefaultOutput = "<file_name>.srs"
 
 var commandRuleSetCompile = &cobra.Command{
 	Use:   "compile [source-path]",
-	Short: "Compile rule-set json to binary",
+	Short: "Compile rule-set json or rule list to binary",
 	Args:  cobra.ExactArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
 		err := compileRuleSet(args[0])
@@ -31,7 +41,10 @@ var commandRuleSetCompile = &cobra.Command{
 
 func init() {
 	commandRuleSet.AddCommand(commandRuleSetCompile)
-	commandRuleSetCompile.Flags().StringVarP(&flagRuleSetCompileOutput, "output", "o", flagRuleSetCompileDefaultOutput, "Output file")
+	commandRuleSetCompile.Flags().StringVarP(&flagRuleSetCompileOutput, "output", "o", flagRuleSetCompileDefaultOutput, "Output file, - for stdout")
+	commandRuleSetCompile.Flags().StringVarP(&flagRuleSetCompileFormat, "format", "f", C.RuleSetFormatSource, "Source format: source, clash, domain_list, ipcidr_list or adblock")
+	commandRuleSetCompile.Flags().StringVar(&flagRuleSetCompileBehavior, "behavior", "", "Behavior of clash rule provider: domain, ipcidr or classical")
+	commandRuleSetCompile.Flags().Uint8Var(&flagRuleSetCompileVersion, "version", srs.Version1, "Binary version: 1, or 2 for the indexed format")
 }
 
 func compileRuleSet(sourcePath string) error {
@@ -51,34 +64,41 @@ func compileRuleSet(sourcePath string) error {
 	if err != nil {
 		return err
 	}
-	plainRuleSet, err := json.UnmarshalExtended[option.PlainRuleSetCompat](content)
-	if err != nil {
-		return err
-	}
-	if err != nil {
-		return err
+	var ruleSet option.PlainRuleSet
+	if ruleprovider.IsFormat(flagRuleSetCompileFormat) {
+		var skipped ruleprovider.Skipped
+		ruleSet, skipped, err = ruleprovider.Read(content, flagRuleSetCompileFormat, flagRuleSetCompileBehavior)
+		if err != nil {
+			return err
+		}
+		if len(skipped) > 0 {
+			log.Warn("skipped entries of unsupported types: ", skipped)
+		}
+	} else if flagRuleSetCompileFormat == C.RuleSetFormatSource {
+		plainRuleSet, err := json.UnmarshalExtended[option.PlainRuleSetCompat](content)
+		if err != nil {
+			return err
+		}
+		ruleSet = plainRuleSet.Upgrade()
+	} else {
+		return E.New("unknown rule set format: ", flagRuleSetCompileFormat)
 	}
-	ruleSet := plainRuleSet.Upgrade()
 	var outputPath string
 	if flagRuleSetCompileOutput == flagRuleSetCompileDefaultOutput {
 		if strings.HasSuffix(sourcePath, ".json") {
 			outputPath = sourcePath[:len(sourcePath)-5] + ".srs"
+		} else if extension := filepath.Ext(sourcePath); extension != "" && flagRuleSetCompileFormat != C.RuleSetFormatSource {
+			outputPath = sourcePath[:len(sourcePath)-len(extension)] + ".srs"
 		} else {
 			outputPath = sourcePath + ".srs"
 		}
 	} else {
 		outputPath = flagRuleSetCompileOutput
 	}
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return err
-	}
-	err = srs.Write(outputFile, ruleSet)
+	buffer := new(bytes.Buffer)
+	err = srs.Write(buffer, ruleSet, flagRuleSetCompileVersion)
 	if err != nil {
-		outputFile.Close()
-		os.Remove(outputPath)
 		return err
 	}
-	outputFile.Close()
-	return nil
+	return writeOutput(outputPath, buffer.Bytes())
 }
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/sagernet/sing-box/log"
+
+	"github.com/spf13/cobra"
+)
+
+var flagRuleSetDecompileOutput string
+
+const flagRuleSetDecompileDefaultOutput = "<file_name>.json"
+
+var commandRuleSetDecompile = &cobra.Command{
+	Use:   "decompile [binary-path]",
+	Short: "Decompile binary rule-set to json",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := decompileRuleSet(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetDecompile)
+	commandRuleSetDecompile.Flags().StringVarP(&flagRuleSetDecompileOutput, "output", "o", flagRuleSetDecompileDefaultOutput, "Output file, - for stdout")
+}
+
+func decompileRuleSet(sourcePath string) error {
+	ruleSet, err := readRuleSet(sourcePath)
+	if err != nil {
+		return err
+	}
+	var outputPath string
+	switch flagRuleSetDecompileOutput {
+	case flagRuleSetDecompileDefaultOutput:
+		if sourcePath == "stdin" {
+			outputPath = ""
+		} else if strings.HasSuffix(sourcePath, ".srs") {
+			outputPath = sourcePath[:len(sourcePath)-4] + ".json"
+		} else {
+			outputPath = sourcePath + ".json"
+		}
+	default:
+		outputPath = flagRuleSetDecompileOutput
+	}
+	return writeRuleSet(outputPath, ruleSet)
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"os"
+	"sort"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+)
+
+var commandRuleSetDiff = &cobra.Command{
+	Use:   "diff <old-path> <new-path>",
+	Short: "Show items added or removed between two rule-sets",
+	Long:  "Show items added or removed between two rule-sets, exit with status 1 if they differ.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		changed, err := diffRuleSets(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if changed {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetDiff)
+}
+
+func diffRuleSets(oldPath string, newPath string) (bool, error) {
+	oldEntries, err := readRuleSetEntries(oldPath)
+	if err != nil {
+		return false, err
+	}
+	newEntries, err := readRuleSetEntries(newPath)
+	if err != nil {
+		return false, err
+	}
+	var lines []string
+	for entry := range oldEntries {
+		if !newEntries[entry] {
+			lines = append(lines, "- "+entry)
+		}
+	}
+	for entry := range newEntries {
+		if !oldEntries[entry] {
+			lines = append(lines, "+ "+entry)
+		}
+	}
+	// Sort by entry, removed before added.
+	sort.Slice(lines, func(i, j int) bool {
+		if lines[i][2:] != lines[j][2:] {
+			return lines[i][2:] < lines[j][2:]
+		}
+		return lines[i] < lines[j]
+	})
+	for _, line := range lines {
+		os.Stdout.WriteString(line + "\n")
+	}
+	return len(lines) > 0, nil
+}
+
+// readRuleSetEntries reads a rule-set as a set of entries: each domain or CIDR of
+// destination address rules, which are merged as they are or-ed, and each other rule in json.
+func readRuleSetEntries(path string) (map[string]bool, error) {
+	ruleSet, err := readRuleSet(path)
+	if err != nil {
+		return nil, err
+	}
+	var items addressRuleItems
+	entries := make(map[string]bool)
+	for _, rule := range ruleSet.Rules {
+		if isAddressRule(rule) {
+			items.add(rule.DefaultOptions)
+			continue
+		}
+		ruleContent, err := json.Marshal(rule)
+		if err != nil {
+			return nil, err
+		}
+		entries["rule: "+string(ruleContent)] = true
+	}
+	addressRule, err := items.build()
+	if err != nil {
+		return nil, err
+	}
+	if addressRule != nil {
+		addEntries(entries, "domain", addressRule.DefaultOptions.Domain)
+		addEntries(entries, "domain_suffix", addressRule.DefaultOptions.DomainSuffix)
+		addEntries(entries, "domain_keyword", addressRule.DefaultOptions.DomainKeyword)
+		addEntries(entries, "domain_regex", addressRule.DefaultOptions.DomainRegex)
+		addEntries(entries, "ip_cidr", addressRule.DefaultOptions.IPCIDR)
+	}
+	return entries, nil
+}
+
+func addEntries(entries map[string]bool, itemName string, values option.Listable[string]) {
+	for _, value := range values {
+		entries[itemName+": "+value] = true
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/route"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+
+	"github.com/spf13/cobra"
+)
+
+var commandRuleSetMatch = &cobra.Command{
+	Use:   "match <rule-set-path> <domain or IP>",
+	Short: "Check if a domain or an IP address matches a rule-set",
+	Long:  "Check if a domain or an IP address matches a rule-set, exit with status 1 if not matched.",
+	Args:  cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		matched, err := matchRuleSet(args[0], args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !matched {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetMatch)
+}
+
+func matchRuleSet(sourcePath string, address string) (bool, error) {
+	ruleSet, err := readRuleSet(sourcePath)
+	if err != nil {
+		return false, err
+	}
+	var metadata adapter.InboundContext
+	destination := M.ParseSocksaddr(address)
+	if destination.IsIP() {
+		metadata.Destination = destination
+	} else {
+		metadata.Domain = strings.ToLower(strings.TrimSuffix(address, "."))
+		metadata.Destination = M.Socksaddr{Fqdn: metadata.Domain}
+	}
+	for i, ruleOptions := range ruleSet.Rules {
+		if containsWIFIRule(ruleOptions) {
+			// WIFI rules require the WIFI state of the router, they never match here.
+			continue
+		}
+		rule, err := route.NewHeadlessRule(nil, ruleOptions)
+		if err != nil {
+			return false, E.Cause(err, "parse rules[", i, "]")
+		}
+		metadata.ResetRuleCache()
+		if !rule.Match(&metadata) {
+			continue
+		}
+		if item := matchedItem(ruleOptions, metadata); item != "" {
+			os.Stdout.WriteString(F.ToString("match rules[", i, "]: ", item, "\n"))
+		} else {
+			ruleContent, err := json.Marshal(ruleOptions)
+			if err != nil {
+				return false, err
+			}
+			os.Stdout.WriteString(F.ToString("match rules[", i, "]: ", string(ruleContent), "\n"))
+		}
+		return true, nil
+	}
+	os.Stdout.WriteString("not matched\n")
+	return false, nil
+}
+
+func containsWIFIRule(rule option.HeadlessRule) bool {
+	switch rule.Type {
+	case C.RuleTypeLogical:
+		for _, subRule := range rule.LogicalOptions.Rules {
+			if containsWIFIRule(subRule) {
+				return true
+			}
+		}
+		return false
+	default:
+		return len(rule.DefaultOptions.WIFISSID) > 0 || len(rule.DefaultOptions.WIFIBSSID) > 0
+	}
+}
+
+// matchedItem returns the item of a destination address rule that matches the destination.
+func matchedItem(rule option.HeadlessRule, metadata adapter.InboundContext) string {
+	if !isAddressRule(rule) {
+		return ""
+	}
+	options := rule.DefaultOptions
+	if metadata.Destination.IsIP() {
+		for _, value := range options.IPCIDR {
+			prefix, err := parseCIDR(value)
+			if err == nil && prefix.Contains(metadata.Destination.Addr) {
+				return "ip_cidr: " + value
+			}
+		}
+		return ""
+	}
+	domain := metadata.Domain
+	for _, value := range options.Domain {
+		if value == domain {
+			return "domain: " + value
+		}
+	}
+	for _, value := range options.DomainSuffix {
+		if domainCovered(map[string]bool{value: true}, domain) {
+			return "domain_suffix: " + value
+		}
+	}
+	for _, value := range options.DomainKeyword {
+		if strings.Contains(domain, value) {
+			return "domain_keyword: " + value
+		}
+	}
+	for _, value := range options.DomainRegex {
+		if regex, err := regexp.Compile(value); err == nil && regex.MatchString(domain) {
+			return "domain_regex: " + value
+		}
+	}
+	return ""
+}
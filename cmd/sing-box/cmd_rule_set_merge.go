@@ -0,0 +1,204 @@
+package main
+
+import (
+	"net/netip"
+	"reflect"
+	"sort"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/spf13/cobra"
+	"go4.org/netipx"
+)
+
+var flagRuleSetMergeOutput string
+
+var commandRuleSetMerge = &cobra.Command{
+	Use:   "merge [rule-set-path]...",
+	Short: "Merge rule-sets and de-duplicate domains and CIDRs",
+	Args:  cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := mergeRuleSets(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	commandRuleSet.AddCommand(commandRuleSetMerge)
+	commandRuleSetMerge.Flags().StringVarP(&flagRuleSetMergeOutput, "output", "o", "", "Output file, binary if ends with .srs, stdout if empty or -")
+}
+
+func mergeRuleSets(sourcePaths []string) error {
+	var (
+		items      addressRuleItems
+		otherRules []option.HeadlessRule
+		otherKeys  = make(map[string]bool)
+	)
+	for _, sourcePath := range sourcePaths {
+		ruleSet, err := readRuleSet(sourcePath)
+		if err != nil {
+			return err
+		}
+		for _, rule := range ruleSet.Rules {
+			if isAddressRule(rule) {
+				items.add(rule.DefaultOptions)
+				continue
+			}
+			ruleKey, err := json.Marshal(rule)
+			if err != nil {
+				return err
+			}
+			if otherKeys[string(ruleKey)] {
+				continue
+			}
+			otherKeys[string(ruleKey)] = true
+			otherRules = append(otherRules, rule)
+		}
+	}
+	var mergedRuleSet option.PlainRuleSet
+	addressRule, err := items.build()
+	if err != nil {
+		return err
+	}
+	if addressRule != nil {
+		mergedRuleSet.Rules = append(mergedRuleSet.Rules, *addressRule)
+	}
+	mergedRuleSet.Rules = append(mergedRuleSet.Rules, otherRules...)
+	return writeRuleSet(flagRuleSetMergeOutput, mergedRuleSet)
+}
+
+// isAddressRule reports whether the rule is a default rule of destination address items only,
+// such rules of a rule-set can be merged into one as all of these items are or-ed.
+func isAddressRule(rule option.HeadlessRule) bool {
+	if rule.Type != C.RuleTypeDefault && rule.Type != "" || rule.DefaultOptions.Invert {
+		return false
+	}
+	options := rule.DefaultOptions
+	options.Domain = nil
+	options.DomainSuffix = nil
+	options.DomainKeyword = nil
+	options.DomainRegex = nil
+	options.IPCIDR = nil
+	options.DomainMatcher = nil
+	options.IPSet = nil
+	return reflect.DeepEqual(options, option.DefaultHeadlessRule{})
+}
+
+type addressRuleItems struct {
+	domain        []string
+	domainSuffix  []string
+	domainKeyword []string
+	domainRegex   []string
+	ipCIDR        []string
+}
+
+func (i *addressRuleItems) add(rule option.DefaultHeadlessRule) {
+	i.domain = append(i.domain, rule.Domain...)
+	i.domainSuffix = append(i.domainSuffix, rule.DomainSuffix...)
+	i.domainKeyword = append(i.domainKeyword, rule.DomainKeyword...)
+	i.domainRegex = append(i.domainRegex, rule.DomainRegex...)
+	i.ipCIDR = append(i.ipCIDR, rule.IPCIDR...)
+}
+
+// build returns a rule of the items without duplicates: domains and domain suffixes
+// covered by other domain suffixes are removed, and overlapping CIDRs are combined.
+func (i *addressRuleItems) build() (*option.HeadlessRule, error) {
+	suffixes := make(map[string]bool)
+	for _, suffix := range i.domainSuffix {
+		suffixes[suffix] = true
+	}
+	var rule option.DefaultHeadlessRule
+	for suffix := range suffixes {
+		if !domainSuffixCovered(suffixes, suffix) {
+			rule.DomainSuffix = append(rule.DomainSuffix, suffix)
+		}
+	}
+	for _, domain := range common.Uniq(i.domain) {
+		if !domainCovered(suffixes, domain) {
+			rule.Domain = append(rule.Domain, domain)
+		}
+	}
+	sort.Strings(rule.Domain)
+	sort.Strings(rule.DomainSuffix)
+	rule.DomainKeyword = sortedUniq(i.domainKeyword)
+	rule.DomainRegex = sortedUniq(i.domainRegex)
+	if len(i.ipCIDR) > 0 {
+		var builder netipx.IPSetBuilder
+		for _, cidr := range i.ipCIDR {
+			prefix, err := parseCIDR(cidr)
+			if err != nil {
+				return nil, err
+			}
+			builder.AddPrefix(prefix)
+		}
+		ipSet, err := builder.IPSet()
+		if err != nil {
+			return nil, err
+		}
+		rule.IPCIDR = common.Map(ipSet.Prefixes(), netip.Prefix.String)
+	}
+	if reflect.DeepEqual(rule, option.DefaultHeadlessRule{}) {
+		return nil, nil
+	}
+	return &option.HeadlessRule{
+		Type:           C.RuleTypeDefault,
+		DefaultOptions: rule,
+	}, nil
+}
+
+// domainCovered reports whether the domain is matched by one of the domain suffixes.
+func domainCovered(suffixes map[string]bool, domain string) bool {
+	return suffixes[domain] || parentDomainCovered(suffixes, domain)
+}
+
+// domainSuffixCovered reports whether all domains matched by the suffix are matched by another suffix.
+func domainSuffixCovered(suffixes map[string]bool, suffix string) bool {
+	name := strings.TrimPrefix(suffix, ".")
+	if name != suffix && suffixes[name] {
+		return true
+	}
+	return parentDomainCovered(suffixes, name)
+}
+
+// parentDomainCovered reports whether a parent domain of the domain is matched by one of the domain suffixes,
+// a suffix with a leading dot only matches subdomains.
+func parentDomainCovered(suffixes map[string]bool, domain string) bool {
+	for parent := domain; ; {
+		index := strings.IndexByte(parent, '.')
+		if index < 0 {
+			return false
+		}
+		parent = parent[index+1:]
+		if suffixes[parent] || suffixes["."+parent] {
+			return true
+		}
+	}
+}
+
+func sortedUniq(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	values = common.Uniq(values)
+	sort.Strings(values)
+	return values
+}
+
+func parseCIDR(value string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(value); err == nil {
+		return prefix.Masked(), nil
+	}
+	address, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, E.Cause(err, "parse CIDR: ", value)
+	}
+	return netip.PrefixFrom(address, address.BitLen()), nil
+}
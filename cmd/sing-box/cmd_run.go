@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"io"
 	"os"
 	"os/signal"
@@ -122,10 +123,10 @@ func readConfigAndMerge() (option.Options, error) {
 	return mergedOptions, nil
 }
 
-func create() (*box.Box, context.CancelFunc, error) {
+func readOptions() (option.Options, error) {
 	options, err := readConfigAndMerge()
 	if err != nil {
-		return nil, nil, err
+		return option.Options{}, err
 	}
 	if disableColor {
 		if options.Log == nil {
@@ -133,6 +134,14 @@ func create() (*box.Box, context.CancelFunc, error) {
 		}
 		options.Log.DisableColor = true
 	}
+	return options, nil
+}
+
+func create() (*box.Box, context.CancelFunc, error) {
+	options, err := readOptions()
+	if err != nil {
+		return nil, nil, err
+	}
 	ctx, cancel := context.WithCancel(globalCtx)
 	instance, err := box.New(box.Options{
 		Context: ctx,
@@ -184,6 +193,16 @@ func run() error {
 					log.Error(E.Cause(err, "reload service"))
 					continue
 				}
+				err = reload(instance)
+				if err == nil {
+					runtimeDebug.FreeOSMemory()
+					continue
+				}
+				if !errors.Is(err, C.ErrRestartRequired) {
+					log.Error(E.Cause(err, "reload service"))
+					continue
+				}
+				log.Warn(err, ", restarting service")
 			}
 			cancel()
 			closeCtx, closed := context.WithCancel(context.Background())
@@ -198,6 +217,14 @@ func run() error {
 	}
 }
 
+func reload(instance *box.Box) error {
+	options, err := readOptions()
+	if err != nil {
+		return err
+	}
+	return instance.Reload(options)
+}
+
 func closeMonitor(ctx context.Context) {
 	time.Sleep(C.DefaultStopFatalTimeout)
 	select {
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var commandSubscribe = &cobra.Command{
+	Use:   "subscribe",
+	Short: "Manage subscriptions",
+}
+
+func init() {
+	mainCommand.AddCommand(commandSubscribe)
+}
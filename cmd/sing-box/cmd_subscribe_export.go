@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/provider"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	commandSubscribeExportFormat    string
+	commandSubscribeExportProviders []string
+	commandSubscribeExportOutput    string
+)
+
+var commandSubscribeExport = &cobra.Command{
+	Use:   "export",
+	Short: "Export outbounds as share links, Clash or sing-box subscription",
+	Run: func(cmd *cobra.Command, args []string) {
+		err := subscribeExport()
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+	Args: cobra.NoArgs,
+}
+
+func init() {
+	commandSubscribeExport.Flags().StringVarP(&commandSubscribeExportFormat, "format", "f", provider.ExportFormatLink, "Output format: link, base64, clash, sing-box")
+	commandSubscribeExport.Flags().StringArrayVarP(&commandSubscribeExportProviders, "provider", "p", nil, "Export outbounds of the provider only")
+	commandSubscribeExport.Flags().StringVarP(&commandSubscribeExportOutput, "output", "o", "stdout", "Output path")
+	commandSubscribe.AddCommand(commandSubscribeExport)
+}
+
+func subscribeExport() error {
+	options, err := readConfigAndMerge()
+	if err != nil {
+		return err
+	}
+	outboundsByProvider, err := provider.LoadOutboundOptions(context.Background(), options.OutboundProviders)
+	if err != nil {
+		return err
+	}
+	var outbounds []option.Outbound
+	if len(commandSubscribeExportProviders) > 0 {
+		for _, tag := range commandSubscribeExportProviders {
+			providerOutbounds, loaded := outboundsByProvider[tag]
+			if !loaded {
+				return E.New("outbound provider not found: ", tag)
+			}
+			outbounds = append(outbounds, providerOutbounds...)
+		}
+	} else {
+		outbounds = append(outbounds, options.Outbounds...)
+		for _, providerOptions := range options.OutboundProviders {
+			outbounds = append(outbounds, outboundsByProvider[providerOptions.Tag]...)
+		}
+	}
+	content, err := provider.Export(outbounds, commandSubscribeExportFormat)
+	if err != nil {
+		return err
+	}
+	var outputWriter io.Writer
+	if commandSubscribeExportOutput == "stdout" {
+		outputWriter = os.Stdout
+	} else {
+		outputFile, err := os.Create(commandSubscribeExportOutput)
+		if err != nil {
+			return err
+		}
+		defer outputFile.Close()
+		outputWriter = outputFile
+	}
+	_, err = outputWriter.Write(content)
+	return err
+}
@@ -0,0 +1,112 @@
+package dnslog
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/common/observable"
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+const (
+	defaultMaxSize    = 10 * 1024 * 1024
+	defaultMaxBackups = 3
+)
+
+var _ adapter.DNSQueryLogger = (*Logger)(nil)
+
+// Logger streams DNS query records to subscribers and writes them to a file as JSON lines if path is set.
+type Logger struct {
+	ctx         context.Context
+	logger      logger.Logger
+	options     option.DNSQueryLogOptions
+	observer    *observable.Observer[adapter.DNSQueryLog]
+	subscribers atomic.Int32
+	access      sync.Mutex
+	writer      *rotateWriter
+}
+
+func NewLogger(ctx context.Context, logger logger.Logger, options option.DNSQueryLogOptions) *Logger {
+	return &Logger{
+		ctx:      ctx,
+		logger:   logger,
+		options:  options,
+		observer: observable.NewObserver[adapter.DNSQueryLog](observable.NewSubscriber[adapter.DNSQueryLog](128), 64),
+	}
+}
+
+func (l *Logger) Start() error {
+	if l.options.Path == "" {
+		return nil
+	}
+	maxSize := int64(l.options.MaxSize)
+	if maxSize == 0 {
+		maxSize = defaultMaxSize
+	}
+	maxBackups := l.options.MaxBackups
+	if maxBackups == 0 {
+		maxBackups = defaultMaxBackups
+	}
+	writer, err := openRotateWriter(l.ctx, filemanager.BasePath(l.ctx, l.options.Path), maxSize, maxBackups)
+	if err != nil {
+		return E.Cause(err, "open query log")
+	}
+	l.access.Lock()
+	l.writer = writer
+	l.access.Unlock()
+	return nil
+}
+
+func (l *Logger) Close() error {
+	l.observer.Close()
+	l.access.Lock()
+	defer l.access.Unlock()
+	if l.writer == nil {
+		return nil
+	}
+	err := l.writer.Close()
+	l.writer = nil
+	return err
+}
+
+func (l *Logger) Enabled() bool {
+	return l.subscribers.Load() > 0 || l.options.Path != ""
+}
+
+func (l *Logger) Log(record adapter.DNSQueryLog) {
+	if l.subscribers.Load() > 0 {
+		l.observer.Emit(record)
+	}
+	l.access.Lock()
+	defer l.access.Unlock()
+	if l.writer == nil {
+		return
+	}
+	content, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+	_, err = l.writer.Write(append(content, '\n'))
+	if err != nil {
+		l.logger.Error(E.Cause(err, "write query log"))
+	}
+}
+
+func (l *Logger) Subscribe() (observable.Subscription[adapter.DNSQueryLog], <-chan struct{}, error) {
+	subscription, done, err := l.observer.Subscribe()
+	if err == nil {
+		l.subscribers.Add(1)
+	}
+	return subscription, done, err
+}
+
+func (l *Logger) UnSubscribe(subscription observable.Subscription[adapter.DNSQueryLog]) {
+	l.subscribers.Add(-1)
+	l.observer.UnSubscribe(subscription)
+}
@@ -0,0 +1,87 @@
+package dnslog
+
+import (
+	"context"
+	"os"
+	"strconv"
+
+	"github.com/sagernet/sing/service/filemanager"
+)
+
+// rotateWriter appends to a file and renames it to path.1, path.2 and so on when it grows over maxSize.
+type rotateWriter struct {
+	ctx        context.Context
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotateWriter(ctx context.Context, path string, maxSize int64, maxBackups int) (*rotateWriter, error) {
+	writer := &rotateWriter{
+		ctx:        ctx,
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	err := writer.open()
+	if err != nil {
+		return nil, err
+	}
+	return writer, nil
+}
+
+func (w *rotateWriter) open() error {
+	file, err := filemanager.OpenFile(w.ctx, w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	w.file = file
+	w.size = info.Size()
+	return nil
+}
+
+func (w *rotateWriter) Write(content []byte) (int, error) {
+	if w.size > 0 && w.size+int64(len(content)) > w.maxSize {
+		err := w.rotate()
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.file.Write(content)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *rotateWriter) rotate() error {
+	err := w.file.Close()
+	if err != nil {
+		return err
+	}
+	if w.maxBackups > 0 {
+		for i := w.maxBackups - 1; i > 0; i-- {
+			os.Rename(w.backupPath(i), w.backupPath(i+1))
+		}
+		err = os.Rename(w.path, w.backupPath(1))
+	} else {
+		err = os.Remove(w.path)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return w.open()
+}
+
+func (w *rotateWriter) backupPath(index int) string {
+	return w.path + "." + strconv.Itoa(index)
+}
+
+func (w *rotateWriter) Close() error {
+	return w.file.Close()
+}
@@ -0,0 +1,190 @@
+package httpauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const (
+	defaultTimeout   = 5 * time.Second
+	defaultTTL       = 5 * time.Minute
+	rejectedTTL      = 30 * time.Second
+	defaultCacheSize = 4096
+)
+
+var errRejected = E.New("rejected by auth server")
+
+var _ adapter.Authenticator = (*Authenticator)(nil)
+
+// Authenticator checks credentials by posting them to an HTTP endpoint.
+// Accepted credentials are cached for the configured TTL, rejected ones for a short time,
+// so that probes repeating the same credentials do not reach the server every time.
+type Authenticator struct {
+	router     adapter.Router
+	url        string
+	headers    http.Header
+	detour     string
+	timeout    time.Duration
+	httpClient *http.Client
+	cache      *cache.LruCache[[sha256.Size]byte, *adapter.AuthResult]
+	rejected   *cache.LruCache[[sha256.Size]byte, bool]
+}
+
+type request struct {
+	Inbound     string `json:"inbound"`
+	InboundType string `json:"inbound_type"`
+	Source      string `json:"source,omitempty"`
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	Key         string `json:"key,omitempty"`
+}
+
+type response struct {
+	Allow    bool                        `json:"allow"`
+	User     string                      `json:"user,omitempty"`
+	Limit    *option.TrafficLimitOptions `json:"limit,omitempty"`
+	Password string                      `json:"password,omitempty"`
+}
+
+func NewAuthenticator(router adapter.Router, options option.ExternalAuthOptions) (*Authenticator, error) {
+	if options.URL == "" {
+		return nil, E.New("missing url")
+	}
+	timeout := time.Duration(options.Timeout)
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+	ttl := time.Duration(options.TTL)
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	authenticator := &Authenticator{
+		router:  router,
+		url:     options.URL,
+		headers: options.Headers.Build(),
+		detour:  options.Detour,
+		timeout: timeout,
+		cache: cache.New(
+			cache.WithAge[[sha256.Size]byte, *adapter.AuthResult](int64(ttl.Seconds())),
+			cache.WithSize[[sha256.Size]byte, *adapter.AuthResult](defaultCacheSize),
+		),
+		rejected: cache.New(
+			cache.WithAge[[sha256.Size]byte, bool](int64(rejectedTTL.Seconds())),
+			cache.WithSize[[sha256.Size]byte, bool](defaultCacheSize),
+		),
+	}
+	authenticator.httpClient = &http.Client{
+		Transport: &http.Transport{
+			TLSHandshakeTimeout: C.TCPTimeout,
+			DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+				dialer, err := authenticator.dialer()
+				if err != nil {
+					return nil, err
+				}
+				return dialer.DialContext(ctx, network, M.ParseSocksaddr(addr))
+			},
+		},
+	}
+	return authenticator, nil
+}
+
+func (a *Authenticator) dialer() (N.Dialer, error) {
+	if a.detour != "" {
+		outbound, loaded := a.router.Outbound(a.detour)
+		if !loaded {
+			return nil, E.New("detour not found: ", a.detour)
+		}
+		return outbound, nil
+	}
+	return a.router.DefaultOutbound(N.NetworkTCP)
+}
+
+func (a *Authenticator) Authenticate(ctx context.Context, metadata adapter.InboundContext, username string, password string) (*adapter.AuthResult, error) {
+	return a.authenticate(ctx, metadata, request{Username: username, Password: password})
+}
+
+func (a *Authenticator) AuthenticateKey(ctx context.Context, metadata adapter.InboundContext, key string) (*adapter.AuthResult, error) {
+	return a.authenticate(ctx, metadata, request{Key: key})
+}
+
+func (a *Authenticator) authenticate(ctx context.Context, metadata adapter.InboundContext, authRequest request) (*adapter.AuthResult, error) {
+	key := sha256.Sum256([]byte(authRequest.Username + "\x00" + authRequest.Password + "\x00" + authRequest.Key))
+	if result, loaded := a.cache.Load(key); loaded {
+		return result, nil
+	}
+	if _, loaded := a.rejected.Load(key); loaded {
+		return nil, errRejected
+	}
+	result, err := a.exchange(ctx, metadata, authRequest)
+	if err == errRejected {
+		a.rejected.Store(key, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+	a.cache.Store(key, result)
+	return result, nil
+}
+
+func (a *Authenticator) exchange(ctx context.Context, metadata adapter.InboundContext, authRequest request) (*adapter.AuthResult, error) {
+	authRequest.Inbound = metadata.Inbound
+	authRequest.InboundType = metadata.InboundType
+	authRequest.Source = metadata.Source.String()
+	content, err := json.Marshal(authRequest)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, a.url, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range a.headers {
+		httpRequest.Header[name] = values
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpResponse, err := a.httpClient.Do(httpRequest)
+	if err != nil {
+		return nil, E.Cause(err, "request auth server")
+	}
+	defer httpResponse.Body.Close()
+	if httpResponse.StatusCode != http.StatusOK {
+		return nil, E.New("unexpected auth server status: ", httpResponse.Status)
+	}
+	content, err = io.ReadAll(io.LimitReader(httpResponse.Body, 64*1024))
+	if err != nil {
+		return nil, E.Cause(err, "read auth server response")
+	}
+	var authResponse response
+	err = json.Unmarshal(content, &authResponse)
+	if err != nil {
+		return nil, E.Cause(err, "decode auth server response")
+	}
+	if !authResponse.Allow {
+		return nil, errRejected
+	}
+	result := &adapter.AuthResult{
+		User:     authResponse.User,
+		Limit:    authResponse.Limit,
+		Password: authResponse.Password,
+	}
+	if result.User == "" {
+		result.User = authRequest.Username
+	}
+	return result, nil
+}
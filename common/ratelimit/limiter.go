@@ -0,0 +1,53 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket allowing rate bytes per second with a burst of one second.
+// Requests larger than the available tokens are admitted and delay the following ones.
+type Limiter struct {
+	access sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func New(rate uint64) *Limiter {
+	return &Limiter{
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   time.Now(),
+	}
+}
+
+func (l *Limiter) reserve(n int) time.Duration {
+	l.access.Lock()
+	defer l.access.Unlock()
+	now := time.Now()
+	l.tokens = math.Min(l.rate, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens -= float64(n)
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	delay := l.reserve(n)
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
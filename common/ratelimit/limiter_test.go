@@ -0,0 +1,51 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/common/ratelimit"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterWait(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name     string
+		rate     uint64
+		requests []int
+		minDelay time.Duration
+		maxDelay time.Duration
+	}{
+		{"within burst", 10000, []int{4000, 6000}, 0, 50 * time.Millisecond},
+		{"over burst", 10000, []int{10000, 1000}, 80 * time.Millisecond, 300 * time.Millisecond},
+		{"over rate", 10000, []int{12000}, 180 * time.Millisecond, 400 * time.Millisecond},
+		{"after over rate", 10000, []int{12000, 5000}, 680 * time.Millisecond, time.Second},
+	} {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			limiter := ratelimit.New(testCase.rate)
+			start := time.Now()
+			for _, n := range testCase.requests {
+				require.NoError(t, limiter.WaitN(context.Background(), n))
+			}
+			elapsed := time.Since(start)
+			require.GreaterOrEqual(t, elapsed, testCase.minDelay)
+			require.Less(t, elapsed, testCase.maxDelay)
+		})
+	}
+}
+
+func TestLimiterWaitCanceled(t *testing.T) {
+	t.Parallel()
+	limiter := ratelimit.New(1000)
+	require.NoError(t, limiter.WaitN(context.Background(), 1000))
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	require.ErrorIs(t, limiter.WaitN(ctx, 10000), context.DeadlineExceeded)
+	require.Less(t, time.Since(start), time.Second)
+}
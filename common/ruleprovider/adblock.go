@@ -0,0 +1,206 @@
+package ruleprovider
+
+import (
+	"net/netip"
+	"regexp"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+// adblockHostsIgnored are the names mapped to local addresses in hosts files, not blocked domains.
+var adblockHostsIgnored = map[string]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+}
+
+var adblockDomainPattern = regexp.MustCompile(`^[a-z0-9_*.-]+$`)
+
+// adblockBuilder collects blocking rules and exceptions of an adblock filter list,
+// $important rules are only overridden by $important exceptions.
+type adblockBuilder struct {
+	block              adblockRules
+	exception          adblockRules
+	importantBlock     adblockRules
+	importantException adblockRules
+}
+
+type adblockRules struct {
+	domain       []string
+	domainSuffix []string
+	domainRegex  []string
+}
+
+func (r *adblockRules) isEmpty() bool {
+	return len(r.domain) == 0 && len(r.domainSuffix) == 0 && len(r.domainRegex) == 0
+}
+
+func (r *adblockRules) append(rules adblockRules) adblockRules {
+	return adblockRules{
+		domain:       append(append([]string(nil), r.domain...), rules.domain...),
+		domainSuffix: append(append([]string(nil), r.domainSuffix...), rules.domainSuffix...),
+		domainRegex:  append(append([]string(nil), r.domainRegex...), rules.domainRegex...),
+	}
+}
+
+func (r *adblockRules) headlessRule(invert bool) option.HeadlessRule {
+	return option.HeadlessRule{
+		Type: C.RuleTypeDefault,
+		DefaultOptions: option.DefaultHeadlessRule{
+			Domain:       r.domain,
+			DomainSuffix: r.domainSuffix,
+			DomainRegex:  r.domainRegex,
+			Invert:       invert,
+		},
+	}
+}
+
+func (b *adblockBuilder) build() []option.HeadlessRule {
+	var rules []option.HeadlessRule
+	if !b.block.isEmpty() {
+		rules = append(rules, exceptRule(b.block, b.exception.append(b.importantException)))
+	}
+	if !b.importantBlock.isEmpty() {
+		rules = append(rules, exceptRule(b.importantBlock, b.importantException))
+	}
+	return rules
+}
+
+// exceptRule matches the blocking rules unless an exception matches, which
+// is a logical and of the blocking rule and the inverted exception rule.
+func exceptRule(block adblockRules, exception adblockRules) option.HeadlessRule {
+	if exception.isEmpty() {
+		return block.headlessRule(false)
+	}
+	return option.HeadlessRule{
+		Type: C.RuleTypeLogical,
+		LogicalOptions: option.LogicalHeadlessRule{
+			Mode:  C.LogicalTypeAnd,
+			Rules: []option.HeadlessRule{block.headlessRule(false), exception.headlessRule(true)},
+		},
+	}
+}
+
+// addLine adds a line of an adblock filter list or a hosts file,
+// comments, cosmetic rules and rules that do not apply to domains are ignored.
+func (b *adblockBuilder) addLine(line string) error {
+	if strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+		return nil
+	}
+	for _, separator := range []string{"##", "#@#", "#?#", "#$#", "#%#"} {
+		if strings.Contains(line, separator) {
+			return nil
+		}
+	}
+	fields := strings.Fields(line)
+	if len(fields) > 1 {
+		if _, err := netip.ParseAddr(fields[0]); err == nil {
+			b.addHosts(fields[1:])
+		}
+		return nil
+	}
+	b.addRule(line)
+	return nil
+}
+
+// addHosts adds the names of a hosts file line, which match exactly.
+func (b *adblockBuilder) addHosts(names []string) {
+	for _, name := range names {
+		if strings.HasPrefix(name, "#") {
+			break
+		}
+		name = strings.ToLower(name)
+		if adblockHostsIgnored[name] || isIPCIDR(name) || !adblockDomainPattern.MatchString(name) || strings.Contains(name, "*") {
+			continue
+		}
+		b.block.domain = append(b.block.domain, name)
+	}
+}
+
+func (b *adblockBuilder) addRule(rule string) {
+	rules := &b.block
+	exception := strings.HasPrefix(rule, "@@")
+	if exception {
+		rule = rule[2:]
+		rules = &b.exception
+	}
+	pattern, modifiers := splitAdblockModifiers(rule)
+	for _, modifier := range modifiers {
+		switch modifier {
+		case "important":
+			if exception {
+				rules = &b.importantException
+			} else {
+				rules = &b.importantBlock
+			}
+		case "all":
+		default:
+			// Modifiers of content types, third-party requests, clients and rewrites do not apply to a domain match.
+			return
+		}
+	}
+	if len(pattern) > 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		expression := pattern[1 : len(pattern)-1]
+		if _, err := regexp.Compile(expression); err == nil {
+			rules.domainRegex = append(rules.domainRegex, expression)
+		}
+		return
+	}
+	pattern = strings.ToLower(pattern)
+	var suffix bool
+	if strings.HasPrefix(pattern, "||") {
+		pattern = pattern[2:]
+		suffix = true
+	} else if strings.HasPrefix(pattern, "|") {
+		pattern = pattern[1:]
+		for _, scheme := range []string{"http://", "https://"} {
+			pattern = strings.TrimPrefix(pattern, scheme)
+		}
+	}
+	pattern = strings.TrimRight(strings.TrimSuffix(pattern, "/"), "^|")
+	pattern = strings.TrimSuffix(pattern, ".")
+	if pattern == "" || !adblockDomainPattern.MatchString(pattern) || strings.Trim(pattern, "*.") == "" || isIPCIDR(pattern) {
+		return
+	}
+	switch {
+	case strings.Contains(pattern, "*"):
+		expression := strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`) + "$"
+		if suffix {
+			expression = `(^|\.)` + expression
+		} else {
+			expression = "^" + expression
+		}
+		rules.domainRegex = append(rules.domainRegex, expression)
+	case suffix:
+		rules.domainSuffix = append(rules.domainSuffix, pattern)
+	default:
+		rules.domain = append(rules.domain, pattern)
+	}
+}
+
+// splitAdblockModifiers splits a rule into the pattern and the modifiers after $,
+// a $ inside a regular expression pattern is not a separator.
+func splitAdblockModifiers(rule string) (string, []string) {
+	index := strings.LastIndex(rule, "$")
+	if index < 0 || strings.HasPrefix(rule, "/") && strings.LastIndex(rule, "/") > index {
+		return rule, nil
+	}
+	var modifiers []string
+	for _, modifier := range strings.Split(rule[index+1:], ",") {
+		modifier = strings.TrimSpace(modifier)
+		if modifier != "" {
+			modifiers = append(modifiers, strings.ToLower(modifier))
+		}
+	}
+	return rule[:index], modifiers
+}
@@ -0,0 +1,121 @@
+package ruleprovider_test
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/common/ruleprovider"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readAdblock(t *testing.T, content string) []option.HeadlessRule {
+	ruleSet, skipped, err := ruleprovider.Read([]byte(content), C.RuleSetFormatAdblock, "")
+	require.NoError(t, err, content)
+	require.Nil(t, skipped, content)
+	return ruleSet.Rules
+}
+
+func TestReadAdblock(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		line string
+		rule option.DefaultHeadlessRule
+	}{
+		{"||example.com^", option.DefaultHeadlessRule{DomainSuffix: []string{"example.com"}}},
+		{"||Example.COM^$all", option.DefaultHeadlessRule{DomainSuffix: []string{"example.com"}}},
+		{"||example.com.^|", option.DefaultHeadlessRule{DomainSuffix: []string{"example.com"}}},
+		{"|https://example.com^", option.DefaultHeadlessRule{Domain: []string{"example.com"}}},
+		{"example.com", option.DefaultHeadlessRule{Domain: []string{"example.com"}}},
+		{"||ad*.example.com^", option.DefaultHeadlessRule{DomainRegex: []string{`(^|\.)ad.*\.example\.com$`}}},
+		{"ad*.example.com", option.DefaultHeadlessRule{DomainRegex: []string{`^ad.*\.example\.com$`}}},
+		{`/^ads?\./`, option.DefaultHeadlessRule{DomainRegex: []string{`^ads?\.`}}},
+		{`/ads$/$important`, option.DefaultHeadlessRule{DomainRegex: []string{`ads$`}}},
+		{"0.0.0.0 example.com localhost www.example.com # comment", option.DefaultHeadlessRule{Domain: []string{"example.com", "www.example.com"}}},
+		{"::1 Example.com", option.DefaultHeadlessRule{Domain: []string{"example.com"}}},
+	} {
+		require.Equal(t, defaultRules(testCase.rule), readAdblock(t, testCase.line), testCase.line)
+	}
+}
+
+func TestReadAdblockIgnored(t *testing.T) {
+	t.Parallel()
+	for _, line := range []string{
+		"! comment",
+		"[Adblock Plus 2.0]",
+		"example.com##.ad",
+		"example.com#@#.ad",
+		"||example.com^$third-party",
+		"||example.com^$dnsrewrite=1.2.3.4",
+		"||example.com/ads",
+		"||1.2.3.4^",
+		"||*.^",
+		"/[/",
+		"127.0.0.1 localhost",
+		"0.0.0.0 0.0.0.0",
+		"not-an-address example.com",
+	} {
+		require.Empty(t, readAdblock(t, line), line)
+	}
+}
+
+func TestReadAdblockException(t *testing.T) {
+	t.Parallel()
+	except := func(block option.DefaultHeadlessRule, exception option.DefaultHeadlessRule) option.HeadlessRule {
+		exception.Invert = true
+		return option.HeadlessRule{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode:  C.LogicalTypeAnd,
+				Rules: defaultRules(block, exception),
+			},
+		}
+	}
+	for _, testCase := range []struct {
+		name    string
+		content string
+		rules   []option.HeadlessRule
+	}{
+		{
+			"exception",
+			"||example.com^\n@@||www.example.com^\n",
+			[]option.HeadlessRule{except(
+				option.DefaultHeadlessRule{DomainSuffix: []string{"example.com"}},
+				option.DefaultHeadlessRule{DomainSuffix: []string{"www.example.com"}},
+			)},
+		},
+		{
+			"exception only",
+			"@@||example.com^\n",
+			nil,
+		},
+		{
+			"important block",
+			"||a.com^$important\n||b.com^\n@@||a.com^\n",
+			[]option.HeadlessRule{
+				except(
+					option.DefaultHeadlessRule{DomainSuffix: []string{"b.com"}},
+					option.DefaultHeadlessRule{DomainSuffix: []string{"a.com"}},
+				),
+				{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{DomainSuffix: []string{"a.com"}}},
+			},
+		},
+		{
+			"important exception",
+			"||a.com^$important\n||b.com^\n@@||a.com^$important\n",
+			[]option.HeadlessRule{
+				except(
+					option.DefaultHeadlessRule{DomainSuffix: []string{"b.com"}},
+					option.DefaultHeadlessRule{DomainSuffix: []string{"a.com"}},
+				),
+				except(
+					option.DefaultHeadlessRule{DomainSuffix: []string{"a.com"}},
+					option.DefaultHeadlessRule{DomainSuffix: []string{"a.com"}},
+				),
+			},
+		},
+	} {
+		require.Equal(t, testCase.rules, readAdblock(t, testCase.content), testCase.name)
+	}
+}
@@ -0,0 +1,327 @@
+package ruleprovider
+
+import (
+	"bufio"
+	"bytes"
+	"net/netip"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+
+	"gopkg.in/yaml.v3"
+)
+
+// IsFormat reports whether the rule set format is parsed by this package.
+func IsFormat(format string) bool {
+	switch format {
+	case C.RuleSetFormatClash, C.RuleSetFormatDomainList, C.RuleSetFormatIPCIDRList, C.RuleSetFormatAdblock:
+		return true
+	default:
+		return false
+	}
+}
+
+// Skipped counts the classical entries of a Clash rule provider skipped by rule type,
+// as types such as GEOIP or AND have no matching headless rule item.
+type Skipped map[string]int
+
+func (s Skipped) String() string {
+	ruleTypes := make([]string, 0, len(s))
+	for ruleType := range s {
+		ruleTypes = append(ruleTypes, ruleType)
+	}
+	sort.Strings(ruleTypes)
+	for i, ruleType := range ruleTypes {
+		ruleTypes[i] = F.ToString(ruleType, " (", s[ruleType], ")")
+	}
+	return strings.Join(ruleTypes, ", ")
+}
+
+// Read parses a rule list in one of the third-party formats into headless rules,
+// behavior only applies to the clash format and is detected per entry if empty.
+func Read(content []byte, format string, behavior string) (option.PlainRuleSet, Skipped, error) {
+	var builder ruleBuilder
+	switch format {
+	case C.RuleSetFormatClash:
+		payload, err := readClashPayload(content)
+		if err != nil {
+			return option.PlainRuleSet{}, nil, err
+		}
+		for i, entry := range payload {
+			err = builder.addClashEntry(entry, behavior)
+			if err != nil {
+				return option.PlainRuleSet{}, nil, E.Cause(err, "parse payload[", i, "]: ", entry)
+			}
+		}
+	case C.RuleSetFormatDomainList:
+		err := readLines(content, builder.addDomainListEntry)
+		if err != nil {
+			return option.PlainRuleSet{}, nil, err
+		}
+	case C.RuleSetFormatIPCIDRList:
+		err := readLines(content, builder.addIPCIDR)
+		if err != nil {
+			return option.PlainRuleSet{}, nil, err
+		}
+	case C.RuleSetFormatAdblock:
+		var adblock adblockBuilder
+		err := readLines(content, adblock.addLine)
+		if err != nil {
+			return option.PlainRuleSet{}, nil, err
+		}
+		return option.PlainRuleSet{Rules: adblock.build()}, nil, nil
+	default:
+		return option.PlainRuleSet{}, nil, E.New("unknown rule set format: ", format)
+	}
+	return option.PlainRuleSet{Rules: builder.build()}, builder.skipped, nil
+}
+
+// readClashPayload reads the payload of a rule provider in YAML, or the lines of a rule provider in text.
+func readClashPayload(content []byte) ([]string, error) {
+	var provider struct {
+		Payload []string `yaml:"payload"`
+	}
+	if yaml.Unmarshal(content, &provider) == nil && provider.Payload != nil {
+		return provider.Payload, nil
+	}
+	var payload []string
+	err := readLines(content, func(line string) error {
+		payload = append(payload, strings.Trim(line, `'"`))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func readLines(content []byte, addLine func(line string) error) error {
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		err := addLine(line)
+		if err != nil {
+			return E.Cause(err, "parse line ", lineNumber)
+		}
+	}
+	return scanner.Err()
+}
+
+// ruleBuilder collects entries by rule item, items that would be AND-ed
+// within a single headless rule are emitted as separate rules.
+type ruleBuilder struct {
+	domain          []string
+	domainSuffix    []string
+	domainKeyword   []string
+	domainRegex     []string
+	ipCIDR          []string
+	sourceIPCIDR    []string
+	port            []uint16
+	portRange       []string
+	sourcePort      []uint16
+	sourcePortRange []string
+	processName     []string
+	processPath     []string
+	network         []string
+	skipped         Skipped
+}
+
+func (b *ruleBuilder) build() []option.HeadlessRule {
+	var rules []option.DefaultHeadlessRule
+	if len(b.domain) > 0 || len(b.domainSuffix) > 0 || len(b.domainKeyword) > 0 || len(b.domainRegex) > 0 || len(b.ipCIDR) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{
+			Domain:        b.domain,
+			DomainSuffix:  b.domainSuffix,
+			DomainKeyword: b.domainKeyword,
+			DomainRegex:   b.domainRegex,
+			IPCIDR:        b.ipCIDR,
+		})
+	}
+	if len(b.sourceIPCIDR) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{SourceIPCIDR: b.sourceIPCIDR})
+	}
+	if len(b.port) > 0 || len(b.portRange) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{Port: b.port, PortRange: b.portRange})
+	}
+	if len(b.sourcePort) > 0 || len(b.sourcePortRange) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{SourcePort: b.sourcePort, SourcePortRange: b.sourcePortRange})
+	}
+	if len(b.processName) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{ProcessName: b.processName})
+	}
+	if len(b.processPath) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{ProcessPath: b.processPath})
+	}
+	if len(b.network) > 0 {
+		rules = append(rules, option.DefaultHeadlessRule{Network: b.network})
+	}
+	headlessRules := make([]option.HeadlessRule, 0, len(rules))
+	for _, rule := range rules {
+		headlessRules = append(headlessRules, option.HeadlessRule{
+			Type:           C.RuleTypeDefault,
+			DefaultOptions: rule,
+		})
+	}
+	return headlessRules
+}
+
+func (b *ruleBuilder) addClashEntry(entry string, behavior string) error {
+	entry = strings.TrimSpace(entry)
+	if entry == "" {
+		return nil
+	}
+	if behavior == "" {
+		if strings.Contains(entry, ",") {
+			behavior = C.RuleSetBehaviorClassical
+		} else if isIPCIDR(entry) {
+			behavior = C.RuleSetBehaviorIPCIDR
+		} else {
+			behavior = C.RuleSetBehaviorDomain
+		}
+	}
+	switch behavior {
+	case C.RuleSetBehaviorDomain:
+		return b.addClashDomain(entry)
+	case C.RuleSetBehaviorIPCIDR:
+		return b.addIPCIDR(entry)
+	case C.RuleSetBehaviorClassical:
+		return b.addClassical(entry)
+	default:
+		return E.New("unknown behavior: ", behavior)
+	}
+}
+
+// addClashDomain adds a domain in the Clash syntax: +.example.com matches the domain and its subdomains,
+// .example.com matches subdomains only, * matches a single label and others match exactly.
+func (b *ruleBuilder) addClashDomain(entry string) error {
+	switch {
+	case strings.HasPrefix(entry, "+."):
+		b.domainSuffix = append(b.domainSuffix, entry[2:])
+	case strings.HasPrefix(entry, "."):
+		b.domainSuffix = append(b.domainSuffix, entry)
+	case strings.Contains(entry, "*"):
+		b.domainRegex = append(b.domainRegex, wildcardRegex(entry))
+	default:
+		b.domain = append(b.domain, entry)
+	}
+	return nil
+}
+
+// addDomainListEntry adds a line of a plain domain list, which matches the domain and its subdomains
+// unless prefixed with full:, keyword: or regexp:, Clash domain syntax is accepted as well.
+func (b *ruleBuilder) addDomainListEntry(line string) error {
+	entry := strings.Fields(line)[0]
+	switch {
+	case strings.HasPrefix(entry, "full:"):
+		b.domain = append(b.domain, strings.TrimPrefix(entry, "full:"))
+	case strings.HasPrefix(entry, "domain:"):
+		b.domainSuffix = append(b.domainSuffix, strings.TrimPrefix(entry, "domain:"))
+	case strings.HasPrefix(entry, "keyword:"):
+		b.domainKeyword = append(b.domainKeyword, strings.TrimPrefix(entry, "keyword:"))
+	case strings.HasPrefix(entry, "regexp:"):
+		b.domainRegex = append(b.domainRegex, strings.TrimPrefix(entry, "regexp:"))
+	case strings.HasPrefix(entry, "+."), strings.HasPrefix(entry, "."), strings.Contains(entry, "*"):
+		return b.addClashDomain(entry)
+	default:
+		b.domainSuffix = append(b.domainSuffix, entry)
+	}
+	return nil
+}
+
+func (b *ruleBuilder) addIPCIDR(line string) error {
+	entry := strings.Fields(line)[0]
+	if !isIPCIDR(entry) {
+		return E.New("invalid IP address or CIDR: ", entry)
+	}
+	b.ipCIDR = append(b.ipCIDR, entry)
+	return nil
+}
+
+// addClassical adds a Clash classical rule, types without a matching headless rule item are counted as skipped.
+func (b *ruleBuilder) addClassical(entry string) error {
+	fields := strings.Split(entry, ",")
+	if len(fields) < 2 {
+		return E.New("missing rule value")
+	}
+	ruleType := strings.ToUpper(strings.TrimSpace(fields[0]))
+	value := strings.TrimSpace(fields[1])
+	switch ruleType {
+	case "DOMAIN":
+		b.domain = append(b.domain, value)
+	case "DOMAIN-SUFFIX":
+		b.domainSuffix = append(b.domainSuffix, value)
+	case "DOMAIN-KEYWORD":
+		b.domainKeyword = append(b.domainKeyword, value)
+	case "DOMAIN-REGEX":
+		b.domainRegex = append(b.domainRegex, value)
+	case "DOMAIN-WILDCARD":
+		b.domainRegex = append(b.domainRegex, wildcardRegex(value))
+	case "IP-CIDR", "IP-CIDR6":
+		if !isIPCIDR(value) {
+			return E.New("invalid IP address or CIDR: ", value)
+		}
+		b.ipCIDR = append(b.ipCIDR, value)
+	case "SRC-IP-CIDR":
+		if !isIPCIDR(value) {
+			return E.New("invalid IP address or CIDR: ", value)
+		}
+		b.sourceIPCIDR = append(b.sourceIPCIDR, value)
+	case "DST-PORT":
+		return addPorts(value, &b.port, &b.portRange)
+	case "SRC-PORT":
+		return addPorts(value, &b.sourcePort, &b.sourcePortRange)
+	case "PROCESS-NAME":
+		b.processName = append(b.processName, value)
+	case "PROCESS-PATH":
+		b.processPath = append(b.processPath, value)
+	case "NETWORK":
+		b.network = append(b.network, strings.ToLower(value))
+	default:
+		if b.skipped == nil {
+			b.skipped = make(Skipped)
+		}
+		b.skipped[ruleType]++
+	}
+	return nil
+}
+
+// addPorts adds Clash ports such as 443, 1000-2000 or 80/443.
+func addPorts(value string, ports *[]uint16, portRanges *[]string) error {
+	for _, portString := range strings.Split(value, "/") {
+		if strings.Contains(portString, "-") {
+			portRange := strings.ReplaceAll(portString, "-", ":")
+			*portRanges = append(*portRanges, portRange)
+			continue
+		}
+		port, err := strconv.ParseUint(portString, 10, 16)
+		if err != nil {
+			return E.Cause(err, "parse port")
+		}
+		*ports = append(*ports, uint16(port))
+	}
+	return nil
+}
+
+func isIPCIDR(value string) bool {
+	if _, err := netip.ParsePrefix(value); err == nil {
+		return true
+	}
+	_, err := netip.ParseAddr(value)
+	return err == nil
+}
+
+func wildcardRegex(pattern string) string {
+	return "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `[^.]+`) + "$"
+}
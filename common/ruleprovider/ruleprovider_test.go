@@ -0,0 +1,172 @@
+package ruleprovider_test
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/common/ruleprovider"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func defaultRules(rules ...option.DefaultHeadlessRule) []option.HeadlessRule {
+	headlessRules := make([]option.HeadlessRule, 0, len(rules))
+	for _, rule := range rules {
+		headlessRules = append(headlessRules, option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: rule})
+	}
+	return headlessRules
+}
+
+func TestReadClash(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name     string
+		behavior string
+		content  string
+		rules    []option.HeadlessRule
+		skipped  ruleprovider.Skipped
+	}{
+		{
+			"domain yaml", C.RuleSetBehaviorDomain,
+			"payload:\n  - '+.example.com'\n  - '.example.net'\n  - 'www.example.org'\n  - '*.example.io'\n",
+			defaultRules(option.DefaultHeadlessRule{
+				Domain:       []string{"www.example.org"},
+				DomainSuffix: []string{"example.com", ".example.net"},
+				DomainRegex:  []string{`^[^.]+\.example\.io$`},
+			}),
+			nil,
+		},
+		{
+			"ipcidr text", C.RuleSetBehaviorIPCIDR,
+			"# comment\n10.0.0.0/8\n\n'2001:db8::/32'\n1.1.1.1\n",
+			defaultRules(option.DefaultHeadlessRule{IPCIDR: []string{"10.0.0.0/8", "2001:db8::/32", "1.1.1.1"}}),
+			nil,
+		},
+		{
+			"classical", C.RuleSetBehaviorClassical,
+			"payload:\n" +
+				"  - DOMAIN,a.com\n" +
+				"  - DOMAIN-SUFFIX,b.com\n" +
+				"  - DOMAIN-KEYWORD,ads\n" +
+				"  - DOMAIN-WILDCARD,*.c.com\n" +
+				"  - IP-CIDR,10.0.0.0/8,no-resolve\n" +
+				"  - IP-CIDR6,2001:db8::/32\n" +
+				"  - SRC-IP-CIDR,192.168.0.0/16\n" +
+				"  - DST-PORT,80/443\n" +
+				"  - DST-PORT,1000-2000\n" +
+				"  - SRC-PORT,53\n" +
+				"  - PROCESS-NAME,curl\n" +
+				"  - NETWORK,UDP\n",
+			defaultRules(
+				option.DefaultHeadlessRule{
+					Domain:        []string{"a.com"},
+					DomainSuffix:  []string{"b.com"},
+					DomainKeyword: []string{"ads"},
+					DomainRegex:   []string{`^[^.]+\.c\.com$`},
+					IPCIDR:        []string{"10.0.0.0/8", "2001:db8::/32"},
+				},
+				option.DefaultHeadlessRule{SourceIPCIDR: []string{"192.168.0.0/16"}},
+				option.DefaultHeadlessRule{Port: []uint16{80, 443}, PortRange: []string{"1000:2000"}},
+				option.DefaultHeadlessRule{SourcePort: []uint16{53}},
+				option.DefaultHeadlessRule{ProcessName: []string{"curl"}},
+				option.DefaultHeadlessRule{Network: []string{"udp"}},
+			),
+			nil,
+		},
+		{
+			"classical skipped", C.RuleSetBehaviorClassical,
+			"DOMAIN,a.com\nGEOIP,CN\ngeoip,US\nAND,((DOMAIN,b.com),(NETWORK,UDP))\nIP-ASN,13335\n",
+			defaultRules(option.DefaultHeadlessRule{Domain: []string{"a.com"}}),
+			ruleprovider.Skipped{"GEOIP": 2, "AND": 1, "IP-ASN": 1},
+		},
+		{
+			"detected", "",
+			"payload:\n  - DOMAIN-SUFFIX,a.com\n  - 10.0.0.0/8\n  - b.com\n",
+			defaultRules(option.DefaultHeadlessRule{
+				Domain:       []string{"b.com"},
+				DomainSuffix: []string{"a.com"},
+				IPCIDR:       []string{"10.0.0.0/8"},
+			}),
+			nil,
+		},
+	} {
+		ruleSet, skipped, err := ruleprovider.Read([]byte(testCase.content), C.RuleSetFormatClash, testCase.behavior)
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, testCase.rules, ruleSet.Rules, testCase.name)
+		require.Equal(t, testCase.skipped, skipped, testCase.name)
+	}
+}
+
+func TestReadClashInvalid(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		behavior string
+		content  string
+	}{
+		{C.RuleSetBehaviorIPCIDR, "example.com\n"},
+		{C.RuleSetBehaviorClassical, "DOMAIN\n"},
+		{C.RuleSetBehaviorClassical, "IP-CIDR,example.com\n"},
+		{C.RuleSetBehaviorClassical, "DST-PORT,http\n"},
+		{"unknown", "example.com\n"},
+	} {
+		_, _, err := ruleprovider.Read([]byte(testCase.content), C.RuleSetFormatClash, testCase.behavior)
+		require.Error(t, err, "%s: %s", testCase.behavior, testCase.content)
+	}
+}
+
+func TestSkippedString(t *testing.T) {
+	t.Parallel()
+	require.Equal(t, "AND (1), GEOIP (2)", ruleprovider.Skipped{"GEOIP": 2, "AND": 1}.String())
+}
+
+func TestReadDomainList(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name    string
+		content string
+		rule    option.DefaultHeadlessRule
+	}{
+		{"suffix", "example.com\n", option.DefaultHeadlessRule{DomainSuffix: []string{"example.com"}}},
+		{"prefixes", "full:a.com\ndomain:b.com\nkeyword:ads\nregexp:^c\\.com$\n", option.DefaultHeadlessRule{
+			Domain:        []string{"a.com"},
+			DomainSuffix:  []string{"b.com"},
+			DomainKeyword: []string{"ads"},
+			DomainRegex:   []string{`^c\.com$`},
+		}},
+		{"clash syntax", "+.a.com\n.b.com\n*.c.com\n", option.DefaultHeadlessRule{
+			DomainSuffix: []string{"a.com", ".b.com"},
+			DomainRegex:  []string{`^[^.]+\.c\.com$`},
+		}},
+		{"comments and attributes", "# comment\n// comment\n\n  a.com @ads  \n", option.DefaultHeadlessRule{DomainSuffix: []string{"a.com"}}},
+	} {
+		ruleSet, skipped, err := ruleprovider.Read([]byte(testCase.content), C.RuleSetFormatDomainList, "")
+		require.NoError(t, err, testCase.name)
+		require.Nil(t, skipped, testCase.name)
+		require.Equal(t, defaultRules(testCase.rule), ruleSet.Rules, testCase.name)
+	}
+}
+
+func TestReadIPCIDRList(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name    string
+		content string
+		ipCIDR  []string
+		invalid bool
+	}{
+		{"cidr", "10.0.0.0/8\n2001:db8::/32\n", []string{"10.0.0.0/8", "2001:db8::/32"}, false},
+		{"address", "1.1.1.1\n::1\n", []string{"1.1.1.1", "::1"}, false},
+		{"comments", "# comment\n\n10.0.0.0/8 # private\n", []string{"10.0.0.0/8"}, false},
+		{"domain", "10.0.0.0/8\nexample.com\n", nil, true},
+		{"bad prefix", "10.0.0.0/33\n", nil, true},
+	} {
+		ruleSet, _, err := ruleprovider.Read([]byte(testCase.content), C.RuleSetFormatIPCIDRList, "")
+		if testCase.invalid {
+			require.Error(t, err, testCase.name)
+			continue
+		}
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, defaultRules(option.DefaultHeadlessRule{IPCIDR: testCase.ipCIDR}), ruleSet.Rules, testCase.name)
+	}
+}
@@ -1,10 +1,12 @@
 package srs
 
 import (
+	"bytes"
 	"compress/zlib"
 	"encoding/binary"
 	"io"
 	"net/netip"
+	"os"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
@@ -35,10 +37,87 @@ const (
 	ruleItemPackageName
 	ruleItemWIFISSID
 	ruleItemWIFIBSSID
+	ruleItemTimeRange
+	ruleItemWeekday
+	ruleItemTimezone
 	ruleItemFinal uint8 = 0xFF
 )
 
+const (
+	// Version1 is compressed with zlib.
+	Version1 uint8 = 1 + iota
+	// Version2 is not compressed, domain and CIDR items are indexed so that they are matched
+	// directly on the content of the file.
+	Version2
+)
+
 func Read(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err error) {
+	version, err := readHeader(reader)
+	if err != nil {
+		return
+	}
+	switch version {
+	case Version1:
+		return readCompressed(reader, recovery)
+	case Version2:
+		var content []byte
+		content, err = io.ReadAll(reader)
+		if err != nil {
+			return
+		}
+		return readIndexed(append(append(MagicBytes[:], version), content...), nil, recovery)
+	default:
+		return ruleSet, E.New("unsupported version: ", version)
+	}
+}
+
+// ReadBytes reads a rule-set from content, matchers of the indexed format refer to content,
+// which must not be modified afterwards.
+func ReadBytes(content []byte, recovery bool) (ruleSet option.PlainRuleSet, err error) {
+	return readBytes(content, nil, recovery)
+}
+
+// ReadFile reads a rule-set file, the indexed format is memory-mapped if supported.
+func ReadFile(path string, recovery bool) (ruleSet option.PlainRuleSet, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	version, err := readHeader(file)
+	if err == nil && version != Version2 {
+		_, err = file.Seek(0, io.SeekStart)
+		if err == nil {
+			ruleSet, err = Read(file, recovery)
+		}
+	}
+	file.Close()
+	if err != nil || version != Version2 {
+		return
+	}
+	content, owner, err := mapFile(path)
+	if err != nil {
+		return
+	}
+	// The file may have been replaced after its header was read.
+	return readBytes(content, owner, recovery)
+}
+
+func readBytes(content []byte, owner any, recovery bool) (ruleSet option.PlainRuleSet, err error) {
+	version, err := readHeader(bytes.NewReader(content))
+	if err != nil {
+		return
+	}
+	switch version {
+	case Version1:
+		return readCompressed(bytes.NewReader(content[4:]), recovery)
+	case Version2:
+		return readIndexed(content, owner, recovery)
+	default:
+		return ruleSet, E.New("unsupported version: ", version)
+	}
+}
+
+func readHeader(reader io.Reader) (version uint8, err error) {
 	var magicBytes [3]byte
 	_, err = io.ReadFull(reader, magicBytes[:])
 	if err != nil {
@@ -48,25 +127,30 @@ func Read(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err err
 		err = E.New("invalid sing-box rule set file")
 		return
 	}
-	var version uint8
 	err = binary.Read(reader, binary.BigEndian, &version)
-	if err != nil {
-		return ruleSet, err
-	}
-	if version != 1 {
-		return ruleSet, E.New("unsupported version: ", version)
-	}
+	return
+}
+
+func readCompressed(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err error) {
 	zReader, err := zlib.NewReader(reader)
 	if err != nil {
 		return
 	}
-	length, err := rw.ReadUVariant(zReader)
+	return readRules(zReader, recovery)
+}
+
+func readIndexed(content []byte, owner any, recovery bool) (ruleSet option.PlainRuleSet, err error) {
+	return readRules(&indexReader{content: content, offset: 4, owner: owner}, recovery)
+}
+
+func readRules(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err error) {
+	length, err := readLength(reader)
 	if err != nil {
 		return
 	}
 	ruleSet.Rules = make([]option.HeadlessRule, length)
 	for i := uint64(0); i < length; i++ {
-		ruleSet.Rules[i], err = readRule(zReader, recovery)
+		ruleSet.Rules[i], err = readRule(reader, recovery)
 		if err != nil {
 			err = E.Cause(err, "read rule[", i, "]")
 			return
@@ -75,30 +159,83 @@ func Read(reader io.Reader, recovery bool) (ruleSet option.PlainRuleSet, err err
 	return
 }
 
-func Write(writer io.Writer, ruleSet option.PlainRuleSet) error {
+func Write(writer io.Writer, ruleSet option.PlainRuleSet, version uint8) error {
+	if version != Version1 && version != Version2 {
+		return E.New("unsupported version: ", version)
+	}
+	if version < RequiredVersion(ruleSet) {
+		return E.New("time_range, weekday and timezone items require version ", Version2)
+	}
 	_, err := writer.Write(MagicBytes[:])
 	if err != nil {
 		return err
 	}
-	err = binary.Write(writer, binary.BigEndian, uint8(1))
+	err = binary.Write(writer, binary.BigEndian, version)
 	if err != nil {
 		return err
 	}
-	zWriter, err := zlib.NewWriterLevel(writer, zlib.BestCompression)
-	if err != nil {
+	switch version {
+	case Version1:
+		zWriter, err := zlib.NewWriterLevel(writer, zlib.BestCompression)
+		if err != nil {
+			return err
+		}
+		err = writeRules(zWriter, ruleSet)
+		if err != nil {
+			return err
+		}
+		return zWriter.Close()
+	default:
+		// Offsets of indexed items are aligned from the start of the file.
+		indexWriter := &indexWriter{}
+		indexWriter.Write(MagicBytes[:])
+		indexWriter.WriteByte(version)
+		err = writeRules(indexWriter, ruleSet)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(indexWriter.Bytes()[4:])
 		return err
 	}
-	err = rw.WriteUVariant(zWriter, uint64(len(ruleSet.Rules)))
+}
+
+// RequiredVersion returns the lowest version able to store the rule-set,
+// time items are unknown to readers of version 1 and only written since version 2.
+func RequiredVersion(ruleSet option.PlainRuleSet) uint8 {
+	for _, rule := range ruleSet.Rules {
+		if hasTimeItems(rule) {
+			return Version2
+		}
+	}
+	return Version1
+}
+
+func hasTimeItems(rule option.HeadlessRule) bool {
+	switch rule.Type {
+	case C.RuleTypeDefault:
+		return len(rule.DefaultOptions.TimeRange) > 0 || len(rule.DefaultOptions.Weekday) > 0 || rule.DefaultOptions.Timezone != ""
+	case C.RuleTypeLogical:
+		for _, subRule := range rule.LogicalOptions.Rules {
+			if hasTimeItems(subRule) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func writeRules(writer io.Writer, ruleSet option.PlainRuleSet) error {
+	err := rw.WriteUVariant(writer, uint64(len(ruleSet.Rules)))
 	if err != nil {
 		return err
 	}
 	for _, rule := range ruleSet.Rules {
-		err = writeRule(zWriter, rule)
+		err = writeRule(writer, rule)
 		if err != nil {
 			return err
 		}
 	}
-	return zWriter.Close()
+	return nil
 }
 
 func readRule(reader io.Reader, recovery bool) (rule option.HeadlessRule, err error) {
@@ -152,32 +289,35 @@ func readDefaultRule(reader io.Reader, recovery bool) (rule option.DefaultHeadle
 		case ruleItemNetwork:
 			rule.Network, err = readRuleItemString(reader)
 		case ruleItemDomain:
-			var matcher *domain.Matcher
-			matcher, err = domain.ReadMatcher(reader)
-			if err != nil {
-				return
+			if indexReader, isIndexed := reader.(*indexReader); isIndexed {
+				var matcher *indexedDomainMatcher
+				matcher, err = indexReader.readDomainMatcher()
+				if err != nil {
+					return
+				}
+				rule.DomainMatcher = matcher
+				if recovery {
+					rule.Domain, rule.DomainSuffix, err = matcher.Dump()
+				}
+			} else {
+				var matcher *domain.Matcher
+				matcher, err = domain.ReadMatcher(reader)
+				if err != nil {
+					return
+				}
+				rule.DomainMatcher = matcher
+				if recovery {
+					rule.Domain, rule.DomainSuffix, err = dumpDomainMatcher(matcher)
+				}
 			}
-			rule.DomainMatcher = matcher
 		case ruleItemDomainKeyword:
 			rule.DomainKeyword, err = readRuleItemString(reader)
 		case ruleItemDomainRegex:
 			rule.DomainRegex, err = readRuleItemString(reader)
 		case ruleItemSourceIPCIDR:
-			rule.SourceIPSet, err = readIPSet(reader)
-			if err != nil {
-				return
-			}
-			if recovery {
-				rule.SourceIPCIDR = common.Map(rule.SourceIPSet.Prefixes(), netip.Prefix.String)
-			}
+			rule.SourceIPSet, rule.SourceIPCIDR, err = readRuleItemCIDR(reader, recovery)
 		case ruleItemIPCIDR:
-			rule.IPSet, err = readIPSet(reader)
-			if err != nil {
-				return
-			}
-			if recovery {
-				rule.IPCIDR = common.Map(rule.IPSet.Prefixes(), netip.Prefix.String)
-			}
+			rule.IPSet, rule.IPCIDR, err = readRuleItemCIDR(reader, recovery)
 		case ruleItemSourcePort:
 			rule.SourcePort, err = readRuleItemUint16(reader)
 		case ruleItemSourcePortRange:
@@ -196,6 +336,16 @@ func readDefaultRule(reader io.Reader, recovery bool) (rule option.DefaultHeadle
 			rule.WIFISSID, err = readRuleItemString(reader)
 		case ruleItemWIFIBSSID:
 			rule.WIFIBSSID, err = readRuleItemString(reader)
+		case ruleItemTimeRange:
+			rule.TimeRange, err = readRuleItemString(reader)
+		case ruleItemWeekday:
+			rule.Weekday, err = readRuleItemString(reader)
+		case ruleItemTimezone:
+			var timezone []string
+			timezone, err = readRuleItemString(reader)
+			if err == nil && len(timezone) > 0 {
+				rule.Timezone = timezone[0]
+			}
 		case ruleItemFinal:
 			err = binary.Read(reader, binary.BigEndian, &rule.Invert)
 			return
@@ -233,7 +383,11 @@ func writeDefaultRule(writer io.Writer, rule option.DefaultHeadlessRule) error {
 		if err != nil {
 			return err
 		}
-		err = domain.NewMatcher(rule.Domain, rule.DomainSuffix).Write(writer)
+		if indexWriter, isIndexed := writer.(*indexWriter); isIndexed {
+			err = indexWriter.writeDomainMatcher(rule.Domain, rule.DomainSuffix)
+		} else {
+			err = domain.NewMatcher(rule.Domain, rule.DomainSuffix).Write(writer)
+		}
 		if err != nil {
 			return err
 		}
@@ -316,6 +470,24 @@ func writeDefaultRule(writer io.Writer, rule option.DefaultHeadlessRule) error {
 			return err
 		}
 	}
+	if len(rule.TimeRange) > 0 {
+		err = writeRuleItemString(writer, ruleItemTimeRange, rule.TimeRange)
+		if err != nil {
+			return err
+		}
+	}
+	if len(rule.Weekday) > 0 {
+		err = writeRuleItemString(writer, ruleItemWeekday, rule.Weekday)
+		if err != nil {
+			return err
+		}
+	}
+	if rule.Timezone != "" {
+		err = writeRuleItemString(writer, ruleItemTimezone, []string{rule.Timezone})
+		if err != nil {
+			return err
+		}
+	}
 	err = binary.Write(writer, binary.BigEndian, ruleItemFinal)
 	if err != nil {
 		return err
@@ -327,14 +499,42 @@ func writeDefaultRule(writer io.Writer, rule option.DefaultHeadlessRule) error {
 	return nil
 }
 
-func readRuleItemString(reader io.Reader) ([]string, error) {
+// readLength reads the count of following items, which are at least one byte each,
+// so that a corrupted indexed rule-set can not make it allocate more than its size.
+func readLength(reader io.Reader) (uint64, error) {
 	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return 0, err
+	}
+	if indexReader, isIndexed := reader.(*indexReader); isIndexed && length > uint64(len(indexReader.content)-indexReader.offset) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	return length, nil
+}
+
+func readString(reader io.Reader) (string, error) {
+	if indexReader, isIndexed := reader.(*indexReader); isIndexed {
+		length, err := rw.ReadUVariant(indexReader)
+		if err != nil {
+			return "", err
+		}
+		content, err := indexReader.next(length)
+		if err != nil {
+			return "", err
+		}
+		return string(content), nil
+	}
+	return rw.ReadVString(reader)
+}
+
+func readRuleItemString(reader io.Reader) ([]string, error) {
+	length, err := readLength(reader)
 	if err != nil {
 		return nil, err
 	}
 	value := make([]string, length)
 	for i := uint64(0); i < length; i++ {
-		value[i], err = rw.ReadVString(reader)
+		value[i], err = readString(reader)
 		if err != nil {
 			return nil, err
 		}
@@ -361,7 +561,7 @@ func writeRuleItemString(writer io.Writer, itemType uint8, value []string) error
 }
 
 func readRuleItemUint16(reader io.Reader) ([]uint16, error) {
-	length, err := rw.ReadUVariant(reader)
+	length, err := readLength(reader)
 	if err != nil {
 		return nil, err
 	}
@@ -416,9 +616,41 @@ func writeRuleItemCIDR(writer io.Writer, itemType uint8, value []string) error {
 	if err != nil {
 		return err
 	}
+	if indexWriter, isIndexed := writer.(*indexWriter); isIndexed {
+		return indexWriter.writeIPSet(ipSet)
+	}
 	return writeIPSet(writer, ipSet)
 }
 
+func readRuleItemCIDR(reader io.Reader, recovery bool) (ipSet option.IPSet, prefixes []string, err error) {
+	var rawIPSet *netipx.IPSet
+	if indexReader, isIndexed := reader.(*indexReader); isIndexed {
+		var indexedIPSet *indexedIPSet
+		indexedIPSet, err = indexReader.readIPSet()
+		if err != nil {
+			return
+		}
+		ipSet = indexedIPSet
+		if !recovery {
+			return
+		}
+		rawIPSet, err = indexedIPSet.IPSet()
+		if err != nil {
+			return
+		}
+	} else {
+		rawIPSet, err = readIPSet(reader)
+		if err != nil {
+			return
+		}
+		ipSet = rawIPSet
+	}
+	if recovery {
+		prefixes = common.Map(rawIPSet.Prefixes(), netip.Prefix.String)
+	}
+	return
+}
+
 func readLogicalRule(reader io.Reader, recovery bool) (logicalRule option.LogicalHeadlessRule, err error) {
 	var mode uint8
 	err = binary.Read(reader, binary.BigEndian, &mode)
@@ -434,7 +666,7 @@ func readLogicalRule(reader io.Reader, recovery bool) (logicalRule option.Logica
 		err = E.New("unknown logical mode: ", mode)
 		return
 	}
-	length, err := rw.ReadUVariant(reader)
+	length, err := readLength(reader)
 	if err != nil {
 		return
 	}
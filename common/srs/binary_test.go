@@ -0,0 +1,182 @@
+package srs_test
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testRuleSet() option.PlainRuleSet {
+	return option.PlainRuleSet{
+		Rules: []option.HeadlessRule{
+			{
+				Type: C.RuleTypeDefault,
+				DefaultOptions: option.DefaultHeadlessRule{
+					Domain:        []string{"example.org", "www.example.com"},
+					DomainSuffix:  []string{".cn", "example.net", "google.com"},
+					DomainKeyword: []string{"ads"},
+					IPCIDR:        []string{"10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32"},
+				},
+			},
+		},
+	}
+}
+
+func TestRuleSetRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, version := range []uint8{srs.Version1, srs.Version2} {
+		buffer := new(bytes.Buffer)
+		require.NoError(t, srs.Write(buffer, testRuleSet(), version))
+		require.Equal(t, append(srs.MagicBytes[:], version), buffer.Bytes()[:4])
+
+		ruleSet, err := srs.ReadBytes(buffer.Bytes(), true)
+		require.NoError(t, err)
+		require.Len(t, ruleSet.Rules, 1)
+		rule := ruleSet.Rules[0].DefaultOptions
+		require.Equal(t, option.Listable[string]{"example.org", "www.example.com"}, rule.Domain, "version %d", version)
+		require.Equal(t, option.Listable[string]{".cn", "example.net", "google.com"}, rule.DomainSuffix, "version %d", version)
+		require.Equal(t, option.Listable[string]{"ads"}, rule.DomainKeyword, "version %d", version)
+		require.Equal(t, option.Listable[string]{"10.0.0.0/8", "192.168.1.0/24", "2001:db8::/32"}, rule.IPCIDR, "version %d", version)
+
+		ruleSet, err = srs.Read(bytes.NewReader(buffer.Bytes()), false)
+		require.NoError(t, err)
+		rule = ruleSet.Rules[0].DefaultOptions
+		for _, testCase := range []struct {
+			domain string
+			match  bool
+		}{
+			{"example.org", true},
+			{"www.example.org", false},
+			{"www.example.com", true},
+			{"example.com", false},
+			{"example.net", true},
+			{"www.example.net", true},
+			{"badexample.net", false},
+			{"cn", false},
+			{"www.gov.cn", true},
+			{"google.com", true},
+			{"mail.google.com", true},
+			{"google.com.hk", false},
+			{"", false},
+		} {
+			require.Equal(t, testCase.match, rule.DomainMatcher.Match(testCase.domain), "version %d: %s", version, testCase.domain)
+		}
+		for _, testCase := range []struct {
+			address string
+			match   bool
+		}{
+			{"10.1.2.3", true},
+			{"11.0.0.0", false},
+			{"192.168.1.255", true},
+			{"192.168.2.1", false},
+			{"2001:db8::1", true},
+			{"2001:db9::1", false},
+			{"::ffff:10.0.0.1", false},
+		} {
+			require.Equal(t, testCase.match, rule.IPSet.Contains(netip.MustParseAddr(testCase.address)), "version %d: %s", version, testCase.address)
+		}
+	}
+}
+
+func TestRuleSetWriteUnsupportedVersion(t *testing.T) {
+	t.Parallel()
+	for _, version := range []uint8{0, srs.Version2 + 1} {
+		buffer := new(bytes.Buffer)
+		require.Error(t, srs.Write(buffer, testRuleSet(), version))
+		require.Zero(t, buffer.Len())
+	}
+}
+
+func TestRuleSetTimeItems(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name string
+		rule option.HeadlessRule
+	}{
+		{"time_range", option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{TimeRange: []string{"22:00-06:00"}}}},
+		{"weekday", option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Weekday: []string{"sat", "sun"}}}},
+		{"timezone", option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Network: []string{"tcp"}, Timezone: "Asia/Shanghai"}}},
+		{"logical", option.HeadlessRule{Type: C.RuleTypeLogical, LogicalOptions: option.LogicalHeadlessRule{
+			Mode:  C.LogicalTypeAnd,
+			Rules: []option.HeadlessRule{{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Network: []string{"udp"}}}, {Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Weekday: []string{"mon"}}}},
+		}}},
+	} {
+		ruleSet := option.PlainRuleSet{Rules: []option.HeadlessRule{testCase.rule}}
+		require.Equal(t, srs.Version2, srs.RequiredVersion(ruleSet), testCase.name)
+		buffer := new(bytes.Buffer)
+		require.Error(t, srs.Write(buffer, ruleSet, srs.Version1), testCase.name)
+		require.Zero(t, buffer.Len(), testCase.name)
+		require.NoError(t, srs.Write(buffer, ruleSet, srs.Version2), testCase.name)
+		decoded, err := srs.ReadBytes(buffer.Bytes(), true)
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, ruleSet, decoded, testCase.name)
+	}
+	require.Equal(t, srs.Version1, srs.RequiredVersion(testRuleSet()))
+}
+
+func TestIndexedRuleSetCorrupted(t *testing.T) {
+	t.Parallel()
+	buffer := new(bytes.Buffer)
+	require.NoError(t, srs.Write(buffer, testRuleSet(), srs.Version2))
+	content := buffer.Bytes()
+	for _, length := range []int{4, 8, len(content) / 2, len(content) - 1} {
+		_, err := srs.ReadBytes(content[:length], false)
+		require.Error(t, err, "truncated to %d", length)
+	}
+	// A corrupted file must be rejected or matched without panicking.
+	for offset := 4; offset < len(content); offset++ {
+		for _, mask := range []byte{0x01, 0x80, 0xFF} {
+			corrupted := bytes.Clone(content)
+			corrupted[offset] ^= mask
+			ruleSet, err := srs.ReadBytes(corrupted, false)
+			if err != nil {
+				continue
+			}
+			for _, rule := range ruleSet.Rules {
+				if rule.DefaultOptions.DomainMatcher != nil {
+					for _, domain := range []string{"example.org", "www.example.com", "www.gov.cn", "mail.google.com", "a.b.c.d.e"} {
+						rule.DefaultOptions.DomainMatcher.Match(domain)
+					}
+				}
+				if rule.DefaultOptions.IPSet != nil {
+					rule.DefaultOptions.IPSet.Contains(netip.MustParseAddr("10.1.2.3"))
+					rule.DefaultOptions.IPSet.Contains(netip.MustParseAddr("2001:db8::1"))
+				}
+			}
+		}
+	}
+}
+
+func TestRuleSetReadFile(t *testing.T) {
+	t.Parallel()
+	for _, version := range []uint8{srs.Version1, srs.Version2} {
+		directory := t.TempDir()
+		path := filepath.Join(directory, "test.srs")
+		buffer := new(bytes.Buffer)
+		require.NoError(t, srs.Write(buffer, testRuleSet(), version))
+		require.NoError(t, os.WriteFile(path, buffer.Bytes(), 0o644))
+
+		ruleSet, err := srs.ReadFile(path, false)
+		require.NoError(t, err, "version %d", version)
+		// Rewriting the file in place must not change the loaded rule-set.
+		require.NoError(t, os.WriteFile(path, []byte("SRS"), 0o644))
+		rule := ruleSet.Rules[0].DefaultOptions
+		require.True(t, rule.DomainMatcher.Match("www.example.com"), "version %d", version)
+		require.False(t, rule.DomainMatcher.Match("example.com"), "version %d", version)
+		require.True(t, rule.IPSet.Contains(netip.MustParseAddr("10.1.2.3")), "version %d", version)
+
+		entries, err := os.ReadDir(directory)
+		require.NoError(t, err)
+		require.Len(t, entries, 1, "version %d", version)
+		_, err = srs.ReadFile(path, false)
+		require.Error(t, err, "version %d", version)
+	}
+}
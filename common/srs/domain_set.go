@@ -0,0 +1,132 @@
+package srs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/sagernet/sing/common/domain"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/rw"
+)
+
+// domainPrefixLabel marks the end of a domain suffix in the keys of a domain matcher.
+const domainPrefixLabel = '\r'
+
+// dumpDomainMatcher recovers the domains and domain suffixes of a domain matcher.
+func dumpDomainMatcher(matcher *domain.Matcher) (domains []string, domainSuffix []string, err error) {
+	leaves, labelBitmap, labels, err := readMatcherTrie(matcher)
+	if err != nil {
+		return
+	}
+	return dumpSuccinctSet(leaves, labelBitmap, labels)
+}
+
+// readMatcherTrie returns the succinct trie of a domain matcher from its serialized form.
+func readMatcherTrie(matcher *domain.Matcher) (leaves []uint64, labelBitmap []uint64, labels []byte, err error) {
+	buffer := new(bytes.Buffer)
+	err = matcher.Write(buffer)
+	if err != nil {
+		return
+	}
+	var version uint8
+	err = binary.Read(buffer, binary.BigEndian, &version)
+	if err != nil {
+		return
+	}
+	if version != 1 {
+		err = E.New("unsupported domain matcher version: ", version)
+		return
+	}
+	leaves, err = readUint64Slice(buffer)
+	if err != nil {
+		return
+	}
+	labelBitmap, err = readUint64Slice(buffer)
+	if err != nil {
+		return
+	}
+	labelsLength, err := rw.ReadUVariant(buffer)
+	if err != nil {
+		return
+	}
+	labels = make([]byte, labelsLength)
+	_, err = io.ReadFull(buffer, labels)
+	return
+}
+
+// dumpSuccinctSet recovers the domains and domain suffixes of a succinct trie, in which keys are reversed domains.
+func dumpSuccinctSet(leaves []uint64, labelBitmap []uint64, labels []byte) (domains []string, domainSuffix []string, err error) {
+	// Nodes are stored in breadth-first order, each as a zero bit per child followed by a one bit,
+	// the n-th zero bit leads to node n+1 with the n-th label.
+	prefixes := [][]byte{nil}
+	var keys []string
+	var node, child int
+	for index := 0; index < len(labelBitmap)*64 && node < len(prefixes); index++ {
+		if getBit(labelBitmap, index) != 0 {
+			if getBit(leaves, node) != 0 {
+				keys = append(keys, string(prefixes[node]))
+			}
+			node++
+			continue
+		}
+		if child >= len(labels) {
+			return nil, nil, E.New("invalid domain matcher")
+		}
+		prefix := make([]byte, len(prefixes[node])+1)
+		copy(prefix, prefixes[node])
+		prefix[len(prefix)-1] = labels[child]
+		prefixes = append(prefixes, prefix)
+		child++
+	}
+	exactDomains := make(map[string]bool)
+	suffixDomains := make(map[string]bool)
+	for _, key := range keys {
+		if key != "" && key[len(key)-1] == domainPrefixLabel {
+			suffixDomains[reverseDomain(key[:len(key)-1])] = true
+		} else {
+			exactDomains[reverseDomain(key)] = true
+		}
+	}
+	// A domain suffix without a leading dot is stored as the domain and the suffix with the dot.
+	for suffix := range suffixDomains {
+		if len(suffix) > 1 && suffix[0] == '.' && exactDomains[suffix[1:]] {
+			delete(exactDomains, suffix[1:])
+			domainSuffix = append(domainSuffix, suffix[1:])
+		} else {
+			domainSuffix = append(domainSuffix, suffix)
+		}
+	}
+	for exactDomain := range exactDomains {
+		domains = append(domains, exactDomain)
+	}
+	sort.Strings(domains)
+	sort.Strings(domainSuffix)
+	return
+}
+
+func readUint64Slice(reader io.Reader) ([]uint64, error) {
+	length, err := rw.ReadUVariant(reader)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]uint64, length)
+	err = binary.Read(reader, binary.BigEndian, values)
+	if err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+func reverseDomain(domain string) string {
+	length := len(domain)
+	reversed := make([]byte, length)
+	for i := 0; i < length; {
+		r, n := utf8.DecodeRuneInString(domain[i:])
+		i += n
+		utf8.EncodeRune(reversed[length-i:], r)
+	}
+	return string(reversed)
+}
@@ -0,0 +1,49 @@
+package srs_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sagernet/sing-box/common/srs"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainMatcherDump(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name         string
+		domain       []string
+		domainSuffix []string
+	}{
+		{"domain", []string{"a.com", "b.a.com", "example.org"}, nil},
+		{"suffix", nil, []string{"a.com", "example.org"}},
+		{"dot suffix", nil, []string{".a.com", ".org"}},
+		{"mixed", []string{"b.a.com", "example.org"}, []string{".example.net", "a.com", "cn"}},
+		{"single label", []string{"localhost"}, []string{"lan"}},
+		{"unicode", []string{"中国.cn"}, []string{"例子.测试"}},
+	} {
+		for _, version := range []uint8{srs.Version1, srs.Version2} {
+			buffer := new(bytes.Buffer)
+			require.NoError(t, srs.Write(buffer, option.PlainRuleSet{
+				Rules: []option.HeadlessRule{{
+					Type: C.RuleTypeDefault,
+					DefaultOptions: option.DefaultHeadlessRule{
+						Domain:       testCase.domain,
+						DomainSuffix: testCase.domainSuffix,
+					},
+				}},
+			}, version))
+			ruleSet, err := srs.ReadBytes(buffer.Bytes(), true)
+			require.NoError(t, err)
+			rule := ruleSet.Rules[0].DefaultOptions
+			require.Equal(t, option.Listable[string](testCase.domain), rule.Domain, "%s version %d", testCase.name, version)
+			require.Equal(t, option.Listable[string](testCase.domainSuffix), rule.DomainSuffix, "%s version %d", testCase.name, version)
+			for _, domain := range testCase.domain {
+				require.True(t, rule.DomainMatcher.Match(domain), "%s version %d: %s", testCase.name, version, domain)
+			}
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package srs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/netip"
+	"sort"
+	"unsafe"
+
+	"github.com/sagernet/sing/common/domain"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/rw"
+
+	"go4.org/netipx"
+)
+
+// The indexed format (version 2) is not compressed, domain items are stored as succinct tries
+// with their rank and select indexes and CIDR items as sorted ranges, all aligned to 8 bytes
+// from the start of the file, so that they are matched directly on the content of the file.
+
+const indexedAlignment = 8
+
+var nativeLittleEndian = func() bool {
+	value := uint16(1)
+	return *(*byte)(unsafe.Pointer(&value)) == 1
+}()
+
+// indexWriter writes an indexed rule-set to memory, as items are aligned to the start of the file.
+type indexWriter struct {
+	bytes.Buffer
+}
+
+func (w *indexWriter) align() {
+	if padding := w.Len() % indexedAlignment; padding > 0 {
+		w.Write(make([]byte, indexedAlignment-padding))
+	}
+}
+
+func (w *indexWriter) writeDomainMatcher(domains []string, domainSuffix []string) error {
+	leaves, labelBitmap, labels, err := readMatcherTrie(domain.NewMatcher(domains, domainSuffix))
+	if err != nil {
+		return err
+	}
+	selects, ranks := indexSelect32R64(labelBitmap)
+	for _, length := range []int{len(leaves), len(labelBitmap), len(selects), len(ranks), len(labels)} {
+		err = rw.WriteUVariant(w, uint64(length))
+		if err != nil {
+			return err
+		}
+	}
+	w.align()
+	for _, values := range [][]uint64{leaves, labelBitmap} {
+		err = binary.Write(w, binary.LittleEndian, values)
+		if err != nil {
+			return err
+		}
+	}
+	for _, values := range [][]int32{selects, ranks} {
+		err = binary.Write(w, binary.LittleEndian, values)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(labels)
+	return err
+}
+
+func (w *indexWriter) writeIPSet(ipSet *netipx.IPSet) error {
+	var ranges4, ranges6 []netipx.IPRange
+	for _, ipRange := range ipSet.Ranges() {
+		if ipRange.From().Is4() {
+			ranges4 = append(ranges4, ipRange)
+		} else {
+			ranges6 = append(ranges6, ipRange)
+		}
+	}
+	for _, ranges := range [][]netipx.IPRange{ranges4, ranges6} {
+		err := rw.WriteUVariant(w, uint64(len(ranges)))
+		if err != nil {
+			return err
+		}
+		for _, ipRange := range ranges {
+			w.Write(ipRange.From().AsSlice())
+			w.Write(ipRange.To().AsSlice())
+		}
+	}
+	return nil
+}
+
+// indexReader reads an indexed rule-set, matchers refer to its content and keep its owner alive.
+type indexReader struct {
+	content []byte
+	offset  int
+	owner   any
+}
+
+func (r *indexReader) Read(p []byte) (n int, err error) {
+	if r.offset >= len(r.content) {
+		return 0, io.EOF
+	}
+	n = copy(p, r.content[r.offset:])
+	r.offset += n
+	return
+}
+
+func (r *indexReader) ReadByte() (byte, error) {
+	if r.offset >= len(r.content) {
+		return 0, io.EOF
+	}
+	value := r.content[r.offset]
+	r.offset++
+	return value, nil
+}
+
+func (r *indexReader) align() {
+	if padding := r.offset % indexedAlignment; padding > 0 {
+		r.offset += indexedAlignment - padding
+	}
+}
+
+func (r *indexReader) next(length uint64) ([]byte, error) {
+	if length > uint64(len(r.content)-r.offset) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	content := r.content[r.offset : r.offset+int(length)]
+	r.offset += int(length)
+	return content, nil
+}
+
+func (r *indexReader) readDomainMatcher() (*indexedDomainMatcher, error) {
+	var lengths [5]uint64
+	for i := range lengths {
+		length, err := rw.ReadUVariant(r)
+		if err != nil {
+			return nil, err
+		}
+		lengths[i] = length
+	}
+	r.align()
+	var (
+		uint64Slices [2][]uint64
+		int32Slices  [2][]int32
+	)
+	for i := range uint64Slices {
+		content, err := r.next(lengths[i] * 8)
+		if err != nil {
+			return nil, err
+		}
+		uint64Slices[i] = bytesToUint64s(content)
+	}
+	for i := range int32Slices {
+		content, err := r.next(lengths[2+i] * 4)
+		if err != nil {
+			return nil, err
+		}
+		int32Slices[i] = bytesToInt32s(content)
+	}
+	labels, err := r.next(lengths[4])
+	if err != nil {
+		return nil, err
+	}
+	matcher := &indexedDomainMatcher{
+		set: succinctSet{
+			leaves:      uint64Slices[0],
+			labelBitmap: uint64Slices[1],
+			labels:      labels,
+			selects:     int32Slices[0],
+			ranks:       int32Slices[1],
+		},
+		owner: r.owner,
+	}
+	err = matcher.set.validate()
+	if err != nil {
+		return nil, err
+	}
+	return matcher, nil
+}
+
+func (r *indexReader) readIPSet() (*indexedIPSet, error) {
+	ipSet := &indexedIPSet{owner: r.owner}
+	for i, addressLength := range []uint64{4, 16} {
+		length, err := rw.ReadUVariant(r)
+		if err != nil {
+			return nil, err
+		}
+		ranges, err := r.next(length * addressLength * 2)
+		if err != nil {
+			return nil, err
+		}
+		err = validateRanges(ranges, int(addressLength))
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			ipSet.ranges4 = ranges
+		} else {
+			ipSet.ranges6 = ranges
+		}
+	}
+	return ipSet, nil
+}
+
+// validateRanges checks that address ranges are sorted and do not overlap, as they are binary searched.
+func validateRanges(ranges []byte, addressLength int) error {
+	var previous []byte
+	for offset := 0; offset < len(ranges); offset += addressLength * 2 {
+		from := ranges[offset : offset+addressLength]
+		to := ranges[offset+addressLength : offset+addressLength*2]
+		if bytes.Compare(from, to) > 0 || previous != nil && bytes.Compare(previous, from) >= 0 {
+			return E.New("invalid CIDR index: unsorted ranges")
+		}
+		previous = to
+	}
+	return nil
+}
+
+type indexedDomainMatcher struct {
+	set   succinctSet
+	owner any
+}
+
+func (m *indexedDomainMatcher) Match(domain string) bool {
+	return m.set.Has(reverseDomain(domain))
+}
+
+func (m *indexedDomainMatcher) Dump() (domains []string, domainSuffix []string, err error) {
+	return dumpSuccinctSet(m.set.leaves, m.set.labelBitmap, m.set.labels)
+}
+
+// indexedIPSet is a set of sorted and non-overlapping address ranges, IPv4 ranges are 8 bytes
+// and IPv6 ranges are 32 bytes, each the first address followed by the last address.
+type indexedIPSet struct {
+	ranges4 []byte
+	ranges6 []byte
+	owner   any
+}
+
+func (s *indexedIPSet) Contains(addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	var (
+		ranges  []byte
+		address []byte
+	)
+	if addr.Is4() {
+		ranges = s.ranges4
+		address4 := addr.As4()
+		address = address4[:]
+	} else {
+		ranges = s.ranges6
+		address16 := addr.As16()
+		address = address16[:]
+	}
+	addressLength := len(address)
+	rangeLength := addressLength * 2
+	count := len(ranges) / rangeLength
+	index := sort.Search(count, func(i int) bool {
+		to := ranges[i*rangeLength+addressLength : (i+1)*rangeLength]
+		return bytes.Compare(to, address) >= 0
+	})
+	if index == count {
+		return false
+	}
+	from := ranges[index*rangeLength : index*rangeLength+addressLength]
+	return bytes.Compare(from, address) <= 0
+}
+
+func (s *indexedIPSet) IPSet() (*netipx.IPSet, error) {
+	var builder netipx.IPSetBuilder
+	for i, ranges := range [][]byte{s.ranges4, s.ranges6} {
+		addressLength := 4
+		if i == 1 {
+			addressLength = 16
+		}
+		for offset := 0; offset+addressLength*2 <= len(ranges); offset += addressLength * 2 {
+			from, _ := netip.AddrFromSlice(ranges[offset : offset+addressLength])
+			to, _ := netip.AddrFromSlice(ranges[offset+addressLength : offset+addressLength*2])
+			builder.AddRange(netipx.IPRangeFrom(from, to))
+		}
+	}
+	return builder.IPSet()
+}
+
+// bytesToUint64s returns the little-endian values of content, without copying if possible.
+func bytesToUint64s(content []byte) []uint64 {
+	if len(content) == 0 {
+		return nil
+	}
+	if nativeLittleEndian && uintptr(unsafe.Pointer(&content[0]))%8 == 0 {
+		return unsafe.Slice((*uint64)(unsafe.Pointer(&content[0])), len(content)/8)
+	}
+	values := make([]uint64, len(content)/8)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint64(content[i*8:])
+	}
+	return values
+}
+
+// bytesToInt32s returns the little-endian values of content, without copying if possible.
+func bytesToInt32s(content []byte) []int32 {
+	if len(content) == 0 {
+		return nil
+	}
+	if nativeLittleEndian && uintptr(unsafe.Pointer(&content[0]))%4 == 0 {
+		return unsafe.Slice((*int32)(unsafe.Pointer(&content[0])), len(content)/4)
+	}
+	values := make([]int32, len(content)/4)
+	for i := range values {
+		values[i] = int32(binary.LittleEndian.Uint32(content[i*4:]))
+	}
+	return values
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin)
+
+package srs
+
+import "os"
+
+func mapFile(path string) ([]byte, any, error) {
+	content, err := os.ReadFile(path)
+	return content, nil, err
+}
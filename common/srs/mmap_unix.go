@@ -0,0 +1,54 @@
+//go:build linux || darwin
+
+package srs
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"golang.org/x/sys/unix"
+)
+
+// mappedFile is the owner of a memory-mapped rule-set, it is unmapped once no matcher refers to it.
+type mappedFile struct {
+	content []byte
+}
+
+// mapFile maps a private copy of path, created next to it and unlinked at once, so that the file
+// can be rewritten in place or truncated without changing what matchers read. The content is loaded
+// into memory if the copy can not be created.
+func mapFile(path string) ([]byte, any, error) {
+	source, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer source.Close()
+	copyFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		content, err := io.ReadAll(source)
+		return content, nil, err
+	}
+	defer copyFile.Close()
+	err = os.Remove(copyFile.Name())
+	if err != nil {
+		return nil, nil, err
+	}
+	size, err := io.Copy(copyFile, source)
+	if err != nil {
+		return nil, nil, err
+	}
+	if size == 0 {
+		return nil, nil, nil
+	}
+	content, err := unix.Mmap(int(copyFile.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return nil, nil, err
+	}
+	owner := &mappedFile{content}
+	runtime.SetFinalizer(owner, func(it *mappedFile) {
+		unix.Munmap(it.content)
+	})
+	return content, owner, nil
+}
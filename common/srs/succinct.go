@@ -0,0 +1,206 @@
+package srs
+
+import (
+	"math"
+	"math/bits"
+
+	E "github.com/sagernet/sing/common/exceptions"
+)
+
+// succinctSet is the succinct trie of a domain matcher, with the rank and select
+// indexes stored in the rule-set as well, so that it is used directly on the content of the file.
+// mod from https://github.com/openacid/succinct
+type succinctSet struct {
+	leaves, labelBitmap []uint64
+	labels              []byte
+	ranks, selects      []int32
+}
+
+func (ss *succinctSet) Has(key string) bool {
+	var nodeId, bmIdx int
+	for i := 0; i < len(key); i++ {
+		currentChar := key[i]
+		for ; ; bmIdx++ {
+			if getBit(ss.labelBitmap, bmIdx) != 0 {
+				return false
+			}
+			nextLabel := ss.labels[bmIdx-nodeId]
+			if nextLabel == domainPrefixLabel {
+				return true
+			}
+			if nextLabel == currentChar {
+				break
+			}
+		}
+		nodeId = countZeros(ss.labelBitmap, ss.ranks, bmIdx+1)
+		bmIdx = selectIthOne(ss.labelBitmap, ss.ranks, ss.selects, nodeId-1) + 1
+	}
+	if getBit(ss.leaves, nodeId) != 0 {
+		return true
+	}
+	for ; ; bmIdx++ {
+		if getBit(ss.labelBitmap, bmIdx) != 0 {
+			return false
+		}
+		if ss.labels[bmIdx-nodeId] == domainPrefixLabel {
+			return true
+		}
+	}
+}
+
+// validate checks the indexes and labels read from a rule-set against the label bitmap,
+// so that a corrupted file is rejected when loaded instead of panicking when matched.
+func (ss *succinctSet) validate() error {
+	if len(ss.labelBitmap) == 0 || len(ss.labelBitmap) > math.MaxInt32>>6 {
+		return E.New("invalid domain index: bad label bitmap length")
+	}
+	if len(ss.ranks) != len(ss.labelBitmap)+1 {
+		return E.New("invalid domain index: bad rank index length")
+	}
+	var (
+		ones    int32
+		lastOne int32
+	)
+	for i, word := range ss.labelBitmap {
+		if ss.ranks[i] != ones {
+			return E.New("invalid domain index: bad rank index")
+		}
+		for w := word; w != 0; w &= w - 1 {
+			lastOne = int32(i<<6 + bits.TrailingZeros64(w))
+			if ones&31 == 0 && (int(ones>>5) >= len(ss.selects) || ss.selects[ones>>5] != lastOne) {
+				return E.New("invalid domain index: bad select index")
+			}
+			ones++
+		}
+	}
+	if ss.ranks[len(ss.labelBitmap)] != ones {
+		return E.New("invalid domain index: bad rank index")
+	}
+	if ones == 0 || len(ss.selects) != int(ones+31)>>5 {
+		return E.New("invalid domain index: bad select index length")
+	}
+	// Each node is a zero bit per child followed by a one bit, so every node but the root has a label.
+	if zeros := lastOne + 1 - ones; zeros != ones-1 || len(ss.labels) != int(zeros) {
+		return E.New("invalid domain index: bad labels length")
+	}
+	if len(ss.leaves) > int(ones+63)>>6 {
+		return E.New("invalid domain index: bad leaves length")
+	}
+	return nil
+}
+
+func getBit(bm []uint64, i int) uint64 {
+	if i>>6 >= len(bm) {
+		return 0
+	}
+	return bm[i>>6] & (1 << uint(i&63))
+}
+
+func countZeros(bm []uint64, ranks []int32, i int) int {
+	a, _ := rank64(bm, ranks, int32(i))
+	return i - int(a)
+}
+
+func selectIthOne(bm []uint64, ranks, selects []int32, i int) int {
+	a, _ := select32R64(bm, selects, ranks, int32(i))
+	return int(a)
+}
+
+func rank64(words []uint64, rindex []int32, i int32) (int32, int32) {
+	wordI := i >> 6
+	j := uint32(i & 63)
+	n := rindex[wordI]
+	if int(wordI) >= len(words) {
+		return n, 0
+	}
+	w := words[wordI]
+	c1 := n + int32(bits.OnesCount64(w&(1<<j-1)))
+	return c1, int32(w>>uint(j)) & 1
+}
+
+func indexRank64(words []uint64) []int32 {
+	idx := make([]int32, len(words)+1)
+	n := int32(0)
+	for i := 0; i < len(words); i++ {
+		idx[i] = n
+		n += int32(bits.OnesCount64(words[i]))
+	}
+	idx[len(words)] = n
+	return idx
+}
+
+func select32R64(words []uint64, selectIndex, rankIndex []int32, i int32) (int32, int32) {
+	a := int32(0)
+	l := int32(len(words))
+	wordI := selectIndex[i>>5] >> 6
+	for ; rankIndex[wordI+1] <= i; wordI++ {
+	}
+	w := words[wordI]
+	ww := w
+	base := wordI << 6
+	findIth := int(i - rankIndex[wordI])
+	offset := int32(0)
+	ones := bits.OnesCount32(uint32(ww))
+	if ones <= findIth {
+		findIth -= ones
+		offset |= 32
+		ww >>= 32
+	}
+	ones = bits.OnesCount16(uint16(ww))
+	if ones <= findIth {
+		findIth -= ones
+		offset |= 16
+		ww >>= 16
+	}
+	ones = bits.OnesCount8(uint8(ww))
+	if ones <= findIth {
+		a = int32(select8Lookup[(ww>>5)&(0x7f8)|uint64(findIth-ones)]) + offset + 8
+	} else {
+		a = int32(select8Lookup[(ww&0xff)<<3|uint64(findIth)]) + offset
+	}
+	a += base
+	w &= ^(1<<uint(a&63+1) - 1)
+	if w != 0 {
+		return a, base + int32(bits.TrailingZeros64(w))
+	}
+	wordI++
+	for ; wordI < l; wordI++ {
+		w = words[wordI]
+		if w != 0 {
+			return a, wordI<<6 + int32(bits.TrailingZeros64(w))
+		}
+	}
+	return a, l << 6
+}
+
+func indexSelect32R64(words []uint64) ([]int32, []int32) {
+	l := len(words) << 6
+	sidx := make([]int32, 0, len(words))
+	ith := -1
+	for i := 0; i < l; i++ {
+		if words[i>>6]&(1<<uint(i&63)) != 0 {
+			ith++
+			if ith&31 == 0 {
+				sidx = append(sidx, int32(i))
+			}
+		}
+	}
+	// clone to reduce cap to len
+	sidx = append(sidx[:0:0], sidx...)
+	return sidx, indexRank64(words)
+}
+
+var select8Lookup [256 * 8]uint8
+
+func init() {
+	for i := 0; i < 256; i++ {
+		w := uint8(i)
+		for j := 0; j < 8; j++ {
+			// x-th 1 in w
+			// if x-th 1 is not found, it is 8
+			x := bits.TrailingZeros8(w)
+			w &= w - 1
+			select8Lookup[i*8+j] = uint8(x)
+		}
+	}
+}
@@ -4,3 +4,9 @@ const (
 	DNSProviderAliDNS     = "alidns"
 	DNSProviderCloudflare = "cloudflare"
 )
+
+const (
+	DNSStrategyRace       = "race"
+	DNSStrategySequential = "sequential"
+	DNSStrategyFastest    = "fastest"
+)
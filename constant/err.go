@@ -5,3 +5,9 @@ import E "github.com/sagernet/sing/common/exceptions"
 var ErrTLSRequired = E.New("TLS required")
 
 var ErrQUICNotIncluded = E.New(`QUIC is not included in this build, rebuild with -tags with_quic`)
+
+var ErrRestartRequired = E.New("restart required")
+
+// ErrRejected is returned by the router for connections rejected by rules that could not be reset by the router,
+// so that inbounds able to, such as tun, reject them.
+var ErrRejected = E.New("connection rejected")
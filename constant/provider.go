@@ -1,6 +1,8 @@
 package constant
 
 const (
-	TypeFileProvider = "file"
-	TypeHTTPProvider = "http"
+	TypeFileProvider   = "file"
+	TypeHTTPProvider   = "http"
+	TypeInlineProvider = "inline"
+	TypeMergeProvider  = "merge"
 )
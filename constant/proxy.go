@@ -26,9 +26,11 @@ const (
 )
 
 const (
-	TypeSelector = "selector"
-	TypeURLTest  = "urltest"
-	TypeRelay    = "relay"
+	TypeSelector    = "selector"
+	TypeURLTest     = "urltest"
+	TypeLoadBalance = "loadbalance"
+	TypeFallback    = "fallback"
+	TypeRelay       = "relay"
 )
 
 func ProxyDisplayName(proxyType string) string {
@@ -73,6 +75,10 @@ func ProxyDisplayName(proxyType string) string {
 		return "Selector"
 	case TypeURLTest:
 		return "URLTest"
+	case TypeLoadBalance:
+		return "LoadBalance"
+	case TypeFallback:
+		return "Fallback"
 	case TypeRelay:
 		return "Relay"
 	default:
@@ -11,9 +11,37 @@ const (
 )
 
 const (
-	RuleSetTypeLocal    = "local"
-	RuleSetTypeRemote   = "remote"
-	RuleSetVersion1     = 1
-	RuleSetFormatSource = "source"
-	RuleSetFormatBinary = "binary"
+	RuleSetTypeLocal        = "local"
+	RuleSetTypeRemote       = "remote"
+	RuleSetVersion1         = 1
+	RuleSetFormatSource     = "source"
+	RuleSetFormatBinary     = "binary"
+	RuleSetFormatClash      = "clash"
+	RuleSetFormatDomainList = "domain_list"
+	RuleSetFormatIPCIDRList = "ipcidr_list"
+	RuleSetFormatAdblock    = "adblock"
+)
+
+const (
+	RuleSetBehaviorDomain    = "domain"
+	RuleSetBehaviorIPCIDR    = "ipcidr"
+	RuleSetBehaviorClassical = "classical"
+)
+
+const (
+	RuleActionTypeRoute     = "route"
+	RuleActionTypeReject    = "reject"
+	RuleActionTypeDrop      = "drop"
+	RuleActionTypeHijackDNS = "hijack-dns"
+)
+
+const (
+	RuleActionRejectMethodDefault = "default"
+	RuleActionRejectMethodReply   = "reply"
+)
+
+const (
+	RuleActionRCodeNXDomain = "nxdomain"
+	RuleActionRCodeNoData   = "nodata"
+	RuleActionRCodeRefused  = "refused"
 )
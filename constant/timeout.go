@@ -6,6 +6,7 @@ const (
 	TCPTimeout                = 5 * time.Second
 	ReadPayloadTimeout        = 300 * time.Millisecond
 	DNSTimeout                = 10 * time.Second
+	DNSSequentialTimeout      = 3 * time.Second
 	QUICTimeout               = 30 * time.Second
 	STUNTimeout               = 15 * time.Second
 	UDPTimeout                = 5 * time.Minute
@@ -24,12 +24,20 @@ var (
 	bucketMode     = []byte("clash_mode")
 	bucketRuleSet  = []byte("rule_set")
 
+	bucketProviderInfo    = []byte("provider_info")
+	bucketProviderHistory = []byte("provider_history")
+	bucketTrafficUsage    = []byte("traffic_usage")
+
 	bucketNameList = []string{
 		string(bucketSelected),
 		string(bucketExpand),
 		string(bucketMode),
 		string(bucketRuleSet),
 		string(bucketRDRC),
+		string(bucketProviderInfo),
+		string(bucketProviderHistory),
+		string(bucketTrafficUsage),
+		string(bucketDNSCache),
 	}
 
 	cacheIDDefault = []byte("default")
@@ -44,6 +52,7 @@ type CacheFile struct {
 	storeFakeIP       bool
 	storeRDRC         bool
 	rdrcTimeout       time.Duration
+	storeDNS          bool
 	DB                *bbolt.DB
 	saveMetadataTimer *time.Timer
 	saveFakeIPAccess  sync.RWMutex
@@ -85,6 +94,7 @@ func New(ctx context.Context, options option.CacheFileOptions) *CacheFile {
 		storeFakeIP:  options.StoreFakeIP,
 		storeRDRC:    options.StoreRDRC,
 		rdrcTimeout:  rdrcTimeout,
+		storeDNS:     options.StoreDNS,
 		saveDomain:   make(map[netip.Addr]string),
 		saveAddress4: make(map[string]netip.Addr),
 		saveAddress6: make(map[string]netip.Addr),
@@ -312,3 +322,102 @@ func (c *CacheFile) SaveRuleSet(tag string, set *adapter.SavedRuleSet) error {
 		return bucket.Put([]byte(tag), setBinary)
 	})
 }
+
+func (c *CacheFile) LoadProviderInfo(tag string) *adapter.SavedProviderInfo {
+	var savedInfo adapter.SavedProviderInfo
+	err := c.DB.View(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketProviderInfo)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		infoBinary := bucket.Get([]byte(tag))
+		if len(infoBinary) == 0 {
+			return os.ErrInvalid
+		}
+		return savedInfo.UnmarshalBinary(infoBinary)
+	})
+	if err != nil {
+		return nil
+	}
+	return &savedInfo
+}
+
+func (c *CacheFile) SaveProviderInfo(tag string, info *adapter.SavedProviderInfo) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketProviderInfo)
+		if err != nil {
+			return err
+		}
+		infoBinary, err := info.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(tag), infoBinary)
+	})
+}
+
+func (c *CacheFile) LoadProviderHistory(tag string) adapter.SavedProviderHistory {
+	savedHistory := make(adapter.SavedProviderHistory)
+	err := c.DB.View(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketProviderHistory)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		historyBinary := bucket.Get([]byte(tag))
+		if len(historyBinary) == 0 {
+			return os.ErrInvalid
+		}
+		return savedHistory.UnmarshalBinary(historyBinary)
+	})
+	if err != nil {
+		return nil
+	}
+	return savedHistory
+}
+
+func (c *CacheFile) SaveProviderHistory(tag string, history adapter.SavedProviderHistory) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketProviderHistory)
+		if err != nil {
+			return err
+		}
+		historyBinary, err := history.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(tag), historyBinary)
+	})
+}
+
+func (c *CacheFile) LoadTrafficUsage(key string) *adapter.SavedTrafficUsage {
+	var savedUsage adapter.SavedTrafficUsage
+	err := c.DB.View(func(t *bbolt.Tx) error {
+		bucket := c.bucket(t, bucketTrafficUsage)
+		if bucket == nil {
+			return os.ErrNotExist
+		}
+		usageBinary := bucket.Get([]byte(key))
+		if len(usageBinary) == 0 {
+			return os.ErrInvalid
+		}
+		return savedUsage.UnmarshalBinary(usageBinary)
+	})
+	if err != nil {
+		return nil
+	}
+	return &savedUsage
+}
+
+func (c *CacheFile) SaveTrafficUsage(key string, usage *adapter.SavedTrafficUsage) error {
+	return c.DB.Batch(func(t *bbolt.Tx) error {
+		bucket, err := c.createBucket(t, bucketTrafficUsage)
+		if err != nil {
+			return err
+		}
+		usageBinary, err := usage.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		return bucket.Put([]byte(key), usageBinary)
+	})
+}
@@ -0,0 +1,104 @@
+package cachefile
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/sagernet/bbolt"
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing/common/logger"
+)
+
+var bucketDNSCache = []byte("dns_cache")
+
+func (c *CacheFile) StoreDNS() bool {
+	return c.storeDNS
+}
+
+func (c *CacheFile) LoadDNSResponses(staleTimeout time.Duration) []*adapter.SavedDNSResponse {
+	var (
+		responses  []*adapter.SavedDNSResponse
+		expiredKey [][]byte
+	)
+	now := time.Now()
+	err := c.DB.View(func(tx *bbolt.Tx) error {
+		bucket := c.bucket(tx, bucketDNSCache)
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(k, v []byte) error {
+			var savedResponse adapter.SavedDNSResponse
+			err := savedResponse.UnmarshalBinary(v)
+			if err != nil || len(savedResponse.Response.Question) != 1 || now.After(savedResponse.ExpireAt.Add(staleTimeout)) {
+				expiredKey = append(expiredKey, append([]byte(nil), k...))
+				return nil
+			}
+			responses = append(responses, &savedResponse)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil
+	}
+	if len(expiredKey) > 0 {
+		c.DB.Update(func(tx *bbolt.Tx) error {
+			bucket := c.bucket(tx, bucketDNSCache)
+			if bucket == nil {
+				return nil
+			}
+			for _, key := range expiredKey {
+				err = bucket.Delete(key)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+	return responses
+}
+
+func (c *CacheFile) SaveDNSResponse(response *adapter.SavedDNSResponse) error {
+	responseBinary, err := response.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		bucket, err := c.createBucket(tx, bucketDNSCache)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(dnsResponseKey(response), responseBinary)
+	})
+}
+
+func (c *CacheFile) SaveDNSResponseAsync(response *adapter.SavedDNSResponse, logger logger.Logger) {
+	go func() {
+		err := c.SaveDNSResponse(response)
+		if err != nil {
+			logger.Warn("save DNS cache: ", err)
+		}
+	}()
+}
+
+func (c *CacheFile) ClearDNSResponses() error {
+	return c.DB.Batch(func(tx *bbolt.Tx) error {
+		if c.bucket(tx, bucketDNSCache) == nil {
+			return nil
+		}
+		if c.cacheID == nil {
+			return tx.DeleteBucket(bucketDNSCache)
+		}
+		return tx.Bucket(c.cacheID).DeleteBucket(bucketDNSCache)
+	})
+}
+
+func dnsResponseKey(response *adapter.SavedDNSResponse) []byte {
+	question := response.Response.Question[0]
+	key := make([]byte, 0, len(response.Transport)+len(question.Name)+5)
+	key = append(key, response.Transport...)
+	key = append(key, 0)
+	key = binary.BigEndian.AppendUint16(key, question.Qtype)
+	key = binary.BigEndian.AppendUint16(key, question.Qclass)
+	return append(key, question.Name...)
+}
@@ -7,6 +7,7 @@ var (
 	CtxKeyProxy        = contextKey("proxy")
 	CtxKeyProvider     = contextKey("provider")
 	CtxKeyRule         = contextKey("rule")
+	CtxKeyInbound      = contextKey("inbound")
 )
 
 type contextKey string
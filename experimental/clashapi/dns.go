@@ -1,12 +1,17 @@
 package clashapi
 
 import (
+	"bytes"
 	"context"
+	"net"
 	"net/http"
 
 	"github.com/sagernet/sing-box/adapter"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/ws"
+	"github.com/sagernet/ws/wsutil"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/render"
@@ -16,9 +21,65 @@ import (
 func dnsRouter(router adapter.Router) http.Handler {
 	r := chi.NewRouter()
 	r.Get("/query", queryDNS(router))
+	r.Get("/logs", getDNSLogs(router))
 	return r
 }
 
+func getDNSLogs(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		queryLogger := router.DNSQueryLogger()
+		if queryLogger == nil {
+			render.Status(r, http.StatusNoContent)
+			return
+		}
+		subscription, done, err := queryLogger.Subscribe()
+		if err != nil {
+			render.Status(r, http.StatusNoContent)
+			return
+		}
+		defer queryLogger.UnSubscribe(subscription)
+
+		var conn net.Conn
+		if r.Header.Get("Upgrade") == "websocket" {
+			conn, _, _, err = ws.UpgradeHTTP(r, w)
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+
+		if conn == nil {
+			w.Header().Set("Content-Type", "application/json")
+			render.Status(r, http.StatusOK)
+		}
+
+		buf := &bytes.Buffer{}
+		var record adapter.DNSQueryLog
+		for {
+			select {
+			case <-done:
+				return
+			case record = <-subscription:
+			}
+			buf.Reset()
+			err = json.NewEncoder(buf).Encode(record)
+			if err != nil {
+				break
+			}
+			if conn == nil {
+				_, err = w.Write(buf.Bytes())
+				w.(http.Flusher).Flush()
+			} else {
+				err = wsutil.WriteServerText(conn, buf.Bytes())
+			}
+
+			if err != nil {
+				break
+			}
+		}
+	}
+}
+
 func queryDNS(router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		name := r.URL.Query().Get("name")
@@ -0,0 +1,247 @@
+package clashapi
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/experimental/clashapi/trafficontrol"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/service/filemanager"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func inboundRouter(server *Server, router adapter.Router) http.Handler {
+	r := chi.NewRouter()
+	r.Route("/{tag}/users", func(r chi.Router) {
+		r.Use(findManagedUserInbound(router))
+		r.Get("/", getInboundUsers)
+		r.Post("/", addInboundUser(server))
+		r.Put("/{name}", updateInboundUser(server))
+		r.Delete("/{name}", removeInboundUser(server))
+	})
+	return r
+}
+
+func findManagedUserInbound(router adapter.Router) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		fn := func(w http.ResponseWriter, r *http.Request) {
+			inbound, loaded := router.Inbound(getEscapeParam(r, "tag"))
+			if !loaded {
+				render.Status(r, http.StatusNotFound)
+				render.JSON(w, r, ErrNotFound)
+				return
+			}
+			managedInbound, isManaged := inbound.(adapter.ManagedUserInbound)
+			if !isManaged {
+				render.Status(r, http.StatusBadRequest)
+				render.JSON(w, r, newError("inbound does not support user management"))
+				return
+			}
+			ctx := context.WithValue(r.Context(), CtxKeyInbound, managedInbound)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		}
+		return http.HandlerFunc(fn)
+	}
+}
+
+func getInboundUsers(w http.ResponseWriter, r *http.Request) {
+	inbound := r.Context().Value(CtxKeyInbound).(adapter.ManagedUserInbound)
+	render.JSON(w, r, render.M{
+		"users": inbound.Users(),
+	})
+}
+
+func addInboundUser(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inbound := r.Context().Value(CtxKeyInbound).(adapter.ManagedUserInbound)
+		var user adapter.InboundUser
+		err := render.DecodeJSON(r.Body, &user)
+		if err != nil || user.Name == "" {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		err = server.updateInboundUsers(inbound, func(users []adapter.InboundUser) ([]adapter.InboundUser, error) {
+			if common.Any(users, func(it adapter.InboundUser) bool {
+				return it.Name == user.Name
+			}) {
+				return nil, errUserExists
+			}
+			return append(users, user), nil
+		})
+		if err != nil {
+			renderUserError(w, r, err)
+			return
+		}
+		render.NoContent(w, r)
+	}
+}
+
+func updateInboundUser(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inbound := r.Context().Value(CtxKeyInbound).(adapter.ManagedUserInbound)
+		name := getEscapeParam(r, "name")
+		var user adapter.InboundUser
+		err := render.DecodeJSON(r.Body, &user)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, ErrBadRequest)
+			return
+		}
+		user.Name = name
+		var credentialsChanged bool
+		err = server.updateInboundUsers(inbound, func(users []adapter.InboundUser) ([]adapter.InboundUser, error) {
+			index := common.Index(users, func(it adapter.InboundUser) bool {
+				return it.Name == name
+			})
+			if index == -1 {
+				return nil, errUserNotFound
+			}
+			credentialsChanged = users[index] != user
+			users[index] = user
+			return users, nil
+		})
+		if err != nil {
+			renderUserError(w, r, err)
+			return
+		}
+		// Connections authenticated with the old credentials must not outlive them.
+		if credentialsChanged {
+			closeUserConnections(server.trafficManager, inbound.Tag(), name)
+		}
+		render.NoContent(w, r)
+	}
+}
+
+func removeInboundUser(server *Server) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inbound := r.Context().Value(CtxKeyInbound).(adapter.ManagedUserInbound)
+		name := getEscapeParam(r, "name")
+		err := server.updateInboundUsers(inbound, func(users []adapter.InboundUser) ([]adapter.InboundUser, error) {
+			newUsers := common.Filter(users, func(it adapter.InboundUser) bool {
+				return it.Name != name
+			})
+			if len(newUsers) == len(users) {
+				return nil, errUserNotFound
+			}
+			return newUsers, nil
+		})
+		if err != nil {
+			renderUserError(w, r, err)
+			return
+		}
+		closeUserConnections(server.trafficManager, inbound.Tag(), name)
+		render.NoContent(w, r)
+	}
+}
+
+var (
+	errUserExists   = E.New("user already exists")
+	errUserNotFound = E.New("user not found")
+)
+
+func renderUserError(w http.ResponseWriter, r *http.Request, err error) {
+	switch err {
+	case errUserExists:
+		render.Status(r, http.StatusConflict)
+	case errUserNotFound:
+		render.Status(r, http.StatusNotFound)
+	default:
+		render.Status(r, http.StatusBadRequest)
+	}
+	render.JSON(w, r, newError(err.Error()))
+}
+
+// updateInboundUsers applies a change to the users of an inbound and writes the result to the user store.
+func (s *Server) updateInboundUsers(inbound adapter.ManagedUserInbound, update func(users []adapter.InboundUser) ([]adapter.InboundUser, error)) error {
+	s.userAccess.Lock()
+	defer s.userAccess.Unlock()
+	users, err := update(inbound.Users())
+	if err != nil {
+		return err
+	}
+	err = inbound.UpdateUsers(users)
+	if err != nil {
+		return err
+	}
+	if s.userStore != nil {
+		err = s.userStore.Save(s.ctx, inbound.Tag(), users)
+		if err != nil {
+			s.logger.Error("save user store: ", err)
+		}
+	}
+	return nil
+}
+
+func closeUserConnections(trafficManager *trafficontrol.Manager, inbound string, user string) {
+	for _, connection := range trafficManager.Snapshot().Connections {
+		metadata := connection.Info().Metadata
+		if metadata.InboundName == inbound && metadata.InboundUser == user {
+			connection.Close()
+		}
+	}
+}
+
+// userStore persists users changed at runtime, by inbound tag.
+type userStore struct {
+	path   string
+	logger log.Logger
+	users  map[string][]adapter.InboundUser
+}
+
+func (s *userStore) Load() error {
+	s.users = make(map[string][]adapter.InboundUser)
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	err = json.Unmarshal(content, &s.users)
+	if err != nil {
+		return E.Cause(err, "decode ", s.path)
+	}
+	return nil
+}
+
+// Apply replaces the users of inbounds with the stored ones, at startup and after inbounds are recreated by a reload.
+// Stored users of inbounds removed from the configuration are kept, in case the inbound is added back.
+func (s *userStore) Apply(router adapter.Router) error {
+	for tag, users := range s.users {
+		inbound, loaded := router.Inbound(tag)
+		if !loaded {
+			s.logger.Warn("inbound not found for stored users: ", tag)
+			continue
+		}
+		managedInbound, isManaged := inbound.(adapter.ManagedUserInbound)
+		if !isManaged {
+			return E.New("inbound/", inbound.Type(), "[", tag, "] does not support user management")
+		}
+		err := managedInbound.UpdateUsers(users)
+		if err != nil {
+			return E.Cause(err, "update users of inbound ", tag)
+		}
+	}
+	return nil
+}
+
+func (s *userStore) Save(ctx context.Context, tag string, users []adapter.InboundUser) error {
+	s.users[tag] = users
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	err := encoder.Encode(s.users)
+	if err != nil {
+		return err
+	}
+	return filemanager.WriteFile(ctx, s.path, buffer.Bytes(), 0o600)
+}
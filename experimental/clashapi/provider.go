@@ -29,11 +29,13 @@ func proxyProviderRouter(server *Server, router adapter.Router) http.Handler {
 
 func providerInfo(server *Server, provider adapter.OutboundProvider) *render.M {
 	return &render.M{
-		"name":             provider.Tag(),
-		"type":             "Proxy",
-		"vehicleType":      strings.ToUpper(provider.Type()),
-		"subscriptionInfo": provider.SubInfo(),
-		"updatedAt":        provider.UpdateTime().Format("2006-01-02T15:04:05.999999999-07:00"),
+		"name":               provider.Tag(),
+		"type":               "Proxy",
+		"vehicleType":        strings.ToUpper(provider.Type()),
+		"subscriptionInfo":   provider.SubInfo(),
+		"subscriptionStatus": provider.SubStatus(),
+		"excluded":           provider.Excluded(),
+		"updatedAt":          provider.UpdateTime().Format("2006-01-02T15:04:05.999999999-07:00"),
 		"proxies": common.Map(provider.Outbounds(), func(it adapter.Outbound) *badjson.JSONObject {
 			return proxyInfo(server, it)
 		}),
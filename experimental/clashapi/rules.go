@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
 	N "github.com/sagernet/sing/common/network"
 
 	"github.com/go-chi/chi/v5"
@@ -63,10 +64,14 @@ func getRules(router adapter.Router) func(w http.ResponseWriter, r *http.Request
 
 		routeRules := router.Rules()
 		for _, rule := range routeRules {
+			proxy := rule.Outbound()
+			if action := rule.Action(); action != C.RuleActionTypeRoute {
+				proxy = action
+			}
 			rules = append(rules, Rule{
 				Type:     "ROUTE",
 				Payload:  rule.String(),
-				Proxy:    rule.Outbound(),
+				Proxy:    proxy,
 				Disabled: rule.Disabled(),
 				UUID:     rule.UUID(),
 			})
@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
@@ -50,6 +51,10 @@ type Server struct {
 	mode           string
 	modeList       []string
 	modeUpdateHook chan<- struct{}
+	outboundAccess sync.RWMutex
+	outbounds      []option.Outbound
+	userStore      *userStore
+	userAccess     sync.Mutex
 
 	externalController       bool
 	externalUI               string
@@ -70,10 +75,17 @@ func NewServer(ctx context.Context, router adapter.Router, logFactory log.Observ
 		},
 		trafficManager:           trafficManager,
 		modeList:                 options.ModeList,
+		outbounds:                options.Outbounds,
 		externalController:       options.ExternalController != "",
 		externalUIDownloadURL:    options.ExternalUIDownloadURL,
 		externalUIDownloadDetour: options.ExternalUIDownloadDetour,
 	}
+	if options.UserStore != "" {
+		server.userStore = &userStore{
+			path:   filemanager.BasePath(ctx, os.ExpandEnv(options.UserStore)),
+			logger: server.logger,
+		}
+	}
 	server.urlTestHistory = service.PtrFromContext[urltest.HistoryStorage](ctx)
 	if server.urlTestHistory == nil {
 		server.urlTestHistory = urltest.NewHistoryStorage()
@@ -126,6 +138,8 @@ func NewServer(ctx context.Context, router adapter.Router, logFactory log.Observ
 		r.Mount("/profile", profileRouter())
 		r.Mount("/cache", cacheRouter(ctx))
 		r.Mount("/dns", dnsRouter(router))
+		r.Mount("/subscribe", subscribeRouter(server, router))
+		r.Mount("/inbounds", inboundRouter(server, router))
 
 		server.setupMetaAPI(r)
 	})
@@ -156,6 +170,16 @@ func (s *Server) PreStart() error {
 }
 
 func (s *Server) Start() error {
+	if s.userStore != nil {
+		err := s.userStore.Load()
+		if err != nil {
+			return E.Cause(err, "load user store")
+		}
+		err = s.userStore.Apply(s.router)
+		if err != nil {
+			return E.Cause(err, "apply user store")
+		}
+	}
 	if s.externalController {
 		s.checkAndDownloadExternalUI()
 		listener, err := net.Listen("tcp", s.httpServer.Addr)
@@ -173,6 +197,28 @@ func (s *Server) Start() error {
 	return nil
 }
 
+func (s *Server) Reload(options option.Options) error {
+	s.outboundAccess.Lock()
+	s.outbounds = options.Outbounds
+	s.outboundAccess.Unlock()
+	if s.userStore != nil {
+		s.userAccess.Lock()
+		defer s.userAccess.Unlock()
+		err := s.userStore.Apply(s.router)
+		if err != nil {
+			return E.Cause(err, "apply user store")
+		}
+	}
+	return nil
+}
+
+// Outbounds returns the options of the outbounds of the running instance.
+func (s *Server) Outbounds() []option.Outbound {
+	s.outboundAccess.RLock()
+	defer s.outboundAccess.RUnlock()
+	return s.outbounds
+}
+
 func (s *Server) Close() error {
 	return common.Close(
 		common.PtrOrNil(s.httpServer),
@@ -288,6 +334,8 @@ func castMetadata(metadata adapter.InboundContext) trafficontrol.Metadata {
 		SniffHost:   metadata.SniffDomain,
 		DNSMode:     "normal",
 		ProcessPath: processPath,
+		InboundName: metadata.Inbound,
+		InboundUser: metadata.User,
 	}
 }
 
@@ -0,0 +1,65 @@
+package clashapi
+
+import (
+	"net/http"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/provider"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/render"
+)
+
+func subscribeRouter(server *Server, router adapter.Router) http.Handler {
+	r := chi.NewRouter()
+	r.Get("/export", exportSubscription(server, router))
+	return r
+}
+
+func exportSubscription(server *Server, router adapter.Router) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var outbounds []option.Outbound
+		if providerTags := query["provider"]; len(providerTags) > 0 {
+			for _, tag := range providerTags {
+				outboundProvider, loaded := router.OutboundProvider(tag)
+				if !loaded {
+					render.Status(r, http.StatusNotFound)
+					render.JSON(w, r, ErrNotFound)
+					return
+				}
+				outbounds = append(outbounds, outboundProvider.OutboundOptions()...)
+			}
+		} else {
+			for _, outbound := range server.Outbounds() {
+				if _, loaded := router.Outbound(outbound.Tag); loaded {
+					outbounds = append(outbounds, outbound)
+				}
+			}
+			for _, outboundProvider := range router.OutboundProviders() {
+				if outboundProvider.Type() == C.TypeMergeProvider {
+					continue
+				}
+				outbounds = append(outbounds, outboundProvider.OutboundOptions()...)
+			}
+		}
+		format := query.Get("format")
+		content, err := provider.Export(outbounds, format)
+		if err != nil {
+			render.Status(r, http.StatusBadRequest)
+			render.JSON(w, r, newError(err.Error()))
+			return
+		}
+		switch format {
+		case provider.ExportFormatSingBox:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		case provider.ExportFormatClash:
+			w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+		default:
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.Write(content)
+	}
+}
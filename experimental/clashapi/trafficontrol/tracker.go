@@ -26,10 +26,13 @@ type Metadata struct {
 	SniffHost   string     `json:"sniffHost"`
 	DNSMode     string     `json:"dnsMode"`
 	ProcessPath string     `json:"processPath"`
+	InboundName string     `json:"inboundName"`
+	InboundUser string     `json:"inboundUser"`
 }
 
 type tracker interface {
 	ID() string
+	Info() *trackerInfo
 	Close() error
 	Leave()
 }
@@ -45,6 +48,10 @@ type trackerInfo struct {
 	RulePayload   string        `json:"rulePayload"`
 }
 
+func (t *trackerInfo) Info() *trackerInfo {
+	return t
+}
+
 func (t trackerInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(map[string]any{
 		"id":          t.UUID.String(),
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing/common/atomic"
+)
+
+const (
+	metricTypeCounter   = "counter"
+	metricTypeGauge     = "gauge"
+	metricTypeHistogram = "histogram"
+)
+
+type series struct {
+	labelValues []string
+	value       atomic.Int64
+}
+
+// metricVec is an integer counter or gauge partitioned by label values.
+type metricVec struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	access     sync.Mutex
+	series     map[string]*series
+}
+
+func newMetricVec(name string, help string, metricType string, labels ...string) *metricVec {
+	return &metricVec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		series:     make(map[string]*series),
+	}
+}
+
+func (v *metricVec) With(labelValues ...string) *atomic.Int64 {
+	key := strings.Join(labelValues, "\xff")
+	v.access.Lock()
+	defer v.access.Unlock()
+	s, loaded := v.series[key]
+	if !loaded {
+		s = &series{labelValues: labelValues}
+		v.series[key] = s
+	}
+	return &s.value
+}
+
+func (v *metricVec) Write(writer io.Writer) {
+	writeHeader(writer, v.name, v.help, v.metricType)
+	v.access.Lock()
+	defer v.access.Unlock()
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		writeSample(writer, v.name, v.labels, s.labelValues, strconv.FormatInt(s.value.Load(), 10))
+	}
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64
+	count       uint64
+	sum         float64
+}
+
+type histogramVec struct {
+	name    string
+	help    string
+	labels  []string
+	buckets []float64
+	access  sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+func newHistogramVec(name string, help string, buckets []float64, labels ...string) *histogramVec {
+	return &histogramVec{
+		name:    name,
+		help:    help,
+		labels:  labels,
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+func (v *histogramVec) Observe(value float64, labelValues ...string) {
+	key := strings.Join(labelValues, "\xff")
+	v.access.Lock()
+	defer v.access.Unlock()
+	s, loaded := v.series[key]
+	if !loaded {
+		s = &histogramSeries{labelValues: labelValues, counts: make([]uint64, len(v.buckets))}
+		v.series[key] = s
+	}
+	for i, bound := range v.buckets {
+		if value <= bound {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (v *histogramVec) Write(writer io.Writer) {
+	writeHeader(writer, v.name, v.help, metricTypeHistogram)
+	v.access.Lock()
+	defer v.access.Unlock()
+	bucketLabels := append(append([]string{}, v.labels...), "le")
+	for _, key := range sortedKeys(v.series) {
+		s := v.series[key]
+		for i, bound := range v.buckets {
+			writeSample(writer, v.name+"_bucket", bucketLabels, append(append([]string{}, s.labelValues...), formatFloat(bound)), strconv.FormatUint(s.counts[i], 10))
+		}
+		writeSample(writer, v.name+"_bucket", bucketLabels, append(append([]string{}, s.labelValues...), "+Inf"), strconv.FormatUint(s.count, 10))
+		writeSample(writer, v.name+"_sum", v.labels, s.labelValues, formatFloat(s.sum))
+		writeSample(writer, v.name+"_count", v.labels, s.labelValues, strconv.FormatUint(s.count, 10))
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeHeader(writer io.Writer, name string, help string, metricType string) {
+	io.WriteString(writer, "# HELP "+name+" "+help+"\n")
+	io.WriteString(writer, "# TYPE "+name+" "+metricType+"\n")
+}
+
+func writeSample(writer io.Writer, name string, labels []string, labelValues []string, value string) {
+	var builder strings.Builder
+	builder.WriteString(name)
+	if len(labels) > 0 {
+		builder.WriteString("{")
+		for i, label := range labels {
+			if i > 0 {
+				builder.WriteString(",")
+			}
+			builder.WriteString(label)
+			builder.WriteString(`="`)
+			builder.WriteString(labelValueReplacer.Replace(labelValues[i]))
+			builder.WriteString(`"`)
+		}
+		builder.WriteString("}")
+	}
+	builder.WriteString(" ")
+	builder.WriteString(value)
+	builder.WriteString("\n")
+	io.WriteString(writer, builder.String())
+}
@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricVecWrite(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name   string
+		vec    func() *metricVec
+		output string
+	}{
+		{
+			"empty",
+			func() *metricVec {
+				return newMetricVec("test_total", "Test counter.", metricTypeCounter)
+			},
+			"# HELP test_total Test counter.\n# TYPE test_total counter\n",
+		},
+		{
+			"no labels",
+			func() *metricVec {
+				vec := newMetricVec("test_total", "Test counter.", metricTypeCounter)
+				vec.With().Add(3)
+				return vec
+			},
+			"# HELP test_total Test counter.\n# TYPE test_total counter\ntest_total 3\n",
+		},
+		{
+			"sorted series",
+			func() *metricVec {
+				vec := newMetricVec("test_connections", "Test gauge.", metricTypeGauge, "inbound", "network")
+				vec.With("tun", "udp").Add(2)
+				vec.With("mixed", "tcp").Add(5)
+				vec.With("tun", "udp").Add(-1)
+				return vec
+			},
+			"# HELP test_connections Test gauge.\n# TYPE test_connections gauge\n" +
+				"test_connections{inbound=\"mixed\",network=\"tcp\"} 5\n" +
+				"test_connections{inbound=\"tun\",network=\"udp\"} 1\n",
+		},
+		{
+			"escaped label value",
+			func() *metricVec {
+				vec := newMetricVec("test_total", "Test counter.", metricTypeCounter, "outbound")
+				vec.With("a\"b\\c\nd").Add(1)
+				return vec
+			},
+			"# HELP test_total Test counter.\n# TYPE test_total counter\n" +
+				"test_total{outbound=\"a\\\"b\\\\c\\nd\"} 1\n",
+		},
+	} {
+		buffer := new(bytes.Buffer)
+		testCase.vec().Write(buffer)
+		require.Equal(t, testCase.output, buffer.String(), testCase.name)
+	}
+}
+
+func TestHistogramVecWrite(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name         string
+		observations []float64
+		output       string
+	}{
+		{
+			"empty",
+			nil,
+			"# HELP test_seconds Test histogram.\n# TYPE test_seconds histogram\n",
+		},
+		{
+			"cumulative buckets",
+			[]float64{0.05, 0.1, 0.3, 2},
+			"# HELP test_seconds Test histogram.\n# TYPE test_seconds histogram\n" +
+				"test_seconds_bucket{outbound=\"direct\",le=\"0.1\"} 2\n" +
+				"test_seconds_bucket{outbound=\"direct\",le=\"0.5\"} 3\n" +
+				"test_seconds_bucket{outbound=\"direct\",le=\"1\"} 3\n" +
+				"test_seconds_bucket{outbound=\"direct\",le=\"+Inf\"} 4\n" +
+				"test_seconds_sum{outbound=\"direct\"} 2.45\n" +
+				"test_seconds_count{outbound=\"direct\"} 4\n",
+		},
+	} {
+		vec := newHistogramVec("test_seconds", "Test histogram.", []float64{0.1, 0.5, 1}, "outbound")
+		for _, value := range testCase.observations {
+			vec.Observe(value, "direct")
+		}
+		buffer := new(bytes.Buffer)
+		vec.Write(buffer)
+		require.Equal(t, testCase.output, buffer.String(), testCase.name)
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		value  float64
+		output string
+	}{
+		{0, "0"},
+		{0.25, "0.25"},
+		{10, "10"},
+		{1e21, "1e+21"},
+		{math.Inf(1), "+Inf"},
+	} {
+		require.Equal(t, testCase.output, formatFloat(testCase.value), "%v", testCase.value)
+	}
+}
@@ -0,0 +1,230 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/atomic"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var dnsDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+var _ adapter.MetricsServer = (*Server)(nil)
+
+type Server struct {
+	ctx        context.Context
+	router     adapter.Router
+	logger     log.Logger
+	httpServer *http.Server
+
+	inboundTraffic  *metricVec
+	outboundTraffic *metricVec
+	userTraffic     *metricVec
+	connections     *metricVec
+	ruleMatches     *metricVec
+	dnsQueries      *histogramVec
+	dnsErrors       *metricVec
+}
+
+func NewServer(ctx context.Context, router adapter.Router, logger log.Logger, options option.MetricsOptions) (*Server, error) {
+	if options.Listen == "" {
+		return nil, E.New("missing listen address")
+	}
+	path := options.Path
+	if path == "" {
+		path = "/metrics"
+	}
+	server := &Server{
+		ctx:             ctx,
+		router:          router,
+		logger:          logger,
+		inboundTraffic:  newMetricVec("sing_box_inbound_bytes_total", "Bytes transferred by inbound.", metricTypeCounter, "inbound", "direction"),
+		outboundTraffic: newMetricVec("sing_box_outbound_bytes_total", "Bytes transferred by outbound.", metricTypeCounter, "outbound", "direction"),
+		userTraffic:     newMetricVec("sing_box_user_bytes_total", "Bytes transferred by user.", metricTypeCounter, "user", "direction"),
+		connections:     newMetricVec("sing_box_active_connections", "Active routed connections.", metricTypeGauge, "network"),
+		ruleMatches:     newMetricVec("sing_box_rule_matches_total", "Routed connections by matched rule index.", metricTypeCounter, "rule_index", "target"),
+		dnsQueries:      newHistogramVec("sing_box_dns_query_duration_seconds", "DNS query latency by transport.", dnsDurationBuckets, "transport"),
+		dnsErrors:       newMetricVec("sing_box_dns_query_errors_total", "Failed DNS queries by transport.", metricTypeCounter, "transport"),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, server.serveMetrics)
+	server.httpServer = &http.Server{
+		Addr:    options.Listen,
+		Handler: mux,
+	}
+	return server, nil
+}
+
+func (s *Server) Start() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return E.Cause(err, "metrics listen error")
+	}
+	s.logger.Info("metrics server listening at ", listener.Addr())
+	go func() {
+		err = s.httpServer.Serve(listener)
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("metrics serve error: ", err)
+		}
+	}()
+	return nil
+}
+
+func (s *Server) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.httpServer),
+	)
+}
+
+func (s *Server) RoutedConnection(inbound string, outbound string, user string, conn net.Conn) net.Conn {
+	readCounter, writeCounter := s.trafficCounters(inbound, outbound, user)
+	gauge := s.connections.With(N.NetworkTCP)
+	gauge.Add(1)
+	return &trackedConn{
+		ExtendedConn: bufio.NewInt64CounterConn(conn, readCounter, writeCounter),
+		gauge:        gauge,
+	}
+}
+
+func (s *Server) RoutedPacketConnection(inbound string, outbound string, user string, conn N.PacketConn) N.PacketConn {
+	readCounter, writeCounter := s.trafficCounters(inbound, outbound, user)
+	gauge := s.connections.With(N.NetworkUDP)
+	gauge.Add(1)
+	return &trackedPacketConn{
+		PacketConn: bufio.NewInt64CounterPacketConn(conn, readCounter, writeCounter),
+		gauge:      gauge,
+	}
+}
+
+func (s *Server) trafficCounters(inbound string, outbound string, user string) (readCounter []*atomic.Int64, writeCounter []*atomic.Int64) {
+	if inbound != "" {
+		readCounter = append(readCounter, s.inboundTraffic.With(inbound, "uplink"))
+		writeCounter = append(writeCounter, s.inboundTraffic.With(inbound, "downlink"))
+	}
+	if outbound != "" {
+		readCounter = append(readCounter, s.outboundTraffic.With(outbound, "uplink"))
+		writeCounter = append(writeCounter, s.outboundTraffic.With(outbound, "downlink"))
+	}
+	if user != "" {
+		readCounter = append(readCounter, s.userTraffic.With(user, "uplink"))
+		writeCounter = append(writeCounter, s.userTraffic.With(user, "downlink"))
+	}
+	return
+}
+
+func (s *Server) RuleMatched(index int, target string) {
+	ruleIndex := "final"
+	if index >= 0 {
+		ruleIndex = F.ToString(index)
+	}
+	s.ruleMatches.With(ruleIndex, target).Add(1)
+}
+
+func (s *Server) DNSExchanged(transport string, duration time.Duration, err error) {
+	if err != nil {
+		s.dnsErrors.With(transport).Add(1)
+		return
+	}
+	s.dnsQueries.Observe(duration.Seconds(), transport)
+}
+
+func (s *Server) serveMetrics(w http.ResponseWriter, r *http.Request) {
+	var buffer bytes.Buffer
+	s.inboundTraffic.Write(&buffer)
+	s.outboundTraffic.Write(&buffer)
+	s.userTraffic.Write(&buffer)
+	s.connections.Write(&buffer)
+	s.ruleMatches.Write(&buffer)
+	s.dnsQueries.Write(&buffer)
+	s.dnsErrors.Write(&buffer)
+	s.writeURLTestDelay(&buffer)
+	w.Header().Set("Content-Type", contentType)
+	w.Write(buffer.Bytes())
+}
+
+// writeURLTestDelay exports the latest health check result of every outbound,
+// including outbounds of providers, at scrape time.
+func (s *Server) writeURLTestDelay(buffer *bytes.Buffer) {
+	delay := newMetricVec("sing_box_outbound_delay_milliseconds", "Latest URL test delay by outbound.", metricTypeGauge, "outbound")
+	lastTest := newMetricVec("sing_box_outbound_delay_timestamp_seconds", "Time of the latest URL test by outbound.", metricTypeGauge, "outbound")
+	history := service.PtrFromContext[urltest.HistoryStorage](s.ctx)
+	if history == nil {
+		if clashServer := s.router.ClashServer(); clashServer != nil {
+			history = clashServer.HistoryStorage()
+		}
+	}
+	if history != nil {
+		outbounds := append([]adapter.Outbound{}, s.router.Outbounds()...)
+		for _, provider := range s.router.OutboundProviders() {
+			if provider.Type() == C.TypeMergeProvider {
+				continue
+			}
+			outbounds = append(outbounds, provider.Outbounds()...)
+		}
+		for _, detour := range outbounds {
+			if _, isGroup := detour.(adapter.OutboundGroup); isGroup {
+				continue
+			}
+			result := history.LoadURLTestHistory(detour.Tag())
+			if result == nil {
+				continue
+			}
+			delay.With(detour.Tag()).Store(int64(result.Delay))
+			lastTest.With(detour.Tag()).Store(result.Time.Unix())
+		}
+	}
+	delay.Write(buffer)
+	lastTest.Write(buffer)
+}
+
+type trackedConn struct {
+	N.ExtendedConn
+	gauge     *atomic.Int64
+	closeOnce sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.gauge.Add(-1)
+	})
+	return c.ExtendedConn.Close()
+}
+
+func (c *trackedConn) Upstream() any {
+	return c.ExtendedConn
+}
+
+type trackedPacketConn struct {
+	N.PacketConn
+	gauge     *atomic.Int64
+	closeOnce sync.Once
+}
+
+func (c *trackedPacketConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.gauge.Add(-1)
+	})
+	return c.PacketConn.Close()
+}
+
+func (c *trackedPacketConn) Upstream() any {
+	return c.PacketConn
+}
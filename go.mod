@@ -53,6 +53,7 @@ require (
 	google.golang.org/protobuf v1.33.0
 	gopkg.in/yaml.v3 v3.0.1
 	howett.net/plist v1.0.1
+	lukechampine.com/blake3 v1.2.1
 )
 
 replace github.com/sagernet/sing v0.4.0-beta.3 => github.com/puernya/sing v0.0.0-20240315152407-4d521945d243
@@ -97,5 +98,4 @@ require (
 	golang.org/x/tools v0.19.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20240123012728-ef4313101c80 // indirect
 	gopkg.in/check.v1 v1.0.0-20200227125254-8fa46927fb4f // indirect
-	lukechampine.com/blake3 v1.2.1 // indirect
 )
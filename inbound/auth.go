@@ -0,0 +1,230 @@
+package inbound
+
+import (
+	std_bufio "bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/httpauth"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/auth"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	sHttp "github.com/sagernet/sing/protocol/http"
+	"github.com/sagernet/sing/protocol/socks"
+	"github.com/sagernet/sing/protocol/socks/socks4"
+	"github.com/sagernet/sing/protocol/socks/socks5"
+)
+
+func newExternalAuthenticator(router adapter.Router, options *option.ExternalAuthOptions) (adapter.Authenticator, error) {
+	if options == nil {
+		return nil, nil
+	}
+	authenticator, err := httpauth.NewAuthenticator(router, *options)
+	if err != nil {
+		return nil, E.Cause(err, "create external authenticator")
+	}
+	return authenticator, nil
+}
+
+// authenticate checks credentials against static users first, then the external authenticator,
+// and returns a context carrying the authenticated user.
+func authenticate(ctx context.Context, authenticator *auth.Authenticator, externalAuthenticator adapter.Authenticator, metadata adapter.InboundContext, username string, password string) (context.Context, error) {
+	if authenticator != nil && authenticator.Verify(username, password) {
+		return auth.ContextWithUser(ctx, username), nil
+	}
+	if externalAuthenticator == nil {
+		return nil, E.New("unknown user")
+	}
+	result, err := externalAuthenticator.Authenticate(ctx, metadata, username, password)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ContextWithUser(ctx, result), nil
+}
+
+// userFromContext fills the user authenticated by the protocol into metadata.
+func userFromContext(ctx context.Context, metadata *adapter.InboundContext) bool {
+	if result, loaded := auth.UserFromContext[*adapter.AuthResult](ctx); loaded {
+		metadata.User = result.User
+		metadata.UserLimit = result.Limit
+		return true
+	}
+	user, loaded := auth.UserFromContext[string](ctx)
+	if loaded {
+		metadata.User = user
+	}
+	return loaded
+}
+
+// authenticateHTTP checks the Proxy-Authorization header of the first request without consuming it.
+// Following requests on the same connection are served as the same user.
+func authenticateHTTP(ctx context.Context, conn net.Conn, reader *std_bufio.Reader, authenticator *auth.Authenticator, externalAuthenticator adapter.Authenticator, metadata adapter.InboundContext) (context.Context, error) {
+	request, err := peekHTTPRequest(reader)
+	if err != nil {
+		return nil, E.Cause(err, "read http request")
+	}
+	username, password, loaded := sHttp.ParseBasicAuth(request.Header.Get("Proxy-Authorization"))
+	if loaded {
+		ctx, err = authenticate(ctx, authenticator, externalAuthenticator, metadata, username, password)
+		if err == nil {
+			return ctx, nil
+		}
+	} else {
+		err = E.New("no Proxy-Authorization header")
+	}
+	_, writeErr := io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n"+
+		"Proxy-Authenticate: Basic realm=\"sing-box\" charset=\"UTF-8\"\r\n"+
+		"Connection: close\r\n"+
+		"Content-Length: 0\r\n\r\n")
+	return nil, E.Errors(E.Cause(err, "http: authentication failed, username=", username), writeErr)
+}
+
+func peekHTTPRequest(reader *std_bufio.Reader) (*http.Request, error) {
+	for {
+		size := reader.Buffered() + 1
+		if size > reader.Size() {
+			return nil, E.New("request header too large")
+		}
+		header, err := reader.Peek(size)
+		if err != nil {
+			return nil, err
+		}
+		header, _ = reader.Peek(reader.Buffered())
+		if index := bytes.Index(header, []byte("\r\n\r\n")); index != -1 {
+			return http.ReadRequest(std_bufio.NewReader(bytes.NewReader(header[:index+4])))
+		}
+	}
+}
+
+// handleSocksConnection serves SOCKS4 and SOCKS5 like socks.HandleConnection0,
+// verifying users with authenticate instead of a static user list.
+func handleSocksConnection(ctx context.Context, conn net.Conn, version byte, authenticate func(username string, password string) (context.Context, error), handler socks.Handler, metadata M.Metadata) error {
+	switch version {
+	case socks4.Version:
+		request, err := socks4.ReadRequest0(conn)
+		if err != nil {
+			return err
+		}
+		if request.Command != socks4.CommandConnect {
+			err = socks4.WriteResponse(conn, socks4.Response{
+				ReplyCode:   socks4.ReplyCodeRejectedOrFailed,
+				Destination: request.Destination,
+			})
+			if err != nil {
+				return err
+			}
+			return E.New("socks4: unsupported command ", request.Command)
+		}
+		userCtx, err := authenticate(request.Username, "")
+		if err != nil {
+			return E.Errors(E.Cause(err, "socks4: authentication failed, username=", request.Username), socks4.WriteResponse(conn, socks4.Response{
+				ReplyCode:   socks4.ReplyCodeRejectedOrFailed,
+				Destination: request.Destination,
+			}))
+		}
+		err = socks4.WriteResponse(conn, socks4.Response{
+			ReplyCode:   socks4.ReplyCodeGranted,
+			Destination: M.SocksaddrFromNet(conn.LocalAddr()),
+		})
+		if err != nil {
+			return err
+		}
+		metadata.Protocol = "socks4"
+		metadata.Destination = request.Destination
+		return handler.NewConnection(userCtx, conn, metadata)
+	case socks5.Version:
+		authRequest, err := socks5.ReadAuthRequest0(conn)
+		if err != nil {
+			return err
+		}
+		if !common.Contains(authRequest.Methods, socks5.AuthTypeUsernamePassword) {
+			return E.Errors(E.New("socks5: username/password authentication required"), socks5.WriteAuthResponse(conn, socks5.AuthResponse{
+				Method: socks5.AuthTypeNoAcceptedMethods,
+			}))
+		}
+		err = socks5.WriteAuthResponse(conn, socks5.AuthResponse{
+			Method: socks5.AuthTypeUsernamePassword,
+		})
+		if err != nil {
+			return err
+		}
+		authPassword, err := socks5.ReadUsernamePasswordAuthRequest(conn)
+		if err != nil {
+			return err
+		}
+		userCtx, err := authenticate(authPassword.Username, authPassword.Password)
+		if err != nil {
+			return E.Errors(E.Cause(err, "socks5: authentication failed, username=", authPassword.Username), socks5.WriteUsernamePasswordAuthResponse(conn, socks5.UsernamePasswordAuthResponse{
+				Status: socks5.UsernamePasswordStatusFailure,
+			}))
+		}
+		err = socks5.WriteUsernamePasswordAuthResponse(conn, socks5.UsernamePasswordAuthResponse{
+			Status: socks5.UsernamePasswordStatusSuccess,
+		})
+		if err != nil {
+			return err
+		}
+		request, err := socks5.ReadRequest(conn)
+		if err != nil {
+			return err
+		}
+		switch request.Command {
+		case socks5.CommandConnect:
+			err = socks5.WriteResponse(conn, socks5.Response{
+				ReplyCode: socks5.ReplyCodeSuccess,
+				Bind:      M.SocksaddrFromNet(conn.LocalAddr()),
+			})
+			if err != nil {
+				return err
+			}
+			metadata.Protocol = "socks5"
+			metadata.Destination = request.Destination
+			return handler.NewConnection(userCtx, conn, metadata)
+		case socks5.CommandUDPAssociate:
+			localAddr := M.AddrFromNetAddr(conn.LocalAddr())
+			udpConn, err := net.ListenUDP(M.NetworkFromNetAddr(N.NetworkUDP, localAddr), net.UDPAddrFromAddrPort(netip.AddrPortFrom(localAddr, 0)))
+			if err != nil {
+				return err
+			}
+			defer udpConn.Close()
+			err = socks5.WriteResponse(conn, socks5.Response{
+				ReplyCode: socks5.ReplyCodeSuccess,
+				Bind:      M.SocksaddrFromNet(udpConn.LocalAddr()),
+			})
+			if err != nil {
+				return err
+			}
+			metadata.Protocol = "socks5"
+			metadata.Destination = request.Destination
+			var innerError error
+			done := make(chan struct{})
+			associatePacketConn := socks.NewAssociatePacketConn(udpConn, request.Destination, conn)
+			go func() {
+				innerError = handler.NewPacketConnection(userCtx, associatePacketConn, metadata)
+				close(done)
+			}()
+			err = common.Error(io.Copy(io.Discard, conn))
+			associatePacketConn.Close()
+			<-done
+			return E.Errors(innerError, err)
+		default:
+			err = socks5.WriteResponse(conn, socks5.Response{
+				ReplyCode: socks5.ReplyCodeUnsupported,
+			})
+			if err != nil {
+				return err
+			}
+			return E.New("socks5: unsupported command ", request.Command)
+		}
+	}
+	return os.ErrInvalid
+}
@@ -25,11 +25,11 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, o
 	case C.TypeDirect:
 		return NewDirect(ctx, router, logger, options.Tag, options.DirectOptions), nil
 	case C.TypeSOCKS:
-		return NewSocks(ctx, router, logger, options.Tag, options.SocksOptions), nil
+		return NewSocks(ctx, router, logger, options.Tag, options.SocksOptions)
 	case C.TypeHTTP:
 		return NewHTTP(ctx, router, logger, options.Tag, options.HTTPOptions)
 	case C.TypeMixed:
-		return NewMixed(ctx, router, logger, options.Tag, options.MixedOptions), nil
+		return NewMixed(ctx, router, logger, options.Tag, options.MixedOptions)
 	case C.TypeShadowsocks:
 		return NewShadowsocks(ctx, router, logger, options.Tag, options.ShadowsocksOptions)
 	case C.TypeVMess:
@@ -48,6 +48,8 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, o
 		return NewTUIC(ctx, router, logger, options.Tag, options.TUICOptions)
 	case C.TypeHysteria2:
 		return NewHysteria2(ctx, router, logger, options.Tag, options.Hysteria2Options)
+	case C.TypeDNS:
+		return NewDNS(ctx, router, logger, options.Tag, options.DNSOptions)
 	default:
 		return nil, E.New("unknown inbound type: ", options.Type)
 	}
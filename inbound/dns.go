@@ -0,0 +1,273 @@
+package inbound
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/tls"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const dnsMessageMimeType = "application/dns-message"
+
+var _ adapter.Inbound = (*DNS)(nil)
+
+// DNS serves the DNS router over plain UDP and TCP, DNS over TLS, or DNS over HTTPS if path is set.
+type DNS struct {
+	myInboundAdapter
+	dnsRouter  adapter.Router
+	tlsConfig  tls.ServerConfig
+	path       string
+	httpServer *http.Server
+}
+
+func NewDNS(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.DNSInboundOptions) (*DNS, error) {
+	inbound := &DNS{
+		myInboundAdapter: myInboundAdapter{
+			protocol:      C.TypeDNS,
+			network:       options.Network.Build(),
+			ctx:           ctx,
+			router:        router,
+			logger:        logger,
+			tag:           tag,
+			listenOptions: options.ListenOptions,
+		},
+		dnsRouter: router,
+		path:      options.Path,
+	}
+	if options.TLS != nil && options.TLS.Enabled {
+		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
+		if err != nil {
+			return nil, err
+		}
+		inbound.tlsConfig = tlsConfig
+	}
+	if inbound.tlsConfig != nil || inbound.path != "" {
+		if options.Network == N.NetworkUDP {
+			return nil, E.New("UDP is not supported by DNS over TLS or HTTPS")
+		}
+		inbound.network = []string{N.NetworkTCP}
+	}
+	if inbound.path != "" && !strings.HasPrefix(inbound.path, "/") {
+		return nil, E.New("path must start with /")
+	}
+	inbound.connHandler = inbound
+	inbound.packetHandler = inbound
+	return inbound, nil
+}
+
+func (d *DNS) Start() error {
+	var tlsConfig *tls.STDConfig
+	if d.tlsConfig != nil {
+		err := d.tlsConfig.Start()
+		if err != nil {
+			return E.Cause(err, "create TLS config")
+		}
+		if d.path != "" {
+			tlsConfig, err = d.tlsConfig.Config()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	if d.path == "" {
+		return d.myInboundAdapter.Start()
+	}
+	tcpListener, err := d.ListenTCP()
+	if err != nil {
+		return err
+	}
+	d.httpServer = &http.Server{
+		Handler:   d,
+		TLSConfig: tlsConfig,
+		BaseContext: func(listener net.Listener) context.Context {
+			return d.ctx
+		},
+	}
+	go func() {
+		var sErr error
+		if tlsConfig != nil {
+			sErr = d.httpServer.ServeTLS(tcpListener, "", "")
+		} else {
+			sErr = d.httpServer.Serve(tcpListener)
+		}
+		if sErr != nil && !E.IsClosedOrCanceled(sErr) {
+			d.logger.Error("http server serve error: ", sErr)
+		}
+	}()
+	return nil
+}
+
+func (d *DNS) Close() error {
+	return common.Close(
+		&d.myInboundAdapter,
+		common.PtrOrNil(d.httpServer),
+		d.tlsConfig,
+	)
+}
+
+func (d *DNS) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	var err error
+	if d.tlsConfig != nil {
+		conn, err = tls.ServerHandshake(ctx, conn, d.tlsConfig)
+		if err != nil {
+			return err
+		}
+	}
+	defer conn.Close()
+	metadata.Network = N.NetworkTCP
+	metadata.Destination = M.Socksaddr{}
+	var writeAccess sync.Mutex
+	for {
+		var queryLength uint16
+		err = binary.Read(conn, binary.BigEndian, &queryLength)
+		if err != nil {
+			if E.IsClosedOrCanceled(err) || err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		if queryLength == 0 {
+			return dns.RCodeFormatError
+		}
+		buffer := buf.NewSize(int(queryLength))
+		_, err = buffer.ReadFullFrom(conn, int(queryLength))
+		if err != nil {
+			buffer.Release()
+			return err
+		}
+		var message mDNS.Msg
+		err = message.Unpack(buffer.Bytes())
+		buffer.Release()
+		if err != nil {
+			return err
+		}
+		go func() {
+			response := d.exchange(ctx, &message, metadata)
+			responseBuffer := buf.NewPacket()
+			defer responseBuffer.Release()
+			responseBuffer.Resize(2, 0)
+			rawResponse, err := response.PackBuffer(responseBuffer.FreeBytes())
+			if err != nil {
+				d.NewError(ctx, E.Cause(err, "pack response"))
+				return
+			}
+			responseBuffer.Truncate(len(rawResponse))
+			binary.BigEndian.PutUint16(responseBuffer.ExtendHeader(2), uint16(len(rawResponse)))
+			writeAccess.Lock()
+			_, err = conn.Write(responseBuffer.Bytes())
+			writeAccess.Unlock()
+			if err != nil {
+				conn.Close()
+			}
+		}()
+	}
+}
+
+func (d *DNS) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
+	var message mDNS.Msg
+	err := message.Unpack(buffer.Bytes())
+	if err != nil {
+		return E.Cause(err, "unpack query")
+	}
+	metadata.Network = N.NetworkUDP
+	ctx = log.ContextWithNewID(ctx)
+	go func() {
+		response := d.exchange(ctx, &message, metadata)
+		responseBuffer, err := dns.TruncateDNSMessage(&message, response, 0)
+		if err != nil {
+			d.NewError(ctx, E.Cause(err, "pack response"))
+			return
+		}
+		err = conn.WritePacket(responseBuffer, metadata.Source)
+		if err != nil {
+			responseBuffer.Release()
+		}
+	}()
+	return nil
+}
+
+func (d *DNS) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	if request.URL.Path != d.path {
+		writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+	var (
+		content []byte
+		err     error
+	)
+	switch request.Method {
+	case http.MethodGet:
+		content, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(request.URL.Query().Get("dns"), "="))
+	case http.MethodPost:
+		if request.Header.Get("Content-Type") != dnsMessageMimeType {
+			writer.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		content, err = io.ReadAll(io.LimitReader(request.Body, mDNS.MaxMsgSize))
+	default:
+		writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var message mDNS.Msg
+	if err == nil {
+		err = message.Unpack(content)
+	}
+	if err != nil {
+		writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	ctx := log.ContextWithNewID(request.Context())
+	var metadata adapter.InboundContext
+	metadata.Inbound = d.tag
+	metadata.InboundType = d.protocol
+	metadata.InboundDetour = d.listenOptions.Detour
+	metadata.InboundOptions = d.listenOptions.InboundOptions
+	metadata.Network = N.NetworkTCP
+	metadata.Source = M.ParseSocksaddr(request.RemoteAddr).Unwrap()
+	response := d.exchange(ctx, &message, metadata)
+	rawResponse, err := response.Pack()
+	if err != nil {
+		d.NewError(ctx, E.Cause(err, "pack response"))
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writer.Header().Set("Content-Type", dnsMessageMimeType)
+	writer.WriteHeader(http.StatusOK)
+	writer.Write(rawResponse)
+}
+
+// exchange answers the query from the DNS router, errors are turned into a response with the matching rcode.
+func (d *DNS) exchange(ctx context.Context, message *mDNS.Msg, metadata adapter.InboundContext) *mDNS.Msg {
+	response, err := d.dnsRouter.Exchange(adapter.WithContext(ctx, &metadata), message)
+	if err == nil {
+		response.Id = message.Id
+		return response
+	}
+	rcode := mDNS.RcodeServerFailure
+	if rcodeError, isRCodeError := err.(dns.RCodeError); isRCodeError {
+		rcode = int(rcodeError)
+	} else {
+		d.NewError(ctx, E.Cause(err, "exchange query from ", metadata.Source))
+	}
+	response = new(mDNS.Msg)
+	response.SetRcode(message, rcode)
+	return response
+}
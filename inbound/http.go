@@ -26,11 +26,16 @@ var (
 
 type HTTP struct {
 	myInboundAdapter
-	authenticator *auth.Authenticator
-	tlsConfig     tls.ServerConfig
+	authenticator         *auth.Authenticator
+	externalAuthenticator adapter.Authenticator
+	tlsConfig             tls.ServerConfig
 }
 
 func NewHTTP(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPMixedInboundOptions) (*HTTP, error) {
+	externalAuthenticator, err := newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
 	inbound := &HTTP{
 		myInboundAdapter: myInboundAdapter{
 			protocol:       C.TypeHTTP,
@@ -42,7 +47,8 @@ func NewHTTP(ctx context.Context, router adapter.Router, logger log.ContextLogge
 			listenOptions:  options.ListenOptions,
 			setSystemProxy: options.SetSystemProxy,
 		},
-		authenticator: auth.NewAuthenticator(options.Users),
+		authenticator:         auth.NewAuthenticator(options.Users),
+		externalAuthenticator: externalAuthenticator,
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -80,7 +86,15 @@ func (h *HTTP) NewConnection(ctx context.Context, conn net.Conn, metadata adapte
 			return err
 		}
 	}
-	return http.HandleConnection(ctx, conn, std_bufio.NewReader(conn), h.authenticator, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
+	reader := std_bufio.NewReader(conn)
+	if h.externalAuthenticator != nil {
+		ctx, err = authenticateHTTP(ctx, conn, reader, h.authenticator, h.externalAuthenticator, metadata)
+		if err != nil {
+			return err
+		}
+		return http.HandleConnection(ctx, conn, reader, nil, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
+	}
+	return http.HandleConnection(ctx, conn, reader, h.authenticator, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
 }
 
 func (h *HTTP) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
@@ -92,23 +106,19 @@ func (a *myInboundAdapter) upstreamUserHandler(metadata adapter.InboundContext)
 }
 
 func (a *myInboundAdapter) newUserConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
-	user, loaded := auth.UserFromContext[string](ctx)
-	if !loaded {
+	if !userFromContext(ctx, &metadata) {
 		a.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
 		return a.router.RouteConnection(ctx, conn, metadata)
 	}
-	metadata.User = user
-	a.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
+	a.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection to ", metadata.Destination)
 	return a.router.RouteConnection(ctx, conn, metadata)
 }
 
 func (a *myInboundAdapter) streamUserPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
-	user, loaded := auth.UserFromContext[string](ctx)
-	if !loaded {
+	if !userFromContext(ctx, &metadata) {
 		a.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
 		return a.router.RoutePacketConnection(ctx, conn, metadata)
 	}
-	metadata.User = user
-	a.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
+	a.logger.InfoContext(ctx, "[", metadata.User, "] inbound packet connection to ", metadata.Destination)
 	return a.router.RoutePacketConnection(ctx, conn, metadata)
 }
@@ -15,21 +15,27 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/log"
 	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/hysteria2"
 	"github.com/sagernet/sing-quic/hysteria"
-	"github.com/sagernet/sing-quic/hysteria2"
+	qHysteria2 "github.com/sagernet/sing-quic/hysteria2"
 	"github.com/sagernet/sing/common"
 	"github.com/sagernet/sing/common/auth"
 	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
 )
 
-var _ adapter.Inbound = (*Hysteria2)(nil)
+var (
+	_ adapter.Inbound            = (*Hysteria2)(nil)
+	_ adapter.ManagedUserInbound = (*Hysteria2)(nil)
+)
 
 type Hysteria2 struct {
 	myInboundAdapter
-	tlsConfig    tls.ServerConfig
-	service      *hysteria2.Service[int]
-	userNameList []string
+	tlsConfig             tls.ServerConfig
+	service               *hysteria2.Service[int]
+	users                 inboundUsers
+	externalAuthenticator adapter.Authenticator
 }
 
 func NewHysteria2(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.Hysteria2InboundOptions) (*Hysteria2, error) {
@@ -47,7 +53,7 @@ func NewHysteria2(ctx context.Context, router adapter.Router, logger log.Context
 			return nil, E.New("missing obfs password")
 		}
 		switch options.Obfs.Type {
-		case hysteria2.ObfsTypeSalamander:
+		case qHysteria2.ObfsTypeSalamander:
 			salamanderPassword = options.Obfs.Password
 		default:
 			return nil, E.New("unknown obfs type: ", options.Obfs.Type)
@@ -88,6 +94,14 @@ func NewHysteria2(ctx context.Context, router adapter.Router, logger log.Context
 		},
 		tlsConfig: tlsConfig,
 	}
+	inbound.externalAuthenticator, err = newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
+	var serviceAuthenticator hysteria2.Authenticator
+	if inbound.externalAuthenticator != nil {
+		serviceAuthenticator = inbound.authenticate
+	}
 	var udpTimeout time.Duration
 	if options.UDPTimeout != 0 {
 		udpTimeout = time.Duration(options.UDPTimeout)
@@ -106,31 +120,73 @@ func NewHysteria2(ctx context.Context, router adapter.Router, logger log.Context
 		UDPTimeout:            udpTimeout,
 		Handler:               adapter.NewUpstreamHandler(adapter.InboundContext{}, inbound.newConnection, inbound.newPacketConnection, nil),
 		MasqueradeHandler:     masqueradeHandler,
+		Authenticator:         serviceAuthenticator,
 	})
 	if err != nil {
 		return nil, err
 	}
-	userList := make([]int, 0, len(options.Users))
-	userNameList := make([]string, 0, len(options.Users))
-	userPasswordList := make([]string, 0, len(options.Users))
-	for index, user := range options.Users {
-		userList = append(userList, index)
-		userNameList = append(userNameList, user.Name)
-		userPasswordList = append(userPasswordList, user.Password)
-	}
-	service.UpdateUsers(userList, userPasswordList)
 	inbound.service = service
-	inbound.userNameList = userNameList
+	err = inbound.UpdateUsers(common.Map(options.Users, func(it option.Hysteria2User) adapter.InboundUser {
+		return adapter.InboundUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
+	}))
+	if err != nil {
+		return nil, err
+	}
 	return inbound, nil
 }
 
+func (h *Hysteria2) Users() []adapter.InboundUser {
+	return h.users.List()
+}
+
+func (h *Hysteria2) UpdateUsers(users []adapter.InboundUser) error {
+	return h.users.Update(users, func(ids []int) error {
+		h.service.UpdateUsers(ids, common.Map(users, func(it adapter.InboundUser) string {
+			return it.Password
+		}))
+		return nil
+	})
+}
+
+// authenticate checks passwords not matching any user with the external authenticator.
+func (h *Hysteria2) authenticate(ctx context.Context, password string, source M.Socksaddr) (context.Context, error) {
+	result, err := h.externalAuthenticator.Authenticate(ctx, adapter.InboundContext{
+		Inbound:     h.tag,
+		InboundType: h.protocol,
+		Source:      source,
+	}, "", password)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ContextWithUser(ctx, result), nil
+}
+
+// loadUser fills the user of the connection into metadata, and returns its name.
+func (h *Hysteria2) loadUser(ctx context.Context, metadata *adapter.InboundContext) (string, error) {
+	if userFromContext(ctx, metadata) {
+		return metadata.User, nil
+	}
+	userID, _ := auth.UserFromContext[int](ctx)
+	user, loaded := h.users.Load(userID)
+	if !loaded {
+		return "", E.New("user removed")
+	}
+	metadata.User = user.Name
+	return user.Name, nil
+}
+
 func (h *Hysteria2) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
 	ctx = log.ContextWithNewID(ctx)
 	metadata = h.createMetadata(conn, metadata)
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
-	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
-		metadata.User = userName
+	userName, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
+	}
+	if userName != "" {
 		h.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", metadata.Destination)
 	} else {
 		h.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
@@ -142,9 +198,11 @@ func (h *Hysteria2) newPacketConnection(ctx context.Context, conn N.PacketConn,
 	ctx = log.ContextWithNewID(ctx)
 	metadata = h.createPacketMetadata(conn, metadata)
 	h.logger.InfoContext(ctx, "inbound packet connection from ", metadata.Source)
-	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
-		metadata.User = userName
+	userName, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
+	}
+	if userName != "" {
 		h.logger.InfoContext(ctx, "[", userName, "] inbound packet connection to ", metadata.Destination)
 	} else {
 		h.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
@@ -29,10 +29,15 @@ var (
 
 type Mixed struct {
 	myInboundAdapter
-	authenticator *auth.Authenticator
+	authenticator         *auth.Authenticator
+	externalAuthenticator adapter.Authenticator
 }
 
-func NewMixed(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPMixedInboundOptions) *Mixed {
+func NewMixed(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.HTTPMixedInboundOptions) (*Mixed, error) {
+	externalAuthenticator, err := newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
 	inbound := &Mixed{
 		myInboundAdapter{
 			protocol:       C.TypeMixed,
@@ -45,9 +50,10 @@ func NewMixed(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			setSystemProxy: options.SetSystemProxy,
 		},
 		auth.NewAuthenticator(options.Users),
+		externalAuthenticator,
 	}
 	inbound.connHandler = inbound
-	return inbound
+	return inbound, nil
 }
 
 func (h *Mixed) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
@@ -57,9 +63,21 @@ func (h *Mixed) NewConnection(ctx context.Context, conn net.Conn, metadata adapt
 	}
 	switch headerType {
 	case socks4.Version, socks5.Version:
+		if h.externalAuthenticator != nil {
+			return handleSocksConnection(ctx, conn, headerType, func(username string, password string) (context.Context, error) {
+				return authenticate(ctx, h.authenticator, h.externalAuthenticator, metadata, username, password)
+			}, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
+		}
 		return socks.HandleConnection0(ctx, conn, headerType, h.authenticator, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
 	}
 	reader := std_bufio.NewReader(bufio.NewCachedReader(conn, buf.As([]byte{headerType})))
+	if h.externalAuthenticator != nil {
+		ctx, err = authenticateHTTP(ctx, conn, reader, h.authenticator, h.externalAuthenticator, metadata)
+		if err != nil {
+			return err
+		}
+		return http.HandleConnection(ctx, conn, reader, nil, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
+	}
 	return http.HandleConnection(ctx, conn, reader, h.authenticator, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
 }
 
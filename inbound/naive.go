@@ -31,10 +31,11 @@ var _ adapter.Inbound = (*Naive)(nil)
 
 type Naive struct {
 	myInboundAdapter
-	authenticator *auth.Authenticator
-	tlsConfig     tls.ServerConfig
-	httpServer    *http.Server
-	h3Server      any
+	authenticator         *auth.Authenticator
+	externalAuthenticator adapter.Authenticator
+	tlsConfig             tls.ServerConfig
+	httpServer            *http.Server
+	h3Server              any
 }
 
 func NewNaive(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.NaiveInboundOptions) (*Naive, error) {
@@ -55,9 +56,14 @@ func NewNaive(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			return nil, E.New("TLS is required for QUIC server")
 		}
 	}
-	if len(options.Users) == 0 {
+	if len(options.Users) == 0 && options.ExternalAuth == nil {
 		return nil, E.New("missing users")
 	}
+	var err error
+	inbound.externalAuthenticator, err = newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
@@ -138,9 +144,16 @@ func (n *Naive) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 		n.badRequest(ctx, request, E.New("missing naive padding"))
 		return
 	}
+	source := sHttp.SourceAddress(request)
+	var metadata adapter.InboundContext
 	userName, password, authOk := sHttp.ParseBasicAuth(request.Header.Get("Proxy-Authorization"))
 	if authOk {
-		authOk = n.authenticator.Verify(userName, password)
+		userCtx, err := authenticate(ctx, n.authenticator, n.externalAuthenticator, adapter.InboundContext{
+			Inbound:     n.tag,
+			InboundType: n.protocol,
+			Source:      source,
+		}, userName, password)
+		authOk = err == nil && userFromContext(userCtx, &metadata)
 	}
 	if !authOk {
 		rejectHTTP(writer, http.StatusProxyAuthRequired)
@@ -155,8 +168,8 @@ func (n *Naive) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 	if hostPort == "" {
 		hostPort = request.Host
 	}
-	source := sHttp.SourceAddress(request)
-	destination := M.ParseSocksaddr(hostPort)
+	metadata.Source = source
+	metadata.Destination = M.ParseSocksaddr(hostPort)
 
 	if hijacker, isHijacker := writer.(http.Hijacker); isHijacker {
 		conn, _, err := hijacker.Hijack()
@@ -164,28 +177,24 @@ func (n *Naive) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
 			n.badRequest(ctx, request, E.New("hijack failed"))
 			return
 		}
-		n.newConnection(ctx, &naiveH1Conn{Conn: conn}, userName, source, destination)
+		n.newConnection(ctx, &naiveH1Conn{Conn: conn}, metadata)
 	} else {
-		n.newConnection(ctx, &naiveH2Conn{reader: request.Body, writer: writer, flusher: writer.(http.Flusher)}, userName, source, destination)
+		n.newConnection(ctx, &naiveH2Conn{reader: request.Body, writer: writer, flusher: writer.(http.Flusher)}, metadata)
 	}
 }
 
-func (n *Naive) newConnection(ctx context.Context, conn net.Conn, userName string, source, destination M.Socksaddr) {
-	if userName != "" {
-		n.logger.InfoContext(ctx, "[", userName, "] inbound connection from ", source)
-		n.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", destination)
+func (n *Naive) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) {
+	if metadata.User != "" {
+		n.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection from ", metadata.Source)
+		n.logger.InfoContext(ctx, "[", metadata.User, "] inbound connection to ", metadata.Destination)
 	} else {
-		n.logger.InfoContext(ctx, "inbound connection from ", source)
-		n.logger.InfoContext(ctx, "inbound connection to ", destination)
-	}
-	hErr := n.router.RouteConnection(ctx, conn, n.createMetadata(conn, adapter.InboundContext{
-		Source:      source,
-		Destination: destination,
-		User:        userName,
-	}))
+		n.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
+		n.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
+	}
+	hErr := n.router.RouteConnection(ctx, conn, n.createMetadata(conn, metadata))
 	if hErr != nil {
 		conn.Close()
-		n.NewError(ctx, E.Cause(hErr, "process connection from ", source))
+		n.NewError(ctx, E.Cause(hErr, "process connection from ", metadata.Source))
 	}
 }
 
@@ -26,7 +26,10 @@ func NewShadowsocks(ctx context.Context, router adapter.Router, logger log.Conte
 	if len(options.Users) > 0 && len(options.Destinations) > 0 {
 		return nil, E.New("users and destinations options must not be combined")
 	}
-	if len(options.Users) > 0 {
+	if options.ExternalAuth != nil && len(options.Destinations) > 0 {
+		return nil, E.New("external_auth and destinations options must not be combined")
+	}
+	if len(options.Users) > 0 || options.ExternalAuth != nil {
 		return newShadowsocksMulti(ctx, router, logger, tag, options)
 	} else if len(options.Destinations) > 0 {
 		return newShadowsocksRelay(ctx, router, logger, tag, options)
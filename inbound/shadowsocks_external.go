@@ -0,0 +1,237 @@
+package inbound
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-shadowsocks"
+	"github.com/sagernet/sing-shadowsocks/shadowaead"
+	"github.com/sagernet/sing-shadowsocks/shadowaead_2022"
+	"github.com/sagernet/sing/common/auth"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/bufio"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+
+	"lukechampine.com/blake3"
+)
+
+// shadowsocksExternalUsers serves Shadowsocks 2022 users not known to the multi-user service.
+// Requests only carry a hash of the user key, encrypted with the server key in the identity header,
+// so the hash is checked with the external authenticator, which returns the user key as password.
+// Requests of accepted users are passed to a single-user service of the user key without the identity header.
+type shadowsocksExternalUsers struct {
+	authenticator adapter.Authenticator
+	service       shadowsocks.MultiService[int]
+	handler       shadowsocks.Handler
+	method        string
+	keyLength     int
+	psk           []byte
+	udpBlock      cipher.Block
+	udpTimeout    int64
+	timeFunc      func() time.Time
+	access        sync.RWMutex
+	static        map[[aes.BlockSize]byte]bool
+	users         map[[aes.BlockSize]byte]*shadowsocksExternalUser
+}
+
+type shadowsocksExternalUser struct {
+	password string
+	service  shadowsocks.Service
+	udpBlock cipher.Block
+}
+
+func newShadowsocksExternalUsers(authenticator adapter.Authenticator, service shadowsocks.MultiService[int], handler shadowsocks.Handler, method string, password string, udpTimeout int64, timeFunc func() time.Time) (*shadowsocksExternalUsers, error) {
+	u := &shadowsocksExternalUsers{
+		authenticator: authenticator,
+		service:       service,
+		handler:       handler,
+		method:        method,
+		udpTimeout:    udpTimeout,
+		timeFunc:      timeFunc,
+		users:         make(map[[aes.BlockSize]byte]*shadowsocksExternalUser),
+	}
+	switch method {
+	case "2022-blake3-aes-128-gcm":
+		u.keyLength = 16
+	case "2022-blake3-aes-256-gcm":
+		u.keyLength = 32
+	default:
+		return nil, E.New("external_auth: unsupported method: ", method)
+	}
+	var err error
+	u.psk, err = u.key(password)
+	if err != nil {
+		return nil, err
+	}
+	u.udpBlock, err = aes.NewCipher(u.psk)
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// key decodes a password into a key the way the service does.
+func (u *shadowsocksExternalUsers) key(password string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(password)
+	if err != nil {
+		return nil, E.Cause(err, "decode psk")
+	}
+	if len(key) < u.keyLength {
+		return nil, shadowsocks.ErrBadKey
+	} else if len(key) > u.keyLength {
+		key = shadowaead_2022.Key(key, u.keyLength)
+	}
+	return key, nil
+}
+
+func shadowsocksKeyHash(key []byte) [aes.BlockSize]byte {
+	var hash [aes.BlockSize]byte
+	hash512 := blake3.Sum512(key)
+	copy(hash[:], hash512[:])
+	return hash
+}
+
+// UpdateUsers sets the passwords of users known to the multi-user service.
+func (u *shadowsocksExternalUsers) UpdateUsers(passwords []string) error {
+	static := make(map[[aes.BlockSize]byte]bool, len(passwords))
+	for _, password := range passwords {
+		key, err := u.key(password)
+		if err != nil {
+			return err
+		}
+		static[shadowsocksKeyHash(key)] = true
+	}
+	u.access.Lock()
+	defer u.access.Unlock()
+	u.static = static
+	for hash := range static {
+		delete(u.users, hash)
+	}
+	return nil
+}
+
+func (u *shadowsocksExternalUsers) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	// the services read the fixed part of the request header in a single read, so all of it is cached
+	header := make([]byte, u.keyLength+aes.BlockSize+shadowaead.Overhead+shadowaead_2022.RequestHeaderFixedChunkLength)
+	_, err := io.ReadFull(conn, header)
+	if err != nil {
+		return E.Cause(err, "read header")
+	}
+	salt := header[:u.keyLength]
+	var hash [aes.BlockSize]byte
+	keyMaterial := make([]byte, u.keyLength*2)
+	copy(keyMaterial, u.psk)
+	copy(keyMaterial[u.keyLength:], salt)
+	identitySubkey := make([]byte, u.keyLength)
+	blake3.DeriveKey(identitySubkey, "shadowsocks 2022 identity subkey", keyMaterial)
+	identityBlock, err := aes.NewCipher(identitySubkey)
+	if err != nil {
+		return err
+	}
+	identityBlock.Decrypt(hash[:], header[u.keyLength:u.keyLength+aes.BlockSize])
+	user, ctx, err := u.loadUser(ctx, metadata, hash)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return u.service.NewConnection(ctx, bufio.NewCachedConn(conn, buf.As(header).ToOwned()), adapter.UpstreamMetadata(metadata))
+	}
+	request := make([]byte, 0, len(header)-aes.BlockSize)
+	request = append(request, salt...)
+	request = append(request, header[u.keyLength+aes.BlockSize:]...)
+	return user.service.NewConnection(ctx, bufio.NewCachedConn(conn, buf.As(request).ToOwned()), adapter.UpstreamMetadata(metadata))
+}
+
+func (u *shadowsocksExternalUsers) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
+	if buffer.Len() < 2*aes.BlockSize {
+		return u.service.NewPacket(ctx, conn, buffer, adapter.UpstreamMetadata(metadata))
+	}
+	var packetHeader, hash [aes.BlockSize]byte
+	u.udpBlock.Decrypt(packetHeader[:], buffer.To(aes.BlockSize))
+	u.udpBlock.Decrypt(hash[:], buffer.Range(aes.BlockSize, 2*aes.BlockSize))
+	for i := range hash {
+		hash[i] ^= packetHeader[i]
+	}
+	user, ctx, err := u.loadUser(ctx, metadata, hash)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return u.service.NewPacket(ctx, conn, buffer, adapter.UpstreamMetadata(metadata))
+	}
+	user.udpBlock.Encrypt(buffer.Range(aes.BlockSize, 2*aes.BlockSize), packetHeader[:])
+	buffer.Advance(aes.BlockSize)
+	return user.service.NewPacket(ctx, conn, buffer, adapter.UpstreamMetadata(metadata))
+}
+
+// loadUser returns the external user of the key hash, or nil for users of the multi-user service.
+func (u *shadowsocksExternalUsers) loadUser(ctx context.Context, metadata adapter.InboundContext, hash [aes.BlockSize]byte) (*shadowsocksExternalUser, context.Context, error) {
+	u.access.RLock()
+	static := u.static[hash]
+	user := u.users[hash]
+	u.access.RUnlock()
+	if static {
+		return nil, ctx, nil
+	}
+	result, err := u.authenticator.AuthenticateKey(ctx, adapter.InboundContext{
+		Inbound:     metadata.Inbound,
+		InboundType: metadata.InboundType,
+		Source:      metadata.Source,
+	}, hex.EncodeToString(hash[:]))
+	if err != nil {
+		if user != nil {
+			u.access.Lock()
+			if u.users[hash] == user {
+				delete(u.users, hash)
+			}
+			u.access.Unlock()
+		}
+		return nil, nil, err
+	}
+	if user == nil || user.password != result.Password {
+		user, err = u.newUser(hash, result.Password)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return user, auth.ContextWithUser(ctx, result), nil
+}
+
+func (u *shadowsocksExternalUsers) newUser(hash [aes.BlockSize]byte, password string) (*shadowsocksExternalUser, error) {
+	key, err := u.key(password)
+	if err != nil {
+		return nil, E.Cause(err, "auth server password")
+	}
+	if shadowsocksKeyHash(key) != hash {
+		return nil, E.New("auth server password does not match the key")
+	}
+	service, err := shadowaead_2022.NewService(u.method, key, u.udpTimeout, u.handler, u.timeFunc)
+	if err != nil {
+		return nil, err
+	}
+	udpBlock, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	user := &shadowsocksExternalUser{
+		password: password,
+		service:  service,
+		udpBlock: udpBlock,
+	}
+	u.access.Lock()
+	defer u.access.Unlock()
+	if loaded := u.users[hash]; loaded != nil && loaded.password == password {
+		return loaded, nil
+	}
+	u.users[hash] = user
+	return user, nil
+}
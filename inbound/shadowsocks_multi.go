@@ -25,14 +25,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*ShadowsocksMulti)(nil)
-	_ adapter.InjectableInbound = (*ShadowsocksMulti)(nil)
+	_ adapter.Inbound            = (*ShadowsocksMulti)(nil)
+	_ adapter.InjectableInbound  = (*ShadowsocksMulti)(nil)
+	_ adapter.ManagedUserInbound = (*ShadowsocksMulti)(nil)
 )
 
 type ShadowsocksMulti struct {
 	myInboundAdapter
-	service shadowsocks.MultiService[int]
-	users   []option.ShadowsocksUser
+	service       shadowsocks.MultiService[int]
+	users         inboundUsers
+	externalUsers *shadowsocksExternalUsers
 }
 
 func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.ShadowsocksInboundOptions) (*ShadowsocksMulti, error) {
@@ -60,77 +62,129 @@ func newShadowsocksMulti(ctx context.Context, router adapter.Router, logger log.
 	} else {
 		udpTimeout = C.UDPTimeout
 	}
+	handler := adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound)
 	var service shadowsocks.MultiService[int]
 	if common.Contains(shadowaead_2022.List, options.Method) {
 		service, err = shadowaead_2022.NewMultiServiceWithPassword[int](
 			options.Method,
 			options.Password,
 			int64(udpTimeout.Seconds()),
-			adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound),
+			handler,
 			ntp.TimeFuncFromContext(ctx),
 		)
 	} else if common.Contains(shadowaead.List, options.Method) {
 		service, err = shadowaead.NewMultiService[int](
 			options.Method,
 			int64(udpTimeout.Seconds()),
-			adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound))
+			handler)
 	} else {
 		return nil, E.New("unsupported method: " + options.Method)
 	}
 	if err != nil {
 		return nil, err
 	}
-	err = service.UpdateUsersWithPasswords(common.MapIndexed(options.Users, func(index int, user option.ShadowsocksUser) int {
-		return index
-	}), common.Map(options.Users, func(user option.ShadowsocksUser) string {
-		return user.Password
+	inbound.service = service
+	inbound.packetUpstream = service
+	externalAuthenticator, err := newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
+	if externalAuthenticator != nil {
+		inbound.externalUsers, err = newShadowsocksExternalUsers(
+			externalAuthenticator,
+			service,
+			handler,
+			options.Method,
+			options.Password,
+			int64(udpTimeout.Seconds()),
+			ntp.TimeFuncFromContext(ctx),
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = inbound.UpdateUsers(common.Map(options.Users, func(user option.ShadowsocksUser) adapter.InboundUser {
+		return adapter.InboundUser{
+			Name:     user.Name,
+			Password: user.Password,
+		}
 	}))
 	if err != nil {
 		return nil, err
 	}
-	inbound.service = service
-	inbound.packetUpstream = service
-	inbound.users = options.Users
-	return inbound, err
+	return inbound, nil
+}
+
+func (h *ShadowsocksMulti) Users() []adapter.InboundUser {
+	return h.users.List()
+}
+
+func (h *ShadowsocksMulti) UpdateUsers(users []adapter.InboundUser) error {
+	return h.users.Update(users, func(ids []int) error {
+		passwords := common.Map(users, func(user adapter.InboundUser) string {
+			return user.Password
+		})
+		err := h.service.UpdateUsersWithPasswords(ids, passwords)
+		if err != nil || h.externalUsers == nil {
+			return err
+		}
+		return h.externalUsers.UpdateUsers(passwords)
+	})
 }
 
 func (h *ShadowsocksMulti) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
-	return h.service.NewConnection(adapter.WithContext(log.ContextWithNewID(ctx), &metadata), conn, adapter.UpstreamMetadata(metadata))
+	ctx = adapter.WithContext(log.ContextWithNewID(ctx), &metadata)
+	if h.externalUsers != nil {
+		return h.externalUsers.NewConnection(ctx, conn, metadata)
+	}
+	return h.service.NewConnection(ctx, conn, adapter.UpstreamMetadata(metadata))
 }
 
 func (h *ShadowsocksMulti) NewPacket(ctx context.Context, conn N.PacketConn, buffer *buf.Buffer, metadata adapter.InboundContext) error {
-	return h.service.NewPacket(adapter.WithContext(ctx, &metadata), conn, buffer, adapter.UpstreamMetadata(metadata))
+	ctx = adapter.WithContext(ctx, &metadata)
+	if h.externalUsers != nil {
+		return h.externalUsers.NewPacket(ctx, conn, buffer, metadata)
+	}
+	return h.service.NewPacket(ctx, conn, buffer, adapter.UpstreamMetadata(metadata))
 }
 
 func (h *ShadowsocksMulti) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
 	return os.ErrInvalid
 }
 
-func (h *ShadowsocksMulti) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+// loadUser fills the user of the connection into metadata, and returns its name for logging.
+func (h *ShadowsocksMulti) loadUser(ctx context.Context, metadata *adapter.InboundContext) (string, error) {
+	if userFromContext(ctx, metadata) {
+		return metadata.User, nil
+	}
 	userIndex, loaded := auth.UserFromContext[int](ctx)
 	if !loaded {
-		return os.ErrInvalid
+		return "", os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
-	if user == "" {
-		user = F.ToString(userIndex)
-	} else {
-		metadata.User = user
+	inboundUser, loaded := h.users.Load(userIndex)
+	if !loaded {
+		return "", E.New("user removed")
+	}
+	if inboundUser.Name == "" {
+		return F.ToString(userIndex), nil
+	}
+	metadata.User = inboundUser.Name
+	return inboundUser.Name, nil
+}
+
+func (h *ShadowsocksMulti) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
 	return h.router.RouteConnection(ctx, conn, metadata)
 }
 
 func (h *ShadowsocksMulti) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
-	userIndex, loaded := auth.UserFromContext[int](ctx)
-	if !loaded {
-		return os.ErrInvalid
-	}
-	user := h.users[userIndex].Name
-	if user == "" {
-		user = F.ToString(userIndex)
-	} else {
-		metadata.User = user
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
 	}
 	ctx = log.ContextWithNewID(ctx)
 	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection from ", metadata.Source)
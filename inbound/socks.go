@@ -12,6 +12,7 @@ import (
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common/auth"
 	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/common/rw"
 	"github.com/sagernet/sing/protocol/socks"
 )
 
@@ -22,10 +23,15 @@ var (
 
 type Socks struct {
 	myInboundAdapter
-	authenticator *auth.Authenticator
+	authenticator         *auth.Authenticator
+	externalAuthenticator adapter.Authenticator
 }
 
-func NewSocks(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SocksInboundOptions) *Socks {
+func NewSocks(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.SocksInboundOptions) (*Socks, error) {
+	externalAuthenticator, err := newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
 	inbound := &Socks{
 		myInboundAdapter{
 			protocol:      C.TypeSOCKS,
@@ -37,12 +43,22 @@ func NewSocks(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			listenOptions: options.ListenOptions,
 		},
 		auth.NewAuthenticator(options.Users),
+		externalAuthenticator,
 	}
 	inbound.connHandler = inbound
-	return inbound
+	return inbound, nil
 }
 
 func (h *Socks) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	if h.externalAuthenticator != nil {
+		version, err := rw.ReadByte(conn)
+		if err != nil {
+			return err
+		}
+		return handleSocksConnection(ctx, conn, version, func(username string, password string) (context.Context, error) {
+			return authenticate(ctx, h.authenticator, h.externalAuthenticator, metadata, username, password)
+		}, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
+	}
 	return socks.HandleConnection(ctx, conn, h.authenticator, h.upstreamUserHandler(metadata), adapter.UpstreamMetadata(metadata))
 }
 
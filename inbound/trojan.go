@@ -22,14 +22,16 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*Trojan)(nil)
-	_ adapter.InjectableInbound = (*Trojan)(nil)
+	_ adapter.Inbound            = (*Trojan)(nil)
+	_ adapter.InjectableInbound  = (*Trojan)(nil)
+	_ adapter.ManagedUserInbound = (*Trojan)(nil)
 )
 
 type Trojan struct {
 	myInboundAdapter
 	service                  *trojan.Service[int]
-	users                    []option.TrojanUser
+	users                    inboundUsers
+	externalAuthenticator    adapter.Authenticator
 	tlsConfig                tls.ServerConfig
 	fallbackAddr             M.Socksaddr
 	fallbackAddrTLSNextProto map[string]M.Socksaddr
@@ -47,7 +49,6 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		users: options.Users,
 	}
 	if options.TLS != nil {
 		tlsConfig, err := tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
@@ -80,15 +81,23 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 		}
 		fallbackHandler = adapter.NewUpstreamContextHandler(inbound.fallbackConnection, nil, nil)
 	}
-	service := trojan.NewService[int](adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), fallbackHandler)
-	err := service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.TrojanUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.TrojanUser) string {
-		return it.Password
+	inbound.service = trojan.NewService[int](adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), fallbackHandler)
+	err := inbound.UpdateUsers(common.Map(options.Users, func(it option.TrojanUser) adapter.InboundUser {
+		return adapter.InboundUser{
+			Name:     it.Name,
+			Password: it.Password,
+		}
 	}))
 	if err != nil {
 		return nil, err
 	}
+	inbound.externalAuthenticator, err = newExternalAuthenticator(router, options.ExternalAuth)
+	if err != nil {
+		return nil, err
+	}
+	if inbound.externalAuthenticator != nil {
+		inbound.service.SetKeyAuthenticator(inbound.authenticateKey)
+	}
 	if options.Transport != nil {
 		inbound.transport, err = v2ray.NewServerTransport(ctx, common.PtrValueOrDefault(options.Transport), inbound.tlsConfig, (*trojanTransportHandler)(inbound))
 		if err != nil {
@@ -99,11 +108,22 @@ func NewTrojan(ctx context.Context, router adapter.Router, logger log.ContextLog
 	if err != nil {
 		return nil, err
 	}
-	inbound.service = service
 	inbound.connHandler = inbound
 	return inbound, nil
 }
 
+func (h *Trojan) Users() []adapter.InboundUser {
+	return h.users.List()
+}
+
+func (h *Trojan) UpdateUsers(users []adapter.InboundUser) error {
+	return h.users.Update(users, func(ids []int) error {
+		return h.service.UpdateUsers(ids, common.Map(users, func(it adapter.InboundUser) string {
+			return it.Password
+		}))
+	})
+}
+
 func (h *Trojan) Start() error {
 	if h.tlsConfig != nil {
 		err := h.tlsConfig.Start()
@@ -169,16 +189,43 @@ func (h *Trojan) NewPacketConnection(ctx context.Context, conn N.PacketConn, met
 	return os.ErrInvalid
 }
 
-func (h *Trojan) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+// loadUser fills the authenticated user into metadata and returns the name to log.
+func (h *Trojan) loadUser(ctx context.Context, metadata *adapter.InboundContext) (string, error) {
+	if userFromContext(ctx, metadata) {
+		return metadata.User, nil
+	}
 	userIndex, loaded := auth.UserFromContext[int](ctx)
 	if !loaded {
-		return os.ErrInvalid
+		return "", os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
-	if user == "" {
-		user = F.ToString(userIndex)
-	} else {
-		metadata.User = user
+	inboundUser, loaded := h.users.Load(userIndex)
+	if !loaded {
+		return "", E.New("user removed")
+	}
+	if inboundUser.Name == "" {
+		return F.ToString(userIndex), nil
+	}
+	metadata.User = inboundUser.Name
+	return inboundUser.Name, nil
+}
+
+// authenticateKey checks unknown keys with the external authenticator.
+func (h *Trojan) authenticateKey(ctx context.Context, key string, metadata M.Metadata) (context.Context, error) {
+	result, err := h.externalAuthenticator.AuthenticateKey(ctx, adapter.InboundContext{
+		Inbound:     h.tag,
+		InboundType: h.protocol,
+		Source:      metadata.Source,
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return auth.ContextWithUser(ctx, result), nil
+}
+
+func (h *Trojan) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound connection to ", metadata.Destination)
 	return h.router.RouteConnection(ctx, conn, metadata)
@@ -208,15 +255,9 @@ func (h *Trojan) fallbackConnection(ctx context.Context, conn net.Conn, metadata
 }
 
 func (h *Trojan) newPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
-	userIndex, loaded := auth.UserFromContext[int](ctx)
-	if !loaded {
-		return os.ErrInvalid
-	}
-	user := h.users[userIndex].Name
-	if user == "" {
-		user = F.ToString(userIndex)
-	} else {
-		metadata.User = user
+	user, err := h.loadUser(ctx, &metadata)
+	if err != nil {
+		return err
 	}
 	h.logger.InfoContext(ctx, "[", user, "] inbound packet connection to ", metadata.Destination)
 	return h.router.RoutePacketConnection(ctx, conn, metadata)
@@ -22,13 +22,16 @@ import (
 	"github.com/gofrs/uuid/v5"
 )
 
-var _ adapter.Inbound = (*TUIC)(nil)
+var (
+	_ adapter.Inbound            = (*TUIC)(nil)
+	_ adapter.ManagedUserInbound = (*TUIC)(nil)
+)
 
 type TUIC struct {
 	myInboundAdapter
-	tlsConfig    tls.ServerConfig
-	server       *tuic.Service[int]
-	userNameList []string
+	tlsConfig tls.ServerConfig
+	server    *tuic.Service[int]
+	users     inboundUsers
 }
 
 func NewTUIC(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.TUICInboundOptions) (*TUIC, error) {
@@ -72,27 +75,42 @@ func NewTUIC(ctx context.Context, router adapter.Router, logger log.ContextLogge
 	if err != nil {
 		return nil, err
 	}
-	var userList []int
-	var userNameList []string
-	var userUUIDList [][16]byte
-	var userPasswordList []string
-	for index, user := range options.Users {
+	inbound.server = service
+	err = inbound.UpdateUsers(common.Map(options.Users, func(it option.TUICUser) adapter.InboundUser {
+		return adapter.InboundUser{
+			Name:     it.Name,
+			UUID:     it.UUID,
+			Password: it.Password,
+		}
+	}))
+	if err != nil {
+		return nil, err
+	}
+	return inbound, nil
+}
+
+func (h *TUIC) Users() []adapter.InboundUser {
+	return h.users.List()
+}
+
+func (h *TUIC) UpdateUsers(users []adapter.InboundUser) error {
+	userUUIDList := make([][16]byte, 0, len(users))
+	for index, user := range users {
 		if user.UUID == "" {
-			return nil, E.New("missing uuid for user ", index)
+			return E.New("missing uuid for user ", index)
 		}
 		userUUID, err := uuid.FromString(user.UUID)
 		if err != nil {
-			return nil, E.Cause(err, "invalid uuid for user ", index)
+			return E.Cause(err, "invalid uuid for user ", index)
 		}
-		userList = append(userList, index)
-		userNameList = append(userNameList, user.Name)
 		userUUIDList = append(userUUIDList, userUUID)
-		userPasswordList = append(userPasswordList, user.Password)
 	}
-	service.UpdateUsers(userList, userUUIDList, userPasswordList)
-	inbound.server = service
-	inbound.userNameList = userNameList
-	return inbound, nil
+	return h.users.Update(users, func(ids []int) error {
+		h.server.UpdateUsers(ids, userUUIDList, common.Map(users, func(it adapter.InboundUser) string {
+			return it.Password
+		}))
+		return nil
+	})
 }
 
 func (h *TUIC) newConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
@@ -100,7 +118,11 @@ func (h *TUIC) newConnection(ctx context.Context, conn net.Conn, metadata adapte
 	metadata = h.createMetadata(conn, metadata)
 	h.logger.InfoContext(ctx, "inbound connection from ", metadata.Source)
 	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	user, loaded := h.users.Load(userID)
+	if !loaded {
+		return E.New("user removed")
+	}
+	if userName := user.Name; userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound connection to ", metadata.Destination)
 	} else {
@@ -114,7 +136,11 @@ func (h *TUIC) newPacketConnection(ctx context.Context, conn N.PacketConn, metad
 	metadata = h.createPacketMetadata(conn, metadata)
 	h.logger.InfoContext(ctx, "inbound packet connection from ", metadata.Source)
 	userID, _ := auth.UserFromContext[int](ctx)
-	if userName := h.userNameList[userID]; userName != "" {
+	user, loaded := h.users.Load(userID)
+	if !loaded {
+		return E.New("user removed")
+	}
+	if userName := user.Name; userName != "" {
 		metadata.User = userName
 		h.logger.InfoContext(ctx, "[", userName, "] inbound packet connection to ", metadata.Destination)
 	} else {
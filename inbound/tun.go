@@ -2,6 +2,7 @@ package inbound
 
 import (
 	"context"
+	"errors"
 	"net"
 	"strconv"
 	"strings"
@@ -15,6 +16,7 @@ import (
 	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing-tun"
 	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
 	E "github.com/sagernet/sing/common/exceptions"
 	M "github.com/sagernet/sing/common/metadata"
 	N "github.com/sagernet/sing/common/network"
@@ -209,6 +211,10 @@ func (t *Tun) NewConnection(ctx context.Context, conn net.Conn, upstreamMetadata
 	t.logger.InfoContext(ctx, "inbound connection to ", metadata.Destination)
 	err := t.router.RouteConnection(ctx, conn, metadata)
 	if err != nil {
+		if errors.Is(err, C.ErrRejected) {
+			// The stack resets the connection on error.
+			return err
+		}
 		t.NewError(ctx, err)
 	}
 	return nil
@@ -226,11 +232,23 @@ func (t *Tun) NewPacketConnection(ctx context.Context, conn N.PacketConn, upstre
 	t.logger.InfoContext(ctx, "inbound packet connection to ", metadata.Destination)
 	err := t.router.RoutePacketConnection(ctx, conn, metadata)
 	if err != nil {
+		if errors.Is(err, C.ErrRejected) {
+			t.writePortUnreachable(ctx, upstreamMetadata)
+			return nil
+		}
 		t.NewError(ctx, err)
 	}
 	return nil
 }
 
+func (t *Tun) writePortUnreachable(ctx context.Context, upstreamMetadata M.Metadata) {
+	packet := newPortUnreachable(upstreamMetadata.Source.Unwrap().AddrPort(), upstreamMetadata.Destination.Unwrap().AddrPort())
+	err := t.tunIf.WriteVectorised([]*buf.Buffer{buf.As(packet)})
+	if err != nil {
+		t.logger.DebugContext(ctx, E.Cause(err, "write ICMP port unreachable"))
+	}
+}
+
 func (t *Tun) NewError(ctx context.Context, err error) {
 	NewError(t.logger, ctx, err)
 }
@@ -0,0 +1,104 @@
+package inbound
+
+import (
+	"encoding/binary"
+	"net/netip"
+)
+
+const icmpTTL = 64
+
+// newPortUnreachable builds the ICMP port unreachable packet answering an UDP packet from source to destination,
+// the UDP packet is quoted with its IP and UDP headers only, which is what clients use to find the socket.
+func newPortUnreachable(source netip.AddrPort, destination netip.AddrPort) []byte {
+	if source.Addr().Is4() {
+		return newPortUnreachable4(source, destination)
+	}
+	return newPortUnreachable6(source, destination)
+}
+
+func newPortUnreachable4(source netip.AddrPort, destination netip.AddrPort) []byte {
+	const (
+		headerLen = 20
+		quoteLen  = headerLen + 8
+		totalLen  = headerLen + 8 + quoteLen
+	)
+	packet := make([]byte, totalLen)
+	writeIPv4Header(packet, totalLen, 1, destination.Addr(), source.Addr())
+	icmp := packet[headerLen:]
+	icmp[0] = 3 // destination unreachable
+	icmp[1] = 3 // port unreachable
+	quote := icmp[8:]
+	writeIPv4Header(quote, quoteLen, 17, source.Addr(), destination.Addr())
+	writeUDPHeader(quote[headerLen:], source.Port(), destination.Port())
+	binary.BigEndian.PutUint16(icmp[2:], checksum(0, icmp))
+	return packet
+}
+
+func writeIPv4Header(packet []byte, totalLen int, protocol uint8, source netip.Addr, destination netip.Addr) {
+	packet[0] = 0x45
+	binary.BigEndian.PutUint16(packet[2:], uint16(totalLen))
+	packet[8] = icmpTTL
+	packet[9] = protocol
+	source4, destination4 := source.As4(), destination.As4()
+	copy(packet[12:], source4[:])
+	copy(packet[16:], destination4[:])
+	binary.BigEndian.PutUint16(packet[10:], checksum(0, packet[:20]))
+}
+
+func newPortUnreachable6(source netip.AddrPort, destination netip.AddrPort) []byte {
+	const (
+		headerLen  = 40
+		quoteLen   = headerLen + 8
+		payloadLen = 8 + quoteLen
+	)
+	packet := make([]byte, headerLen+payloadLen)
+	writeIPv6Header(packet, payloadLen, 58, destination.Addr(), source.Addr())
+	icmp := packet[headerLen:]
+	icmp[0] = 1 // destination unreachable
+	icmp[1] = 4 // port unreachable
+	quote := icmp[8:]
+	writeIPv6Header(quote, 8, 17, source.Addr(), destination.Addr())
+	writeUDPHeader(quote[headerLen:], source.Port(), destination.Port())
+	// The pseudo header is made of the addresses, the upper-layer length and the next header.
+	var pseudoHeader [8]byte
+	binary.BigEndian.PutUint32(pseudoHeader[:], payloadLen)
+	pseudoHeader[7] = 58
+	sum := checksumAdd(0, packet[8:40])
+	sum = checksumAdd(sum, pseudoHeader[:])
+	binary.BigEndian.PutUint16(icmp[2:], checksum(sum, icmp))
+	return packet
+}
+
+func writeIPv6Header(packet []byte, payloadLen int, nextHeader uint8, source netip.Addr, destination netip.Addr) {
+	packet[0] = 0x60
+	binary.BigEndian.PutUint16(packet[4:], uint16(payloadLen))
+	packet[6] = nextHeader
+	packet[7] = icmpTTL
+	source16, destination16 := source.As16(), destination.As16()
+	copy(packet[8:], source16[:])
+	copy(packet[24:], destination16[:])
+}
+
+func writeUDPHeader(packet []byte, sourcePort uint16, destinationPort uint16) {
+	binary.BigEndian.PutUint16(packet[0:], sourcePort)
+	binary.BigEndian.PutUint16(packet[2:], destinationPort)
+	binary.BigEndian.PutUint16(packet[4:], 8)
+}
+
+func checksumAdd(sum uint32, data []byte) uint32 {
+	for i := 0; i+1 < len(data); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(data[i:]))
+	}
+	if len(data)%2 == 1 {
+		sum += uint32(data[len(data)-1]) << 8
+	}
+	return sum
+}
+
+func checksum(sum uint32, data []byte) uint16 {
+	sum = checksumAdd(sum, data)
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,68 @@
+package inbound
+
+import (
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+)
+
+// inboundUsers maps the ids passed to protocol services back to users.
+// Named users keep their ids across updates, so connections authenticated
+// right before an update are still attributed to the right user.
+type inboundUsers struct {
+	access sync.RWMutex
+	nextID int
+	ids    []int
+	users  map[int]adapter.InboundUser
+}
+
+// Update assigns ids to users and passes them to apply, the users are only replaced if apply succeeds.
+func (u *inboundUsers) Update(users []adapter.InboundUser, apply func(ids []int) error) error {
+	u.access.Lock()
+	defer u.access.Unlock()
+	idByName := make(map[string]int)
+	for id, user := range u.users {
+		if user.Name != "" {
+			idByName[user.Name] = id
+		}
+	}
+	nextID := u.nextID
+	ids := make([]int, 0, len(users))
+	userByID := make(map[int]adapter.InboundUser, len(users))
+	for _, user := range users {
+		id, loaded := idByName[user.Name]
+		if user.Name == "" || !loaded {
+			id = nextID
+			nextID++
+		} else {
+			delete(idByName, user.Name)
+		}
+		ids = append(ids, id)
+		userByID[id] = user
+	}
+	err := apply(ids)
+	if err != nil {
+		return err
+	}
+	u.nextID = nextID
+	u.ids = ids
+	u.users = userByID
+	return nil
+}
+
+func (u *inboundUsers) Load(id int) (adapter.InboundUser, bool) {
+	u.access.RLock()
+	defer u.access.RUnlock()
+	user, loaded := u.users[id]
+	return user, loaded
+}
+
+func (u *inboundUsers) List() []adapter.InboundUser {
+	u.access.RLock()
+	defer u.access.RUnlock()
+	users := make([]adapter.InboundUser, 0, len(u.ids))
+	for _, id := range u.ids {
+		users = append(users, u.users[id])
+	}
+	return users
+}
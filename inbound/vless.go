@@ -25,14 +25,15 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*VLESS)(nil)
-	_ adapter.InjectableInbound = (*VLESS)(nil)
+	_ adapter.Inbound            = (*VLESS)(nil)
+	_ adapter.InjectableInbound  = (*VLESS)(nil)
+	_ adapter.ManagedUserInbound = (*VLESS)(nil)
 )
 
 type VLESS struct {
 	myInboundAdapter
 	ctx       context.Context
-	users     []option.VLESSUser
+	users     inboundUsers
 	service   *vless.Service[int]
 	tlsConfig tls.ServerConfig
 	transport adapter.V2RayServerTransport
@@ -49,23 +50,24 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		ctx:   ctx,
-		users: options.Users,
+		ctx: ctx,
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
 	if err != nil {
 		return nil, err
 	}
-	service := vless.NewService[int](logger, adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound))
-	service.UpdateUsers(common.MapIndexed(inbound.users, func(index int, _ option.VLESSUser) int {
-		return index
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.UUID
-	}), common.Map(inbound.users, func(it option.VLESSUser) string {
-		return it.Flow
+	inbound.service = vless.NewService[int](logger, adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound))
+	err = inbound.UpdateUsers(common.Map(options.Users, func(it option.VLESSUser) adapter.InboundUser {
+		return adapter.InboundUser{
+			Name: it.Name,
+			UUID: it.UUID,
+			Flow: it.Flow,
+		}
 	}))
-	inbound.service = service
+	if err != nil {
+		return nil, err
+	}
 	if options.TLS != nil {
 		inbound.tlsConfig, err = tls.NewServer(ctx, logger, common.PtrValueOrDefault(options.TLS))
 		if err != nil {
@@ -82,6 +84,26 @@ func NewVLESS(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	return inbound, nil
 }
 
+func (h *VLESS) Users() []adapter.InboundUser {
+	return h.users.List()
+}
+
+func (h *VLESS) UpdateUsers(users []adapter.InboundUser) error {
+	for _, user := range users {
+		if user.Flow != "" && user.Flow != vless.FlowVision {
+			return E.New("unsupported flow for user ", user.Name, ": ", user.Flow)
+		}
+	}
+	return h.users.Update(users, func(ids []int) error {
+		h.service.UpdateUsers(ids, common.Map(users, func(it adapter.InboundUser) string {
+			return it.UUID
+		}), common.Map(users, func(it adapter.InboundUser) string {
+			return it.Flow
+		}))
+		return nil
+	})
+}
+
 func (h *VLESS) Start() error {
 	err := common.Start(
 		h.service,
@@ -154,7 +176,11 @@ func (h *VLESS) newConnection(ctx context.Context, conn net.Conn, metadata adapt
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	inboundUser, loaded := h.users.Load(userIndex)
+	if !loaded {
+		return E.New("user removed")
+	}
+	user := inboundUser.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -169,7 +195,11 @@ func (h *VLESS) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	inboundUser, loaded := h.users.Load(userIndex)
+	if !loaded {
+		return E.New("user removed")
+	}
+	user := inboundUser.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -25,15 +25,17 @@ import (
 )
 
 var (
-	_ adapter.Inbound           = (*VMess)(nil)
-	_ adapter.InjectableInbound = (*VMess)(nil)
+	_ adapter.Inbound            = (*VMess)(nil)
+	_ adapter.InjectableInbound  = (*VMess)(nil)
+	_ adapter.ManagedUserInbound = (*VMess)(nil)
 )
 
 type VMess struct {
 	myInboundAdapter
 	ctx       context.Context
 	service   *vmess.Service[int]
-	users     []option.VMessUser
+	users     inboundUsers
+	legacy    bool
 	tlsConfig tls.ServerConfig
 	transport adapter.V2RayServerTransport
 }
@@ -49,8 +51,10 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 			tag:           tag,
 			listenOptions: options.ListenOptions,
 		},
-		ctx:   ctx,
-		users: options.Users,
+		ctx: ctx,
+		legacy: common.Any(options.Users, func(it option.VMessUser) bool {
+			return it.AlterId > 0
+		}),
 	}
 	var err error
 	inbound.router, err = mux.NewRouterWithOptions(inbound.router, logger, common.PtrValueOrDefault(options.Multiplex))
@@ -66,12 +70,12 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	}
 	service := vmess.NewService[int](adapter.NewUpstreamContextHandler(inbound.newConnection, inbound.newPacketConnection, inbound), serviceOptions...)
 	inbound.service = service
-	err = service.UpdateUsers(common.MapIndexed(options.Users, func(index int, it option.VMessUser) int {
-		return index
-	}), common.Map(options.Users, func(it option.VMessUser) string {
-		return it.UUID
-	}), common.Map(options.Users, func(it option.VMessUser) int {
-		return it.AlterId
+	err = inbound.UpdateUsers(common.Map(options.Users, func(it option.VMessUser) adapter.InboundUser {
+		return adapter.InboundUser{
+			Name:    it.Name,
+			UUID:    it.UUID,
+			AlterId: it.AlterId,
+		}
 	}))
 	if err != nil {
 		return nil, err
@@ -92,6 +96,26 @@ func NewVMess(ctx context.Context, router adapter.Router, logger log.ContextLogg
 	return inbound, nil
 }
 
+func (h *VMess) Users() []adapter.InboundUser {
+	return h.users.List()
+}
+
+func (h *VMess) UpdateUsers(users []adapter.InboundUser) error {
+	// legacy keys are only refreshed if alterId users exist at start
+	if !h.legacy && common.Any(users, func(it adapter.InboundUser) bool {
+		return it.AlterId > 0
+	}) {
+		return E.New("alterId is not supported by this inbound, since no users had it at start")
+	}
+	return h.users.Update(users, func(ids []int) error {
+		return h.service.UpdateUsers(ids, common.Map(users, func(it adapter.InboundUser) string {
+			return it.UUID
+		}), common.Map(users, func(it adapter.InboundUser) int {
+			return it.AlterId
+		}))
+	})
+}
+
 func (h *VMess) Start() error {
 	err := common.Start(
 		h.service,
@@ -164,7 +188,11 @@ func (h *VMess) newConnection(ctx context.Context, conn net.Conn, metadata adapt
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	inboundUser, loaded := h.users.Load(userIndex)
+	if !loaded {
+		return E.New("user removed")
+	}
+	user := inboundUser.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -179,7 +207,11 @@ func (h *VMess) newPacketConnection(ctx context.Context, conn N.PacketConn, meta
 	if !loaded {
 		return os.ErrInvalid
 	}
-	user := h.users[userIndex].Name
+	inboundUser, loaded := h.users.Load(userIndex)
+	if !loaded {
+		return E.New("user removed")
+	}
+	user := inboundUser.Name
 	if user == "" {
 		user = F.ToString(userIndex)
 	} else {
@@ -3,11 +3,14 @@ package option
 import "net/netip"
 
 type DNSOptions struct {
-	Servers        []DNSServerOptions `json:"servers,omitempty"`
-	Rules          []DNSRule          `json:"rules,omitempty"`
-	Final          Listable[string]   `json:"final,omitempty"`
-	ReverseMapping bool               `json:"reverse_mapping,omitempty"`
-	FakeIP         *DNSFakeIPOptions  `json:"fakeip,omitempty"`
+	Servers        []DNSServerOptions    `json:"servers,omitempty"`
+	Rules          []DNSRule             `json:"rules,omitempty"`
+	Final          Listable[string]      `json:"final,omitempty"`
+	ReverseMapping bool                  `json:"reverse_mapping,omitempty"`
+	FakeIP         *DNSFakeIPOptions     `json:"fakeip,omitempty"`
+	Hosts          *DNSHostsOptions      `json:"hosts,omitempty"`
+	QueryLog       *DNSQueryLogOptions   `json:"query_log,omitempty"`
+	Optimistic     *DNSOptimisticOptions `json:"optimistic,omitempty"`
 	DNSClientOptions
 }
 
@@ -35,3 +38,42 @@ type DNSFakeIPOptions struct {
 	Inet4Range *netip.Prefix `json:"inet4_range,omitempty"`
 	Inet6Range *netip.Prefix `json:"inet6_range,omitempty"`
 }
+
+type DNSQueryLogOptions struct {
+	Path       string      `json:"path,omitempty"`
+	MaxSize    MemoryBytes `json:"max_size,omitempty"`
+	MaxBackups int         `json:"max_backups,omitempty"`
+}
+
+type DNSOptimisticOptions struct {
+	Enabled bool     `json:"enabled,omitempty"`
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+type DNSHostsOptions struct {
+	Path    Listable[string] `json:"path,omitempty"`
+	TTL     uint32           `json:"ttl,omitempty"`
+	Records []DNSHostsRecord `json:"records,omitempty"`
+}
+
+type DNSHostsRecord struct {
+	Domain  Listable[string]     `json:"domain"`
+	Address Listable[netip.Addr] `json:"address,omitempty"`
+	CNAME   string               `json:"cname,omitempty"`
+	TXT     Listable[string]     `json:"txt,omitempty"`
+	SRV     []DNSHostsSRVRecord  `json:"srv,omitempty"`
+}
+
+type DNSHostsSRVRecord struct {
+	Priority uint16 `json:"priority,omitempty"`
+	Weight   uint16 `json:"weight,omitempty"`
+	Port     uint16 `json:"port"`
+	Target   string `json:"target"`
+}
+
+type DNSInboundOptions struct {
+	ListenOptions
+	Network NetworkList `json:"network,omitempty"`
+	InboundTLSOptionsContainer
+	Path string `json:"path,omitempty"`
+}
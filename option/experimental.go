@@ -4,6 +4,7 @@ type ExperimentalOptions struct {
 	CacheFile *CacheFileOptions `json:"cache_file,omitempty"`
 	ClashAPI  *ClashAPIOptions  `json:"clash_api,omitempty"`
 	V2RayAPI  *V2RayAPIOptions  `json:"v2ray_api,omitempty"`
+	Metrics   *MetricsOptions   `json:"metrics,omitempty"`
 	Debug     *DebugOptions     `json:"debug,omitempty"`
 }
 
@@ -14,6 +15,7 @@ type CacheFileOptions struct {
 	StoreFakeIP bool     `json:"store_fakeip,omitempty"`
 	StoreRDRC   bool     `json:"store_rdrc,omitempty"`
 	RDRCTimeout Duration `json:"rdrc_timeout,omitempty"`
+	StoreDNS    bool     `json:"store_dns,omitempty"`
 }
 
 type ClashAPIOptions struct {
@@ -24,7 +26,9 @@ type ClashAPIOptions struct {
 	TrustedDomain            Listable[string] `json:"trusted_domain,omitempty"`
 	Secret                   string           `json:"secret,omitempty"`
 	DefaultMode              string           `json:"default_mode,omitempty"`
+	UserStore                string           `json:"user_store,omitempty"`
 	ModeList                 []string         `json:"-"`
+	Outbounds                []Outbound       `json:"-"`
 
 	// Deprecated: migrated to global cache file
 	CacheFile string `json:"cache_file,omitempty"`
@@ -43,6 +47,11 @@ type V2RayAPIOptions struct {
 	Stats  *V2RayStatsServiceOptions `json:"stats,omitempty"`
 }
 
+type MetricsOptions struct {
+	Listen string `json:"listen,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
 type V2RayStatsServiceOptions struct {
 	Enabled   bool     `json:"enabled,omitempty"`
 	Inbounds  []string `json:"inbounds,omitempty"`
@@ -0,0 +1,9 @@
+package option
+
+type ExternalAuthOptions struct {
+	URL     string     `json:"url"`
+	Headers HTTPHeader `json:"headers,omitempty"`
+	Detour  string     `json:"detour,omitempty"`
+	Timeout Duration   `json:"timeout,omitempty"`
+	TTL     Duration   `json:"ttl,omitempty"`
+}
@@ -30,3 +30,20 @@ type RelayOutboundOptions struct {
 	Outbounds                 []string `json:"outbounds"`
 	InterruptExistConnections bool     `json:"interrupt_exist_connections,omitempty"`
 }
+
+type LoadBalanceOutboundOptions struct {
+	GroupOutboundOptions
+	Strategy    string   `json:"strategy,omitempty"`
+	HashKey     string   `json:"hash_key,omitempty"`
+	URL         string   `json:"url,omitempty"`
+	Interval    Duration `json:"interval,omitempty"`
+	IdleTimeout Duration `json:"idle_timeout,omitempty"`
+}
+
+type FallbackOutboundOptions struct {
+	GroupOutboundOptions
+	URL                       string   `json:"url,omitempty"`
+	Interval                  Duration `json:"interval,omitempty"`
+	IdleTimeout               Duration `json:"idle_timeout,omitempty"`
+	InterruptExistConnections bool     `json:"interrupt_exist_connections,omitempty"`
+}
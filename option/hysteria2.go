@@ -2,11 +2,12 @@ package option
 
 type Hysteria2InboundOptions struct {
 	ListenOptions
-	UpMbps                int             `json:"up_mbps,omitempty"`
-	DownMbps              int             `json:"down_mbps,omitempty"`
-	Obfs                  *Hysteria2Obfs  `json:"obfs,omitempty"`
-	Users                 []Hysteria2User `json:"users,omitempty"`
-	IgnoreClientBandwidth bool            `json:"ignore_client_bandwidth,omitempty"`
+	UpMbps                int                  `json:"up_mbps,omitempty"`
+	DownMbps              int                  `json:"down_mbps,omitempty"`
+	Obfs                  *Hysteria2Obfs       `json:"obfs,omitempty"`
+	Users                 []Hysteria2User      `json:"users,omitempty"`
+	ExternalAuth          *ExternalAuthOptions `json:"external_auth,omitempty"`
+	IgnoreClientBandwidth bool                 `json:"ignore_client_bandwidth,omitempty"`
 	InboundTLSOptionsContainer
 	Masquerade  string `json:"masquerade,omitempty"`
 	BrutalDebug bool   `json:"brutal_debug,omitempty"`
@@ -27,6 +27,7 @@ type _Inbound struct {
 	VLESSOptions       VLESSInboundOptions       `json:"-"`
 	TUICOptions        TUICInboundOptions        `json:"-"`
 	Hysteria2Options   Hysteria2InboundOptions   `json:"-"`
+	DNSOptions         DNSInboundOptions         `json:"-"`
 }
 
 type Inbound _Inbound
@@ -66,6 +67,8 @@ func (h *Inbound) RawOptions() (any, error) {
 		rawOptionsPtr = &h.TUICOptions
 	case C.TypeHysteria2:
 		rawOptionsPtr = &h.Hysteria2Options
+	case C.TypeDNS:
+		rawOptionsPtr = &h.DNSOptions
 	case "":
 		return nil, E.New("missing inbound type")
 	default:
@@ -98,6 +101,46 @@ func (h *Inbound) UnmarshalJSON(bytes []byte) error {
 	return nil
 }
 
+func (h *Inbound) GetInboundOptions() *InboundOptions {
+	switch h.Type {
+	case C.TypeTun:
+		return &h.TunOptions.InboundOptions
+	case C.TypeRedirect:
+		return &h.RedirectOptions.InboundOptions
+	case C.TypeTProxy:
+		return &h.TProxyOptions.InboundOptions
+	case C.TypeDirect:
+		return &h.DirectOptions.InboundOptions
+	case C.TypeSOCKS:
+		return &h.SocksOptions.InboundOptions
+	case C.TypeHTTP:
+		return &h.HTTPOptions.InboundOptions
+	case C.TypeMixed:
+		return &h.MixedOptions.InboundOptions
+	case C.TypeShadowsocks:
+		return &h.ShadowsocksOptions.InboundOptions
+	case C.TypeVMess:
+		return &h.VMessOptions.InboundOptions
+	case C.TypeTrojan:
+		return &h.TrojanOptions.InboundOptions
+	case C.TypeNaive:
+		return &h.NaiveOptions.InboundOptions
+	case C.TypeHysteria:
+		return &h.HysteriaOptions.InboundOptions
+	case C.TypeShadowTLS:
+		return &h.ShadowTLSOptions.InboundOptions
+	case C.TypeVLESS:
+		return &h.VLESSOptions.InboundOptions
+	case C.TypeTUIC:
+		return &h.TUICOptions.InboundOptions
+	case C.TypeHysteria2:
+		return &h.Hysteria2Options.InboundOptions
+	case C.TypeDNS:
+		return &h.DNSOptions.InboundOptions
+	}
+	return nil
+}
+
 func (h *Inbound) GetSniffOverrideRules() []Rule {
 	switch h.Type {
 	case C.TypeTun:
@@ -132,18 +175,38 @@ func (h *Inbound) GetSniffOverrideRules() []Rule {
 		return h.TUICOptions.GetSniffOverrideRules()
 	case C.TypeHysteria2:
 		return h.Hysteria2Options.GetSniffOverrideRules()
+	case C.TypeDNS:
+		return h.DNSOptions.GetSniffOverrideRules()
 	}
 	return nil
 }
 
 type InboundOptions struct {
-	SniffEnabled              bool           `json:"sniff,omitempty"`
-	SniffOverrideDestination  bool           `json:"sniff_override_destination,omitempty"`
-	SniffOverrideRules        []Rule         `json:"sniff_override_rules,omitempty"`
-	SniffTimeout              Duration       `json:"sniff_timeout,omitempty"`
-	DomainStrategy            DomainStrategy `json:"domain_strategy,omitempty"`
-	AlwaysResolveUDP          bool           `json:"always_resolve_udp,omitempty"`
-	UDPDisableDomainUnmapping bool           `json:"udp_disable_domain_unmapping,omitempty"`
+	SniffEnabled              bool                 `json:"sniff,omitempty"`
+	SniffOverrideDestination  bool                 `json:"sniff_override_destination,omitempty"`
+	SniffOverrideRules        []Rule               `json:"sniff_override_rules,omitempty"`
+	SniffTimeout              Duration             `json:"sniff_timeout,omitempty"`
+	DomainStrategy            DomainStrategy       `json:"domain_strategy,omitempty"`
+	AlwaysResolveUDP          bool                 `json:"always_resolve_udp,omitempty"`
+	UDPDisableDomainUnmapping bool                 `json:"udp_disable_domain_unmapping,omitempty"`
+	Limit                     *InboundLimitOptions `json:"limit,omitempty"`
+}
+
+type InboundLimitOptions struct {
+	TrafficLimitOptions
+	Users []UserTrafficLimitOptions `json:"users,omitempty"`
+}
+
+type UserTrafficLimitOptions struct {
+	Name string `json:"name"`
+	TrafficLimitOptions
+}
+
+type TrafficLimitOptions struct {
+	Upload      string `json:"upload,omitempty"`
+	Download    string `json:"download,omitempty"`
+	Quota       string `json:"quota,omitempty"`
+	QuotaPeriod string `json:"quota_period,omitempty"`
 }
 
 func (o *InboundOptions) GetSniffOverrideRules() []Rule {
@@ -27,6 +27,8 @@ type _Outbound struct {
 	Hysteria2Options    Hysteria2OutboundOptions    `json:"-"`
 	SelectorOptions     SelectorOutboundOptions     `json:"-"`
 	URLTestOptions      URLTestOutboundOptions      `json:"-"`
+	LoadBalanceOptions  LoadBalanceOutboundOptions  `json:"-"`
+	FallbackOptions     FallbackOutboundOptions     `json:"-"`
 	RelayOptions        RelayOutboundOptions        `json:"-"`
 }
 
@@ -71,6 +73,10 @@ func (h *Outbound) RawOptions() (any, error) {
 		rawOptionsPtr = &h.SelectorOptions
 	case C.TypeURLTest:
 		rawOptionsPtr = &h.URLTestOptions
+	case C.TypeLoadBalance:
+		rawOptionsPtr = &h.LoadBalanceOptions
+	case C.TypeFallback:
+		rawOptionsPtr = &h.FallbackOptions
 	case C.TypeRelay:
 		rawOptionsPtr = &h.RelayOptions
 	case "":
@@ -4,16 +4,28 @@ import (
 	C "github.com/sagernet/sing-box/constant"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badjson"
 )
 
 type _OutboundProvider struct {
-	Type                string                      `json:"type"`
-	Path                string                      `json:"path"`
-	Tag                 string                      `json:"tag,omitempty"`
-	HealthcheckUrl      string                      `json:"healthcheck_url,omitempty"`
-	HealthcheckInterval Duration                    `json:"healthcheck_interval,omitempty"`
-	OverrideDialer      *OverrideDialerOptions      `json:"override_dialer,omitempty"`
-	HTTPOptions         HTTPOutboundProviderOptions `json:"-"`
+	Type                string                        `json:"type"`
+	Path                string                        `json:"path,omitempty"`
+	Tag                 string                        `json:"tag,omitempty"`
+	HealthcheckUrl      string                        `json:"healthcheck_url,omitempty"`
+	HealthcheckInterval Duration                      `json:"healthcheck_interval,omitempty"`
+	OverrideDialer      *OverrideDialerOptions        `json:"override_dialer,omitempty"`
+	Includes            Listable[string]              `json:"includes,omitempty"`
+	Excludes            string                        `json:"excludes,omitempty"`
+	Types               Listable[string]              `json:"types,omitempty"`
+	ExcludeTypes        Listable[string]              `json:"exclude_types,omitempty"`
+	Rename              []OutboundRenameOptions       `json:"rename,omitempty"`
+	OverrideOutbound    *badjson.JSONObject           `json:"override_outbound,omitempty"`
+	QuotaWarningPercent int                           `json:"quota_warning_percent,omitempty"`
+	ExpireWarningDays   int                           `json:"expire_warning_days,omitempty"`
+	ExcludeExhausted    bool                          `json:"exclude_exhausted,omitempty"`
+	HTTPOptions         HTTPOutboundProviderOptions   `json:"-"`
+	InlineOptions       InlineOutboundProviderOptions `json:"-"`
+	MergeOptions        MergeOutboundProviderOptions  `json:"-"`
 }
 
 type OutboundProvider _OutboundProvider
@@ -35,6 +47,11 @@ type OverrideDialerOptions struct {
 	FallbackDelay    *Duration       `json:"fallback_delay,omitempty"`
 }
 
+type OutboundRenameOptions struct {
+	Pattern string `json:"pattern"`
+	Replace string `json:"replace"`
+}
+
 type HTTPOutboundProviderOptions struct {
 	Url       string   `json:"download_url"`
 	UserAgent string   `json:"download_ua,omitempty"`
@@ -42,6 +59,15 @@ type HTTPOutboundProviderOptions struct {
 	Detour    string   `json:"download_detour,omitempty"`
 }
 
+type InlineOutboundProviderOptions struct {
+	Content string           `json:"content,omitempty"`
+	Links   Listable[string] `json:"links,omitempty"`
+}
+
+type MergeOutboundProviderOptions struct {
+	Providers Listable[string] `json:"providers"`
+}
+
 func (h OutboundProvider) MarshalJSON() ([]byte, error) {
 	var v any
 	switch h.Type {
@@ -49,6 +75,10 @@ func (h OutboundProvider) MarshalJSON() ([]byte, error) {
 		v = nil
 	case C.TypeHTTPProvider:
 		v = h.HTTPOptions
+	case C.TypeInlineProvider:
+		v = h.InlineOptions
+	case C.TypeMergeProvider:
+		v = h.MergeOptions
 	default:
 		return nil, E.New("unknown provider type: ", h.Type)
 	}
@@ -66,6 +96,10 @@ func (h *OutboundProvider) UnmarshalJSON(bytes []byte) error {
 		v = nil
 	case C.TypeHTTPProvider:
 		v = &h.HTTPOptions
+	case C.TypeInlineProvider:
+		v = &h.InlineOptions
+	case C.TypeMergeProvider:
+		v = &h.MergeOptions
 	default:
 		return E.New("unknown provider type: ", h.Type)
 	}
@@ -94,17 +94,25 @@ type DefaultRule struct {
 	ClashMode                string           `json:"clash_mode,omitempty"`
 	WIFISSID                 Listable[string] `json:"wifi_ssid,omitempty"`
 	WIFIBSSID                Listable[string] `json:"wifi_bssid,omitempty"`
+	TimeRange                Listable[string] `json:"time_range,omitempty"`
+	Weekday                  Listable[string] `json:"weekday,omitempty"`
+	Timezone                 string           `json:"timezone,omitempty"`
 	RuleSet                  Listable[string] `json:"rule_set,omitempty"`
 	RuleSetIPCIDRMatchSource bool             `json:"rule_set_ipcidr_match_source,omitempty"`
 	Invert                   bool             `json:"invert,omitempty"`
 	SkipResolve              bool             `json:"skip_resolve,omitempty"`
+	Action                   string           `json:"action,omitempty"`
 	Outbound                 string           `json:"outbound,omitempty"`
+	Method                   string           `json:"method,omitempty"`
 }
 
 func (r DefaultRule) IsValid() bool {
 	var defaultValue DefaultRule
 	defaultValue.Invert = r.Invert
+	defaultValue.Action = r.Action
 	defaultValue.Outbound = r.Outbound
+	defaultValue.Method = r.Method
+	defaultValue.Timezone = r.Timezone
 	return !reflect.DeepEqual(r, defaultValue)
 }
 
@@ -114,7 +122,9 @@ type LogicalRule struct {
 	Rules       []Rule `json:"rules,omitempty"`
 	Invert      bool   `json:"invert,omitempty"`
 	SkipResolve bool   `json:"skip_resolve,omitempty"`
+	Action      string `json:"action,omitempty"`
 	Outbound    string `json:"outbound,omitempty"`
+	Method      string `json:"method,omitempty"`
 }
 
 func (r LogicalRule) IsValid() bool {
@@ -116,6 +116,9 @@ type DefaultDNSRule struct {
 	ClashMode                string                 `json:"clash_mode,omitempty"`
 	WIFISSID                 Listable[string]       `json:"wifi_ssid,omitempty"`
 	WIFIBSSID                Listable[string]       `json:"wifi_bssid,omitempty"`
+	TimeRange                Listable[string]       `json:"time_range,omitempty"`
+	Weekday                  Listable[string]       `json:"weekday,omitempty"`
+	Timezone                 string                 `json:"timezone,omitempty"`
 	RuleSet                  Listable[string]       `json:"rule_set,omitempty"`
 	RuleSetIPCIDRMatchSource bool                   `json:"rule_set_ipcidr_match_source,omitempty"`
 	Invert                   bool                   `json:"invert,omitempty"`
@@ -123,6 +126,15 @@ type DefaultDNSRule struct {
 	DisableCache             bool                   `json:"disable_cache,omitempty"`
 	RewriteTTL               *uint32                `json:"rewrite_ttl,omitempty"`
 	ClientSubnet             *ListenAddress         `json:"client_subnet,omitempty"`
+	Strategy                 string                 `json:"strategy,omitempty"`
+	ServerTimeout            Duration               `json:"server_timeout,omitempty"`
+	Action                   string                 `json:"action,omitempty"`
+	RCode                    string                 `json:"rcode,omitempty"`
+	StripType                Listable[DNSQueryType] `json:"strip_type,omitempty"`
+	DropIPCIDR               Listable[string]       `json:"drop_ip_cidr,omitempty"`
+	DropIPIsPrivate          bool                   `json:"drop_ip_is_private,omitempty"`
+	DropRuleSet              Listable[string]       `json:"drop_rule_set,omitempty"`
+	MaxAddresses             int                    `json:"max_addresses,omitempty"`
 }
 
 func (r DefaultDNSRule) IsValid() bool {
@@ -132,17 +144,36 @@ func (r DefaultDNSRule) IsValid() bool {
 	defaultValue.DisableCache = r.DisableCache
 	defaultValue.RewriteTTL = r.RewriteTTL
 	defaultValue.ClientSubnet = r.ClientSubnet
+	defaultValue.Strategy = r.Strategy
+	defaultValue.ServerTimeout = r.ServerTimeout
+	defaultValue.Action = r.Action
+	defaultValue.RCode = r.RCode
+	defaultValue.StripType = r.StripType
+	defaultValue.DropIPCIDR = r.DropIPCIDR
+	defaultValue.DropIPIsPrivate = r.DropIPIsPrivate
+	defaultValue.DropRuleSet = r.DropRuleSet
+	defaultValue.MaxAddresses = r.MaxAddresses
+	defaultValue.Timezone = r.Timezone
 	return !reflect.DeepEqual(r, defaultValue)
 }
 
 type LogicalDNSRule struct {
-	Mode         string           `json:"mode"`
-	Rules        []DNSRule        `json:"rules,omitempty"`
-	Invert       bool             `json:"invert,omitempty"`
-	Server       Listable[string] `json:"server,omitempty"`
-	DisableCache bool             `json:"disable_cache,omitempty"`
-	RewriteTTL   *uint32          `json:"rewrite_ttl,omitempty"`
-	ClientSubnet *ListenAddress   `json:"client_subnet,omitempty"`
+	Mode            string                 `json:"mode"`
+	Rules           []DNSRule              `json:"rules,omitempty"`
+	Invert          bool                   `json:"invert,omitempty"`
+	Server          Listable[string]       `json:"server,omitempty"`
+	DisableCache    bool                   `json:"disable_cache,omitempty"`
+	RewriteTTL      *uint32                `json:"rewrite_ttl,omitempty"`
+	ClientSubnet    *ListenAddress         `json:"client_subnet,omitempty"`
+	Strategy        string                 `json:"strategy,omitempty"`
+	ServerTimeout   Duration               `json:"server_timeout,omitempty"`
+	Action          string                 `json:"action,omitempty"`
+	RCode           string                 `json:"rcode,omitempty"`
+	StripType       Listable[DNSQueryType] `json:"strip_type,omitempty"`
+	DropIPCIDR      Listable[string]       `json:"drop_ip_cidr,omitempty"`
+	DropIPIsPrivate bool                   `json:"drop_ip_is_private,omitempty"`
+	DropRuleSet     Listable[string]       `json:"drop_rule_set,omitempty"`
+	MaxAddresses    int                    `json:"max_addresses,omitempty"`
 }
 
 func (r LogicalDNSRule) IsValid() bool {
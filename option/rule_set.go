@@ -1,16 +1,14 @@
 package option
 
 import (
+	"net/netip"
 	"reflect"
 
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing/common"
-	"github.com/sagernet/sing/common/domain"
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/json"
-
-	"go4.org/netipx"
 )
 
 type _RuleSet struct {
@@ -18,6 +16,7 @@ type _RuleSet struct {
 	Tag           string        `json:"tag,omitempty"`
 	Path          string        `json:"path,omitempty"`
 	Format        string        `json:"format,omitempty"`
+	Behavior      string        `json:"behavior,omitempty"`
 	RemoteOptions RemoteRuleSet `json:"-"`
 }
 
@@ -47,10 +46,19 @@ func (r *RuleSet) UnmarshalJSON(bytes []byte) error {
 	switch r.Format {
 	case "":
 		return E.New("missing format")
-	case C.RuleSetFormatSource, C.RuleSetFormatBinary:
+	case C.RuleSetFormatSource, C.RuleSetFormatBinary, C.RuleSetFormatClash, C.RuleSetFormatDomainList, C.RuleSetFormatIPCIDRList, C.RuleSetFormatAdblock:
 	default:
 		return E.New("unknown rule set format: " + r.Format)
 	}
+	switch r.Behavior {
+	case "":
+	case C.RuleSetBehaviorDomain, C.RuleSetBehaviorIPCIDR, C.RuleSetBehaviorClassical:
+		if r.Format != C.RuleSetFormatClash {
+			return E.New("behavior is only available for clash format")
+		}
+	default:
+		return E.New("unknown rule set behavior: " + r.Behavior)
+	}
 	var v any
 	switch r.Type {
 	case C.RuleSetTypeLocal:
@@ -148,16 +156,30 @@ type DefaultHeadlessRule struct {
 	PackageName     Listable[string]       `json:"package_name,omitempty"`
 	WIFISSID        Listable[string]       `json:"wifi_ssid,omitempty"`
 	WIFIBSSID       Listable[string]       `json:"wifi_bssid,omitempty"`
+	TimeRange       Listable[string]       `json:"time_range,omitempty"`
+	Weekday         Listable[string]       `json:"weekday,omitempty"`
+	Timezone        string                 `json:"timezone,omitempty"`
 	Invert          bool                   `json:"invert,omitempty"`
 
-	DomainMatcher *domain.Matcher `json:"-"`
-	SourceIPSet   *netipx.IPSet   `json:"-"`
-	IPSet         *netipx.IPSet   `json:"-"`
+	DomainMatcher DomainMatcher `json:"-"`
+	SourceIPSet   IPSet         `json:"-"`
+	IPSet         IPSet         `json:"-"`
+}
+
+// DomainMatcher is a prebuilt matcher of domains and domain suffixes of binary rule-set.
+type DomainMatcher interface {
+	Match(domain string) bool
+}
+
+// IPSet is a prebuilt set of CIDRs of binary rule-set.
+type IPSet interface {
+	Contains(addr netip.Addr) bool
 }
 
 func (r DefaultHeadlessRule) IsValid() bool {
 	var defaultValue DefaultHeadlessRule
 	defaultValue.Invert = r.Invert
+	defaultValue.Timezone = r.Timezone
 	return !reflect.DeepEqual(r, defaultValue)
 }
 
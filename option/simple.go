@@ -4,13 +4,15 @@ import "github.com/sagernet/sing/common/auth"
 
 type SocksInboundOptions struct {
 	ListenOptions
-	Users []auth.User `json:"users,omitempty"`
+	Users        []auth.User          `json:"users,omitempty"`
+	ExternalAuth *ExternalAuthOptions `json:"external_auth,omitempty"`
 }
 
 type HTTPMixedInboundOptions struct {
 	ListenOptions
-	Users          []auth.User `json:"users,omitempty"`
-	SetSystemProxy bool        `json:"set_system_proxy,omitempty"`
+	Users          []auth.User          `json:"users,omitempty"`
+	ExternalAuth   *ExternalAuthOptions `json:"external_auth,omitempty"`
+	SetSystemProxy bool                 `json:"set_system_proxy,omitempty"`
 	InboundTLSOptionsContainer
 }
 
@@ -59,6 +59,10 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, t
 		return NewSelector(ctx, router, logger, tag, options.SelectorOptions)
 	case C.TypeURLTest:
 		return NewURLTest(ctx, router, logger, tag, options.URLTestOptions)
+	case C.TypeLoadBalance:
+		return NewLoadBalance(ctx, router, logger, tag, options.LoadBalanceOptions)
+	case C.TypeFallback:
+		return NewFallback(ctx, router, logger, tag, options.FallbackOptions)
 	case C.TypeRelay:
 		return NewRelay(router, logger, tag, options.RelayOptions)
 	default:
@@ -0,0 +1,325 @@
+package outbound
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/interrupt"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+var (
+	_ adapter.Outbound                = (*Fallback)(nil)
+	_ adapter.OutboundGroup           = (*Fallback)(nil)
+	_ adapter.URLTestGroup            = (*Fallback)(nil)
+	_ adapter.InterfaceUpdateListener = (*Fallback)(nil)
+)
+
+type Fallback struct {
+	myOutboundAdapter
+	myGroupAdapter
+	options                      option.FallbackOutboundOptions
+	group                        *URLTestGroup
+	interruptGroup               *interrupt.Group
+	interruptExternalConnections bool
+
+	access      sync.Mutex
+	selectedTCP adapter.Outbound
+	selectedUDP adapter.Outbound
+}
+
+func NewFallback(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.FallbackOutboundOptions) (*Fallback, error) {
+	outbound := &Fallback{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol:     C.TypeFallback,
+			network:      []string{N.NetworkTCP, N.NetworkUDP},
+			router:       router,
+			logger:       logger,
+			tag:          tag,
+			dependencies: options.Outbounds,
+		},
+		myGroupAdapter: myGroupAdapter{
+			ctx:             ctx,
+			tags:            options.Outbounds,
+			uses:            options.Providers,
+			useAllProviders: options.UseAllProviders,
+			includes:        options.Includes,
+			excludes:        options.Excludes,
+			types:           options.Types,
+			ports:           make(map[int]bool),
+			providers:       make(map[string]adapter.OutboundProvider),
+		},
+		options:                      options,
+		interruptGroup:               interrupt.NewGroup(),
+		interruptExternalConnections: options.InterruptExistConnections,
+	}
+	if len(outbound.tags) == 0 && len(outbound.uses) == 0 && !outbound.useAllProviders {
+		return nil, E.New("missing tags and uses")
+	}
+	portMap, err := CreatePortsMap(options.Ports)
+	if err != nil {
+		return nil, err
+	}
+	outbound.ports = portMap
+	return outbound, nil
+}
+
+func (s *Fallback) pickOutbounds() ([]adapter.Outbound, error) {
+	outbounds := []adapter.Outbound{}
+	for i, tag := range s.tags {
+		detour, loaded := s.router.Outbound(tag)
+		if !loaded {
+			return nil, E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	for i, tag := range s.uses {
+		provider, loaded := s.router.OutboundProvider(tag)
+		if !loaded {
+			return nil, E.New("provider ", i, " not found: ", tag)
+		}
+		if _, ok := s.providers[tag]; !ok {
+			s.providers[tag] = provider
+		}
+		if provider.Excluded() {
+			continue
+		}
+		for _, outbound := range provider.Outbounds() {
+			if s.OutboundFilter(outbound) {
+				outbounds = append(outbounds, outbound)
+			}
+		}
+	}
+	if len(outbounds) == 0 {
+		OUTBOUNDLESS, _ := s.router.Outbound("OUTBOUNDLESS")
+		outbounds = append(outbounds, OUTBOUNDLESS)
+	}
+	return outbounds, nil
+}
+
+func (s *Fallback) Start() error {
+	if s.useAllProviders {
+		uses := []string{}
+		for _, provider := range s.router.OutboundProviders() {
+			// outbounds of merge providers are listed by the providers they are merged from
+			if provider.Type() == C.TypeMergeProvider {
+				continue
+			}
+			uses = append(uses, provider.Tag())
+		}
+		s.uses = uses
+	}
+	outbounds, err := s.pickOutbounds()
+	if err != nil {
+		return err
+	}
+	group, err := NewURLTestGroup(
+		s.ctx,
+		s.router,
+		s.logger,
+		outbounds,
+		s.options.URL,
+		time.Duration(s.options.Interval),
+		0,
+		time.Duration(s.options.IdleTimeout),
+		false,
+	)
+	if err != nil {
+		return err
+	}
+	s.group = group
+	return nil
+}
+
+func (s *Fallback) UpdateOutbounds(tag string) error {
+	if _, ok := s.providers[tag]; ok {
+		outbounds, err := s.pickOutbounds()
+		if err != nil {
+			return E.New("update outbounds failed: ", s.tag, ", with reason: ", err)
+		}
+		s.group.SetOutbounds(outbounds)
+		s.PerformUpdateCheck(tag, true)
+	}
+	return nil
+}
+
+func (s *Fallback) PostStart() error {
+	s.group.PostStart()
+	return nil
+}
+
+func (s *Fallback) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.group),
+	)
+}
+
+func (s *Fallback) Now() string {
+	return s.SelectedOutbound(N.NetworkTCP).Tag()
+}
+
+// SelectedOutbound returns the member last selected for network without selecting again,
+// selection only happens when connections are dialed and outbounds are checked.
+func (s *Fallback) SelectedOutbound(network string) adapter.Outbound {
+	s.access.Lock()
+	var selected adapter.Outbound
+	switch network {
+	case N.NetworkTCP:
+		selected = s.selectedTCP
+	case N.NetworkUDP:
+		selected = s.selectedUDP
+	}
+	s.access.Unlock()
+	if selected != nil {
+		return selected
+	}
+	if candidates := s.candidates(network); len(candidates) > 0 {
+		return candidates[0]
+	}
+	return s.group.Outbounds()[0]
+}
+
+func (s *Fallback) All() []string {
+	all := []string{}
+	for _, outbound := range s.group.Outbounds() {
+		all = append(all, outbound.Tag())
+	}
+	return all
+}
+
+func (s *Fallback) URLTest(ctx context.Context) (map[string]uint16, error) {
+	result, err := s.group.URLTest(ctx)
+	s.PerformUpdateCheck("", true)
+	return result, err
+}
+
+func (s *Fallback) PerformUpdateCheck(tag string, force bool) {
+	if _, exists := s.providers[tag]; !exists && !force {
+		return
+	}
+	s.Select(N.NetworkTCP)
+	s.Select(N.NetworkUDP)
+}
+
+func (s *Fallback) CheckOutbounds() {
+	s.group.CheckOutbounds(true)
+	s.PerformUpdateCheck("", true)
+}
+
+func (s *Fallback) InterfaceUpdated() {
+	go s.CheckOutbounds()
+}
+
+// Select returns the first member whose last health check passed,
+// or the first member supporting network if none has passed yet.
+// Existing connections are interrupted when the result changes.
+func (s *Fallback) Select(network string) adapter.Outbound {
+	var selected adapter.Outbound
+	for _, detour := range s.candidates(network) {
+		selected = detour
+		break
+	}
+	s.access.Lock()
+	var updated bool
+	switch network {
+	case N.NetworkTCP:
+		updated = s.selectedTCP != nil && s.selectedTCP != selected
+		s.selectedTCP = selected
+	case N.NetworkUDP:
+		updated = s.selectedUDP != nil && s.selectedUDP != selected
+		s.selectedUDP = selected
+	}
+	s.access.Unlock()
+	if updated && selected != nil {
+		s.logger.Info("switch ", network, " outbound to ", selected.Tag())
+		s.interruptGroup.Interrupt(s.interruptExternalConnections)
+	}
+	return selected
+}
+
+// candidates lists the members supporting network in the order they are tried:
+// members whose last health check passed first, then the others, each keeping the configured order.
+func (s *Fallback) candidates(network string) []adapter.Outbound {
+	var healthy, unknown []adapter.Outbound
+	for _, detour := range s.group.Outbounds() {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		if s.group.history.LoadURLTestHistory(RealTag(detour)) != nil {
+			healthy = append(healthy, detour)
+		} else {
+			unknown = append(unknown, detour)
+		}
+	}
+	return append(healthy, unknown...)
+}
+
+func (s *Fallback) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	s.group.Touch()
+	switch N.NetworkName(network) {
+	case N.NetworkTCP, N.NetworkUDP:
+	default:
+		return nil, E.Extend(N.ErrUnknownNetwork, network)
+	}
+	s.Select(N.NetworkName(network))
+	var errors []error
+	for _, outbound := range s.candidates(N.NetworkName(network)) {
+		conn, err := outbound.DialContext(ctx, network, destination)
+		if err == nil {
+			return s.interruptGroup.NewConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
+		}
+		s.logger.ErrorContext(ctx, "outbound ", outbound.Tag(), ": ", err)
+		s.group.history.DeleteURLTestHistory(RealTag(outbound))
+		s.Select(N.NetworkName(network))
+		errors = append(errors, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errors) == 0 {
+		return nil, E.New("missing supported outbound")
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (s *Fallback) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	s.group.Touch()
+	s.Select(N.NetworkUDP)
+	var errors []error
+	for _, outbound := range s.candidates(N.NetworkUDP) {
+		conn, err := outbound.ListenPacket(ctx, destination)
+		if err == nil {
+			return s.interruptGroup.NewPacketConn(conn, interrupt.IsExternalConnectionFromContext(ctx)), nil
+		}
+		s.logger.ErrorContext(ctx, "outbound ", outbound.Tag(), ": ", err)
+		s.group.history.DeleteURLTestHistory(RealTag(outbound))
+		s.Select(N.NetworkUDP)
+		errors = append(errors, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errors) == 0 {
+		return nil, E.New("missing supported outbound")
+	}
+	return nil, E.Errors(errors...)
+}
+
+func (s *Fallback) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	ctx = interrupt.ContextWithIsExternalConnection(ctx)
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *Fallback) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	ctx = interrupt.ContextWithIsExternalConnection(ctx)
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
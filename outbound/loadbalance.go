@@ -0,0 +1,353 @@
+package outbound
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const (
+	LoadBalanceStrategyRoundRobin        = "round_robin"
+	LoadBalanceStrategyRandom            = "random"
+	LoadBalanceStrategyConsistentHashing = "consistent_hashing"
+
+	LoadBalanceHashKeyDestination = "destination"
+	LoadBalanceHashKeySource      = "source"
+)
+
+var (
+	_ adapter.Outbound                = (*LoadBalance)(nil)
+	_ adapter.OutboundGroup           = (*LoadBalance)(nil)
+	_ adapter.URLTestGroup            = (*LoadBalance)(nil)
+	_ adapter.InterfaceUpdateListener = (*LoadBalance)(nil)
+)
+
+type LoadBalance struct {
+	myOutboundAdapter
+	myGroupAdapter
+	strategy    string
+	hashKey     string
+	options     option.LoadBalanceOutboundOptions
+	group       *URLTestGroup
+	index       atomic.Uint32
+	selectedTCP atomic.Pointer[adapter.Outbound]
+	selectedUDP atomic.Pointer[adapter.Outbound]
+}
+
+func NewLoadBalance(ctx context.Context, router adapter.Router, logger log.ContextLogger, tag string, options option.LoadBalanceOutboundOptions) (*LoadBalance, error) {
+	outbound := &LoadBalance{
+		myOutboundAdapter: myOutboundAdapter{
+			protocol:     C.TypeLoadBalance,
+			network:      []string{N.NetworkTCP, N.NetworkUDP},
+			router:       router,
+			logger:       logger,
+			tag:          tag,
+			dependencies: options.Outbounds,
+		},
+		myGroupAdapter: myGroupAdapter{
+			ctx:             ctx,
+			tags:            options.Outbounds,
+			uses:            options.Providers,
+			useAllProviders: options.UseAllProviders,
+			includes:        options.Includes,
+			excludes:        options.Excludes,
+			types:           options.Types,
+			ports:           make(map[int]bool),
+			providers:       make(map[string]adapter.OutboundProvider),
+		},
+		strategy: options.Strategy,
+		hashKey:  options.HashKey,
+		options:  options,
+	}
+	if len(outbound.tags) == 0 && len(outbound.uses) == 0 && !outbound.useAllProviders {
+		return nil, E.New("missing tags and uses")
+	}
+	switch outbound.strategy {
+	case "":
+		outbound.strategy = LoadBalanceStrategyRoundRobin
+	case LoadBalanceStrategyRoundRobin, LoadBalanceStrategyRandom, LoadBalanceStrategyConsistentHashing:
+	default:
+		return nil, E.New("unknown load balance strategy: ", outbound.strategy)
+	}
+	switch outbound.hashKey {
+	case "":
+		outbound.hashKey = LoadBalanceHashKeyDestination
+	case LoadBalanceHashKeyDestination, LoadBalanceHashKeySource:
+	default:
+		return nil, E.New("unknown load balance hash key: ", outbound.hashKey)
+	}
+	portMap, err := CreatePortsMap(options.Ports)
+	if err != nil {
+		return nil, err
+	}
+	outbound.ports = portMap
+	return outbound, nil
+}
+
+func (s *LoadBalance) pickOutbounds() ([]adapter.Outbound, error) {
+	outbounds := []adapter.Outbound{}
+	for i, tag := range s.tags {
+		detour, loaded := s.router.Outbound(tag)
+		if !loaded {
+			return nil, E.New("outbound ", i, " not found: ", tag)
+		}
+		outbounds = append(outbounds, detour)
+	}
+	for i, tag := range s.uses {
+		provider, loaded := s.router.OutboundProvider(tag)
+		if !loaded {
+			return nil, E.New("provider ", i, " not found: ", tag)
+		}
+		if _, ok := s.providers[tag]; !ok {
+			s.providers[tag] = provider
+		}
+		if provider.Excluded() {
+			continue
+		}
+		for _, outbound := range provider.Outbounds() {
+			if s.OutboundFilter(outbound) {
+				outbounds = append(outbounds, outbound)
+			}
+		}
+	}
+	if len(outbounds) == 0 {
+		OUTBOUNDLESS, _ := s.router.Outbound("OUTBOUNDLESS")
+		outbounds = append(outbounds, OUTBOUNDLESS)
+	}
+	return outbounds, nil
+}
+
+func (s *LoadBalance) Start() error {
+	if s.useAllProviders {
+		uses := []string{}
+		for _, provider := range s.router.OutboundProviders() {
+			// outbounds of merge providers are listed by the providers they are merged from
+			if provider.Type() == C.TypeMergeProvider {
+				continue
+			}
+			uses = append(uses, provider.Tag())
+		}
+		s.uses = uses
+	}
+	outbounds, err := s.pickOutbounds()
+	if err != nil {
+		return err
+	}
+	group, err := NewURLTestGroup(
+		s.ctx,
+		s.router,
+		s.logger,
+		outbounds,
+		s.options.URL,
+		time.Duration(s.options.Interval),
+		0,
+		time.Duration(s.options.IdleTimeout),
+		false,
+	)
+	if err != nil {
+		return err
+	}
+	s.group = group
+	return nil
+}
+
+func (s *LoadBalance) UpdateOutbounds(tag string) error {
+	if _, ok := s.providers[tag]; ok {
+		outbounds, err := s.pickOutbounds()
+		if err != nil {
+			return E.New("update outbounds failed: ", s.tag, ", with reason: ", err)
+		}
+		s.group.SetOutbounds(outbounds)
+		s.selectedTCP.Store(nil)
+		s.selectedUDP.Store(nil)
+	}
+	return nil
+}
+
+func (s *LoadBalance) PostStart() error {
+	s.group.PostStart()
+	return nil
+}
+
+func (s *LoadBalance) Close() error {
+	return common.Close(
+		common.PtrOrNil(s.group),
+	)
+}
+
+func (s *LoadBalance) Now() string {
+	if selected := s.selectedTCP.Load(); selected != nil {
+		return (*selected).Tag()
+	} else if selected = s.selectedUDP.Load(); selected != nil {
+		return (*selected).Tag()
+	}
+	return s.group.Outbounds()[0].Tag()
+}
+
+func (s *LoadBalance) SelectedOutbound(network string) adapter.Outbound {
+	var selected *adapter.Outbound
+	switch network {
+	case N.NetworkTCP:
+		selected = s.selectedTCP.Load()
+	case N.NetworkUDP:
+		selected = s.selectedUDP.Load()
+	}
+	if selected != nil {
+		return *selected
+	}
+	return s.group.Outbounds()[0]
+}
+
+func (s *LoadBalance) All() []string {
+	all := []string{}
+	for _, outbound := range s.group.Outbounds() {
+		all = append(all, outbound.Tag())
+	}
+	return all
+}
+
+func (s *LoadBalance) URLTest(ctx context.Context) (map[string]uint16, error) {
+	return s.group.URLTest(ctx)
+}
+
+// PerformUpdateCheck is a no-op, members are picked per connection in Select.
+func (s *LoadBalance) PerformUpdateCheck(tag string, force bool) {
+}
+
+func (s *LoadBalance) CheckOutbounds() {
+	s.group.CheckOutbounds(true)
+}
+
+func (s *LoadBalance) InterfaceUpdated() {
+	go s.group.CheckOutbounds(true)
+}
+
+// Select picks the member used for a connection to destination.
+// Members without a successful health check are skipped, unless none of them has one.
+func (s *LoadBalance) Select(ctx context.Context, network string, destination M.Socksaddr) adapter.Outbound {
+	var available, healthy []adapter.Outbound
+	for _, detour := range s.group.Outbounds() {
+		if !common.Contains(detour.Network(), network) {
+			continue
+		}
+		available = append(available, detour)
+		if s.group.history.LoadURLTestHistory(RealTag(detour)) != nil {
+			healthy = append(healthy, detour)
+		}
+	}
+	if len(healthy) > 0 {
+		available = healthy
+	}
+	if len(available) == 0 {
+		return nil
+	}
+	var selected adapter.Outbound
+	switch s.strategy {
+	case LoadBalanceStrategyRandom:
+		selected = available[rand.Intn(len(available))]
+	case LoadBalanceStrategyConsistentHashing:
+		selected = s.selectByHash(s.hashKeyFor(ctx, destination), available)
+	default:
+		selected = available[(s.index.Add(1)-1)%uint32(len(available))]
+	}
+	switch network {
+	case N.NetworkTCP:
+		s.selectedTCP.Store(&selected)
+	case N.NetworkUDP:
+		s.selectedUDP.Store(&selected)
+	}
+	return selected
+}
+
+func (s *LoadBalance) hashKeyFor(ctx context.Context, destination M.Socksaddr) string {
+	metadata := adapter.ContextFrom(ctx)
+	if s.hashKey == LoadBalanceHashKeySource {
+		if metadata != nil && metadata.Source.IsValid() {
+			return metadata.Source.Addr.String()
+		}
+		return ""
+	}
+	if metadata != nil && metadata.Domain != "" {
+		return metadata.Domain
+	}
+	if destination.IsFqdn() {
+		return destination.Fqdn
+	}
+	return destination.Addr.String()
+}
+
+// selectByHash uses rendezvous hashing, so only keys mapped to a removed
+// or unhealthy member move when the member list changes.
+func (s *LoadBalance) selectByHash(key string, outbounds []adapter.Outbound) adapter.Outbound {
+	var (
+		maxScore uint64
+		selected adapter.Outbound
+	)
+	for _, detour := range outbounds {
+		hash := fnv.New64a()
+		hash.Write([]byte(key))
+		hash.Write([]byte{0})
+		hash.Write([]byte(detour.Tag()))
+		score := hash.Sum64()
+		if selected == nil || score > maxScore {
+			maxScore = score
+			selected = detour
+		}
+	}
+	return selected
+}
+
+func (s *LoadBalance) DialContext(ctx context.Context, network string, destination M.Socksaddr) (net.Conn, error) {
+	s.group.Touch()
+	switch N.NetworkName(network) {
+	case N.NetworkTCP, N.NetworkUDP:
+	default:
+		return nil, E.Extend(N.ErrUnknownNetwork, network)
+	}
+	outbound := s.Select(ctx, N.NetworkName(network), destination)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	conn, err := outbound.DialContext(ctx, network, destination)
+	if err == nil {
+		return conn, nil
+	}
+	s.logger.ErrorContext(ctx, err)
+	s.group.history.DeleteURLTestHistory(RealTag(outbound))
+	return nil, err
+}
+
+func (s *LoadBalance) ListenPacket(ctx context.Context, destination M.Socksaddr) (net.PacketConn, error) {
+	s.group.Touch()
+	outbound := s.Select(ctx, N.NetworkUDP, destination)
+	if outbound == nil {
+		return nil, E.New("missing supported outbound")
+	}
+	conn, err := outbound.ListenPacket(ctx, destination)
+	if err == nil {
+		return conn, nil
+	}
+	s.logger.ErrorContext(ctx, err)
+	s.group.history.DeleteURLTestHistory(RealTag(outbound))
+	return nil, err
+}
+
+func (s *LoadBalance) NewConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	return NewConnection(ctx, s, conn, metadata)
+}
+
+func (s *LoadBalance) NewPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	return NewPacketConnection(ctx, s, conn, metadata)
+}
@@ -83,6 +83,10 @@ func (s *Selector) Start() error {
 	if s.useAllProviders {
 		uses := []string{}
 		for _, provider := range s.router.OutboundProviders() {
+			// outbounds of merge providers are listed by the providers they are merged from
+			if provider.Type() == C.TypeMergeProvider {
+				continue
+			}
 			uses = append(uses, provider.Tag())
 		}
 		s.uses = uses
@@ -114,6 +118,9 @@ func (s *Selector) pickOutbounds() ([]adapter.Outbound, map[string]adapter.Outbo
 		if _, ok := s.providers[tag]; !ok {
 			s.providers[tag] = provider
 		}
+		if provider.Excluded() {
+			continue
+		}
 		for _, outbound := range provider.Outbounds() {
 			if s.OutboundFilter(outbound) {
 				tag := outbound.Tag()
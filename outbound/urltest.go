@@ -96,6 +96,9 @@ func (s *URLTest) pickOutbounds() ([]adapter.Outbound, error) {
 		if _, ok := s.providers[tag]; !ok {
 			s.providers[tag] = provider
 		}
+		if provider.Excluded() {
+			continue
+		}
 		for _, outbound := range provider.Outbounds() {
 			if s.OutboundFilter(outbound) {
 				outbounds = append(outbounds, outbound)
@@ -113,6 +116,10 @@ func (s *URLTest) Start() error {
 	if s.useAllProviders {
 		uses := []string{}
 		for _, provider := range s.router.OutboundProviders() {
+			// outbounds of merge providers are listed by the providers they are merged from
+			if provider.Type() == C.TypeMergeProvider {
+				continue
+			}
 			uses = append(uses, provider.Tag())
 		}
 		s.uses = uses
@@ -136,6 +143,8 @@ func (s *URLTest) Start() error {
 		return err
 	}
 	s.group = group
+	// pick from the history restored by providers before the first check
+	s.group.performUpdateCheck()
 	return nil
 }
 
@@ -145,7 +154,7 @@ func (s *URLTest) UpdateOutbounds(tag string) error {
 		if err != nil {
 			return E.New("update outbounds failed: ", s.tag, ", with reason: ", err)
 		}
-		s.group.outbounds = outbounds
+		s.group.SetOutbounds(outbounds)
 		s.group.performUpdateCheck()
 	}
 	return nil
@@ -182,12 +191,12 @@ func (s *URLTest) SelectedOutbound(network string) adapter.Outbound {
 			return s.group.selectedOutboundUDP
 		}
 	}
-	return s.group.outbounds[0]
+	return s.group.Outbounds()[0]
 }
 
 func (s *URLTest) All() []string {
 	all := []string{}
-	for _, outbound := range s.group.outbounds {
+	for _, outbound := range s.group.Outbounds() {
 		all = append(all, outbound.Tag())
 	}
 	return all
@@ -284,11 +293,12 @@ type URLTestGroup struct {
 	interruptGroup               *interrupt.Group
 	interruptExternalConnections bool
 
-	access     sync.Mutex
-	ticker     *time.Ticker
-	close      chan struct{}
-	started    bool
-	lastActive atomic.TypedValue[time.Time]
+	access          sync.Mutex
+	outboundsAccess sync.RWMutex
+	ticker          *time.Ticker
+	close           chan struct{}
+	started         bool
+	lastActive      atomic.TypedValue[time.Time]
 }
 
 func NewURLTestGroup(
@@ -352,6 +362,19 @@ func NewURLTestGroup(
 	}, nil
 }
 
+// Outbounds returns the members, the returned slice is replaced as a whole by SetOutbounds and never modified.
+func (g *URLTestGroup) Outbounds() []adapter.Outbound {
+	g.outboundsAccess.RLock()
+	defer g.outboundsAccess.RUnlock()
+	return g.outbounds
+}
+
+func (g *URLTestGroup) SetOutbounds(outbounds []adapter.Outbound) {
+	g.outboundsAccess.Lock()
+	defer g.outboundsAccess.Unlock()
+	g.outbounds = outbounds
+}
+
 func (g *URLTestGroup) PostStart() {
 	g.started = true
 	g.lastActive.Store(time.Now())
@@ -385,10 +408,11 @@ func (g *URLTestGroup) Close() error {
 }
 
 func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
+	outbounds := g.Outbounds()
 	var minDelay uint16
 	var minTime time.Time
 	var minOutbound adapter.Outbound
-	for _, detour := range g.outbounds {
+	for _, detour := range outbounds {
 		if !common.Contains(detour.Network(), network) {
 			continue
 		}
@@ -403,7 +427,7 @@ func (g *URLTestGroup) Select(network string) (adapter.Outbound, bool) {
 		}
 	}
 	if minOutbound == nil {
-		for _, detour := range g.outbounds {
+		for _, detour := range outbounds {
 			if !common.Contains(detour.Network(), network) {
 				continue
 			}
@@ -454,7 +478,7 @@ func (g *URLTestGroup) urlTest(ctx context.Context, force bool) (map[string]uint
 	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
 	checked := make(map[string]bool)
 	var resultAccess sync.Mutex
-	for _, detour := range g.outbounds {
+	for _, detour := range g.Outbounds() {
 		tag := detour.Tag()
 		realTag := RealTag(detour)
 		if checked[realTag] {
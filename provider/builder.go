@@ -14,16 +14,25 @@ import (
 )
 
 func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, options option.OutboundProvider) (adapter.OutboundProvider, error) {
-	if options.Path == "" {
-		return nil, E.New("provider path missing")
+	if options.HealthcheckUrl == "" {
+		options.HealthcheckUrl = "https://www.gstatic.com/generate_204"
 	}
-	path, _ := C.FindPath(options.Path)
-	if foundPath, loaded := C.FindPath(path); loaded {
-		path = foundPath
+	if options.QuotaWarningPercent < 0 || options.QuotaWarningPercent > 100 {
+		return nil, E.New("invalid quota_warning_percent: ", options.QuotaWarningPercent)
 	}
-	if !rw.FileExists(path) {
-		path = filemanager.BasePath(ctx, path)
+	if options.ExpireWarningDays < 0 {
+		return nil, E.New("invalid expire_warning_days: ", options.ExpireWarningDays)
+	}
+	switch options.Type {
+	case C.TypeInlineProvider:
+		return NewInlineProvider(ctx, router, logger, options)
+	case C.TypeMergeProvider:
+		return NewMergeProvider(ctx, router, logger, options)
+	}
+	if options.Path == "" {
+		return nil, E.New("provider path missing")
 	}
+	path := resolvePath(ctx, options.Path)
 	if stat, err := os.Stat(path); err == nil {
 		if stat.IsDir() {
 			return nil, E.New("provider path is a directory: ", path)
@@ -32,9 +41,6 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, o
 			os.Remove(path)
 		}
 	}
-	if options.HealthcheckUrl == "" {
-		options.HealthcheckUrl = "https://www.gstatic.com/generate_204"
-	}
 	switch options.Type {
 	case C.TypeFileProvider:
 		return NewFileProvider(ctx, router, logger, options, path)
@@ -44,3 +50,14 @@ func New(ctx context.Context, router adapter.Router, logger log.ContextLogger, o
 		return nil, E.New("invalid provider type")
 	}
 }
+
+func resolvePath(ctx context.Context, path string) string {
+	path, _ = C.FindPath(path)
+	if foundPath, loaded := C.FindPath(path); loaded {
+		path = foundPath
+	}
+	if !rw.FileExists(path) {
+		path = filemanager.BasePath(ctx, path)
+	}
+	return path
+}
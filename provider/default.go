@@ -24,6 +24,7 @@ import (
 	E "github.com/sagernet/sing/common/exceptions"
 	F "github.com/sagernet/sing/common/format"
 	"github.com/sagernet/sing/common/rw"
+	"github.com/sagernet/sing/service"
 	"github.com/sagernet/sing/service/pause"
 )
 
@@ -45,15 +46,24 @@ type myProviderAdapter struct {
 	healthcheckUrl      string
 	healthcheckInterval time.Duration
 	overrideDialer      *option.OverrideDialerOptions
+	processor           *outboundProcessor
 	healchcheckHistory  *urltest.HistoryStorage
 	providerType        string
 	updateTime          time.Time
+	outboundAccess      sync.RWMutex
 	outbounds           []adapter.Outbound
 	outboundByTag       map[string]adapter.Outbound
+	outboundOptions     []option.Outbound
+	outboundSources     []option.Outbound
 	checking            atomic.Bool
 	updating            atomic.Bool
 	pauseManager        pause.Manager
 	lastHealthcheck     time.Time
+	historyRestored     bool
+	quotaWarning        int
+	expireWarning       time.Duration
+	excludeExhausted    bool
+	subStatus           atomic.TypedValue[string]
 
 	ticker *time.Ticker
 	close  chan struct{}
@@ -76,16 +86,44 @@ func (a *myProviderAdapter) UpdateTime() time.Time {
 }
 
 func (a *myProviderAdapter) Outbound(tag string) (adapter.Outbound, bool) {
+	a.outboundAccess.RLock()
+	defer a.outboundAccess.RUnlock()
 	outbound, loaded := a.outboundByTag[tag]
 	return outbound, loaded
 }
 
 func (a *myProviderAdapter) Outbounds() []adapter.Outbound {
+	a.outboundAccess.RLock()
+	defer a.outboundAccess.RUnlock()
 	outbounds := []adapter.Outbound{}
 	outbounds = append(outbounds, a.outbounds...)
 	return outbounds
 }
 
+func (a *myProviderAdapter) OutboundOptions() []option.Outbound {
+	a.outboundAccess.RLock()
+	defer a.outboundAccess.RUnlock()
+	outboundOptions := []option.Outbound{}
+	outboundOptions = append(outboundOptions, a.outboundOptions...)
+	return outboundOptions
+}
+
+// outboundsWithSources returns the outbounds with the options each one was created from.
+func (a *myProviderAdapter) outboundsWithSources() ([]adapter.Outbound, []option.Outbound) {
+	a.outboundAccess.RLock()
+	defer a.outboundAccess.RUnlock()
+	return a.outbounds, a.outboundSources
+}
+
+// setOutbounds replaces the outbounds, other providers and groups read them concurrently.
+func (a *myProviderAdapter) setOutbounds(outbounds []adapter.Outbound, sources []option.Outbound, outboundByTag map[string]adapter.Outbound) {
+	a.outboundAccess.Lock()
+	defer a.outboundAccess.Unlock()
+	a.outbounds = outbounds
+	a.outboundSources = sources
+	a.outboundByTag = outboundByTag
+}
+
 func (a *myProviderAdapter) firstStart() error {
 	if !rw.FileExists(a.path) {
 		return nil
@@ -93,20 +131,37 @@ func (a *myProviderAdapter) firstStart() error {
 	fileInfo, _ := os.Stat(a.path)
 	fileModeTime := fileInfo.ModTime()
 	info, content := a.getContentFromFile(a.router)
-	outbounds, err := a.parseOutbounds(a.ctx, a.router, decodeBase64Safe(content))
+	err := a.loadContent(decodeBase64Safe(content))
+	if err != nil {
+		return err
+	}
+	a.subInfo = info
+	a.updateTime = fileModeTime
+	return nil
+}
+
+func (a *myProviderAdapter) loadContent(content string) error {
+	outboundOptions, err := a.parseOutbounds(content)
 	if err != nil {
 		return err
 	}
+	a.loadOutbounds(outboundOptions)
+	return nil
+}
+
+func (a *myProviderAdapter) loadOutbounds(outboundOptions []option.Outbound) {
+	outbounds, sources := a.createOutbounds(a.ctx, a.router, outboundOptions)
 	outboundByTag := make(map[string]adapter.Outbound)
 	for _, out := range outbounds {
 		tag := out.Tag()
 		outboundByTag[tag] = out
 	}
+	a.outboundAccess.Lock()
+	defer a.outboundAccess.Unlock()
 	a.outbounds = outbounds
+	a.outboundSources = sources
 	a.outboundByTag = outboundByTag
-	a.subInfo = info
-	a.updateTime = fileModeTime
-	return nil
+	a.outboundOptions = outboundOptions
 }
 
 func getFirstLine(content string) (string, string) {
@@ -145,13 +200,15 @@ func parseSubInfo(infoString string) (SubInfo, bool) {
 	return info, false
 }
 
-func (a *myProviderAdapter) createOutbounds(ctx context.Context, router adapter.Router, outbounds []option.Outbound) ([]adapter.Outbound, error) {
+// createOutbounds returns the outbounds created and the options of each of them, groups are skipped.
+func (a *myProviderAdapter) createOutbounds(ctx context.Context, router adapter.Router, outbounds []option.Outbound) ([]adapter.Outbound, []option.Outbound) {
 	outs := []adapter.Outbound{}
+	var sources []option.Outbound
 	for _, outbound := range outbounds {
 		otype := outbound.Type
 		tag := outbound.Tag
 		switch otype {
-		case C.TypeDirect, C.TypeBlock, C.TypeDNS, C.TypeSelector, C.TypeURLTest:
+		case C.TypeDirect, C.TypeBlock, C.TypeDNS, C.TypeSelector, C.TypeURLTest, C.TypeLoadBalance, C.TypeFallback:
 			continue
 		default:
 			out, err := O.New(ctx, router, a.logger, tag, outbound)
@@ -160,9 +217,10 @@ func (a *myProviderAdapter) createOutbounds(ctx context.Context, router adapter.
 				continue
 			}
 			outs = append(outs, out)
+			sources = append(sources, outbound)
 		}
 	}
-	return outs, nil
+	return outs, sources
 }
 
 func getTrimedFile(path string) []byte {
@@ -236,49 +294,154 @@ func decodeBase64Safe(content string) string {
 	return content
 }
 
-func (p *myProviderAdapter) parseOutbounds(ctx context.Context, router adapter.Router, content string) ([]adapter.Outbound, error) {
+func (p *myProviderAdapter) parseOutbounds(content string) ([]option.Outbound, error) {
 	outbounds, err := newParser(content, p.overrideDialer)
 	if err != nil {
 		return nil, err
 	}
-	return p.createOutbounds(ctx, router, outbounds)
+	return p.processor.process(outbounds)
 }
 
 func (p *myProviderAdapter) updateProviderFromContent(ctx context.Context, router adapter.Router, content string) error {
-	outbounds, err := p.parseOutbounds(ctx, router, decodeBase64Safe(content))
+	outboundOptions, err := p.parseOutbounds(decodeBase64Safe(content))
 	if err != nil {
 		return err
 	}
+	return p.updateProviderFromOptions(ctx, router, outboundOptions)
+}
+
+func (p *myProviderAdapter) updateProviderFromOptions(ctx context.Context, router adapter.Router, outboundOptions []option.Outbound) error {
+	outbounds, sources := p.createOutbounds(ctx, router, outboundOptions)
 
 	outbounds, outboundByTag, err := p.startOutbounds(router, outbounds)
 	if err != nil {
 		return err
 	}
 
+	p.outboundAccess.RLock()
 	outsBackup := p.outbounds
+	sourcesBackup := p.outboundSources
 	outByTagBackup := p.outboundByTag
-	p.outbounds = outbounds
-	p.outboundByTag = outboundByTag
+	p.outboundAccess.RUnlock()
+	p.setOutbounds(outbounds, sources, outboundByTag)
 
 	if err := p.updateGroups(router); err != nil {
 		for _, out := range outbounds {
 			common.Close(out)
 		}
-		p.outbounds = outsBackup
-		p.outboundByTag = outByTagBackup
+		p.setOutbounds(outsBackup, sourcesBackup, outByTagBackup)
 		return err
 	}
+	p.outboundAccess.Lock()
+	p.outboundOptions = outboundOptions
+	p.outboundAccess.Unlock()
+	p.updateMergeProviders(ctx, router)
 
 	return nil
 }
 
+// updateMergeProviders rebuilds the merge providers containing this provider.
+func (p *myProviderAdapter) updateMergeProviders(ctx context.Context, router adapter.Router) {
+	for _, provider := range router.OutboundProviders() {
+		mergeProvider, isMerge := provider.(*MergeProvider)
+		if !isMerge || !common.Contains(mergeProvider.providers, p.tag) {
+			continue
+		}
+		err := mergeProvider.UpdateProvider(ctx, router, true)
+		if err != nil {
+			mergeProvider.logger.Error(E.Cause(err, "update outbound provider ", mergeProvider.tag))
+		}
+	}
+}
+
 func (p *myProviderAdapter) UpdateOutboundByTag() {
+	p.outboundAccess.Lock()
 	outboundByTag := make(map[string]adapter.Outbound)
 	for _, out := range p.outbounds {
 		tag := out.Tag()
 		outboundByTag[tag] = out
 	}
 	p.outboundByTag = outboundByTag
+	p.outboundAccess.Unlock()
+	if !p.historyRestored {
+		// outbound tags are final only after the box has de-duplicated them
+		p.historyRestored = true
+		p.restoreHistory()
+	}
+}
+
+func (p *myProviderAdapter) restoreInfo() {
+	cacheFile := service.FromContext[adapter.CacheFile](p.ctx)
+	if cacheFile == nil {
+		return
+	}
+	savedInfo := cacheFile.LoadProviderInfo(p.tag)
+	if savedInfo == nil {
+		return
+	}
+	if p.subInfo == (SubInfo{}) {
+		p.subInfo = SubInfo{
+			upload:   savedInfo.Upload,
+			download: savedInfo.Download,
+			total:    savedInfo.Total,
+			expire:   savedInfo.Expire,
+		}
+	}
+	// Without loaded content the update time stays zero, so that the provider is updated at startup.
+	if !p.updateTime.IsZero() && savedInfo.LastUpdated.After(p.updateTime) {
+		p.updateTime = savedInfo.LastUpdated
+	}
+}
+
+func (p *myProviderAdapter) saveInfo() {
+	cacheFile := service.FromContext[adapter.CacheFile](p.ctx)
+	if cacheFile == nil {
+		return
+	}
+	err := cacheFile.SaveProviderInfo(p.tag, &adapter.SavedProviderInfo{
+		LastUpdated: p.updateTime,
+		Upload:      p.subInfo.upload,
+		Download:    p.subInfo.download,
+		Total:       p.subInfo.total,
+		Expire:      p.subInfo.expire,
+	})
+	if err != nil {
+		p.logger.Error("save provider info: ", err)
+	}
+}
+
+func (p *myProviderAdapter) restoreHistory() {
+	cacheFile := service.FromContext[adapter.CacheFile](p.ctx)
+	if cacheFile == nil || p.healchcheckHistory == nil {
+		return
+	}
+	savedHistory := cacheFile.LoadProviderHistory(p.tag)
+	for _, out := range p.Outbounds() {
+		tag := out.Tag()
+		history := savedHistory[tag]
+		if history == nil || p.healchcheckHistory.LoadURLTestHistory(tag) != nil {
+			continue
+		}
+		p.healchcheckHistory.StoreURLTestHistory(tag, history)
+	}
+}
+
+func (p *myProviderAdapter) saveHistory() {
+	cacheFile := service.FromContext[adapter.CacheFile](p.ctx)
+	if cacheFile == nil {
+		return
+	}
+	savedHistory := make(adapter.SavedProviderHistory)
+	for _, out := range p.Outbounds() {
+		tag := out.Tag()
+		if history := p.healchcheckHistory.LoadURLTestHistory(tag); history != nil {
+			savedHistory[tag] = history
+		}
+	}
+	err := cacheFile.SaveProviderHistory(p.tag, savedHistory)
+	if err != nil {
+		p.logger.Error("save provider history: ", err)
+	}
 }
 
 func (p *myProviderAdapter) startOutbounds(router adapter.Router, outbounds []adapter.Outbound) ([]adapter.Outbound, map[string]adapter.Outbound, error) {
@@ -288,7 +451,8 @@ func (p *myProviderAdapter) startOutbounds(router adapter.Router, outbounds []ad
 		outboundTag[out.Tag()] = true
 	}
 	for _, p := range router.OutboundProviders() {
-		if p.Tag() == pTag {
+		// merge providers list outbounds of the other providers
+		if p.Tag() == pTag || p.Type() == C.TypeMergeProvider {
 			continue
 		}
 		for _, out := range p.Outbounds() {
@@ -358,6 +522,12 @@ func (p *myProviderAdapter) refreshURLTestSelected(router adapter.Router) {
 }
 
 func (p *myProviderAdapter) CheckOutbounds(force bool) {
+	if p.updateSubStatus() {
+		if err := p.updateGroups(p.router); err != nil {
+			p.logger.Error(err)
+		}
+		p.updateMergeProviders(p.ctx, p.router)
+	}
 	p.healthcheck(p.ctx, p.healthcheckUrl, force)
 	p.refreshURLTestSelected(p.router)
 }
@@ -384,13 +554,13 @@ func (p *myProviderAdapter) healthcheck(ctx context.Context, link string, force
 	b, _ := batch.New(ctx, batch.WithConcurrencyNum[any](10))
 	checked := make(map[string]bool)
 	var resultAccess sync.Mutex
-	for _, detour := range p.outbounds {
+	for _, detour := range p.Outbounds() {
 		tag := detour.Tag()
 		if checked[tag] {
 			continue
 		}
 		checked[tag] = true
-		detour, loaded := p.outboundByTag[tag]
+		detour, loaded := p.Outbound(tag)
 		if !loaded {
 			continue
 		}
@@ -415,6 +585,7 @@ func (p *myProviderAdapter) healthcheck(ctx context.Context, link string, force
 		})
 	}
 	b.Wait()
+	p.saveHistory()
 	for _, outbound := range p.router.Outbounds() {
 		group, isGroup := outbound.(adapter.OutboundGroup)
 		if !isGroup {
@@ -0,0 +1,123 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/rw"
+)
+
+const (
+	ExportFormatLink    = "link"
+	ExportFormatBase64  = "base64"
+	ExportFormatClash   = "clash"
+	ExportFormatSingBox = "sing-box"
+)
+
+// Export serializes outbounds as share links, Clash YAML or a sing-box outbound list.
+// Outbounds not supported by the format and the same server appearing more than once are skipped.
+func Export(outbounds []option.Outbound, format string) ([]byte, error) {
+	outbounds = common.Filter(outbounds, func(it option.Outbound) bool {
+		switch it.Type {
+		case C.TypeDirect, C.TypeBlock, C.TypeDNS, C.TypeSelector, C.TypeURLTest, C.TypeLoadBalance, C.TypeFallback:
+			return false
+		default:
+			return true
+		}
+	})
+	outbounds, err := dedupeOutbounds(outbounds)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case "", ExportFormatLink:
+		return exportNativeURIs(outbounds), nil
+	case ExportFormatBase64:
+		return []byte(base64.StdEncoding.EncodeToString(exportNativeURIs(outbounds))), nil
+	case ExportFormatClash:
+		return exportClash(outbounds)
+	case ExportFormatSingBox:
+		var buffer bytes.Buffer
+		encoder := json.NewEncoder(&buffer)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(option.Options{Outbounds: outbounds})
+		if err != nil {
+			return nil, err
+		}
+		return buffer.Bytes(), nil
+	default:
+		return nil, E.New("unknown export format: ", format)
+	}
+}
+
+// LoadOutboundOptions parses the outbounds of providers without creating them.
+// http providers are read from the downloaded file, and are empty if it does not exist yet.
+func LoadOutboundOptions(ctx context.Context, providers []option.OutboundProvider) (map[string][]option.Outbound, error) {
+	outboundsByTag := make(map[string][]option.Outbound)
+	var mergeProviders []option.OutboundProvider
+	for i, options := range providers {
+		if options.Type == C.TypeMergeProvider {
+			mergeProviders = append(mergeProviders, options)
+			continue
+		}
+		outbounds, err := loadOutboundOptions(ctx, options)
+		if err != nil {
+			return nil, E.Cause(err, "parse outbound provider[", i, "]")
+		}
+		outboundsByTag[options.Tag] = outbounds
+	}
+	for _, options := range mergeProviders {
+		processor, err := newOutboundProcessor(options)
+		if err != nil {
+			return nil, err
+		}
+		p := &myProviderAdapter{
+			overrideDialer: options.OverrideDialer,
+			processor:      processor,
+		}
+		outbounds, err := p.mergeOutboundOptions(options.MergeOptions.Providers, func(i int, tag string) ([]option.Outbound, error) {
+			outbounds, loaded := outboundsByTag[tag]
+			if !loaded {
+				return nil, E.New("provider ", i, " not found: ", tag)
+			}
+			return outbounds, nil
+		})
+		if err != nil {
+			return nil, E.Cause(err, "merge outbound provider[", options.Tag, "]")
+		}
+		outboundsByTag[options.Tag] = outbounds
+	}
+	return outboundsByTag, nil
+}
+
+func loadOutboundOptions(ctx context.Context, options option.OutboundProvider) ([]option.Outbound, error) {
+	processor, err := newOutboundProcessor(options)
+	if err != nil {
+		return nil, err
+	}
+	p := &myProviderAdapter{
+		ctx:            ctx,
+		tag:            options.Tag,
+		overrideDialer: options.OverrideDialer,
+		processor:      processor,
+	}
+	switch options.Type {
+	case C.TypeInlineProvider:
+		return p.parseInlineOutbounds(options.InlineOptions)
+	case C.TypeFileProvider, C.TypeHTTPProvider:
+		p.path = resolvePath(ctx, options.Path)
+		if !rw.FileExists(p.path) {
+			return nil, nil
+		}
+		_, content := p.getContentFromFile(nil)
+		return p.parseOutbounds(decodeBase64Safe(content))
+	default:
+		return nil, E.New("invalid provider type")
+	}
+}
@@ -0,0 +1,327 @@
+package provider
+
+import (
+	"strings"
+	"time"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"gopkg.in/yaml.v3"
+)
+
+// clashProxy keeps the keys in insertion order, so name, type and server come first.
+type clashProxy struct {
+	keys   []string
+	values map[string]any
+}
+
+func newClashProxy(outbound option.Outbound, proxyType string, server option.ServerOptions) *clashProxy {
+	proxy := &clashProxy{values: make(map[string]any)}
+	proxy.set("name", outbound.Tag)
+	proxy.set("type", proxyType)
+	proxy.set("server", server.Server)
+	proxy.set("port", server.ServerPort)
+	return proxy
+}
+
+func (p *clashProxy) set(key string, value any) {
+	if _, loaded := p.values[key]; !loaded {
+		p.keys = append(p.keys, key)
+	}
+	p.values[key] = value
+}
+
+func (p *clashProxy) MarshalYAML() (any, error) {
+	node := &yaml.Node{Kind: yaml.MappingNode}
+	for _, key := range p.keys {
+		var valueNode yaml.Node
+		err := valueNode.Encode(p.values[key])
+		if err != nil {
+			return nil, err
+		}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, &valueNode)
+	}
+	return node, nil
+}
+
+func exportClash(outbounds []option.Outbound) ([]byte, error) {
+	proxies := []*clashProxy{}
+	for _, outbound := range outbounds {
+		var proxy *clashProxy
+		switch outbound.Type {
+		case C.TypeShadowsocks:
+			proxy = exportSSClash(outbound)
+		case C.TypeVMess:
+			proxy = exportVMessClash(outbound)
+		case C.TypeVLESS:
+			proxy = exportVLESSClash(outbound)
+		case C.TypeTrojan:
+			proxy = exportTrojanClash(outbound)
+		case C.TypeTUIC:
+			proxy = exportTUICClash(outbound)
+		case C.TypeHysteria:
+			proxy = exportHysteriaClash(outbound)
+		case C.TypeHysteria2:
+			proxy = exportHysteria2Clash(outbound)
+		case C.TypeSOCKS:
+			proxy = exportSOCKS5Clash(outbound)
+		case C.TypeHTTP:
+			proxy = exportHTTPClash(outbound)
+		default:
+			continue
+		}
+		proxies = append(proxies, proxy)
+	}
+	return yaml.Marshal(map[string]any{
+		"proxies": proxies,
+	})
+}
+
+func exportTLSClash(proxy *clashProxy, options *option.OutboundTLSOptions, serverNameKey string) {
+	if options == nil || !options.Enabled {
+		return
+	}
+	if serverNameKey != "" {
+		proxy.set("tls", true)
+	}
+	if options.ServerName != "" {
+		if serverNameKey == "" {
+			serverNameKey = "sni"
+		}
+		proxy.set(serverNameKey, options.ServerName)
+	}
+	if len(options.ALPN) > 0 {
+		proxy.set("alpn", []string(options.ALPN))
+	}
+	if options.Insecure {
+		proxy.set("skip-cert-verify", true)
+	}
+	if options.UTLS != nil && options.UTLS.Enabled && options.UTLS.Fingerprint != "" {
+		proxy.set("client-fingerprint", options.UTLS.Fingerprint)
+	}
+	if options.Reality != nil && options.Reality.Enabled {
+		realityOpts := map[string]any{
+			"public-key": options.Reality.PublicKey,
+		}
+		if options.Reality.ShortID != "" {
+			realityOpts["short-id"] = options.Reality.ShortID
+		}
+		proxy.set("reality-opts", realityOpts)
+	}
+}
+
+func exportTransportClash(proxy *clashProxy, options *option.V2RayTransportOptions) {
+	if options == nil {
+		return
+	}
+	switch options.Type {
+	case C.V2RayTransportTypeWebsocket:
+		proxy.set("network", "ws")
+		wsOpts := map[string]any{
+			"path": options.WebsocketOptions.Path,
+		}
+		if host := headerValue(options.WebsocketOptions.Headers, "Host"); host != "" {
+			wsOpts["headers"] = map[string]string{"Host": host}
+		}
+		if options.WebsocketOptions.MaxEarlyData > 0 {
+			wsOpts["max-early-data"] = options.WebsocketOptions.MaxEarlyData
+			wsOpts["early-data-header-name"] = options.WebsocketOptions.EarlyDataHeaderName
+		}
+		proxy.set("ws-opts", wsOpts)
+	case C.V2RayTransportTypeHTTPUpgrade:
+		proxy.set("network", "ws")
+		wsOpts := map[string]any{
+			"path":               options.HTTPUpgradeOptions.Path,
+			"v2ray-http-upgrade": true,
+		}
+		if options.HTTPUpgradeOptions.Host != "" {
+			wsOpts["headers"] = map[string]string{"Host": options.HTTPUpgradeOptions.Host}
+		}
+		proxy.set("ws-opts", wsOpts)
+	case C.V2RayTransportTypeHTTP:
+		if tls, _ := proxy.values["tls"].(bool); tls {
+			proxy.set("network", "h2")
+			proxy.set("h2-opts", map[string]any{
+				"host": []string(options.HTTPOptions.Host),
+				"path": options.HTTPOptions.Path,
+			})
+		} else {
+			proxy.set("network", "http")
+			httpOpts := map[string]any{
+				"path": []string{options.HTTPOptions.Path},
+			}
+			if options.HTTPOptions.Method != "" {
+				httpOpts["method"] = options.HTTPOptions.Method
+			}
+			if len(options.HTTPOptions.Host) > 0 {
+				httpOpts["headers"] = map[string]any{"Host": []string(options.HTTPOptions.Host)}
+			}
+			proxy.set("http-opts", httpOpts)
+		}
+	case C.V2RayTransportTypeGRPC:
+		proxy.set("network", "grpc")
+		proxy.set("grpc-opts", map[string]any{
+			"grpc-service-name": options.GRPCOptions.ServiceName,
+		})
+	}
+}
+
+func exportSSClash(outbound option.Outbound) *clashProxy {
+	options := outbound.ShadowsocksOptions
+	proxy := newClashProxy(outbound, "ss", options.ServerOptions)
+	proxy.set("cipher", options.Method)
+	proxy.set("password", options.Password)
+	if options.Plugin == "obfs-local" || options.Plugin == "v2ray-plugin" {
+		pluginOpts := map[string]any{}
+		for _, item := range strings.Split(options.PluginOptions, ";") {
+			key, value, _ := strings.Cut(item, "=")
+			switch key {
+			case "obfs":
+				pluginOpts["mode"] = value
+			case "obfs-host":
+				pluginOpts["host"] = value
+			case "":
+			default:
+				pluginOpts[key] = value
+			}
+		}
+		if options.Plugin == "obfs-local" {
+			proxy.set("plugin", "obfs")
+		} else {
+			proxy.set("plugin", "v2ray-plugin")
+		}
+		proxy.set("plugin-opts", pluginOpts)
+	}
+	if options.UDPOverTCP != nil && options.UDPOverTCP.Enabled {
+		proxy.set("udp-over-tcp", true)
+	}
+	return proxy
+}
+
+func exportVMessClash(outbound option.Outbound) *clashProxy {
+	options := outbound.VMessOptions
+	proxy := newClashProxy(outbound, "vmess", options.ServerOptions)
+	proxy.set("uuid", options.UUID)
+	proxy.set("alterId", options.AlterId)
+	if options.Security != "" {
+		proxy.set("cipher", options.Security)
+	} else {
+		proxy.set("cipher", "auto")
+	}
+	exportTLSClash(proxy, options.TLS, "servername")
+	exportTransportClash(proxy, options.Transport)
+	return proxy
+}
+
+func exportVLESSClash(outbound option.Outbound) *clashProxy {
+	options := outbound.VLESSOptions
+	proxy := newClashProxy(outbound, "vless", options.ServerOptions)
+	proxy.set("uuid", options.UUID)
+	if options.Flow != "" {
+		proxy.set("flow", options.Flow)
+	}
+	exportTLSClash(proxy, options.TLS, "servername")
+	exportTransportClash(proxy, options.Transport)
+	return proxy
+}
+
+func exportTrojanClash(outbound option.Outbound) *clashProxy {
+	options := outbound.TrojanOptions
+	proxy := newClashProxy(outbound, "trojan", options.ServerOptions)
+	proxy.set("password", options.Password)
+	exportTLSClash(proxy, options.TLS, "")
+	exportTransportClash(proxy, options.Transport)
+	return proxy
+}
+
+func exportTUICClash(outbound option.Outbound) *clashProxy {
+	options := outbound.TUICOptions
+	proxy := newClashProxy(outbound, "tuic", options.ServerOptions)
+	proxy.set("uuid", options.UUID)
+	proxy.set("password", options.Password)
+	if options.CongestionControl != "" {
+		proxy.set("congestion-controller", options.CongestionControl)
+	}
+	if options.UDPRelayMode != "" {
+		proxy.set("udp-relay-mode", options.UDPRelayMode)
+	}
+	if options.ZeroRTTHandshake {
+		proxy.set("reduce-rtt", true)
+	}
+	if options.UDPOverStream {
+		proxy.set("udp-over-stream", true)
+	}
+	if options.Heartbeat > 0 {
+		proxy.set("heartbeat-interval", time.Duration(options.Heartbeat).Milliseconds())
+	}
+	exportTLSClash(proxy, options.TLS, "")
+	if options.TLS != nil && options.TLS.DisableSNI {
+		proxy.set("disable-sni", true)
+	}
+	return proxy
+}
+
+func exportHysteriaClash(outbound option.Outbound) *clashProxy {
+	options := outbound.HysteriaOptions
+	proxy := newClashProxy(outbound, "hysteria", options.ServerOptions)
+	if options.AuthString != "" {
+		proxy.set("auth-str", options.AuthString)
+	}
+	if options.UpMbps > 0 {
+		proxy.set("up", options.UpMbps)
+	} else if options.Up != "" {
+		proxy.set("up", options.Up)
+	}
+	if options.DownMbps > 0 {
+		proxy.set("down", options.DownMbps)
+	} else if options.Down != "" {
+		proxy.set("down", options.Down)
+	}
+	if options.Obfs != "" {
+		proxy.set("obfs", options.Obfs)
+	}
+	exportTLSClash(proxy, options.TLS, "")
+	return proxy
+}
+
+func exportHysteria2Clash(outbound option.Outbound) *clashProxy {
+	options := outbound.Hysteria2Options
+	proxy := newClashProxy(outbound, "hysteria2", options.ServerOptions)
+	proxy.set("password", options.Password)
+	if options.UpMbps > 0 {
+		proxy.set("up", options.UpMbps)
+	}
+	if options.DownMbps > 0 {
+		proxy.set("down", options.DownMbps)
+	}
+	if options.Obfs != nil && options.Obfs.Type != "" {
+		proxy.set("obfs", options.Obfs.Type)
+		proxy.set("obfs-password", options.Obfs.Password)
+	}
+	exportTLSClash(proxy, options.TLS, "")
+	return proxy
+}
+
+func exportSOCKS5Clash(outbound option.Outbound) *clashProxy {
+	options := outbound.SocksOptions
+	proxy := newClashProxy(outbound, "socks5", options.ServerOptions)
+	if options.Username != "" {
+		proxy.set("username", options.Username)
+		proxy.set("password", options.Password)
+	}
+	if options.UDPOverTCP != nil && options.UDPOverTCP.Enabled {
+		proxy.set("udp-over-tcp", true)
+	}
+	return proxy
+}
+
+func exportHTTPClash(outbound option.Outbound) *clashProxy {
+	options := outbound.HTTPOptions
+	proxy := newClashProxy(outbound, "http", options.ServerOptions)
+	if options.Username != "" {
+		proxy.set("username", options.Username)
+		proxy.set("password", options.Password)
+	}
+	exportTLSClash(proxy, options.TLS, "sni")
+	return proxy
+}
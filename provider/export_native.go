@@ -0,0 +1,307 @@
+package provider
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+)
+
+func exportNativeURIs(outbounds []option.Outbound) []byte {
+	links := []string{}
+	for _, outbound := range outbounds {
+		var link string
+		switch outbound.Type {
+		case C.TypeShadowsocks:
+			link = exportSSNativeURI(outbound)
+		case C.TypeVMess:
+			link = exportVMessNativeURI(outbound)
+		case C.TypeVLESS:
+			link = exportVLESSNativeURI(outbound)
+		case C.TypeTrojan:
+			link = exportTrojanNativeURI(outbound)
+		case C.TypeTUIC:
+			link = exportTuicNativeURI(outbound)
+		case C.TypeHysteria:
+			link = exportHysteriaNativeURI(outbound)
+		case C.TypeHysteria2:
+			link = exportHysteria2NativeURI(outbound)
+		default:
+			continue
+		}
+		links = append(links, link)
+	}
+	return []byte(strings.Join(links, "\n"))
+}
+
+func encodeURIComponent(content string) string {
+	return strings.ReplaceAll(url.QueryEscape(content), "+", "%20")
+}
+
+func nativeURI(scheme string, userInfo string, server option.ServerOptions, query url.Values, tag string) string {
+	var builder strings.Builder
+	builder.WriteString(scheme)
+	builder.WriteString("://")
+	if userInfo != "" {
+		builder.WriteString(userInfo)
+		builder.WriteString("@")
+	}
+	builder.WriteString(net.JoinHostPort(server.Server, strconv.Itoa(int(server.ServerPort))))
+	if len(query) > 0 {
+		builder.WriteString("?")
+		builder.WriteString(query.Encode())
+	}
+	builder.WriteString("#")
+	builder.WriteString(encodeURIComponent(tag))
+	return builder.String()
+}
+
+func setTLSQuery(query url.Values, options *option.OutboundTLSOptions, insecureKey string) {
+	if options == nil || !options.Enabled {
+		return
+	}
+	query.Set("security", "tls")
+	if options.ServerName != "" {
+		query.Set("sni", options.ServerName)
+	}
+	if len(options.ALPN) > 0 {
+		query.Set("alpn", strings.Join(options.ALPN, ","))
+	}
+	if options.Insecure {
+		query.Set(insecureKey, "1")
+	}
+	if options.UTLS != nil && options.UTLS.Enabled && options.UTLS.Fingerprint != "" {
+		query.Set("fp", options.UTLS.Fingerprint)
+	}
+	if options.Reality != nil && options.Reality.Enabled {
+		query.Set("security", "reality")
+		query.Set("pbk", options.Reality.PublicKey)
+		if options.Reality.ShortID != "" {
+			query.Set("sid", options.Reality.ShortID)
+		}
+	}
+}
+
+func websocketPath(options option.V2RayWebsocketOptions) string {
+	if options.MaxEarlyData > 0 && options.EarlyDataHeaderName == "Sec-WebSocket-Protocol" {
+		return fmt.Sprint(options.Path, "?ed=", options.MaxEarlyData)
+	}
+	return options.Path
+}
+
+func headerValue(headers option.HTTPHeader, key string) string {
+	for name, values := range headers {
+		if strings.EqualFold(name, key) && len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func setTransportQuery(query url.Values, options *option.V2RayTransportOptions) {
+	if options == nil {
+		return
+	}
+	switch options.Type {
+	case C.V2RayTransportTypeWebsocket:
+		query.Set("type", "ws")
+		query.Set("path", websocketPath(options.WebsocketOptions))
+		if host := headerValue(options.WebsocketOptions.Headers, "Host"); host != "" {
+			query.Set("host", host)
+		}
+	case C.V2RayTransportTypeHTTP:
+		query.Set("type", "http")
+		if options.HTTPOptions.Path != "" {
+			query.Set("path", options.HTTPOptions.Path)
+		}
+		if len(options.HTTPOptions.Host) > 0 {
+			query.Set("host", strings.Join(options.HTTPOptions.Host, ","))
+		}
+	case C.V2RayTransportTypeHTTPUpgrade:
+		query.Set("type", "httpupgrade")
+		if options.HTTPUpgradeOptions.Path != "" {
+			query.Set("path", options.HTTPUpgradeOptions.Path)
+		}
+		if options.HTTPUpgradeOptions.Host != "" {
+			query.Set("host", options.HTTPUpgradeOptions.Host)
+		}
+	case C.V2RayTransportTypeGRPC:
+		query.Set("type", "grpc")
+		query.Set("serviceName", options.GRPCOptions.ServiceName)
+	}
+}
+
+func exportSSNativeURI(outbound option.Outbound) string {
+	options := outbound.ShadowsocksOptions
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte(options.Method + ":" + options.Password))
+	query := url.Values{}
+	if options.Plugin != "" {
+		plugin := options.Plugin
+		if options.PluginOptions != "" {
+			plugin += ";" + options.PluginOptions
+		}
+		query.Set("plugin", plugin)
+	}
+	return nativeURI("ss", userInfo, options.ServerOptions, query, outbound.Tag)
+}
+
+func exportVMessNativeURI(outbound option.Outbound) string {
+	options := outbound.VMessOptions
+	proxy := map[string]string{
+		"v":    "2",
+		"ps":   outbound.Tag,
+		"add":  options.Server,
+		"port": strconv.Itoa(int(options.ServerPort)),
+		"id":   options.UUID,
+		"aid":  strconv.Itoa(options.AlterId),
+		"scy":  options.Security,
+		"net":  "tcp",
+		"type": "none",
+	}
+	if options.Security == "" {
+		proxy["scy"] = "auto"
+	}
+	if tls := options.TLS; tls != nil && tls.Enabled {
+		proxy["tls"] = "tls"
+		if tls.ServerName != "" {
+			proxy["sni"] = tls.ServerName
+		}
+		if len(tls.ALPN) > 0 {
+			proxy["alpn"] = strings.Join(tls.ALPN, ",")
+		}
+		if tls.UTLS != nil && tls.UTLS.Enabled {
+			proxy["fp"] = tls.UTLS.Fingerprint
+		}
+		if tls.Insecure {
+			proxy["skip-cert-verify"] = "true"
+		}
+	}
+	if transport := options.Transport; transport != nil {
+		switch transport.Type {
+		case C.V2RayTransportTypeWebsocket:
+			proxy["net"] = "ws"
+			proxy["path"] = websocketPath(transport.WebsocketOptions)
+			proxy["host"] = headerValue(transport.WebsocketOptions.Headers, "Host")
+		case C.V2RayTransportTypeHTTP:
+			if options.TLS != nil && options.TLS.Enabled {
+				proxy["net"] = "h2"
+			} else {
+				proxy["type"] = "http"
+			}
+			proxy["path"] = transport.HTTPOptions.Path
+			proxy["host"] = strings.Join(transport.HTTPOptions.Host, ",")
+		case C.V2RayTransportTypeHTTPUpgrade:
+			proxy["net"] = "httpupgrade"
+			proxy["path"] = transport.HTTPUpgradeOptions.Path
+			proxy["host"] = transport.HTTPUpgradeOptions.Host
+		case C.V2RayTransportTypeGRPC:
+			proxy["net"] = "grpc"
+			proxy["path"] = transport.GRPCOptions.ServiceName
+		}
+	}
+	content, _ := json.Marshal(proxy)
+	return "vmess://" + base64.StdEncoding.EncodeToString(content)
+}
+
+func exportVLESSNativeURI(outbound option.Outbound) string {
+	options := outbound.VLESSOptions
+	query := url.Values{}
+	query.Set("encryption", "none")
+	if options.Flow != "" {
+		query.Set("flow", options.Flow)
+	}
+	setTLSQuery(query, options.TLS, "insecure")
+	setTransportQuery(query, options.Transport)
+	return nativeURI("vless", encodeURIComponent(options.UUID), options.ServerOptions, query, outbound.Tag)
+}
+
+func exportTrojanNativeURI(outbound option.Outbound) string {
+	options := outbound.TrojanOptions
+	query := url.Values{}
+	setTLSQuery(query, options.TLS, "allowInsecure")
+	setTransportQuery(query, options.Transport)
+	return nativeURI("trojan", encodeURIComponent(options.Password), options.ServerOptions, query, outbound.Tag)
+}
+
+func exportTuicNativeURI(outbound option.Outbound) string {
+	options := outbound.TUICOptions
+	query := url.Values{}
+	if options.CongestionControl != "" {
+		query.Set("congestion_control", options.CongestionControl)
+	}
+	if options.UDPRelayMode != "" {
+		query.Set("udp_relay_mode", options.UDPRelayMode)
+	}
+	if options.UDPOverStream {
+		query.Set("udp_over_stream", "1")
+	}
+	if options.ZeroRTTHandshake {
+		query.Set("reduce_rtt", "1")
+	}
+	setTLSQuery(query, options.TLS, "allow_insecure")
+	query.Del("security")
+	if options.TLS != nil && options.TLS.DisableSNI {
+		query.Set("disable_sni", "1")
+	}
+	userInfo := encodeURIComponent(options.UUID) + ":" + encodeURIComponent(options.Password)
+	return nativeURI("tuic", userInfo, options.ServerOptions, query, outbound.Tag)
+}
+
+func exportHysteriaNativeURI(outbound option.Outbound) string {
+	options := outbound.HysteriaOptions
+	query := url.Values{}
+	query.Set("protocol", "udp")
+	if options.AuthString != "" {
+		query.Set("auth", options.AuthString)
+	}
+	if options.TLS != nil {
+		if options.TLS.ServerName != "" {
+			query.Set("peer", options.TLS.ServerName)
+		}
+		if len(options.TLS.ALPN) > 0 {
+			query.Set("alpn", strings.Join(options.TLS.ALPN, ","))
+		}
+		if options.TLS.Insecure {
+			query.Set("insecure", "1")
+		}
+	}
+	if options.UpMbps > 0 {
+		query.Set("upmbps", strconv.Itoa(options.UpMbps))
+	} else if options.Up != "" {
+		query.Set("up", options.Up)
+	}
+	if options.DownMbps > 0 {
+		query.Set("downmbps", strconv.Itoa(options.DownMbps))
+	} else if options.Down != "" {
+		query.Set("down", options.Down)
+	}
+	if options.Obfs != "" {
+		query.Set("obfs", "xplus")
+		query.Set("obfsParam", options.Obfs)
+	}
+	return nativeURI("hysteria", "", options.ServerOptions, query, outbound.Tag)
+}
+
+func exportHysteria2NativeURI(outbound option.Outbound) string {
+	options := outbound.Hysteria2Options
+	query := url.Values{}
+	if options.TLS != nil {
+		if options.TLS.ServerName != "" {
+			query.Set("sni", options.TLS.ServerName)
+		}
+		if options.TLS.Insecure {
+			query.Set("insecure", "1")
+		}
+	}
+	if options.Obfs != nil && options.Obfs.Type != "" {
+		query.Set("obfs", options.Obfs.Type)
+		query.Set("obfs-password", options.Obfs.Password)
+	}
+	return nativeURI("hysteria2", encodeURIComponent(options.Password), options.ServerOptions, query, outbound.Tag)
+}
@@ -0,0 +1,60 @@
+package provider
+
+import (
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/json"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testExportOutbounds = `{"outbounds": [
+  {"type": "direct", "tag": "direct"},
+  {"type": "shadowsocks", "tag": "ss 1", "server": "1.1.1.1", "server_port": 8388, "method": "aes-128-gcm", "password": "pass word"},
+  {"type": "vmess", "tag": "vmess", "server": "example.com", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "security": "auto", "tls": {"enabled": true, "server_name": "sni.example.com"}, "transport": {"type": "ws", "path": "/ws", "headers": {"Host": "cdn.example.com"}}},
+  {"type": "vless", "tag": "vless", "server": "example.org", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "flow": "xtls-rprx-vision", "tls": {"enabled": true, "server_name": "sni.example.org", "utls": {"enabled": true, "fingerprint": "chrome"}}},
+  {"type": "trojan", "tag": "trojan", "server": "2001:db8::1", "server_port": 443, "password": "secret", "tls": {"enabled": true, "server_name": "t.example.com", "insecure": true}, "transport": {"type": "grpc", "service_name": "svc"}},
+  {"type": "hysteria2", "tag": "hy2", "server": "h.example.com", "server_port": 8443, "password": "pw", "obfs": {"type": "salamander", "password": "ob"}, "tls": {"enabled": true, "server_name": "h.example.com"}},
+  {"type": "tuic", "tag": "tuic", "server": "u.example.com", "server_port": 443, "uuid": "b831381d-6324-4d53-ad4f-8cda48b30811", "password": "pw", "congestion_control": "bbr", "tls": {"enabled": true, "server_name": "u.example.com", "alpn": ["h3"]}},
+  {"type": "socks", "tag": "socks", "server": "127.0.0.1", "server_port": 1080, "username": "u", "password": "p"},
+  {"type": "http", "tag": "http", "server": "127.0.0.1", "server_port": 8080, "username": "u", "password": "p"},
+  {"type": "shadowsocks", "tag": "ss 2", "server": "1.1.1.1", "server_port": 8388, "method": "aes-128-gcm", "password": "pass word"}
+]}`
+
+func TestExportRoundTrip(t *testing.T) {
+	t.Parallel()
+	var options option.Options
+	require.NoError(t, options.UnmarshalJSON([]byte(testExportOutbounds)))
+	for _, testCase := range []struct {
+		format string
+		tags   []string
+	}{
+		{ExportFormatLink, []string{"ss 1", "vmess", "vless", "trojan", "hy2", "tuic"}},
+		{ExportFormatBase64, []string{"ss 1", "vmess", "vless", "trojan", "hy2", "tuic"}},
+		{ExportFormatClash, []string{"ss 1", "vmess", "vless", "trojan", "hy2", "tuic", "socks", "http"}},
+		{ExportFormatSingBox, []string{"ss 1", "vmess", "vless", "trojan", "hy2", "tuic", "socks", "http"}},
+	} {
+		content, err := Export(options.Outbounds, testCase.format)
+		require.NoError(t, err, testCase.format)
+		// Providers decode base64 subscriptions before parsing them.
+		outbounds, err := newParser(decodeBase64Safe(string(content)), nil)
+		require.NoError(t, err, testCase.format)
+		require.Len(t, outbounds, len(testCase.tags), testCase.format)
+		for i, outbound := range outbounds {
+			require.Equal(t, testCase.tags[i], outbound.Tag, testCase.format)
+			expected, err := json.Marshal(options.Outbounds[i+1])
+			require.NoError(t, err)
+			parsed, err := json.Marshal(outbound)
+			require.NoError(t, err)
+			require.JSONEq(t, string(expected), string(parsed), "%s: %s", testCase.format, outbound.Tag)
+		}
+	}
+}
+
+func TestExportUnknownFormat(t *testing.T) {
+	t.Parallel()
+	_, err := Export([]option.Outbound{{Type: C.TypeSOCKS, Tag: "socks"}}, "surge")
+	require.Error(t, err)
+}
@@ -31,6 +31,10 @@ func NewFileProvider(ctx context.Context, router adapter.Router, logger log.Cont
 	if interval == 0 {
 		interval = C.DefaultURLTestInterval
 	}
+	processor, err := newOutboundProcessor(options)
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	provider := &FileProvider{
 		myProviderAdapter: myProviderAdapter{
@@ -43,6 +47,10 @@ func NewFileProvider(ctx context.Context, router adapter.Router, logger log.Cont
 			healthcheckUrl:      options.HealthcheckUrl,
 			healthcheckInterval: interval,
 			overrideDialer:      options.OverrideDialer,
+			processor:           processor,
+			quotaWarning:        options.QuotaWarningPercent,
+			expireWarning:       time.Duration(options.ExpireWarningDays) * 24 * time.Hour,
+			excludeExhausted:    options.ExcludeExhausted,
 			providerType:        C.TypeFileProvider,
 			close:               make(chan struct{}),
 			pauseManager:        service.FromContext[pause.Manager](ctx),
@@ -66,6 +74,8 @@ func (p *FileProvider) Start() error {
 		history = urltest.NewHistoryStorage()
 	}
 	p.healchcheckHistory = history
+	p.restoreInfo()
+	p.updateSubStatus()
 	return nil
 }
 
@@ -115,6 +125,7 @@ func (p *FileProvider) UpdateProvider(ctx context.Context, router adapter.Router
 
 	p.subInfo = info
 	p.updateTime = fileModeTime
+	p.saveInfo()
 	p.CheckOutbounds(true)
 	return nil
 }
@@ -68,6 +68,10 @@ func NewHTTPProvider(ctx context.Context, router adapter.Router, logger log.Cont
 	if downloadInterval < defaultDownloadInterval {
 		downloadInterval = defaultDownloadInterval
 	}
+	processor, err := newOutboundProcessor(options)
+	if err != nil {
+		return nil, err
+	}
 	ctx, cancel := context.WithCancel(ctx)
 	provider := &HTTPProvider{
 		myProviderAdapter: myProviderAdapter{
@@ -81,6 +85,10 @@ func NewHTTPProvider(ctx context.Context, router adapter.Router, logger log.Cont
 			healthcheckInterval: healthcheckInterval,
 			providerType:        C.TypeHTTPProvider,
 			overrideDialer:      options.OverrideDialer,
+			processor:           processor,
+			quotaWarning:        options.QuotaWarningPercent,
+			expireWarning:       time.Duration(options.ExpireWarningDays) * 24 * time.Hour,
+			excludeExhausted:    options.ExcludeExhausted,
 			close:               make(chan struct{}),
 			pauseManager:        service.FromContext[pause.Manager](ctx),
 			subInfo:             SubInfo{},
@@ -128,6 +136,9 @@ func (p *HTTPProvider) Start() error {
 		history = urltest.NewHistoryStorage()
 	}
 	p.healchcheckHistory = history
+	p.restoreInfo()
+	p.updateSubStatus()
+	p.lastUpdated = p.updateTime
 	return nil
 }
 
@@ -188,6 +199,7 @@ func (p *HTTPProvider) fetchOnce(ctx context.Context, router adapter.Router) err
 			return nil
 		}
 		p.subInfo = info
+		p.saveInfo()
 
 		contentRaw := getTrimedFile(p.path)
 		content := decodeBase64Safe(string(contentRaw))
@@ -237,6 +249,7 @@ func (p *HTTPProvider) fetchOnce(ctx context.Context, router adapter.Router) err
 
 	p.subInfo = info
 	p.updateTime = p.lastUpdated
+	p.saveInfo()
 
 	p.logger.Info("update outbound provider ", p.tag, " success")
 
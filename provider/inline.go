@@ -0,0 +1,120 @@
+package provider
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/pause"
+)
+
+var (
+	_ adapter.OutboundProvider        = (*InlineProvider)(nil)
+	_ adapter.InterfaceUpdateListener = (*InlineProvider)(nil)
+)
+
+type InlineProvider struct {
+	myProviderAdapter
+}
+
+func NewInlineProvider(ctx context.Context, router adapter.Router, logger log.ContextLogger, options option.OutboundProvider) (*InlineProvider, error) {
+	inlineOptions := options.InlineOptions
+	if trimBlank(inlineOptions.Content) == "" && len(inlineOptions.Links) == 0 {
+		return nil, E.New("missing content and links")
+	}
+	interval := time.Duration(options.HealthcheckInterval)
+	if interval == 0 {
+		interval = C.DefaultURLTestInterval
+	}
+	processor, err := newOutboundProcessor(options)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	provider := &InlineProvider{
+		myProviderAdapter: myProviderAdapter{
+			ctx:                 ctx,
+			cancel:              cancel,
+			router:              router,
+			logger:              logger,
+			tag:                 options.Tag,
+			healthcheckUrl:      options.HealthcheckUrl,
+			healthcheckInterval: interval,
+			overrideDialer:      options.OverrideDialer,
+			processor:           processor,
+			providerType:        C.TypeInlineProvider,
+			close:               make(chan struct{}),
+			pauseManager:        service.FromContext[pause.Manager](ctx),
+			subInfo:             SubInfo{},
+			outbounds:           []adapter.Outbound{},
+			outboundByTag:       make(map[string]adapter.Outbound),
+			updateTime:          time.Now(),
+		},
+	}
+	outboundOptions, err := provider.parseInlineOutbounds(inlineOptions)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	provider.loadOutbounds(outboundOptions)
+	return provider, nil
+}
+
+func (p *myProviderAdapter) parseInlineOutbounds(options option.InlineOutboundProviderOptions) ([]option.Outbound, error) {
+	var outboundOptions []option.Outbound
+	for _, content := range []string{trimBlank(options.Content), strings.Join(options.Links, "\n")} {
+		if content == "" {
+			continue
+		}
+		parsedOptions, err := p.parseOutbounds(decodeBase64Safe(content))
+		if err != nil {
+			return nil, err
+		}
+		outboundOptions = append(outboundOptions, parsedOptions...)
+	}
+	return outboundOptions, nil
+}
+
+func (p *InlineProvider) Start() error {
+	var history *urltest.HistoryStorage
+	if history = service.PtrFromContext[urltest.HistoryStorage](p.ctx); history != nil {
+	} else if clashServer := p.router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	} else {
+		history = urltest.NewHistoryStorage()
+	}
+	p.healchcheckHistory = history
+	p.restoreInfo()
+	return nil
+}
+
+func (p *InlineProvider) loopCheck() {
+	p.CheckOutbounds(true)
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.ticker.C:
+			p.pauseManager.WaitActive()
+			p.CheckOutbounds(false)
+		}
+	}
+}
+
+func (p *InlineProvider) PostStart() error {
+	p.ticker = time.NewTicker(1 * time.Minute)
+	go p.loopCheck()
+	return nil
+}
+
+// UpdateProvider is a no-op, inline content only changes with the configuration.
+func (p *InlineProvider) UpdateProvider(ctx context.Context, router adapter.Router, force bool) error {
+	return nil
+}
@@ -0,0 +1,273 @@
+package provider
+
+import (
+	"context"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/urltest"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badjson"
+	"github.com/sagernet/sing/service"
+	"github.com/sagernet/sing/service/pause"
+)
+
+var (
+	_ adapter.OutboundProvider        = (*MergeProvider)(nil)
+	_ adapter.InterfaceUpdateListener = (*MergeProvider)(nil)
+)
+
+var outboundCredentialKeys = []string{"username", "password", "uuid", "private_key", "auth_str", "auth"}
+
+type MergeProvider struct {
+	myProviderAdapter
+	providers []string
+}
+
+func NewMergeProvider(ctx context.Context, router adapter.Router, logger log.ContextLogger, options option.OutboundProvider) (*MergeProvider, error) {
+	if len(options.MergeOptions.Providers) == 0 {
+		return nil, E.New("missing providers")
+	}
+	if len(options.Rename) > 0 || options.OverrideOutbound != nil || options.OverrideDialer != nil {
+		return nil, E.New("rename, override_outbound and override_dialer are not supported by merge providers")
+	}
+	interval := time.Duration(options.HealthcheckInterval)
+	if interval == 0 {
+		interval = C.DefaultURLTestInterval
+	}
+	processor, err := newOutboundProcessor(options)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	provider := &MergeProvider{
+		myProviderAdapter: myProviderAdapter{
+			ctx:                 ctx,
+			cancel:              cancel,
+			router:              router,
+			logger:              logger,
+			tag:                 options.Tag,
+			healthcheckUrl:      options.HealthcheckUrl,
+			healthcheckInterval: interval,
+			overrideDialer:      options.OverrideDialer,
+			processor:           processor,
+			providerType:        C.TypeMergeProvider,
+			close:               make(chan struct{}),
+			pauseManager:        service.FromContext[pause.Manager](ctx),
+			subInfo:             SubInfo{},
+			outbounds:           []adapter.Outbound{},
+			outboundByTag:       make(map[string]adapter.Outbound),
+		},
+		providers: options.MergeOptions.Providers,
+	}
+	return provider, nil
+}
+
+// Start lists the outbounds of the providers already started,
+// they are owned by these providers and not created again.
+func (p *MergeProvider) Start() error {
+	var history *urltest.HistoryStorage
+	if history = service.PtrFromContext[urltest.HistoryStorage](p.ctx); history != nil {
+	} else if clashServer := p.router.ClashServer(); clashServer != nil {
+		history = clashServer.HistoryStorage()
+	} else {
+		history = urltest.NewHistoryStorage()
+	}
+	p.healchcheckHistory = history
+	p.restoreInfo()
+	outbounds, outboundOptions, err := p.mergeOutbounds(p.router)
+	if err != nil {
+		return err
+	}
+	p.setMergedOutbounds(outbounds, outboundOptions)
+	p.updateTime = time.Now()
+	return nil
+}
+
+func (p *MergeProvider) loopCheck() {
+	p.CheckOutbounds(true)
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.ticker.C:
+			p.pauseManager.WaitActive()
+			p.CheckOutbounds(false)
+		}
+	}
+}
+
+func (p *MergeProvider) PostStart() error {
+	p.ticker = time.NewTicker(1 * time.Minute)
+	go p.loopCheck()
+	return nil
+}
+
+// UpdateProvider lists the current outbounds of the merged providers again.
+func (p *MergeProvider) UpdateProvider(ctx context.Context, router adapter.Router, force bool) error {
+	if p.updating.Swap(true) {
+		return E.New("provider is updating")
+	}
+	defer p.updating.Store(false)
+	p.logger.Debug("updating outbound provider ", p.tag, " from ", len(p.providers), " providers")
+	outbounds, outboundOptions, err := p.mergeOutbounds(router)
+	if err != nil {
+		return err
+	}
+	p.outboundAccess.RLock()
+	outsBackup := p.outbounds
+	optionsBackup := p.outboundOptions
+	p.outboundAccess.RUnlock()
+	p.setMergedOutbounds(outbounds, outboundOptions)
+	err = p.updateGroups(router)
+	if err != nil {
+		p.setMergedOutbounds(outsBackup, optionsBackup)
+		return err
+	}
+	p.updateTime = time.Now()
+	p.saveInfo()
+	p.CheckOutbounds(true)
+	return nil
+}
+
+func (p *MergeProvider) setMergedOutbounds(outbounds []adapter.Outbound, outboundOptions []option.Outbound) {
+	outboundByTag := make(map[string]adapter.Outbound)
+	for _, out := range outbounds {
+		outboundByTag[out.Tag()] = out
+	}
+	p.outboundAccess.Lock()
+	defer p.outboundAccess.Unlock()
+	p.outbounds = outbounds
+	p.outboundByTag = outboundByTag
+	p.outboundOptions = outboundOptions
+}
+
+// UpdateOutboundByTag indexes the outbounds again, after the merged providers have set their final tags.
+func (p *MergeProvider) UpdateOutboundByTag() {
+	p.outboundAccess.RLock()
+	outbounds := p.outbounds
+	outboundOptions := append([]option.Outbound(nil), p.outboundOptions...)
+	p.outboundAccess.RUnlock()
+	for i := range outboundOptions {
+		outboundOptions[i].Tag = outbounds[i].Tag()
+	}
+	p.setMergedOutbounds(outbounds, outboundOptions)
+	if !p.historyRestored {
+		p.historyRestored = true
+		p.restoreHistory()
+	}
+}
+
+// mergeOutbounds returns the outbounds of the merged providers kept by the filters,
+// with the options they were created from.
+func (p *MergeProvider) mergeOutbounds(router adapter.Router) ([]adapter.Outbound, []option.Outbound, error) {
+	var (
+		outbounds       []adapter.Outbound
+		outboundOptions []option.Outbound
+	)
+	loaded := make(map[string]bool)
+	for i, tag := range p.providers {
+		provider, exists := router.OutboundProvider(tag)
+		if !exists {
+			return nil, nil, E.New("provider ", i, " not found: ", tag)
+		}
+		if provider.Excluded() {
+			continue
+		}
+		var (
+			providerOutbounds []adapter.Outbound
+			sources           []option.Outbound
+		)
+		switch provider := provider.(type) {
+		case *MergeProvider:
+			return nil, nil, E.New("provider ", i, " is a merge provider: ", tag)
+		case *FileProvider:
+			providerOutbounds, sources = provider.outboundsWithSources()
+		case *HTTPProvider:
+			providerOutbounds, sources = provider.outboundsWithSources()
+		case *InlineProvider:
+			providerOutbounds, sources = provider.outboundsWithSources()
+		default:
+			return nil, nil, E.New("provider ", i, " cannot be merged: ", tag)
+		}
+		for j, outbound := range providerOutbounds {
+			options := sources[j]
+			options.Tag = outbound.Tag()
+			if !p.processor.match(options) {
+				continue
+			}
+			key, err := outboundKey(options)
+			if err != nil {
+				return nil, nil, err
+			}
+			if loaded[key] {
+				continue
+			}
+			loaded[key] = true
+			outbounds = append(outbounds, outbound)
+			outboundOptions = append(outboundOptions, options)
+		}
+	}
+	return outbounds, outboundOptions, nil
+}
+
+func (p *myProviderAdapter) mergeOutboundOptions(providers []string, load func(i int, tag string) ([]option.Outbound, error)) ([]option.Outbound, error) {
+	var outboundOptions []option.Outbound
+	for i, tag := range providers {
+		providerOptions, err := load(i, tag)
+		if err != nil {
+			return nil, err
+		}
+		outboundOptions = append(outboundOptions, providerOptions...)
+	}
+	outboundOptions, err := dedupeOutbounds(outboundOptions)
+	if err != nil {
+		return nil, err
+	}
+	return p.processor.process(overrideOutbounds(outboundOptions, p.overrideDialer))
+}
+
+func dedupeOutbounds(outbounds []option.Outbound) ([]option.Outbound, error) {
+	var outboundOptions []option.Outbound
+	loaded := make(map[string]bool)
+	for _, options := range outbounds {
+		key, err := outboundKey(options)
+		if err != nil {
+			return nil, err
+		}
+		if loaded[key] {
+			continue
+		}
+		loaded[key] = true
+		outboundOptions = append(outboundOptions, options)
+	}
+	return outboundOptions, nil
+}
+
+// outboundKey identifies an outbound by its server, port and credential,
+// so the same node from different subscriptions is merged whatever its tag is.
+func outboundKey(options option.Outbound) (string, error) {
+	content, err := json.Marshal(&options)
+	if err != nil {
+		return "", err
+	}
+	var object badjson.JSONObject
+	err = object.UnmarshalJSON(content)
+	if err != nil {
+		return "", err
+	}
+	values := []any{options.Type}
+	for _, key := range append([]string{"server", "server_port"}, outboundCredentialKeys...) {
+		value, _ := object.Get(key)
+		values = append(values, value)
+	}
+	key, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(key), nil
+}
@@ -0,0 +1,153 @@
+package provider
+
+import (
+	"regexp"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/json/badjson"
+)
+
+type outboundRename struct {
+	pattern *regexp.Regexp
+	replace string
+}
+
+type outboundProcessor struct {
+	includes         []*regexp.Regexp
+	excludes         *regexp.Regexp
+	types            []string
+	excludeTypes     []string
+	renames          []outboundRename
+	overrideOutbound *badjson.JSONObject
+}
+
+func newOutboundProcessor(options option.OutboundProvider) (*outboundProcessor, error) {
+	processor := &outboundProcessor{
+		types:            options.Types,
+		excludeTypes:     options.ExcludeTypes,
+		overrideOutbound: options.OverrideOutbound,
+	}
+	for i, include := range options.Includes {
+		reg, err := regexp.Compile("(?i)" + include)
+		if err != nil {
+			return nil, E.Cause(err, "parse includes[", i, "]")
+		}
+		processor.includes = append(processor.includes, reg)
+	}
+	if options.Excludes != "" {
+		reg, err := regexp.Compile("(?i)" + options.Excludes)
+		if err != nil {
+			return nil, E.Cause(err, "parse excludes")
+		}
+		processor.excludes = reg
+	}
+	for i, rename := range options.Rename {
+		reg, err := regexp.Compile(rename.Pattern)
+		if err != nil {
+			return nil, E.Cause(err, "parse rename[", i, "]")
+		}
+		processor.renames = append(processor.renames, outboundRename{reg, rename.Replace})
+	}
+	return processor, nil
+}
+
+// process filters the parsed outbounds, then renames and overrides the ones kept.
+// Filters match the tags from the subscription, before renaming.
+func (p *outboundProcessor) process(outbounds []option.Outbound) ([]option.Outbound, error) {
+	processed := make([]option.Outbound, 0, len(outbounds))
+	for _, outbound := range outbounds {
+		if !p.match(outbound) {
+			continue
+		}
+		for _, rename := range p.renames {
+			outbound.Tag = rename.pattern.ReplaceAllString(outbound.Tag, rename.replace)
+		}
+		if p.overrideOutbound != nil {
+			overridden, err := overrideOutbound(outbound, p.overrideOutbound)
+			if err != nil {
+				return nil, E.Cause(err, "override outbound[", outbound.Tag, "]")
+			}
+			outbound = overridden
+		}
+		processed = append(processed, outbound)
+	}
+	return processed, nil
+}
+
+func (p *outboundProcessor) match(outbound option.Outbound) bool {
+	for _, include := range p.includes {
+		if !include.MatchString(outbound.Tag) {
+			return false
+		}
+	}
+	if p.excludes != nil && p.excludes.MatchString(outbound.Tag) {
+		return false
+	}
+	if len(p.types) > 0 && !common.Contains(p.types, outbound.Type) {
+		return false
+	}
+	return !common.Contains(p.excludeTypes, outbound.Type)
+}
+
+// overrideOutbound sets the fields of override on the outbound options, merging nested objects.
+// Fields not supported by the outbound type are skipped, so one override can target mixed protocols.
+func overrideOutbound(outbound option.Outbound, override *badjson.JSONObject) (option.Outbound, error) {
+	content, err := json.Marshal(&outbound)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	var object badjson.JSONObject
+	err = object.UnmarshalJSON(content)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	for _, entry := range override.Entries() {
+		switch entry.Key {
+		case "type", "tag":
+			continue
+		}
+		oldValue, loaded := object.Get(entry.Key)
+		newValue := overrideJSON(oldValue, entry.Value)
+		object.Put(entry.Key, newValue)
+		content, err = json.Marshal(&object)
+		if err != nil {
+			return option.Outbound{}, err
+		}
+		var overridden option.Outbound
+		if json.Unmarshal(content, &overridden) != nil {
+			if loaded {
+				object.Put(entry.Key, oldValue)
+			} else {
+				object.Remove(entry.Key)
+			}
+		}
+	}
+	content, err = json.Marshal(&object)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	var overridden option.Outbound
+	err = json.Unmarshal(content, &overridden)
+	if err != nil {
+		return option.Outbound{}, err
+	}
+	return overridden, nil
+}
+
+func overrideJSON(oldValue any, newValue any) any {
+	oldObject, isOldObject := oldValue.(*badjson.JSONObject)
+	newObject, isNewObject := newValue.(*badjson.JSONObject)
+	if !isOldObject || !isNewObject {
+		return newValue
+	}
+	var merged badjson.JSONObject
+	merged.PutAll(&oldObject.Map)
+	for _, entry := range newObject.Entries() {
+		oldEntryValue, _ := merged.Get(entry.Key)
+		merged.Put(entry.Key, overrideJSON(oldEntryValue, entry.Value))
+	}
+	return &merged
+}
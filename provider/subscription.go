@@ -0,0 +1,70 @@
+package provider
+
+import (
+	"time"
+)
+
+const (
+	subStatusQuotaWarning  = "quota_warning"
+	subStatusExpireWarning = "expire_warning"
+	subStatusExhausted     = "exhausted"
+	subStatusExpired       = "expired"
+)
+
+func (a *myProviderAdapter) SubStatus() string {
+	return a.subStatus.Load()
+}
+
+// Excluded returns whether the outbounds should be left out of groups,
+// because the subscription has expired or its quota is used up.
+func (a *myProviderAdapter) Excluded() bool {
+	return a.excluded(a.subStatus.Load())
+}
+
+func (a *myProviderAdapter) excluded(status string) bool {
+	return a.excludeExhausted && (status == subStatusExhausted || status == subStatusExpired)
+}
+
+func (a *myProviderAdapter) computeSubStatus() string {
+	info := a.subInfo
+	used := info.upload + info.download
+	var expire time.Time
+	if info.expire > 0 {
+		expire = time.Unix(info.expire, 0)
+	}
+	switch {
+	case !expire.IsZero() && !time.Now().Before(expire):
+		return subStatusExpired
+	case info.total > 0 && used >= info.total:
+		return subStatusExhausted
+	case a.expireWarning > 0 && !expire.IsZero() && time.Until(expire) <= a.expireWarning:
+		return subStatusExpireWarning
+	case a.quotaWarning > 0 && info.total > 0 && used*100 >= info.total*int64(a.quotaWarning):
+		return subStatusQuotaWarning
+	default:
+		return ""
+	}
+}
+
+// updateSubStatus logs threshold crossings and returns whether Excluded has changed.
+func (a *myProviderAdapter) updateSubStatus() bool {
+	status := a.computeSubStatus()
+	oldStatus := a.subStatus.Swap(status)
+	if status == oldStatus {
+		return false
+	}
+	info := a.subInfo
+	switch status {
+	case subStatusQuotaWarning:
+		a.logger.Warn("outbound provider ", a.tag, ": ", (info.upload+info.download)*100/info.total, "% of quota used")
+	case subStatusExpireWarning:
+		a.logger.Warn("outbound provider ", a.tag, ": subscription expires at ", time.Unix(info.expire, 0).Format(time.DateTime))
+	case subStatusExhausted:
+		a.logger.Error("outbound provider ", a.tag, ": quota exhausted")
+	case subStatusExpired:
+		a.logger.Error("outbound provider ", a.tag, ": subscription expired at ", time.Unix(info.expire, 0).Format(time.DateTime))
+	default:
+		a.logger.Info("outbound provider ", a.tag, ": subscription available")
+	}
+	return a.excluded(oldStatus) != a.excluded(status)
+}
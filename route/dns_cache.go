@@ -0,0 +1,307 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	dns "github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/cache"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/service"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	dnsOptimisticTimeoutDefault = 24 * time.Hour
+	// dnsStaleTTL is the TTL of an expired response served by the optimistic cache,
+	// clients should query again soon and get the refreshed response.
+	dnsStaleTTL = 10
+)
+
+// dnsCache keeps router responses beyond the DNS client cache: an expired response is served
+// once while it is refreshed in the background if optimistic, and responses are persisted
+// in the cache file if store_dns is enabled.
+type dnsCache struct {
+	ctx          context.Context
+	logger       log.ContextLogger
+	optimistic   bool
+	staleTimeout time.Duration
+	independent  bool
+	cacheFile    adapter.CacheFile
+	access       sync.Mutex
+	cache        *cache.LruCache[dnsCacheKey, *dnsCacheEntry]
+}
+
+type dnsCacheKey struct {
+	mDNS.Question
+	transport string
+}
+
+type dnsCacheEntry struct {
+	response *mDNS.Msg
+	expireAt time.Time
+}
+
+func newDNSCache(ctx context.Context, logger log.ContextLogger, options option.DNSOptions, independent bool) (*dnsCache, error) {
+	optimisticOptions := common.PtrValueOrDefault(options.Optimistic)
+	if options.DisableCache {
+		if optimisticOptions.Enabled {
+			return nil, E.New("optimistic DNS cache requires the DNS cache to be enabled")
+		}
+		return nil, nil
+	}
+	var staleTimeout time.Duration
+	if optimisticOptions.Enabled {
+		staleTimeout = time.Duration(optimisticOptions.Timeout)
+		if staleTimeout <= 0 {
+			staleTimeout = dnsOptimisticTimeoutDefault
+		}
+	}
+	return &dnsCache{
+		ctx:          ctx,
+		logger:       logger,
+		optimistic:   optimisticOptions.Enabled,
+		staleTimeout: staleTimeout,
+		independent:  independent,
+		cache:        cache.New[dnsCacheKey, *dnsCacheEntry](),
+	}, nil
+}
+
+func (c *dnsCache) Start() {
+	cacheFile := service.FromContext[adapter.CacheFile](c.ctx)
+	if cacheFile == nil || !cacheFile.StoreDNS() {
+		return
+	}
+	c.cacheFile = cacheFile
+	savedResponses := cacheFile.LoadDNSResponses(c.staleTimeout)
+	for _, savedResponse := range savedResponses {
+		key := dnsCacheKey{savedResponse.Response.Question[0], savedResponse.Transport}
+		c.cache.StoreWithExpire(key, &dnsCacheEntry{savedResponse.Response, savedResponse.ExpireAt}, savedResponse.ExpireAt.Add(c.staleTimeout))
+	}
+	if len(savedResponses) > 0 {
+		c.logger.Debug("loaded ", len(savedResponses), " DNS cache entries")
+	}
+}
+
+func (c *dnsCache) Enabled() bool {
+	return c != nil && (c.optimistic || c.cacheFile != nil)
+}
+
+func (c *dnsCache) Key(question mDNS.Question, transport dns.Transport) dnsCacheKey {
+	key := dnsCacheKey{Question: question}
+	if c.independent {
+		key.transport = transport.Name()
+	}
+	return key
+}
+
+// Load returns copies of the cached responses only if all keys are cached,
+// expired responses are removed once loaded and their keys returned as stale.
+func (c *dnsCache) Load(keys ...dnsCacheKey) (responses []*mDNS.Msg, staleKeys []dnsCacheKey, loaded bool) {
+	c.access.Lock()
+	defer c.access.Unlock()
+	now := time.Now()
+	entries := make([]*dnsCacheEntry, 0, len(keys))
+	for _, key := range keys {
+		entry, cached := c.cache.Load(key)
+		if !cached || !c.optimistic && !now.Before(entry.expireAt) {
+			return nil, nil, false
+		}
+		entries = append(entries, entry)
+	}
+	for i, entry := range entries {
+		timeToLive := int(entry.expireAt.Sub(now) / time.Second)
+		if timeToLive <= 0 {
+			c.cache.Delete(keys[i])
+			staleKeys = append(staleKeys, keys[i])
+			timeToLive = dnsStaleTTL
+		}
+		response := entry.response.Copy()
+		forEachTTLRecord(response, func(record mDNS.RR) {
+			record.Header().Ttl = uint32(timeToLive)
+		})
+		responses = append(responses, response)
+	}
+	return responses, staleKeys, true
+}
+
+func (c *dnsCache) Save(key dnsCacheKey, response *mDNS.Msg) {
+	if response.Rcode != mDNS.RcodeSuccess && response.Rcode != mDNS.RcodeNameError {
+		return
+	}
+	var timeToLive uint32
+	forEachTTLRecord(response, func(record mDNS.RR) {
+		if timeToLive == 0 || record.Header().Ttl < timeToLive {
+			timeToLive = record.Header().Ttl
+		}
+	})
+	if timeToLive == 0 {
+		return
+	}
+	response = response.Copy()
+	response.Question = []mDNS.Question{key.Question}
+	expireAt := time.Now().Add(time.Duration(timeToLive) * time.Second)
+	c.cache.StoreWithExpire(key, &dnsCacheEntry{response, expireAt}, expireAt.Add(c.staleTimeout))
+	if c.cacheFile != nil {
+		c.cacheFile.SaveDNSResponseAsync(&adapter.SavedDNSResponse{
+			Transport: key.transport,
+			Response:  response.Copy(),
+			ExpireAt:  expireAt,
+		}, c.logger)
+	}
+}
+
+func (c *dnsCache) Clear() {
+	c.access.Lock()
+	defer c.access.Unlock()
+	c.cache.Clear()
+	if c.cacheFile != nil {
+		err := c.cacheFile.ClearDNSResponses()
+		if err != nil {
+			c.logger.Warn("clear DNS cache: ", err)
+		}
+	}
+}
+
+func forEachTTLRecord(response *mDNS.Msg, f func(record mDNS.RR)) {
+	for _, recordList := range [][]mDNS.RR{response.Answer, response.Ns, response.Extra} {
+		for _, record := range recordList {
+			if record.Header().Rrtype == mDNS.TypeOPT {
+				continue
+			}
+			f(record)
+		}
+	}
+}
+
+// lookupDNSCache answers a lookup from the router cache if the responses of all queried types are cached.
+func (r *Router) lookupDNSCache(ctx context.Context, domain string, strategy dns.DomainStrategy, transport dns.Transport) ([]netip.Addr, bool) {
+	if strategy == dns.DomainStrategyAsIS {
+		strategy = r.GetStrategy(transport)
+	}
+	keys := common.Map(lookupQueryTypes(strategy), func(queryType uint16) dnsCacheKey {
+		return r.dnsCache.Key(mDNS.Question{Name: mDNS.Fqdn(domain), Qtype: queryType, Qclass: mDNS.ClassINET}, transport)
+	})
+	responses, staleKeys, loaded := r.dnsCache.Load(keys...)
+	if !loaded {
+		return nil, false
+	}
+	r.refreshDNSCache(ctx, staleKeys)
+	var addresses []netip.Addr
+	for _, response := range responses {
+		responseAddrs, _ := dns.MessageToAddresses(response)
+		addresses = append(addresses, responseAddrs...)
+	}
+	addresses = sortLookupAddresses(addresses, strategy)
+	return addresses, len(addresses) > 0
+}
+
+// lookupExchange looks up a domain with a query per type instead of the address lookup of the DNS client,
+// so that the responses are returned to be saved in the router cache.
+func (r *Router) lookupExchange(ctx context.Context, transport dns.Transport, domain string, strategy dns.DomainStrategy) ([]netip.Addr, []*mDNS.Msg, error) {
+	queryTypes := lookupQueryTypes(strategy)
+	responses := make([]*mDNS.Msg, len(queryTypes))
+	errs := make([]error, len(queryTypes))
+	var wg sync.WaitGroup
+	for i, queryType := range queryTypes {
+		wg.Add(1)
+		go func(i int, queryType uint16) {
+			defer wg.Done()
+			message := &mDNS.Msg{
+				MsgHdr: mDNS.MsgHdr{
+					Id:               mDNS.Id(),
+					RecursionDesired: true,
+				},
+				Question: []mDNS.Question{{Name: mDNS.Fqdn(domain), Qtype: queryType, Qclass: mDNS.ClassINET}},
+			}
+			responses[i], errs[i] = r.dnsClient.Exchange(ctx, r.observedTransport(transport), message, strategy)
+		}(i, queryType)
+	}
+	wg.Wait()
+	var (
+		addresses []netip.Addr
+		lastErr   error
+	)
+	for i, response := range responses {
+		if errs[i] != nil {
+			lastErr = errs[i]
+			responses[i] = nil
+			continue
+		}
+		responseAddrs, err := dns.MessageToAddresses(response)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		addresses = append(addresses, responseAddrs...)
+	}
+	responses = common.Filter(responses, func(response *mDNS.Msg) bool {
+		return response != nil
+	})
+	if len(addresses) == 0 && lastErr != nil {
+		return nil, responses, lastErr
+	}
+	return sortLookupAddresses(addresses, strategy), responses, nil
+}
+
+func lookupQueryTypes(strategy dns.DomainStrategy) []uint16 {
+	switch strategy {
+	case dns.DomainStrategyUseIPv4:
+		return []uint16{mDNS.TypeA}
+	case dns.DomainStrategyUseIPv6:
+		return []uint16{mDNS.TypeAAAA}
+	default:
+		return []uint16{mDNS.TypeA, mDNS.TypeAAAA}
+	}
+}
+
+// sortLookupAddresses puts IPv6 addresses first if preferred, as the DNS client does.
+func sortLookupAddresses(addresses []netip.Addr, strategy dns.DomainStrategy) []netip.Addr {
+	var response4, response6 []netip.Addr
+	for _, address := range addresses {
+		if address.Is4() {
+			response4 = append(response4, address)
+		} else {
+			response6 = append(response6, address)
+		}
+	}
+	if strategy == dns.DomainStrategyPreferIPv6 {
+		return append(response6, response4...)
+	}
+	return append(response4, response6...)
+}
+
+// refreshDNSCache queries stale entries again in the background, refreshed responses replace them in the cache.
+func (r *Router) refreshDNSCache(ctx context.Context, staleKeys []dnsCacheKey) {
+	for _, key := range staleKeys {
+		r.dnsLogger.DebugContext(ctx, "refresh expired cache for ", formatQuestion(key.Question.String()))
+		var metadata adapter.InboundContext
+		if ctxMetadata := adapter.ContextFrom(ctx); ctxMetadata != nil {
+			metadata = *ctxMetadata
+			metadata.IPVersion = 0
+			metadata.DestinationAddresses = nil
+			metadata.ResetRuleCache()
+		}
+		refreshCtx := adapter.WithContext(log.ContextWithNewID(r.ctx), &metadata)
+		message := &mDNS.Msg{
+			MsgHdr: mDNS.MsgHdr{
+				Id:               mDNS.Id(),
+				RecursionDesired: true,
+			},
+			Question: []mDNS.Question{key.Question},
+		}
+		go func() {
+			_, err := r.Exchange(refreshCtx, message)
+			if err != nil {
+				r.dnsLogger.DebugContext(refreshCtx, E.Cause(err, "refresh cache for ", formatQuestion(message.Question[0].String())))
+			}
+		}()
+	}
+}
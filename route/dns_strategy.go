@@ -0,0 +1,222 @@
+package route
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	dns "github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	// dnsStatsWeight is the weight of a new sample in the moving averages of a transport.
+	dnsStatsWeight = 0.3
+	// dnsProbeInterval is how often a transport not selected by the fastest strategy is probed.
+	dnsProbeInterval = time.Minute
+)
+
+// dnsTransportStats tracks the moving average latency and error rate of each transport
+// for the fastest strategy of DNS rules.
+type dnsTransportStats struct {
+	access sync.Mutex
+	stats  map[dns.Transport]*dnsTransportStat
+}
+
+type dnsTransportStat struct {
+	latency    float64
+	errorRate  float64
+	measured   bool
+	lastUpdate time.Time
+	lastProbe  time.Time
+}
+
+func newDNSTransportStats() *dnsTransportStats {
+	return &dnsTransportStats{
+		stats: make(map[dns.Transport]*dnsTransportStat),
+	}
+}
+
+func (s *dnsTransportStats) Observe(transport dns.Transport, latency time.Duration, err error) {
+	var failure float64
+	if err != nil && !errors.Is(err, dns.RCodeNameError) {
+		failure = 1
+	}
+	s.access.Lock()
+	defer s.access.Unlock()
+	stat := s.stats[transport]
+	if stat == nil {
+		stat = new(dnsTransportStat)
+		s.stats[transport] = stat
+	}
+	if !stat.measured {
+		stat.latency = float64(latency)
+		stat.errorRate = failure
+		stat.measured = true
+	} else {
+		stat.latency += (float64(latency) - stat.latency) * dnsStatsWeight
+		stat.errorRate += (failure - stat.errorRate) * dnsStatsWeight
+	}
+	stat.lastUpdate = time.Now()
+}
+
+// Select returns the transport with the lowest expected latency, where failures count as timeouts,
+// and the other transports due to be probed.
+func (s *dnsTransportStats) Select(transports []dns.Transport) (dns.Transport, []dns.Transport) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	selected := transports[0]
+	bestScore := -1.0
+	for _, transport := range transports {
+		stat := s.stats[transport]
+		if stat == nil || !stat.measured {
+			continue
+		}
+		score := stat.latency + stat.errorRate*float64(C.DNSTimeout)
+		if bestScore < 0 || score < bestScore {
+			selected = transport
+			bestScore = score
+		}
+	}
+	var probeTransports []dns.Transport
+	now := time.Now()
+	for _, transport := range transports {
+		if transport == selected {
+			continue
+		}
+		stat := s.stats[transport]
+		if stat == nil {
+			stat = new(dnsTransportStat)
+			s.stats[transport] = stat
+		}
+		if now.Sub(stat.lastUpdate) < dnsProbeInterval || now.Sub(stat.lastProbe) < dnsProbeInterval {
+			continue
+		}
+		stat.lastProbe = now
+		probeTransports = append(probeTransports, transport)
+	}
+	return selected, probeTransports
+}
+
+func (s *dnsTransportStats) Remove(transport dns.Transport) {
+	s.access.Lock()
+	defer s.access.Unlock()
+	delete(s.stats, transport)
+}
+
+type dnsQueryResult interface {
+	queryError() error
+}
+
+func (r dnsRes) queryError() error {
+	return r.err
+}
+
+func (r dnsAddr) queryError() error {
+	return r.err
+}
+
+// queryDNSTransports queries the transports of the matched rule as its strategy requires:
+// race queries all at once, sequential queries one after another and fastest queries
+// only the best one while probing the others with probeMessage.
+func queryDNSTransports[T dnsQueryResult](r *Router, ctx context.Context, rule adapter.DNSRule, transports []dns.Transport, probeMessage *mDNS.Msg, query func(ctx context.Context, transport dns.Transport) T) T {
+	strategy := C.DNSStrategyRace
+	if rule != nil && len(transports) > 1 {
+		strategy = rule.ServerStrategy()
+	}
+	switch strategy {
+	case C.DNSStrategySequential:
+		var (
+			result T
+			loaded bool
+		)
+		for _, transport := range transports {
+			queryCtx, cancel := context.WithTimeout(ctx, rule.ServerTimeout())
+			cResult := query(queryCtx, transport)
+			cancel()
+			if !loaded || cResult.queryError() != context.DeadlineExceeded {
+				result = cResult
+				loaded = true
+			}
+			if cResult.queryError() == nil {
+				break
+			}
+		}
+		return result
+	case C.DNSStrategyFastest:
+		transport, probeTransports := r.dnsTransportStats.Select(transports)
+		if len(probeMessage.Question) > 0 {
+			for _, probeTransport := range probeTransports {
+				go r.probeDNSTransport(probeTransport, probeMessage.Copy())
+			}
+		}
+		queryCtx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
+		defer cancel()
+		return query(queryCtx, transport)
+	default:
+		resultChan := make(chan T, len(transports))
+		for _, transport := range transports {
+			go func(transport dns.Transport) {
+				queryCtx, cancel := context.WithTimeout(ctx, C.DNSTimeout)
+				defer cancel()
+				resultChan <- query(queryCtx, transport)
+			}(transport)
+		}
+		var (
+			result T
+			loaded bool
+		)
+		for i := 0; i < len(transports); i++ {
+			cResult := <-resultChan
+			if !loaded || cResult.queryError() != context.DeadlineExceeded {
+				result = cResult
+				loaded = true
+			}
+			if cResult.queryError() == nil || cResult.queryError() == context.DeadlineExceeded {
+				break
+			}
+		}
+		return result
+	}
+}
+
+// probeDNSTransport measures a transport skipped by the fastest strategy, bypassing the cache.
+func (r *Router) probeDNSTransport(transport dns.Transport, message *mDNS.Msg) {
+	ctx, cancel := context.WithTimeout(dns.ContextWithDisableCache(r.ctx, true), C.DNSTimeout)
+	defer cancel()
+	_, err := r.dnsClient.Exchange(ctx, r.observedTransport(transport), message, r.GetStrategy(transport))
+	if err != nil {
+		r.dnsLogger.Debug(E.Cause(err, "probe ", transport.Name()))
+	}
+}
+
+// observedDNSTransport measures the round trips of a transport to its upstream.
+// It is only passed to the DNS client, so that cache hits and cached rejections are not measured.
+type observedDNSTransport struct {
+	dns.Transport
+	router *Router
+}
+
+func (r *Router) observedTransport(transport dns.Transport) dns.Transport {
+	return &observedDNSTransport{transport, r}
+}
+
+func (t *observedDNSTransport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	start := time.Now()
+	response, err := t.Transport.Exchange(ctx, message)
+	t.router.observeDNSExchange(t.Transport, start, err)
+	return response, err
+}
+
+func (t *observedDNSTransport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	start := time.Now()
+	addresses, err := t.Transport.Lookup(ctx, domain, strategy)
+	t.router.observeDNSExchange(t.Transport, start, err)
+	return addresses, err
+}
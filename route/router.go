@@ -10,11 +10,13 @@ import (
 	"os/user"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/common/conntrack"
 	"github.com/sagernet/sing-box/common/dialer"
+	"github.com/sagernet/sing-box/common/dnslog"
 	"github.com/sagernet/sing-box/common/geoip"
 	"github.com/sagernet/sing-box/common/geosite"
 	"github.com/sagernet/sing-box/common/process"
@@ -27,6 +29,7 @@ import (
 	"github.com/sagernet/sing-box/option"
 	O "github.com/sagernet/sing-box/outbound"
 	"github.com/sagernet/sing-box/transport/fakeip"
+	"github.com/sagernet/sing-box/transport/hosts"
 	"github.com/sagernet/sing-dns"
 	mux "github.com/sagernet/sing-mux"
 	"github.com/sagernet/sing-tun"
@@ -51,60 +54,53 @@ import (
 var _ adapter.Router = (*Router)(nil)
 
 type Router struct {
-	ctx                                context.Context
-	logger                             log.ContextLogger
-	dnsLogger                          log.ContextLogger
-	overrideLogger                     log.ContextLogger
-	inboundByTag                       map[string]adapter.Inbound
-	outbounds                          []adapter.Outbound
-	outboundByTag                      map[string]adapter.Outbound
-	outboundProviders                  []adapter.OutboundProvider
-	outboundProviderByTag              map[string]adapter.OutboundProvider
-	rules                              []adapter.Rule
-	routeRuleByUUID                    map[string]adapter.Rule
-	defaultDetour                      string
-	defaultOutboundForConnection       adapter.Outbound
-	defaultOutboundForPacketConnection adapter.Outbound
-	needGeoIPDatabase                  bool
-	needGeositeDatabase                bool
-	geoIPOptions                       option.GeoIPOptions
-	geositeOptions                     option.GeositeOptions
-	geoIPReader                        *geoip.Reader
-	geositeReader                      *geosite.Reader
-	geositeCache                       map[string]adapter.Rule
-	needFindProcess                    bool
-	dnsClient                          *dns.Client
-	dnsIndependentCache                bool
-	defaultDomainStrategy              dns.DomainStrategy
-	dnsRules                           []adapter.DNSRule
-	dnsRuleByUUID                      map[string]adapter.DNSRule
-	ruleSets                           []adapter.RuleSet
-	ruleSetMap                         map[string]adapter.RuleSet
-	sniffOverrideRules                 map[string][]adapter.Rule
-	defaultTransports                  []dns.Transport
-	transports                         []dns.Transport
-	transportMap                       map[string]dns.Transport
-	transportDomainStrategy            map[dns.Transport]dns.DomainStrategy
-	dnsReverseMapping                  *DNSReverseMapping
-	fakeIPStore                        adapter.FakeIPStore
-	interfaceFinder                    myInterfaceFinder
-	autoDetectInterface                bool
-	defaultInterface                   string
-	defaultMark                        int
-	networkMonitor                     tun.NetworkUpdateMonitor
-	interfaceMonitor                   tun.DefaultInterfaceMonitor
-	packageManager                     tun.PackageManager
-	powerListener                      winpowrprof.EventListener
-	processSearcher                    process.Searcher
-	timeService                        *ntp.Service
-	pauseManager                       pause.Manager
-	clashServer                        adapter.ClashServer
-	v2rayServer                        adapter.V2RayServer
-	platformInterface                  platform.Interface
-	needWIFIState                      bool
-	needPackageManager                 bool
-	wifiState                          adapter.WIFIState
-	started                            bool
+	ctx                   context.Context
+	logFactory            log.Factory
+	logger                log.ContextLogger
+	dnsLogger             log.ContextLogger
+	overrideLogger        log.ContextLogger
+	needGeoIPDatabase     bool
+	needGeositeDatabase   bool
+	geoIPOptions          option.GeoIPOptions
+	geositeOptions        option.GeositeOptions
+	geoIPReader           *geoip.Reader
+	geositeReader         *geosite.Reader
+	geositeCache          map[string]adapter.Rule
+	needFindProcess       bool
+	dnsClient             *dns.Client
+	dnsIndependentCache   bool
+	defaultDomainStrategy dns.DomainStrategy
+	dnsReverseMapping     *DNSReverseMapping
+	fakeIPStore           adapter.FakeIPStore
+	dnsQueryLogger        adapter.DNSQueryLogger
+	dnsCache              *dnsCache
+	dnsTransportStats     *dnsTransportStats
+	interfaceFinder       myInterfaceFinder
+	autoDetectInterface   bool
+	defaultInterface      string
+	defaultMark           int
+	networkMonitor        tun.NetworkUpdateMonitor
+	interfaceMonitor      tun.DefaultInterfaceMonitor
+	packageManager        tun.PackageManager
+	powerListener         winpowrprof.EventListener
+	processSearcher       process.Searcher
+	timeService           *ntp.Service
+	pauseManager          pause.Manager
+	clashServer           adapter.ClashServer
+	v2rayServer           adapter.V2RayServer
+	metricsServer         adapter.MetricsServer
+	dnsHijacker           *O.DNS
+	platformInterface     platform.Interface
+	needWIFIState         bool
+	needPackageManager    bool
+	wifiState             adapter.WIFIState
+	options               option.RouteOptions
+	dnsOptions            option.DNSOptions
+	inboundOptions        []option.Inbound
+	state                 atomic.Pointer[routerState]
+	stagedState           atomic.Pointer[routerState]
+	droppedConns          atomic.Int32
+	started               bool
 }
 
 func NewRouter(
@@ -118,17 +114,10 @@ func NewRouter(
 ) (*Router, error) {
 	router := &Router{
 		ctx:                   ctx,
+		logFactory:            logFactory,
 		logger:                logFactory.NewLogger("router"),
 		dnsLogger:             logFactory.NewLogger("dns"),
 		overrideLogger:        logFactory.NewLogger("override"),
-		outboundByTag:         make(map[string]adapter.Outbound),
-		outboundProviderByTag: make(map[string]adapter.OutboundProvider),
-		rules:                 make([]adapter.Rule, 0, len(options.Rules)),
-		routeRuleByUUID:       make(map[string]adapter.Rule),
-		dnsRules:              make([]adapter.DNSRule, 0, len(dnsOptions.Rules)),
-		dnsRuleByUUID:         make(map[string]adapter.DNSRule),
-		sniffOverrideRules:    make(map[string][]adapter.Rule),
-		ruleSetMap:            make(map[string]adapter.RuleSet),
 		needGeoIPDatabase:     hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule) || hasDNSFallbackRuleUseGeoIP(dnsOptions.Rules),
 		needGeositeDatabase:   hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule),
 		geoIPOptions:          common.PtrValueOrDefault(options.GeoIP),
@@ -136,7 +125,7 @@ func NewRouter(
 		geositeCache:          make(map[string]adapter.Rule),
 		needFindProcess:       hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess,
 		dnsIndependentCache:   dnsOptions.IndependentCache,
-		defaultDetour:         options.Final,
+		dnsTransportStats:     newDNSTransportStats(),
 		defaultDomainStrategy: dns.DomainStrategy(dnsOptions.Strategy),
 		autoDetectInterface:   options.AutoDetectInterface,
 		defaultInterface:      options.DefaultInterface,
@@ -147,11 +136,28 @@ func NewRouter(
 		needPackageManager: C.IsAndroid && platformInterface == nil && common.Any(inbounds, func(inbound option.Inbound) bool {
 			return len(inbound.TunOptions.IncludePackage) > 0 || len(inbound.TunOptions.ExcludePackage) > 0
 		}),
+		options:        options,
+		dnsOptions:     dnsOptions,
+		inboundOptions: inbounds,
+	}
+	state := &routerState{
+		defaultDetour:         options.Final,
+		outboundByTag:         make(map[string]adapter.Outbound),
+		outboundProviderByTag: make(map[string]adapter.OutboundProvider),
+		rules:                 make([]adapter.Rule, 0, len(options.Rules)),
+		routeRuleByUUID:       make(map[string]adapter.Rule),
+		dnsRules:              make([]adapter.DNSRule, 0, len(dnsOptions.Rules)),
+		dnsRuleByUUID:         make(map[string]adapter.DNSRule),
+		sniffOverrideRules:    make(map[string][]adapter.Rule),
+		ruleSetMap:            make(map[string]adapter.RuleSet),
 	}
+	router.state.Store(state)
+	router.dnsHijacker = O.NewDNS(router, "")
+	independentCache := dnsOptions.DNSClientOptions.IndependentCache || hasMultiDNSServer(dnsOptions.Rules) || len(dnsOptions.Final) > 1
 	router.dnsClient = dns.NewClient(dns.ClientOptions{
 		DisableCache:     dnsOptions.DNSClientOptions.DisableCache,
 		DisableExpire:    dnsOptions.DNSClientOptions.DisableExpire,
-		IndependentCache: dnsOptions.DNSClientOptions.IndependentCache || hasMultiDNSServer(dnsOptions.Rules) || len(dnsOptions.Final) > 1,
+		IndependentCache: independentCache,
 		RDRC: func() dns.RDRCStore {
 			cacheFile := service.FromContext[adapter.CacheFile](ctx)
 			if cacheFile == nil {
@@ -181,7 +187,7 @@ func NewRouter(
 			}
 			rules = append(rules, sniffOverrdideRule)
 		}
-		router.sniffOverrideRules[tag] = rules
+		state.sniffOverrideRules[tag] = rules
 	}
 	for i, ruleOptions := range options.Rules {
 		routeRule, err := NewRule(router, router.logger, ruleOptions, true)
@@ -189,8 +195,8 @@ func NewRouter(
 			return nil, E.Cause(err, "parse rule[", i, "]")
 		}
 		uuid := routeRule.UUID()
-		router.rules = append(router.rules, routeRule)
-		router.routeRuleByUUID[uuid] = routeRule
+		state.rules = append(state.rules, routeRule)
+		state.routeRuleByUUID[uuid] = routeRule
 	}
 	for i, dnsRuleOptions := range dnsOptions.Rules {
 		dnsRule, err := NewDNSRule(router, router.logger, dnsRuleOptions, true)
@@ -198,21 +204,115 @@ func NewRouter(
 			return nil, E.Cause(err, "parse dns rule[", i, "]")
 		}
 		uuid := dnsRule.UUID()
-		router.dnsRules = append(router.dnsRules, dnsRule)
-		router.dnsRuleByUUID[uuid] = dnsRule
+		state.dnsRules = append(state.dnsRules, dnsRule)
+		state.dnsRuleByUUID[uuid] = dnsRule
 	}
 	for i, ruleSetOptions := range options.RuleSet {
-		if _, exists := router.ruleSetMap[ruleSetOptions.Tag]; exists {
+		if _, exists := state.ruleSetMap[ruleSetOptions.Tag]; exists {
 			return nil, E.New("duplicate rule-set tag: ", ruleSetOptions.Tag)
 		}
 		ruleSet, err := NewRuleSet(ctx, router, router.logger, ruleSetOptions)
 		if err != nil {
 			return nil, E.Cause(err, "parse rule-set[", i, "]")
 		}
-		router.ruleSets = append(router.ruleSets, ruleSet)
-		router.ruleSetMap[ruleSetOptions.Tag] = ruleSet
+		state.ruleSets = append(state.ruleSets, ruleSet)
+		state.ruleSetMap[ruleSetOptions.Tag] = ruleSet
+	}
+
+	err := router.createDNSTransports(state, dnsOptions, nil)
+	if err != nil {
+		return nil, err
+	}
+	state.trafficLimiter, err = newTrafficLimiter(ctx, router.logger, inbounds, nil)
+	if err != nil {
+		return nil, err
+	}
+	ctx = adapter.ContextWithRouter(ctx, router)
+
+	if dnsOptions.ReverseMapping {
+		router.dnsReverseMapping = NewDNSReverseMapping()
+	}
+
+	if fakeIPOptions := dnsOptions.FakeIP; fakeIPOptions != nil && dnsOptions.FakeIP.Enabled {
+		var inet4Range netip.Prefix
+		var inet6Range netip.Prefix
+		if fakeIPOptions.Inet4Range != nil {
+			inet4Range = *fakeIPOptions.Inet4Range
+		}
+		if fakeIPOptions.Inet6Range != nil {
+			inet6Range = *fakeIPOptions.Inet6Range
+		}
+		router.fakeIPStore = fakeip.NewStore(ctx, router.logger, inet4Range, inet6Range)
+	}
+
+	dnsCache, err := newDNSCache(ctx, router.dnsLogger, dnsOptions, independentCache)
+	if err != nil {
+		return nil, err
+	}
+	router.dnsCache = dnsCache
+
+	if dnsOptions.Hosts != nil {
+		dnsHosts, err := hosts.NewStore(ctx, router.dnsLogger, *dnsOptions.Hosts, router.clearDNSCache)
+		if err != nil {
+			return nil, E.Cause(err, "parse hosts")
+		}
+		state.dnsHosts = dnsHosts
+	}
+	router.dnsQueryLogger = dnslog.NewLogger(ctx, router.dnsLogger, common.PtrValueOrDefault(dnsOptions.QueryLog))
+
+	usePlatformDefaultInterfaceMonitor := platformInterface != nil && platformInterface.UsePlatformDefaultInterfaceMonitor()
+	needInterfaceMonitor := options.AutoDetectInterface || common.Any(inbounds, func(inbound option.Inbound) bool {
+		return inbound.HTTPOptions.SetSystemProxy || inbound.MixedOptions.SetSystemProxy || inbound.TunOptions.AutoRoute
+	})
+
+	if !usePlatformDefaultInterfaceMonitor {
+		networkMonitor, err := tun.NewNetworkUpdateMonitor(router.logger)
+		if !((err != nil && !needInterfaceMonitor) || errors.Is(err, os.ErrInvalid)) {
+			if err != nil {
+				return nil, err
+			}
+			router.networkMonitor = networkMonitor
+			networkMonitor.RegisterCallback(func() {
+				_ = router.interfaceFinder.update()
+			})
+			interfaceMonitor, err := tun.NewDefaultInterfaceMonitor(router.networkMonitor, router.logger, tun.DefaultInterfaceMonitorOptions{
+				OverrideAndroidVPN:    options.OverrideAndroidVPN,
+				UnderNetworkExtension: platformInterface != nil && platformInterface.UnderNetworkExtension(),
+			})
+			if err != nil {
+				return nil, E.New("auto_detect_interface unsupported on current platform")
+			}
+			interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
+			router.interfaceMonitor = interfaceMonitor
+		}
+	} else {
+		interfaceMonitor := platformInterface.CreateDefaultInterfaceMonitor(router.logger)
+		interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
+		router.interfaceMonitor = interfaceMonitor
 	}
 
+	if runtime.GOOS == "windows" {
+		powerListener, err := winpowrprof.NewEventListener(router.notifyWindowsPowerEvent)
+		if err != nil {
+			return nil, E.Cause(err, "initialize power listener")
+		}
+		router.powerListener = powerListener
+	}
+
+	if ntpOptions.Enabled {
+		timeService, err := ntp.NewService(ctx, router, logFactory.NewLogger("ntp"), ntpOptions)
+		if err != nil {
+			return nil, err
+		}
+		service.ContextWith[serviceNTP.TimeService](ctx, timeService)
+		router.timeService = timeService
+	}
+	return router, nil
+}
+
+// createDNSTransports builds the DNS transports described by dnsOptions into state.
+// Transports found in previous are kept as-is unless their address resolver was recreated.
+func (r *Router) createDNSTransports(state *routerState, dnsOptions option.DNSOptions, previous map[string]dns.Transport) error {
 	transports := make([]dns.Transport, len(dnsOptions.Servers))
 	dummyTransportMap := make(map[string]dns.Transport)
 	transportMap := make(map[string]dns.Transport)
@@ -227,12 +327,13 @@ func NewRouter(
 			tag = F.ToString(i)
 		}
 		if transportTagMap[tag] {
-			return nil, E.New("duplicate dns server tag: ", tag)
+			return E.New("duplicate dns server tag: ", tag)
 		}
 		transportTags[i] = tag
 		transportTagMap[tag] = true
 	}
-	ctx = adapter.ContextWithRouter(ctx, router)
+	ctx := adapter.ContextWithRouter(r.ctx, r)
+	reused := make(map[string]bool)
 	for {
 		lastLen := len(dummyTransportMap)
 		for i, server := range dnsOptions.Servers {
@@ -240,11 +341,30 @@ func NewRouter(
 			if _, exists := dummyTransportMap[tag]; exists {
 				continue
 			}
+			if transport, loaded := previous[tag]; loaded {
+				if server.AddressResolver != "" && !reused[server.AddressResolver] {
+					if _, exists := dummyTransportMap[server.AddressResolver]; !exists {
+						continue
+					}
+				} else {
+					transports[i] = transport
+					dummyTransportMap[tag] = transport
+					reused[tag] = true
+					if server.Tag != "" {
+						transportMap[server.Tag] = transport
+					}
+					strategy := dns.DomainStrategy(server.Strategy)
+					if strategy != dns.DomainStrategyAsIS {
+						transportDomainStrategy[transport] = strategy
+					}
+					continue
+				}
+			}
 			var detour N.Dialer
 			if server.Detour == "" {
-				detour = dialer.NewRouter(router)
+				detour = dialer.NewRouter(r)
 			} else {
-				detour = dialer.NewDetour(router, server.Detour)
+				detour = dialer.NewDetour(r, server.Detour)
 			}
 			switch server.Address {
 			case "local":
@@ -260,15 +380,15 @@ func NewRouter(
 				_, notIpAddress := netip.ParseAddr(serverAddress)
 				if server.AddressResolver != "" {
 					if !transportTagMap[server.AddressResolver] {
-						return nil, E.New("parse dns server[", tag, "]: address resolver not found: ", server.AddressResolver)
+						return E.New("parse dns server[", tag, "]: address resolver not found: ", server.AddressResolver)
 					}
 					if upstream, exists := dummyTransportMap[server.AddressResolver]; exists {
-						detour = dns.NewDialerWrapper(detour, router.dnsClient, upstream, dns.DomainStrategy(server.AddressStrategy), time.Duration(server.AddressFallbackDelay))
+						detour = dns.NewDialerWrapper(detour, r.dnsClient, upstream, dns.DomainStrategy(server.AddressStrategy), time.Duration(server.AddressFallbackDelay))
 					} else {
 						continue
 					}
 				} else if notIpAddress != nil && strings.Contains(server.Address, ".") {
-					return nil, E.New("parse dns server[", tag, "]: missing address_resolver")
+					return E.New("parse dns server[", tag, "]: missing address_resolver")
 				}
 			}
 			var clientSubnet netip.Addr
@@ -279,14 +399,14 @@ func NewRouter(
 			}
 			transport, err := dns.CreateTransport(dns.TransportOptions{
 				Context:      ctx,
-				Logger:       logFactory.NewLogger(F.ToString("dns/transport[", tag, "]")),
+				Logger:       r.logFactory.NewLogger(F.ToString("dns/transport[", tag, "]")),
 				Name:         tag,
 				Dialer:       detour,
 				Address:      server.Address,
 				ClientSubnet: clientSubnet,
 			})
 			if err != nil {
-				return nil, E.Cause(err, "parse dns server[", tag, "]")
+				return E.Cause(err, "parse dns server[", tag, "]")
 			}
 			transports[i] = transport
 			dummyTransportMap[tag] = transport
@@ -313,14 +433,14 @@ func NewRouter(
 		if len(unresolvedTags) == 0 {
 			panic(F.ToString("unexpected unresolved dns servers: ", len(transports), " ", len(dummyTransportMap), " ", len(transportMap)))
 		}
-		return nil, E.New("found circular reference in dns servers: ", strings.Join(unresolvedTags, " "))
+		return E.New("found circular reference in dns servers: ", strings.Join(unresolvedTags, " "))
 	}
 	var defaultTransports []dns.Transport
 	if len(dnsOptions.Final) > 0 {
 		for i, server := range dnsOptions.Final {
 			transport := dummyTransportMap[server]
 			if transport == nil {
-				return nil, E.New("default dns server[", i, "] not found: ", server)
+				return E.New("default dns server[", i, "] not found: ", server)
 			}
 			defaultTransports = append(defaultTransports, transport)
 		}
@@ -331,88 +451,46 @@ func NewRouter(
 				Context: ctx,
 				Name:    "local",
 				Address: "local",
-				Dialer:  common.Must1(dialer.NewDefault(router, option.DialerOptions{})),
+				Dialer:  common.Must1(dialer.NewDefault(r, option.DialerOptions{})),
 			})))
 		}
 		defaultTransports = append(defaultTransports, transports[0])
 	}
 	for _, server := range defaultTransports {
 		if _, isFakeIP := server.(adapter.FakeIPTransport); isFakeIP {
-			return nil, E.New("default dns servers cannot be fakeip")
-		}
-	}
-	router.defaultTransports = defaultTransports
-	router.transports = transports
-	router.transportMap = transportMap
-	router.transportDomainStrategy = transportDomainStrategy
-
-	if dnsOptions.ReverseMapping {
-		router.dnsReverseMapping = NewDNSReverseMapping()
-	}
-
-	if fakeIPOptions := dnsOptions.FakeIP; fakeIPOptions != nil && dnsOptions.FakeIP.Enabled {
-		var inet4Range netip.Prefix
-		var inet6Range netip.Prefix
-		if fakeIPOptions.Inet4Range != nil {
-			inet4Range = *fakeIPOptions.Inet4Range
-		}
-		if fakeIPOptions.Inet6Range != nil {
-			inet6Range = *fakeIPOptions.Inet6Range
+			return E.New("default dns servers cannot be fakeip")
 		}
-		router.fakeIPStore = fakeip.NewStore(ctx, router.logger, inet4Range, inet6Range)
 	}
+	state.defaultTransports = defaultTransports
+	state.transports = transports
+	state.transportMap = transportMap
+	state.transportByTag = dummyTransportMap
+	state.transportDomainStrategy = transportDomainStrategy
+	return nil
+}
 
-	usePlatformDefaultInterfaceMonitor := platformInterface != nil && platformInterface.UsePlatformDefaultInterfaceMonitor()
-	needInterfaceMonitor := options.AutoDetectInterface || common.Any(inbounds, func(inbound option.Inbound) bool {
-		return inbound.HTTPOptions.SetSystemProxy || inbound.MixedOptions.SetSystemProxy || inbound.TunOptions.AutoRoute
-	})
-
-	if !usePlatformDefaultInterfaceMonitor {
-		networkMonitor, err := tun.NewNetworkUpdateMonitor(router.logger)
-		if !((err != nil && !needInterfaceMonitor) || errors.Is(err, os.ErrInvalid)) {
-			if err != nil {
-				return nil, err
-			}
-			router.networkMonitor = networkMonitor
-			networkMonitor.RegisterCallback(func() {
-				_ = router.interfaceFinder.update()
-			})
-			interfaceMonitor, err := tun.NewDefaultInterfaceMonitor(router.networkMonitor, router.logger, tun.DefaultInterfaceMonitorOptions{
-				OverrideAndroidVPN:    options.OverrideAndroidVPN,
-				UnderNetworkExtension: platformInterface != nil && platformInterface.UnderNetworkExtension(),
-			})
-			if err != nil {
-				return nil, E.New("auto_detect_interface unsupported on current platform")
-			}
-			interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
-			router.interfaceMonitor = interfaceMonitor
-		}
-	} else {
-		interfaceMonitor := platformInterface.CreateDefaultInterfaceMonitor(router.logger)
-		interfaceMonitor.RegisterCallback(router.notifyNetworkUpdate)
-		router.interfaceMonitor = interfaceMonitor
+func (r *Router) Initialize(inbounds []adapter.Inbound, outboundProviders []adapter.OutboundProvider, outbounds []adapter.Outbound) error {
+	state := r.state.Load()
+	err := r.initializeOutbounds(state, inbounds, outboundProviders, outbounds)
+	if err != nil {
+		return err
 	}
+	return checkRuleOutbounds(state)
+}
 
-	if runtime.GOOS == "windows" {
-		powerListener, err := winpowrprof.NewEventListener(router.notifyWindowsPowerEvent)
-		if err != nil {
-			return nil, E.Cause(err, "initialize power listener")
+func checkRuleOutbounds(state *routerState) error {
+	for i, rule := range state.rules {
+		if rule.Action() != C.RuleActionTypeRoute {
+			continue
 		}
-		router.powerListener = powerListener
-	}
-
-	if ntpOptions.Enabled {
-		timeService, err := ntp.NewService(ctx, router, logFactory.NewLogger("ntp"), ntpOptions)
-		if err != nil {
-			return nil, err
+		if _, loaded := state.outboundByTag[rule.Outbound()]; !loaded {
+			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
 		}
-		service.ContextWith[serviceNTP.TimeService](ctx, timeService)
-		router.timeService = timeService
 	}
-	return router, nil
+	return nil
 }
 
-func (r *Router) Initialize(inbounds []adapter.Inbound, outboundProviders []adapter.OutboundProvider, outbounds []adapter.Outbound) error {
+func (r *Router) initializeOutbounds(state *routerState, inbounds []adapter.Inbound, outboundProviders []adapter.OutboundProvider, outbounds []adapter.Outbound) error {
 	inboundByTag := make(map[string]adapter.Inbound)
 	for _, inbound := range inbounds {
 		inboundByTag[inbound.Tag()] = inbound
@@ -427,10 +505,10 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outboundProviders []adap
 	}
 	var defaultOutboundForConnection adapter.Outbound
 	var defaultOutboundForPacketConnection adapter.Outbound
-	if r.defaultDetour != "" {
-		detour, loaded := outboundByTag[r.defaultDetour]
+	if state.defaultDetour != "" {
+		detour, loaded := outboundByTag[state.defaultDetour]
 		if !loaded {
-			return E.New("default detour not found: ", r.defaultDetour)
+			return E.New("default detour not found: ", state.defaultDetour)
 		}
 		if common.Contains(detour.Network(), N.NetworkTCP) {
 			defaultOutboundForConnection = detour
@@ -465,7 +543,7 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outboundProviders []adap
 		}
 	}
 	if defaultOutboundForConnection == nil || defaultOutboundForPacketConnection == nil {
-		detour := r.outboundByTag["OUTBOUNDLESS"]
+		detour := outboundByTag["OUTBOUNDLESS"]
 		if defaultOutboundForConnection == nil {
 			defaultOutboundForConnection = detour
 		}
@@ -491,43 +569,43 @@ func (r *Router) Initialize(inbounds []adapter.Inbound, outboundProviders []adap
 		r.logger.Info("using ", defaultOutboundForConnection.Type(), "[", description, "] as default outbound for connection")
 		r.logger.Info("using ", defaultOutboundForPacketConnection.Type(), "[", packetDescription, "] as default outbound for packet connection")
 	}
-	r.inboundByTag = inboundByTag
-	r.outbounds = outbounds
-	r.defaultOutboundForConnection = defaultOutboundForConnection
-	r.defaultOutboundForPacketConnection = defaultOutboundForPacketConnection
-	r.outboundByTag = outboundByTag
-	r.outboundProviderByTag = outboundProviderByTag
-	r.outboundProviders = outboundProviders
-	for i, rule := range r.rules {
-		if _, loaded := outboundByTag[rule.Outbound()]; !loaded {
-			return E.New("outbound not found for rule[", i, "]: ", rule.Outbound())
-		}
-	}
+	state.inboundByTag = inboundByTag
+	state.outbounds = outbounds
+	state.defaultOutboundForConnection = defaultOutboundForConnection
+	state.defaultOutboundForPacketConnection = defaultOutboundForPacketConnection
+	state.outboundByTag = outboundByTag
+	state.outboundProviderByTag = outboundProviderByTag
+	state.outboundProviders = outboundProviders
 	return nil
 }
 
 func (r *Router) Outbounds() []adapter.Outbound {
+	state := r.lookupState()
 	if !r.started {
 		return nil
 	}
-	return r.outbounds
+	return state.outbounds
 }
 
 func (r *Router) OutboundProviders() []adapter.OutboundProvider {
-	return r.outboundProviders
+	state := r.lookupState()
+	return state.outboundProviders
 }
 
 func (r *Router) OutboundProvider(tag string) (adapter.OutboundProvider, bool) {
-	provider, loaded := r.outboundProviderByTag[tag]
+	state := r.lookupState()
+	provider, loaded := state.outboundProviderByTag[tag]
 	return provider, loaded
 }
 
 func (r *Router) Transport(tag string) (dns.Transport, bool) {
-	transport, loaded := r.transportMap[tag]
+	state := r.lookupState()
+	transport, loaded := state.transportMap[tag]
 	return transport, loaded
 }
 
 func (r *Router) PreStart() error {
+	state := r.state.Load()
 	monitor := taskmonitor.New(r.logger, C.DefaultStartTimeout)
 	if r.interfaceMonitor != nil {
 		monitor.Start("initialize interface monitor")
@@ -553,11 +631,32 @@ func (r *Router) PreStart() error {
 			return err
 		}
 	}
+	if state.dnsHosts != nil {
+		monitor.Start("initialize hosts")
+		err := state.dnsHosts.Start()
+		monitor.Finish()
+		if err != nil {
+			return err
+		}
+	}
+	if r.dnsCache != nil {
+		monitor.Start("initialize DNS cache")
+		r.dnsCache.Start()
+		monitor.Finish()
+	}
+	monitor.Start("initialize DNS query log")
+	err := r.dnsQueryLogger.Start()
+	monitor.Finish()
+	if err != nil {
+		return err
+	}
 	return nil
 }
 
 func (r *Router) Start() error {
+	state := r.state.Load()
 	monitor := taskmonitor.New(r.logger, C.DefaultStartTimeout)
+	state.trafficLimiter.Start()
 	if r.needGeoIPDatabase {
 		monitor.Start("initialize geoip database")
 		err := r.prepareGeoIPDatabase()
@@ -575,19 +674,19 @@ func (r *Router) Start() error {
 		}
 	}
 	if r.needGeositeDatabase {
-		for _, rule := range r.rules {
+		for _, rule := range state.rules {
 			err := rule.UpdateGeosite()
 			if err != nil {
 				r.logger.Error("failed to initialize geosite: ", err)
 			}
 		}
-		for _, rule := range r.dnsRules {
+		for _, rule := range state.dnsRules {
 			err := rule.UpdateGeosite()
 			if err != nil {
 				r.logger.Error("failed to initialize geosite: ", err)
 			}
 		}
-		for _, rules := range r.sniffOverrideRules {
+		for _, rules := range state.sniffOverrideRules {
 			for _, rule := range rules {
 				err := rule.UpdateGeosite()
 				if err != nil {
@@ -603,11 +702,11 @@ func (r *Router) Start() error {
 		r.geositeReader = nil
 	}
 
-	if len(r.ruleSets) > 0 {
+	if len(state.ruleSets) > 0 {
 		monitor.Start("initialize rule-set")
 		ruleSetStartContext := NewRuleSetStartContext()
 		var ruleSetStartGroup task.Group
-		for i, ruleSet := range r.ruleSets {
+		for i, ruleSet := range state.ruleSets {
 			ruleSetInPlace := ruleSet
 			ruleSetStartGroup.Append0(func(ctx context.Context) error {
 				err := ruleSetInPlace.StartContext(ctx, ruleSetStartContext)
@@ -630,7 +729,7 @@ func (r *Router) Start() error {
 		needProcessFromRuleSet   bool
 		needWIFIStateFromRuleSet bool
 	)
-	for _, ruleSet := range r.ruleSets {
+	for _, ruleSet := range state.ruleSets {
 		metadata := ruleSet.Metadata()
 		if metadata.ContainsProcessRule {
 			needProcessFromRuleSet = true
@@ -694,7 +793,7 @@ func (r *Router) Start() error {
 		monitor.Finish()
 	}
 
-	for i, rule := range r.rules {
+	for i, rule := range state.rules {
 		monitor.Start("initialize rule[", i, "]")
 		err := rule.Start()
 		monitor.Finish()
@@ -707,7 +806,7 @@ func (r *Router) Start() error {
 	r.dnsClient.Start()
 	monitor.Finish()
 
-	for in, rules := range r.sniffOverrideRules {
+	for in, rules := range state.sniffOverrideRules {
 		for i, rule := range rules {
 			monitor.Start("initialize inbound[", in, "] sniff_overrride_rule[", i, "]")
 			err := rule.Start()
@@ -717,7 +816,7 @@ func (r *Router) Start() error {
 			}
 		}
 	}
-	for i, transport := range r.transports {
+	for i, transport := range state.transports {
 		monitor.Start("initialize DNS transport[", i, "]")
 		err := transport.Start()
 		monitor.Finish()
@@ -725,12 +824,12 @@ func (r *Router) Start() error {
 			return E.Cause(err, "initialize DNS server[", i, "]")
 		}
 	}
-	for _, transport := range r.defaultTransports {
-		if _, isRCode := transport.(*dns.RCodeTransport); isRCode && len(r.defaultTransports) > 1 {
+	for _, transport := range state.defaultTransports {
+		if _, isRCode := transport.(*dns.RCodeTransport); isRCode && len(state.defaultTransports) > 1 {
 			return E.New("initialize default dns servers failed: rcode server can only be used stand-alone")
 		}
 	}
-	for i, rule := range r.dnsRules {
+	for i, rule := range state.dnsRules {
 		monitor.Start("initialize DNS rule[", i, "]")
 		err := rule.Start()
 		monitor.Finish()
@@ -750,23 +849,27 @@ func (r *Router) Start() error {
 }
 
 func (r *Router) Close() error {
+	state := r.state.Load()
 	monitor := taskmonitor.New(r.logger, C.DefaultStopTimeout)
 	var err error
-	for i, rule := range r.rules {
+	monitor.Start("close traffic limiter")
+	state.trafficLimiter.Close()
+	monitor.Finish()
+	for i, rule := range state.rules {
 		monitor.Start("close rule[", i, "]")
 		err = E.Append(err, rule.Close(), func(err error) error {
 			return E.Cause(err, "close rule[", i, "]")
 		})
 		monitor.Finish()
 	}
-	for i, rule := range r.dnsRules {
+	for i, rule := range state.dnsRules {
 		monitor.Start("close dns rule[", i, "]")
 		err = E.Append(err, rule.Close(), func(err error) error {
 			return E.Cause(err, "close dns rule[", i, "]")
 		})
 		monitor.Finish()
 	}
-	for i, transport := range r.transports {
+	for i, transport := range state.transports {
 		monitor.Start("close dns transport[", i, "]")
 		err = E.Append(err, transport.Close(), func(err error) error {
 			return E.Cause(err, "close dns transport[", i, "]")
@@ -822,12 +925,25 @@ func (r *Router) Close() error {
 		})
 		monitor.Finish()
 	}
+	if state.dnsHosts != nil {
+		monitor.Start("close hosts")
+		err = E.Append(err, state.dnsHosts.Close(), func(err error) error {
+			return E.Cause(err, "close hosts")
+		})
+		monitor.Finish()
+	}
+	monitor.Start("close DNS query log")
+	err = E.Append(err, r.dnsQueryLogger.Close(), func(err error) error {
+		return E.Cause(err, "close DNS query log")
+	})
+	monitor.Finish()
 	return err
 }
 
 func (r *Router) PostStart() error {
-	if len(r.ruleSets) > 0 {
-		for i, ruleSet := range r.ruleSets {
+	state := r.state.Load()
+	if len(state.ruleSets) > 0 {
+		for i, ruleSet := range state.ruleSets {
 			err := ruleSet.PostStart()
 			if err != nil {
 				return E.Cause(err, "post start rule-set[", i, "]")
@@ -838,17 +954,25 @@ func (r *Router) PostStart() error {
 	return nil
 }
 
+func (r *Router) Inbound(tag string) (adapter.Inbound, bool) {
+	state := r.state.Load()
+	inbound, loaded := state.inboundByTag[tag]
+	return inbound, loaded
+}
+
 func (r *Router) Outbound(tag string) (adapter.Outbound, bool) {
-	outbound, loaded := r.outboundByTag[tag]
+	state := r.lookupState()
+	outbound, loaded := state.outboundByTag[tag]
 	return outbound, loaded
 }
 
 func (r *Router) OutboundWithProvider(tag string) (adapter.Outbound, bool) {
-	outbound, loaded := r.outboundByTag[tag]
+	state := r.lookupState()
+	outbound, loaded := state.outboundByTag[tag]
 	if loaded {
 		return outbound, loaded
 	}
-	for _, provider := range r.outboundProviders {
+	for _, provider := range state.outboundProviders {
 		outbound, loaded = provider.Outbound(tag)
 		if loaded {
 			return outbound, loaded
@@ -858,9 +982,10 @@ func (r *Router) OutboundWithProvider(tag string) (adapter.Outbound, bool) {
 }
 
 func (r *Router) OutboundsWithProvider() []adapter.Outbound {
+	state := r.lookupState()
 	outbounds := []adapter.Outbound{}
-	outbounds = append(outbounds, r.outbounds...)
-	for _, provider := range r.outboundProviders {
+	outbounds = append(outbounds, state.outbounds...)
+	for _, provider := range state.outboundProviders {
 		myOutbounds := provider.Outbounds()
 		outbounds = append(outbounds, myOutbounds...)
 	}
@@ -868,16 +993,17 @@ func (r *Router) OutboundsWithProvider() []adapter.Outbound {
 }
 
 func (r *Router) DefaultOutbound(network string) (adapter.Outbound, error) {
+	state := r.state.Load()
 	if network == N.NetworkTCP {
-		if r.defaultOutboundForConnection == nil {
+		if state.defaultOutboundForConnection == nil {
 			return nil, E.New("missing default outbound for TCP connections")
 		}
-		return r.defaultOutboundForConnection, nil
+		return state.defaultOutboundForConnection, nil
 	} else {
-		if r.defaultOutboundForPacketConnection == nil {
+		if state.defaultOutboundForPacketConnection == nil {
 			return nil, E.New("missing default outbound for UDP connections")
 		}
-		return r.defaultOutboundForPacketConnection, nil
+		return state.defaultOutboundForPacketConnection, nil
 	}
 }
 
@@ -885,12 +1011,23 @@ func (r *Router) FakeIPStore() adapter.FakeIPStore {
 	return r.fakeIPStore
 }
 
+func (r *Router) DNSHosts() adapter.DNSHostsStore {
+	state := r.state.Load()
+	return state.dnsHosts
+}
+
+func (r *Router) DNSQueryLogger() adapter.DNSQueryLogger {
+	return r.dnsQueryLogger
+}
+
 func (r *Router) RuleSets() []adapter.RuleSet {
-	return r.ruleSets
+	state := r.state.Load()
+	return state.ruleSets
 }
 
 func (r *Router) RuleSet(tag string) (adapter.RuleSet, bool) {
-	ruleSet, loaded := r.ruleSetMap[tag]
+	state := r.lookupState()
+	ruleSet, loaded := state.ruleSetMap[tag]
 	return ruleSet, loaded
 }
 
@@ -899,6 +1036,7 @@ func (r *Router) NeedWIFIState() bool {
 }
 
 func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) error {
+	state := r.state.Load()
 	if r.pauseManager.IsDevicePaused() {
 		return E.New("reject connection to ", metadata.Destination, " while device paused")
 	}
@@ -907,7 +1045,7 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
-		detour := r.inboundByTag[metadata.InboundDetour]
+		detour := state.inboundByTag[metadata.InboundDetour]
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -1005,13 +1143,20 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 	} else if metadata.Destination.IsIPv6() {
 		metadata.IPVersion = 6
 	}
-	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.defaultOutboundForConnection)
+	ctx, matchedRule, detour, err := r.match(ctx, &metadata, state.defaultOutboundForConnection)
 	if err != nil {
 		return err
 	}
+	if detour == nil {
+		return r.routeConnectionAction(ctx, conn, metadata, matchedRule)
+	}
 	if !common.Contains(detour.Network(), N.NetworkTCP) {
 		return E.New("missing supported outbound, closing connection")
 	}
+	conn, err = state.trafficLimiter.RoutedConnection(ctx, conn, metadata)
+	if err != nil {
+		return err
+	}
 	if r.clashServer != nil {
 		trackerConn, tracker := r.clashServer.RoutedConnection(ctx, conn, metadata, matchedRule)
 		defer tracker.Leave()
@@ -1022,10 +1167,14 @@ func (r *Router) RouteConnection(ctx context.Context, conn net.Conn, metadata ad
 			conn = statsService.RoutedConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
 		}
 	}
+	if r.metricsServer != nil {
+		conn = r.metricsServer.RoutedConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
+	}
 	return detour.NewConnection(ctx, conn, metadata)
 }
 
 func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) error {
+	state := r.state.Load()
 	var fakeipOverride, destOverride bool
 	if r.pauseManager.IsDevicePaused() {
 		return E.New("reject packet connection to ", metadata.Destination, " while device paused")
@@ -1034,7 +1183,7 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 		if metadata.LastInbound == metadata.InboundDetour {
 			return E.New("routing loop on detour: ", metadata.InboundDetour)
 		}
-		detour := r.inboundByTag[metadata.InboundDetour]
+		detour := state.inboundByTag[metadata.InboundDetour]
 		if detour == nil {
 			return E.New("inbound detour not found: ", metadata.InboundDetour)
 		}
@@ -1131,13 +1280,20 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 	} else if metadata.Destination.IsIPv6() {
 		metadata.IPVersion = 6
 	}
-	ctx, matchedRule, detour, err := r.match(ctx, &metadata, r.defaultOutboundForPacketConnection)
+	ctx, matchedRule, detour, err := r.match(ctx, &metadata, state.defaultOutboundForPacketConnection)
 	if err != nil {
 		return err
 	}
+	if detour == nil {
+		return r.routePacketConnectionAction(ctx, conn, metadata, matchedRule)
+	}
 	if !common.Contains(detour.Network(), N.NetworkUDP) {
 		return E.New("missing supported outbound, closing packet connection")
 	}
+	conn, err = state.trafficLimiter.RoutedPacketConnection(ctx, conn, metadata)
+	if err != nil {
+		return err
+	}
 	if r.clashServer != nil {
 		trackerConn, tracker := r.clashServer.RoutedPacketConnection(ctx, conn, metadata, matchedRule)
 		defer tracker.Leave()
@@ -1148,6 +1304,9 @@ func (r *Router) RoutePacketConnection(ctx context.Context, conn N.PacketConn, m
 			conn = statsService.RoutedPacketConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
 		}
 	}
+	if r.metricsServer != nil {
+		conn = r.metricsServer.RoutedPacketConnection(metadata.Inbound, detour.Tag(), metadata.User, conn)
+	}
 	if destOverride {
 		conn = bufio.NewNATPacketConn(bufio.NewNetPacketConn(conn), metadata.OriginDestination, metadata.Destination)
 	}
@@ -1186,6 +1345,9 @@ func (r *Router) mustResolve(detour adapter.Outbound, metadata *adapter.InboundC
 
 func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound) (context.Context, adapter.Rule, adapter.Outbound, error) {
 	matchRule, matchOutbound := r.match0(ctx, metadata, defaultOutbound)
+	if matchOutbound == nil {
+		return ctx, matchRule, nil, nil
+	}
 	if contextOutbound, loaded := O.TagFromContext(ctx); loaded {
 		if contextOutbound == matchOutbound.Tag() {
 			return nil, nil, nil, E.New("connection loopback in outbound/", matchOutbound.Type(), "[", matchOutbound.Tag(), "]")
@@ -1196,6 +1358,7 @@ func (r *Router) match(ctx context.Context, metadata *adapter.InboundContext, de
 }
 
 func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, defaultOutbound adapter.Outbound) (adapter.Rule, adapter.Outbound) {
+	state := r.state.Load()
 	if r.processSearcher != nil {
 		var originDestination netip.AddrPort
 		if metadata.OriginDestination.IsValid() {
@@ -1233,11 +1396,11 @@ func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, d
 	}
 	var outbound adapter.Outbound
 	defer func() {
-		if resolveStatus == 1 && !r.mustUseIP(outbound, metadata) {
+		if resolveStatus == 1 && (outbound == nil || !r.mustUseIP(outbound, metadata)) {
 			metadata.DestinationAddresses = []netip.Addr{}
 		}
 	}()
-	for i, rule := range r.rules {
+	for i, rule := range state.rules {
 		if rule.Disabled() {
 			continue
 		}
@@ -1253,16 +1416,29 @@ func (r *Router) match0(ctx context.Context, metadata *adapter.InboundContext, d
 			metadata.ResetRuleCache()
 		}
 		if rule.Match(metadata) {
+			if action := rule.Action(); action != C.RuleActionTypeRoute {
+				r.logger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", action)
+				if r.metricsServer != nil {
+					r.metricsServer.RuleMatched(i, action)
+				}
+				return rule, nil
+			}
 			detour := rule.Outbound()
 			r.logger.DebugContext(ctx, "match[", i, "] ", rule.String(), " => ", detour)
 			var loaded bool
-			if outbound, loaded = r.Outbound(detour); loaded {
+			if outbound, loaded = state.outboundByTag[detour]; loaded {
+				if r.metricsServer != nil {
+					r.metricsServer.RuleMatched(i, detour)
+				}
 				return rule, outbound
 			}
 			r.logger.ErrorContext(ctx, "outbound not found: ", detour)
 		}
 	}
 	outbound = defaultOutbound
+	if r.metricsServer != nil {
+		r.metricsServer.RuleMatched(-1, outbound.Tag())
+	}
 	return nil, outbound
 }
 
@@ -1344,25 +1520,30 @@ func (r *Router) DefaultMark() int {
 }
 
 func (r *Router) Rules() []adapter.Rule {
-	return r.rules
+	state := r.state.Load()
+	return state.rules
 }
 
 func (r *Router) Rule(uuid string) (adapter.Rule, bool) {
-	rule, exists := r.routeRuleByUUID[uuid]
+	state := r.state.Load()
+	rule, exists := state.routeRuleByUUID[uuid]
 	return rule, exists
 }
 
 func (r *Router) DNSRules() []adapter.DNSRule {
-	return r.dnsRules
+	state := r.state.Load()
+	return state.dnsRules
 }
 
 func (r *Router) DNSRule(uuid string) (adapter.DNSRule, bool) {
-	rule, exists := r.dnsRuleByUUID[uuid]
+	state := r.state.Load()
+	rule, exists := state.dnsRuleByUUID[uuid]
 	return rule, exists
 }
 
 func (r *Router) DefaultDNSServers() []string {
-	return common.Map(r.defaultTransports, func(it dns.Transport) string {
+	state := r.state.Load()
+	return common.Map(state.defaultTransports, func(it dns.Transport) string {
 		return it.Name()
 	})
 }
@@ -1399,6 +1580,14 @@ func (r *Router) SetV2RayServer(server adapter.V2RayServer) {
 	r.v2rayServer = server
 }
 
+func (r *Router) MetricsServer() adapter.MetricsServer {
+	return r.metricsServer
+}
+
+func (r *Router) SetMetricsServer(server adapter.MetricsServer) {
+	r.metricsServer = server
+}
+
 func (r *Router) OnPackagesUpdated(packages int, sharedUsers int) {
 	r.logger.Info("updated packages list: ", packages, " packages, ", sharedUsers, " shared users")
 }
@@ -1439,23 +1628,24 @@ func (r *Router) notifyNetworkUpdate(event int) {
 }
 
 func (r *Router) ResetNetwork() error {
+	state := r.state.Load()
 	conntrack.Close()
 
-	for _, provider := range r.outboundProviders {
+	for _, provider := range state.outboundProviders {
 		listener, isListener := provider.(adapter.InterfaceUpdateListener)
 		if isListener {
 			listener.InterfaceUpdated()
 		}
 	}
 
-	for _, outbound := range r.outbounds {
+	for _, outbound := range state.outbounds {
 		listener, isListener := outbound.(adapter.InterfaceUpdateListener)
 		if isListener {
 			listener.InterfaceUpdated()
 		}
 	}
 
-	for _, transport := range r.transports {
+	for _, transport := range state.transports {
 		transport.Reset()
 	}
 
@@ -0,0 +1,126 @@
+package route
+
+import (
+	"context"
+	"io"
+	"net"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing/common"
+	"github.com/sagernet/sing/common/buf"
+	"github.com/sagernet/sing/common/canceler"
+	E "github.com/sagernet/sing/common/exceptions"
+	N "github.com/sagernet/sing/common/network"
+)
+
+const (
+	dropTimeout = time.Minute
+	// dropLimit bounds connections being dropped at once, later connections are closed at once
+	// instead of holding more goroutines.
+	dropLimit = 256
+)
+
+func (r *Router) routeConnectionAction(ctx context.Context, conn net.Conn, metadata adapter.InboundContext, rule adapter.Rule) error {
+	switch rule.Action() {
+	case C.RuleActionTypeReject:
+		if rule.RejectMethod() == C.RuleActionRejectMethodReply && metadata.Protocol == C.ProtocolHTTP {
+			r.logger.InfoContext(ctx, "rejected connection to ", metadata.Destination, " with HTTP 204")
+			return replyNoContent(conn)
+		}
+		r.logger.InfoContext(ctx, "rejected connection to ", metadata.Destination)
+		return resetConnection(conn, metadata)
+	case C.RuleActionTypeDrop:
+		r.logger.InfoContext(ctx, "dropped connection to ", metadata.Destination)
+		return r.dropConnection(conn)
+	case C.RuleActionTypeHijackDNS:
+		r.logger.InfoContext(ctx, "hijacked connection to ", metadata.Destination, " to dns")
+		return r.dnsHijacker.NewConnection(ctx, conn, metadata)
+	default:
+		conn.Close()
+		return E.New("unknown rule action: ", rule.Action())
+	}
+}
+
+func (r *Router) routePacketConnectionAction(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext, rule adapter.Rule) error {
+	switch rule.Action() {
+	case C.RuleActionTypeReject:
+		r.logger.InfoContext(ctx, "rejected packet connection to ", metadata.Destination)
+		conn.Close()
+		if metadata.InboundType == C.TypeTun {
+			// tun answers with ICMP port unreachable.
+			return C.ErrRejected
+		}
+		return nil
+	case C.RuleActionTypeDrop:
+		r.logger.InfoContext(ctx, "dropped packet connection to ", metadata.Destination)
+		return r.dropPacketConnection(ctx, conn)
+	case C.RuleActionTypeHijackDNS:
+		r.logger.InfoContext(ctx, "hijacked packet connection to ", metadata.Destination, " to dns")
+		return r.dnsHijacker.NewPacketConnection(ctx, conn, metadata)
+	default:
+		conn.Close()
+		return E.New("unknown rule action: ", rule.Action())
+	}
+}
+
+// resetConnection closes a TCP connection with RST instead of FIN where the
+// underlying socket is reachable, so clients fail fast instead of retrying.
+// Connections of tun are left to the stack, which resets them on error.
+func resetConnection(conn net.Conn, metadata adapter.InboundContext) error {
+	if metadata.InboundType == C.TypeTun {
+		return C.ErrRejected
+	}
+	if tcpConn, isTCPConn := common.Cast[*net.TCPConn](conn); isTCPConn {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+	return nil
+}
+
+func replyNoContent(conn net.Conn) error {
+	defer conn.Close()
+	_, err := conn.Write([]byte("HTTP/1.1 204 No Content\r\nContent-Length: 0\r\nConnection: close\r\n\r\n"))
+	return err
+}
+
+// dropConnection discards everything the client sends without answering,
+// until the client gives up or dropTimeout elapses.
+func (r *Router) dropConnection(conn net.Conn) error {
+	defer conn.Close()
+	if !r.acquireDrop() {
+		return nil
+	}
+	defer r.droppedConns.Add(-1)
+	conn.SetReadDeadline(time.Now().Add(dropTimeout))
+	io.Copy(io.Discard, conn)
+	return nil
+}
+
+func (r *Router) dropPacketConnection(ctx context.Context, conn N.PacketConn) error {
+	if !r.acquireDrop() {
+		return conn.Close()
+	}
+	defer r.droppedConns.Add(-1)
+	_, conn = canceler.NewPacketConn(ctx, conn, dropTimeout)
+	defer conn.Close()
+	buffer := buf.NewPacket()
+	defer buffer.Release()
+	for {
+		buffer.Reset()
+		_, err := conn.ReadPacket(buffer)
+		if err != nil {
+			return nil
+		}
+	}
+}
+
+func (r *Router) acquireDrop() bool {
+	if r.droppedConns.Add(1) > dropLimit {
+		r.droppedConns.Add(-1)
+		r.logger.Debug("too many dropped connections, closing")
+		return false
+	}
+	return true
+}
@@ -38,22 +38,31 @@ func (m *DNSReverseMapping) Query(address netip.Addr) (string, bool) {
 }
 
 func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (context.Context, []dns.Transport, adapter.DNSRule, int, bool) {
+	state := r.state.Load()
 	metadata := adapter.ContextFrom(ctx)
 	if metadata == nil {
 		panic("no context")
 	}
-	if index < len(r.dnsRules) {
-		dnsRules := r.dnsRules
+	if index < len(state.dnsRules) {
+		dnsRules := state.dnsRules
 		if index != -1 {
 			dnsRules = dnsRules[index+1:]
 		}
 		for currentRuleIndex, rule := range dnsRules {
 			metadata.ResetRuleCache()
 			if rule.Match(metadata) {
+				ruleIndex := currentRuleIndex
+				if index != -1 {
+					ruleIndex += index + 1
+				}
+				if rule.Action() == C.RuleActionTypeReject {
+					r.dnsLogger.DebugContext(ctx, "match[", ruleIndex, "] ", rule.String(), " => reject")
+					return ctx, nil, rule, ruleIndex, false
+				}
 				var transports []dns.Transport
 				var detours []string
 				for _, detour := range rule.Servers() {
-					transport, loaded := r.transportMap[detour]
+					transport, loaded := state.transportMap[detour]
 					if !loaded {
 						r.dnsLogger.ErrorContext(ctx, "transport not found: ", detour)
 						continue
@@ -68,10 +77,6 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (con
 				if isFakeIP && !allowFakeIP {
 					continue
 				}
-				ruleIndex := currentRuleIndex
-				if index != -1 {
-					ruleIndex += index + 1
-				}
 				detour := detours[0]
 				if len(detours) > 1 {
 					detour = "[" + strings.Join(detours, " ") + "]"
@@ -90,33 +95,41 @@ func (r *Router) matchDNS(ctx context.Context, allowFakeIP bool, index int) (con
 			}
 		}
 	}
-	return ctx, r.defaultTransports, nil, -1, false
+	return ctx, state.defaultTransports, nil, -1, false
 }
 
 func (r *Router) GetStrategy(transport dns.Transport) uint8 {
-	if domainStrategy, dsLoaded := r.transportDomainStrategy[transport]; dsLoaded {
+	state := r.state.Load()
+	if domainStrategy, dsLoaded := state.transportDomainStrategy[transport]; dsLoaded {
 		return domainStrategy
 	}
 	return r.defaultDomainStrategy
 }
 
 type dnsRes struct {
-	res *mDNS.Msg
-	err error
-	rej bool
+	res       *mDNS.Msg
+	err       error
+	rej       bool
+	transport string
 }
 
 func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	state := r.state.Load()
 	if len(message.Question) > 0 {
 		r.dnsLogger.DebugContext(ctx, "exchange ", formatQuestion(message.Question[0].String()))
 	}
 	var (
-		response *mDNS.Msg
-		cached   bool
-		isFakeIP bool
-		err      error
+		response    *mDNS.Msg
+		cached      bool
+		isFakeIP    bool
+		err         error
+		queryRecord *dnsQueryRecord
 	)
+	if len(message.Question) > 0 {
+		queryRecord = r.newDNSQueryRecord(ctx, fqdnToDomain(message.Question[0].Name), mDNS.TypeToString[message.Question[0].Qtype])
+	}
 	defer func() {
+		r.logDNSExchange(queryRecord, response, err, cached)
 		if err == nil && !isFakeIP && r.dnsReverseMapping != nil && len(message.Question) > 0 && response != nil && len(response.Answer) > 0 {
 			for _, answer := range response.Answer {
 				switch record := answer.(type) {
@@ -128,9 +141,6 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 			}
 		}
 	}()
-	if response, cached = r.dnsClient.ExchangeCache(ctx, message); cached {
-		return response, nil
-	}
 	ctx, metadata := adapter.AppendContext(ctx)
 	if len(message.Question) > 0 {
 		metadata.QueryType = message.Question[0].Qtype
@@ -143,6 +153,7 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 		metadata.Domain = fqdnToDomain(message.Question[0].Name)
 	}
 
+	var cacheKey *dnsCacheKey
 	ruleIndex := -1
 	for {
 		var (
@@ -150,53 +161,72 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 			rule       adapter.DNSRule
 			dnsCtx     context.Context
 		)
+		cacheKey = nil
 		dnsCtx, transports, rule, ruleIndex, isFakeIP = r.matchDNS(ctx, true, ruleIndex)
-		resChan := make(chan dnsRes, len(transports))
+		queryRecord.matchRule(ruleIndex, rule, isFakeIP)
+		if rule != nil {
+			if localResponse, loaded := rule.LocalResponse(message); loaded {
+				response = localResponse
+				break
+			}
+		}
+		// Responses are cached unfiltered, rules with response filters apply them to cached responses
+		// and do not save their filtered responses.
+		withResponseFilter := rule != nil && rule.WithResponseFilter()
 		addressLimit := rule != nil && rule.WithAddressLimit() && isAddressQuery(message)
-		for _, transport := range transports {
-			go func(rawDnsCtx context.Context, transport dns.Transport) {
-				var res dnsRes
-				defer func() {
-					resChan <- res
-				}()
-				strategy := r.GetStrategy(transport)
-				dnsCtx, cancel := context.WithTimeout(rawDnsCtx, C.DNSTimeout)
-				if addressLimit {
-					res.res, res.err = r.dnsClient.ExchangeWithResponseCheck(dnsCtx, transport, message, strategy, func(response *mDNS.Msg) bool {
-						metadata.DestinationAddresses, _ = dns.MessageToAddresses(response)
-						return rule.MatchAddressLimit(metadata)
-					})
-				} else {
-					res.res, res.err = r.dnsClient.Exchange(dnsCtx, transport, message, strategy)
+		if !addressLimit {
+			if response, cached = r.dnsClient.ExchangeCache(dnsCtx, message); cached {
+				if withResponseFilter {
+					rule.FilterResponse(response)
 				}
-				cancel()
-				if res.err == nil {
-					return
-				} else if errors.Is(res.err, dns.ErrResponseRejectedCached) {
-					res.rej = true
-					r.dnsLogger.DebugContext(ctx, E.Cause(res.err, "response rejected for ", formatQuestion(message.Question[0].String())), " (cached)")
-				} else if errors.Is(res.err, dns.ErrResponseRejected) {
-					res.rej = true
-					r.dnsLogger.DebugContext(ctx, E.Cause(res.err, "response rejected for ", formatQuestion(message.Question[0].String())))
-				} else if len(message.Question) > 0 {
-					r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "exchange failed for ", formatQuestion(message.Question[0].String())))
-				} else {
-					r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "exchange failed for <empty query>"))
-				}
-			}(dnsCtx, transport)
-		}
-		var res *dnsRes
-		for i := 0; i < len(transports); i++ {
-			cRes := <-resChan
-			if cRes.err != context.DeadlineExceeded || res == nil {
-				res = &cRes
+				break
 			}
-			if cRes.err == nil || cRes.err == context.DeadlineExceeded {
+		}
+		if r.dnsCache.Enabled() && len(message.Question) == 1 && len(transports) > 0 && !dns.DisableCacheFromContext(dnsCtx) {
+			key := r.dnsCache.Key(message.Question[0], transports[0])
+			if responses, staleKeys, loaded := r.dnsCache.Load(key); loaded && !addressLimit {
+				response = responses[0]
+				response.Id = message.Id
+				cached = true
+				if withResponseFilter {
+					rule.FilterResponse(response)
+				}
+				r.refreshDNSCache(ctx, staleKeys)
 				break
 			}
+			if !withResponseFilter {
+				cacheKey = &key
+			}
 		}
+		var addressChecker func(response *mDNS.Msg) bool
+		if addressLimit {
+			addressChecker = func(response *mDNS.Msg) bool {
+				metadata.DestinationAddresses, _ = dns.MessageToAddresses(response)
+				return rule.MatchAddressLimit(metadata)
+			}
+		}
+		res := queryDNSTransports(r, dnsCtx, rule, transports, message, func(dnsCtx context.Context, transport dns.Transport) dnsRes {
+			res := dnsRes{transport: transport.Name()}
+			strategy := r.GetStrategy(transport)
+			res.res, res.err = r.exchangeWithRule(dnsCtx, transport, message, strategy, rule, addressChecker)
+			if res.err == nil {
+				return res
+			} else if errors.Is(res.err, dns.ErrResponseRejectedCached) {
+				res.rej = true
+				r.dnsLogger.DebugContext(ctx, E.Cause(res.err, "response rejected for ", formatQuestion(message.Question[0].String())), " (cached)")
+			} else if errors.Is(res.err, dns.ErrResponseRejected) {
+				res.rej = true
+				r.dnsLogger.DebugContext(ctx, E.Cause(res.err, "response rejected for ", formatQuestion(message.Question[0].String())))
+			} else if len(message.Question) > 0 {
+				r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "exchange failed for ", formatQuestion(message.Question[0].String())))
+			} else {
+				r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "exchange failed for <empty query>"))
+			}
+			return res
+		})
 		response = res.res
 		err = res.err
+		queryRecord.exchanged(res.transport, false)
 		if rule == nil {
 			break
 		} else if addressLimit && res.rej {
@@ -218,7 +248,7 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 		}
 		var fbTransports []dns.Transport
 		for _, server := range servers {
-			if transport, loaded := r.transportMap[server]; loaded {
+			if transport, loaded := state.transportMap[server]; loaded {
 				fbTransports = append(fbTransports, transport)
 				continue
 			}
@@ -233,13 +263,13 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 		fbResChan := make(chan dnsRes, len(fbTransports))
 		for _, transport := range fbTransports {
 			go func(rawDnsCtx context.Context, transport dns.Transport) {
-				var res dnsRes
+				res := dnsRes{transport: transport.Name()}
 				defer func() {
 					fbResChan <- res
 				}()
 				strategy := r.GetStrategy(transport)
 				dnsCtx, cancel := context.WithTimeout(rawDnsCtx, C.DNSTimeout)
-				res.res, res.err = r.dnsClient.Exchange(dnsCtx, transport, message, strategy)
+				res.res, res.err = r.exchangeWithRule(dnsCtx, transport, message, strategy, rule, nil)
 				cancel()
 				if res.err == nil {
 					return
@@ -250,34 +280,65 @@ func (r *Router) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, er
 				}
 			}(dnsCtx, transport)
 		}
-		res = nil
+		var fbRes *dnsRes
 		for i := 0; i < len(fbTransports); i++ {
 			cRes := <-fbResChan
-			if cRes.err != context.DeadlineExceeded || res == nil {
-				res = &cRes
+			if cRes.err != context.DeadlineExceeded || fbRes == nil {
+				fbRes = &cRes
 			}
 			if cRes.err == nil || cRes.err == context.DeadlineExceeded {
 				break
 			}
 		}
-		response = res.res
-		err = res.err
+		response = fbRes.res
+		err = fbRes.err
+		queryRecord.exchanged(fbRes.transport, true)
 		break
 	}
+	if err == nil && cacheKey != nil && !isFakeIP {
+		r.dnsCache.Save(*cacheKey, response)
+	}
 	return response, err
 }
 
+// exchangeWithRule filters a copy of the response with the rule, so that the client caches it unfiltered
+// for other rules, responses loaded from the cache are filtered as well.
+func (r *Router) exchangeWithRule(ctx context.Context, transport dns.Transport, message *mDNS.Msg, strategy dns.DomainStrategy, rule adapter.DNSRule, addressChecker func(response *mDNS.Msg) bool) (*mDNS.Msg, error) {
+	if rule == nil || !rule.WithResponseFilter() {
+		if addressChecker != nil {
+			return r.dnsClient.ExchangeWithResponseCheck(ctx, r.observedTransport(transport), message, strategy, addressChecker)
+		}
+		return r.dnsClient.Exchange(ctx, r.observedTransport(transport), message, strategy)
+	}
+	var responseChecker func(response *mDNS.Msg) bool
+	if addressChecker != nil {
+		responseChecker = func(response *mDNS.Msg) bool {
+			response = response.Copy()
+			rule.FilterResponse(response)
+			return addressChecker(response)
+		}
+	}
+	response, err := r.dnsClient.ExchangeWithResponseCheck(ctx, r.observedTransport(transport), message, strategy, responseChecker)
+	if err != nil {
+		return response, err
+	}
+	response = response.Copy()
+	rule.FilterResponse(response)
+	return response, nil
+}
+
 type dnsAddr struct {
-	addrs []netip.Addr
-	err   error
-	rej   bool
+	addrs     []netip.Addr
+	responses []*mDNS.Msg
+	err       error
+	rej       bool
+	transport string
 }
 
 func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
-	if responseAddrs, cached := r.dnsClient.LookupCache(ctx, domain, strategy); cached {
-		return responseAddrs, nil
-	}
+	state := r.state.Load()
 	r.dnsLogger.DebugContext(ctx, "lookup domain ", domain)
+	queryRecord := r.newDNSQueryRecord(ctx, domain, lookupQueryType(strategy))
 	ctx, metadata := adapter.AppendContext(ctx)
 	metadata.Domain = domain
 	defer metadata.ResetRuleCache()
@@ -285,6 +346,9 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 		responseAddrs []netip.Addr
 		err           error
 	)
+	defer func() {
+		r.logDNSLookup(queryRecord, responseAddrs, err)
+	}()
 	ruleIndex := -1
 	for {
 		var (
@@ -295,55 +359,82 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 		metadata.ResetRuleCache()
 		metadata.DestinationAddresses = nil
 		dnsCtx, transports, rule, ruleIndex, _ = r.matchDNS(ctx, false, ruleIndex)
-		resChan := make(chan dnsAddr, len(transports))
+		queryRecord.matchRule(ruleIndex, rule, false)
+		if rule != nil && rule.Action() == C.RuleActionTypeReject {
+			localResponse, _ := rule.LocalResponse(&mDNS.Msg{Question: []mDNS.Question{{Name: mDNS.Fqdn(domain), Qtype: mDNS.TypeA, Qclass: mDNS.ClassINET}}})
+			err = dns.RCodeError(localResponse.Rcode)
+			if err == dns.RCodeSuccess {
+				err = dns.RCodeNameError
+			}
+			break
+		}
+		// Responses are cached unfiltered, rules with response filters apply them to cached addresses.
 		addressLimit := rule != nil && rule.WithAddressLimit()
-		for _, transport := range transports {
-			go func(rawDNSCtx context.Context, transport dns.Transport) {
-				var res dnsAddr
-				defer func() {
-					resChan <- res
-				}()
-				strategy := r.GetStrategy(transport)
-				dnsCtx, cancel := context.WithTimeout(rawDNSCtx, C.DNSTimeout)
-				if addressLimit {
-					res.addrs, res.err = r.dnsClient.LookupWithResponseCheck(dnsCtx, transport, domain, strategy, func(addrs []netip.Addr) bool {
-						metadata.DestinationAddresses = addrs
-						return rule.MatchAddressLimit(metadata)
-					})
-				} else {
-					res.addrs, res.err = r.dnsClient.Lookup(dnsCtx, transport, domain, strategy)
-				}
-				cancel()
-				if res.err != nil {
-					if errors.Is(res.err, dns.ErrResponseRejectedCached) {
-						res.rej = true
-						r.dnsLogger.DebugContext(ctx, "response rejected for ", domain, " (cached)")
-					} else if errors.Is(res.err, dns.ErrResponseRejected) {
-						res.rej = true
-						r.dnsLogger.DebugContext(ctx, "response rejected for ", domain)
-					} else {
-						r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "lookup failed for ", domain))
-					}
-				} else if len(res.addrs) == 0 {
-					r.dnsLogger.ErrorContext(ctx, "lookup failed for ", domain, ": empty result")
-					res.err = dns.RCodeNameError
-				} else {
-					r.dnsLogger.DebugContext(ctx, "lookup succeed for ", domain, ": ", strings.Join(F.MapToString(res.addrs), " "))
+		if !addressLimit {
+			if addresses, cached := r.dnsClient.LookupCache(dnsCtx, domain, strategy); cached {
+				if rule != nil {
+					addresses = rule.FilterAddresses(addresses)
 				}
-			}(dnsCtx, transport)
-		}
-		var res *dnsAddr
-		for i := 0; i < len(transports); i++ {
-			cRes := <-resChan
-			if cRes.err != context.DeadlineExceeded || res == nil {
-				res = &cRes
+				responseAddrs = addresses
+				queryRecord.cacheHit()
+				break
 			}
-			if cRes.err == nil || cRes.err == context.DeadlineExceeded {
+		}
+		saveCache := r.dnsCache.Enabled() && len(transports) > 0 && !dns.DisableCacheFromContext(dnsCtx) && !addressLimit
+		if saveCache {
+			if addresses, loaded := r.lookupDNSCache(ctx, domain, strategy, transports[0]); loaded {
+				if rule != nil {
+					addresses = rule.FilterAddresses(addresses)
+				}
+				responseAddrs = addresses
+				queryRecord.cacheHit()
 				break
 			}
 		}
+		res := queryDNSTransports(r, dnsCtx, rule, transports, lookupProbeMessage(domain, strategy), func(dnsCtx context.Context, transport dns.Transport) dnsAddr {
+			res := dnsAddr{transport: transport.Name()}
+			strategy := r.GetStrategy(transport)
+			if addressLimit {
+				res.addrs, res.err = r.dnsClient.LookupWithResponseCheck(dnsCtx, r.observedTransport(transport), domain, strategy, func(addrs []netip.Addr) bool {
+					metadata.DestinationAddresses = rule.FilterAddresses(addrs)
+					return rule.MatchAddressLimit(metadata)
+				})
+			} else if saveCache {
+				res.addrs, res.responses, res.err = r.lookupExchange(dnsCtx, transport, domain, strategy)
+			} else {
+				res.addrs, res.err = r.dnsClient.Lookup(dnsCtx, r.observedTransport(transport), domain, strategy)
+			}
+			if res.err == nil && rule != nil {
+				res.addrs = rule.FilterAddresses(res.addrs)
+			}
+			if res.err != nil {
+				if errors.Is(res.err, dns.ErrResponseRejectedCached) {
+					res.rej = true
+					r.dnsLogger.DebugContext(ctx, "response rejected for ", domain, " (cached)")
+				} else if errors.Is(res.err, dns.ErrResponseRejected) {
+					res.rej = true
+					r.dnsLogger.DebugContext(ctx, "response rejected for ", domain)
+				} else {
+					r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "lookup failed for ", domain))
+				}
+			} else if len(res.addrs) == 0 {
+				r.dnsLogger.ErrorContext(ctx, "lookup failed for ", domain, ": empty result")
+				res.err = dns.RCodeNameError
+			} else {
+				r.dnsLogger.DebugContext(ctx, "lookup succeed for ", domain, ": ", strings.Join(F.MapToString(res.addrs), " "))
+			}
+			return res
+		})
 		responseAddrs = res.addrs
 		err = res.err
+		queryRecord.exchanged(res.transport, false)
+		// Saved unfiltered per query type, as exchanged responses are, under the key lookupDNSCache loads.
+		for _, response := range res.responses {
+			if len(response.Question) != 1 {
+				continue
+			}
+			r.dnsCache.Save(r.dnsCache.Key(response.Question[0], transports[0]), response)
+		}
 		if rule == nil {
 			break
 		} else if addressLimit && res.rej || errors.Is(err, dns.RCodeNameError) {
@@ -369,7 +460,7 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 			}
 			fbTransports = make([]dns.Transport, 0)
 			for _, server := range servers {
-				transport, loaded := r.transportMap[server]
+				transport, loaded := state.transportMap[server]
 				if !loaded {
 					r.dnsLogger.ErrorContext(ctx, "transport not found: ", server)
 					continue
@@ -390,13 +481,16 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 		fbResChan := make(chan dnsAddr, len(fbTransports))
 		for _, transport := range fbTransports {
 			go func(rawDnsCtx context.Context, transport dns.Transport) {
-				var res dnsAddr
+				res := dnsAddr{transport: transport.Name()}
 				defer func() {
 					fbResChan <- res
 				}()
 				strategy := r.GetStrategy(transport)
 				dnsCtx, cancel := context.WithTimeout(rawDnsCtx, C.DNSTimeout)
-				res.addrs, res.err = r.dnsClient.Lookup(dnsCtx, transport, domain, strategy)
+				res.addrs, res.err = r.dnsClient.Lookup(dnsCtx, r.observedTransport(transport), domain, strategy)
+				if res.err == nil {
+					res.addrs = rule.FilterAddresses(res.addrs)
+				}
 				cancel()
 				if res.err != nil {
 					r.dnsLogger.ErrorContext(ctx, E.Cause(res.err, "lookup failed for ", domain))
@@ -408,16 +502,19 @@ func (r *Router) Lookup(ctx context.Context, domain string, strategy dns.DomainS
 				}
 			}(dnsCtx, transport)
 		}
-		res = nil
+		var fbRes *dnsAddr
 		for i := 0; i < len(fbTransports); i++ {
 			cRes := <-fbResChan
-			if cRes.err != context.DeadlineExceeded || res == nil {
-				res = &cRes
+			if cRes.err != context.DeadlineExceeded || fbRes == nil {
+				fbRes = &cRes
 			}
 			if cRes.err == nil || cRes.err == context.DeadlineExceeded {
 				break
 			}
 		}
+		responseAddrs = fbRes.addrs
+		err = fbRes.err
+		queryRecord.exchanged(fbRes.transport, true)
 		break
 	}
 	if err == nil {
@@ -430,13 +527,39 @@ func (r *Router) LookupDefault(ctx context.Context, domain string) ([]netip.Addr
 	return r.Lookup(ctx, domain, dns.DomainStrategyAsIS)
 }
 
+func (r *Router) observeDNSExchange(transport dns.Transport, start time.Time, err error) {
+	latency := time.Since(start)
+	r.dnsTransportStats.Observe(transport, latency, err)
+	if r.metricsServer == nil {
+		return
+	}
+	r.metricsServer.DNSExchanged(transport.Name(), latency, err)
+}
+
+func lookupProbeMessage(domain string, strategy dns.DomainStrategy) *mDNS.Msg {
+	queryType := mDNS.TypeA
+	if strategy == dns.DomainStrategyUseIPv6 {
+		queryType = mDNS.TypeAAAA
+	}
+	message := new(mDNS.Msg)
+	message.SetQuestion(mDNS.Fqdn(domain), queryType)
+	return message
+}
+
 func (r *Router) ClearDNSCache() {
-	r.dnsClient.ClearCache()
+	r.clearDNSCache()
 	if r.platformInterface != nil {
 		r.platformInterface.ClearDNSCache()
 	}
 }
 
+func (r *Router) clearDNSCache() {
+	r.dnsClient.ClearCache()
+	if r.dnsCache != nil {
+		r.dnsCache.Clear()
+	}
+}
+
 func isAddressQuery(message *mDNS.Msg) bool {
 	for _, question := range message.Question {
 		if question.Qtype == mDNS.TypeA || question.Qtype == mDNS.TypeAAAA {
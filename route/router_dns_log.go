@@ -0,0 +1,122 @@
+package route
+
+import (
+	"context"
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	F "github.com/sagernet/sing/common/format"
+
+	mDNS "github.com/miekg/dns"
+)
+
+// dnsQueryRecord collects the query log record while a query is handled,
+// it is nil if the query logger is not enabled and its methods do nothing then.
+type dnsQueryRecord struct {
+	adapter.DNSQueryLog
+	start time.Time
+}
+
+func (r *Router) newDNSQueryRecord(ctx context.Context, domain string, queryType string) *dnsQueryRecord {
+	if r.dnsQueryLogger == nil || !r.dnsQueryLogger.Enabled() {
+		return nil
+	}
+	record := &dnsQueryRecord{
+		DNSQueryLog: adapter.DNSQueryLog{
+			Time:      time.Now(),
+			Domain:    domain,
+			Type:      queryType,
+			RuleIndex: -1,
+		},
+		start: time.Now(),
+	}
+	if metadata := adapter.ContextFrom(ctx); metadata != nil {
+		if metadata.Source.IsValid() {
+			record.Client = metadata.Source.String()
+		}
+		record.Inbound = metadata.Inbound
+	}
+	return record
+}
+
+func (q *dnsQueryRecord) matchRule(ruleIndex int, rule adapter.DNSRule, isFakeIP bool) {
+	if q == nil {
+		return
+	}
+	q.RuleIndex = ruleIndex
+	q.Rule = ""
+	if rule != nil {
+		q.Rule = rule.String()
+	}
+	q.Transport = ""
+	q.FakeIP = isFakeIP
+}
+
+func (q *dnsQueryRecord) exchanged(transport string, fallback bool) {
+	if q == nil {
+		return
+	}
+	q.Transport = transport
+	q.Fallback = fallback
+}
+
+func (q *dnsQueryRecord) cacheHit() {
+	if q == nil {
+		return
+	}
+	q.Cached = true
+}
+
+func (q *dnsQueryRecord) finish(err error) {
+	q.Latency = time.Since(q.start).Milliseconds()
+	if err == nil {
+		return
+	}
+	if rcodeError, isRCodeError := err.(dns.RCodeError); isRCodeError {
+		q.RCode = mDNS.RcodeToString[int(rcodeError)]
+	} else {
+		q.Error = err.Error()
+	}
+}
+
+func (r *Router) logDNSExchange(record *dnsQueryRecord, response *mDNS.Msg, err error, cached bool) {
+	if record == nil {
+		return
+	}
+	record.Cached = cached
+	record.finish(err)
+	if err == nil && response != nil {
+		record.RCode = mDNS.RcodeToString[response.Rcode]
+		record.Answers = common.Map(response.Answer, func(it mDNS.RR) string {
+			header := it.Header()
+			return mDNS.TypeToString[header.Rrtype] + " " + it.String()[len(header.String()):]
+		})
+	}
+	r.dnsQueryLogger.Log(record.DNSQueryLog)
+}
+
+func (r *Router) logDNSLookup(record *dnsQueryRecord, addresses []netip.Addr, err error) {
+	if record == nil {
+		return
+	}
+	record.finish(err)
+	if err == nil {
+		record.RCode = mDNS.RcodeToString[mDNS.RcodeSuccess]
+		record.Answers = F.MapToString(addresses)
+	}
+	r.dnsQueryLogger.Log(record.DNSQueryLog)
+}
+
+func lookupQueryType(strategy dns.DomainStrategy) string {
+	switch strategy {
+	case dns.DomainStrategyUseIPv4:
+		return "A"
+	case dns.DomainStrategyUseIPv6:
+		return "AAAA"
+	default:
+		return "A+AAAA"
+	}
+}
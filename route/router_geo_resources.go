@@ -133,6 +133,7 @@ func (r *Router) prepareGeositeDatabase() error {
 }
 
 func (r *Router) downloadGeoIPDatabase(savePath string) error {
+	state := r.state.Load()
 	var downloadURL string
 	if r.geoIPOptions.DownloadURL != "" {
 		downloadURL = r.geoIPOptions.DownloadURL
@@ -148,7 +149,7 @@ func (r *Router) downloadGeoIPDatabase(savePath string) error {
 		}
 		detour = outbound
 	} else {
-		detour = r.defaultOutboundForConnection
+		detour = state.defaultOutboundForConnection
 	}
 
 	if parentDir := filepath.Dir(savePath); parentDir != "" {
@@ -188,6 +189,7 @@ func (r *Router) downloadGeoIPDatabase(savePath string) error {
 }
 
 func (r *Router) downloadGeositeDatabase(savePath string) error {
+	state := r.state.Load()
 	var downloadURL string
 	if r.geositeOptions.DownloadURL != "" {
 		downloadURL = r.geositeOptions.DownloadURL
@@ -203,7 +205,7 @@ func (r *Router) downloadGeositeDatabase(savePath string) error {
 		}
 		detour = outbound
 	} else {
-		detour = r.defaultOutboundForConnection
+		detour = state.defaultOutboundForConnection
 	}
 
 	if parentDir := filepath.Dir(savePath); parentDir != "" {
@@ -0,0 +1,469 @@
+package route
+
+import (
+	"context"
+	"reflect"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/hosts"
+	"github.com/sagernet/sing-dns"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+	"github.com/sagernet/sing/common/task"
+)
+
+// ReloadOutbounds stages the inbounds, outbound providers and outbounds of a reload, the default
+// outbounds are picked again with final. Outbounds and providers being started by the caller find
+// them by tag, while connections are still routed by the current state until Reload publishes them.
+func (r *Router) ReloadOutbounds(final string, inbounds []adapter.Inbound, outboundProviders []adapter.OutboundProvider, outbounds []adapter.Outbound) error {
+	state := *r.state.Load()
+	state.defaultDetour = final
+	err := r.initializeOutbounds(&state, inbounds, outboundProviders, outbounds)
+	if err != nil {
+		return err
+	}
+	r.stagedState.Store(&state)
+	return nil
+}
+
+// CancelReload discards the state staged by a reload which failed before being published.
+func (r *Router) CancelReload() {
+	r.stagedState.Store(nil)
+}
+
+// Reload rebuilds the rule-sets, rules, DNS servers and DNS rules whose options changed,
+// and keeps the others running with their current state.
+// reloadedOutbounds contains tags of outbounds recreated by the caller,
+// DNS servers and rule-sets using them as detour are recreated too.
+// The new state is published with the outbounds staged by ReloadOutbounds once everything started,
+// the current state is left untouched if any step fails.
+func (r *Router) Reload(options option.RouteOptions, dnsOptions option.DNSOptions, inbounds []option.Inbound, reloadedOutbounds map[string]bool) error {
+	defer r.CancelReload()
+	oldState := r.state.Load()
+	state := *r.lookupState()
+	err := r.checkReload(options, dnsOptions, inbounds)
+	if err != nil {
+		return err
+	}
+	state.trafficLimiter, err = newTrafficLimiter(r.ctx, r.logger, inbounds, oldState.trafficLimiter)
+	if err != nil {
+		return err
+	}
+	ruleSets, ruleSetMap, reloadedRuleSets, err := r.reloadRuleSets(oldState, options.RuleSet, reloadedOutbounds)
+	if err != nil {
+		return err
+	}
+	usesReloadedRuleSet := func(tags []string) bool {
+		return common.Any(tags, func(tag string) bool {
+			return reloadedRuleSets[tag]
+		})
+	}
+	needRuleReload := func(rules []option.Rule, oldRules []option.Rule) bool {
+		return !reflect.DeepEqual(rules, oldRules) || hasRule(rules, func(rule option.DefaultRule) bool {
+			return usesReloadedRuleSet(rule.RuleSet)
+		})
+	}
+	var (
+		newRules         []adapter.Rule
+		rules            = make([]adapter.Rule, 0, len(options.Rules))
+		routeRuleByUUID  = make(map[string]adapter.Rule)
+		sniffRules       = make(map[string][]adapter.Rule)
+		newDNSRules      []adapter.DNSRule
+		dnsRules         = make([]adapter.DNSRule, 0, len(dnsOptions.Rules))
+		dnsRuleByUUID    = make(map[string]adapter.DNSRule)
+		oldSniffRules    = make(map[string][]option.Rule)
+		closeOnError     []adapter.Rule
+		closeNewOnFailed = func() {
+			for _, rule := range closeOnError {
+				rule.Close()
+			}
+			for _, ruleSet := range ruleSets {
+				if !common.Contains(oldState.ruleSets, ruleSet) {
+					ruleSet.Close()
+				}
+			}
+			if state.dnsHosts != oldState.dnsHosts {
+				common.Close(state.dnsHosts)
+			}
+		}
+	)
+	for i, ruleOptions := range options.Rules {
+		var routeRule adapter.Rule
+		if i >= len(r.options.Rules) || needRuleReload([]option.Rule{ruleOptions}, r.options.Rules[i:i+1]) {
+			routeRule, err = NewRule(r, r.logger, ruleOptions, true)
+			if err != nil {
+				closeNewOnFailed()
+				return E.Cause(err, "parse rule[", i, "]")
+			}
+			newRules = append(newRules, routeRule)
+			closeOnError = append(closeOnError, routeRule)
+		} else {
+			routeRule = oldState.rules[i]
+		}
+		rules = append(rules, routeRule)
+		routeRuleByUUID[routeRule.UUID()] = routeRule
+	}
+	for _, inboundOptions := range r.inboundOptions {
+		oldSniffRules[inboundOptions.Tag] = inboundOptions.GetSniffOverrideRules()
+	}
+	for i, inboundOptions := range inbounds {
+		tag := inboundOptions.Tag
+		rawRules := inboundOptions.GetSniffOverrideRules()
+		oldRawRules, loaded := oldSniffRules[tag]
+		if currentRules, hasCurrent := oldState.sniffOverrideRules[tag]; loaded && hasCurrent && !needRuleReload(rawRules, oldRawRules) {
+			sniffRules[tag] = currentRules
+			continue
+		}
+		var inboundRules []adapter.Rule
+		for j, ruleOptions := range rawRules {
+			sniffOverrideRule, err := NewRule(r, r.logger, ruleOptions, false)
+			if err != nil {
+				closeNewOnFailed()
+				return E.Cause(err, "parse inbound[", i, "] sniff_override_rule[", j, "]")
+			}
+			inboundRules = append(inboundRules, sniffOverrideRule)
+			newRules = append(newRules, sniffOverrideRule)
+			closeOnError = append(closeOnError, sniffOverrideRule)
+		}
+		sniffRules[tag] = inboundRules
+	}
+	for i, dnsRuleOptions := range dnsOptions.Rules {
+		var dnsRule adapter.DNSRule
+		if i < len(r.dnsOptions.Rules) && reflect.DeepEqual(dnsRuleOptions, r.dnsOptions.Rules[i]) && !hasDNSRule([]option.DNSRule{dnsRuleOptions}, func(rule option.DefaultDNSRule) bool {
+			return usesReloadedRuleSet(rule.RuleSet)
+		}) {
+			dnsRule = oldState.dnsRules[i]
+		} else {
+			dnsRule, err = NewDNSRule(r, r.logger, dnsRuleOptions, true)
+			if err != nil {
+				closeNewOnFailed()
+				return E.Cause(err, "parse dns rule[", i, "]")
+			}
+			newDNSRules = append(newDNSRules, dnsRule)
+			closeOnError = append(closeOnError, dnsRule)
+		}
+		dnsRules = append(dnsRules, dnsRule)
+		dnsRuleByUUID[dnsRule.UUID()] = dnsRule
+	}
+	state.ruleSets = ruleSets
+	state.ruleSetMap = ruleSetMap
+	state.rules = rules
+	state.routeRuleByUUID = routeRuleByUUID
+	state.sniffOverrideRules = sniffRules
+	state.dnsRules = dnsRules
+	state.dnsRuleByUUID = dnsRuleByUUID
+	// Rules being started find the reloaded rule-sets by tag.
+	r.stagedState.Store(&state)
+
+	if len(newRules) > 0 || len(newDNSRules) > 0 {
+		if hasRule(options.Rules, isGeositeRule) || hasDNSRule(dnsOptions.Rules, isGeositeDNSRule) || common.Any(inbounds, func(it option.Inbound) bool {
+			return hasRule(it.GetSniffOverrideRules(), isGeositeRule)
+		}) {
+			err = r.prepareGeositeDatabase()
+			if err != nil {
+				closeNewOnFailed()
+				return err
+			}
+			r.geositeCache = make(map[string]adapter.Rule)
+			for _, rule := range newRules {
+				err = rule.UpdateGeosite()
+				if err != nil {
+					r.logger.Error("failed to initialize geosite: ", err)
+				}
+			}
+			for _, rule := range newDNSRules {
+				err = rule.UpdateGeosite()
+				if err != nil {
+					r.logger.Error("failed to initialize geosite: ", err)
+				}
+			}
+			common.Close(r.geositeReader)
+			r.geositeCache = nil
+			r.geositeReader = nil
+		}
+	}
+	for i, rule := range newRules {
+		err = rule.Start()
+		if err != nil {
+			closeNewOnFailed()
+			return E.Cause(err, "initialize rule[", i, "]")
+		}
+	}
+	for i, rule := range newDNSRules {
+		err = rule.Start()
+		if err != nil {
+			closeNewOnFailed()
+			return E.Cause(err, "initialize DNS rule[", i, "]")
+		}
+	}
+
+	state.dnsHosts, err = r.reloadDNSHosts(oldState, dnsOptions.Hosts)
+	if err != nil {
+		state.dnsHosts = oldState.dnsHosts
+		closeNewOnFailed()
+		return err
+	}
+	startedTransports, err := r.reloadDNSTransports(&state, dnsOptions, reloadedOutbounds)
+	if err != nil {
+		closeNewOnFailed()
+		return err
+	}
+	closeTransportsOnFailed := func() {
+		for _, transport := range startedTransports {
+			transport.Close()
+		}
+		closeNewOnFailed()
+	}
+	err = checkRuleOutbounds(&state)
+	if err != nil {
+		closeTransportsOnFailed()
+		return err
+	}
+	for _, ruleSet := range ruleSets {
+		if common.Contains(oldState.ruleSets, ruleSet) {
+			continue
+		}
+		err = ruleSet.PostStart()
+		if err != nil {
+			closeTransportsOnFailed()
+			return E.Cause(err, "post start rule-set[", ruleSet.Tag(), "]")
+		}
+	}
+
+	state.trafficLimiter.Start()
+	r.state.Store(&state)
+	r.stagedState.Store(nil)
+	r.options = options
+	r.dnsOptions = dnsOptions
+	r.inboundOptions = inbounds
+
+	for _, ruleSet := range oldState.ruleSets {
+		if !common.Contains(ruleSets, ruleSet) {
+			ruleSet.Close()
+		}
+	}
+	for _, rule := range oldState.rules {
+		if !common.Contains(rules, rule) {
+			rule.Close()
+		}
+	}
+	for _, rule := range oldState.dnsRules {
+		if !common.Contains(dnsRules, rule) {
+			rule.Close()
+		}
+	}
+	for tag, inboundRules := range oldState.sniffOverrideRules {
+		for _, rule := range inboundRules {
+			if !common.Contains(sniffRules[tag], rule) {
+				rule.Close()
+			}
+		}
+	}
+	if state.dnsHosts != oldState.dnsHosts {
+		common.Close(oldState.dnsHosts)
+	}
+	oldState.trafficLimiter.Close()
+	for _, transport := range oldState.transports {
+		if !common.Contains(state.transports, transport) {
+			transport.Close()
+			r.dnsTransportStats.Remove(transport)
+		}
+	}
+	// Cached responses were resolved by the old rules and servers, and may be answered by others now.
+	dnsRulesChanged := len(newDNSRules) > 0 || len(dnsRules) != len(oldState.dnsRules) || !reflect.DeepEqual(state.defaultTransports, oldState.defaultTransports)
+	if dnsRulesChanged || state.dnsHosts != oldState.dnsHosts || len(startedTransports) > 0 || !reflect.DeepEqual(state.transports, oldState.transports) {
+		r.clearDNSCache()
+	}
+	if len(startedTransports) > 0 {
+		r.logger.Info("reloaded ", len(startedTransports), " dns servers")
+	}
+	r.logger.Info("reloaded ", len(newRules), " rules, ", len(newDNSRules), " dns rules, ", len(reloadedRuleSets), " rule-sets")
+	return nil
+}
+
+func (r *Router) checkReload(options option.RouteOptions, dnsOptions option.DNSOptions, inbounds []option.Inbound) error {
+	needFindProcess := hasRule(options.Rules, isProcessRule) || hasDNSRule(dnsOptions.Rules, isProcessDNSRule) || options.FindProcess
+	if needFindProcess && !r.needFindProcess && r.processSearcher == nil {
+		return E.Extend(C.ErrRestartRequired, "process rules added")
+	}
+	needWIFIState := hasRule(options.Rules, isWIFIRule) || hasDNSRule(dnsOptions.Rules, isWIFIDNSRule)
+	if needWIFIState && !r.needWIFIState && r.platformInterface != nil {
+		return E.Extend(C.ErrRestartRequired, "WIFI rules added")
+	}
+	needInterfaceMonitor := options.AutoDetectInterface || common.Any(inbounds, func(inbound option.Inbound) bool {
+		return inbound.HTTPOptions.SetSystemProxy || inbound.MixedOptions.SetSystemProxy || inbound.TunOptions.AutoRoute
+	})
+	if needInterfaceMonitor && r.interfaceMonitor == nil {
+		return E.Extend(C.ErrRestartRequired, "interface monitor required")
+	}
+	needPackageManager := C.IsAndroid && r.platformInterface == nil && common.Any(inbounds, func(inbound option.Inbound) bool {
+		return len(inbound.TunOptions.IncludePackage) > 0 || len(inbound.TunOptions.ExcludePackage) > 0
+	})
+	if needPackageManager && r.packageManager == nil {
+		return E.Extend(C.ErrRestartRequired, "package manager required")
+	}
+	if !reflect.DeepEqual(dnsOptions.QueryLog, r.dnsOptions.QueryLog) {
+		return E.Extend(C.ErrRestartRequired, "DNS query log changed")
+	}
+	if !reflect.DeepEqual(dnsOptions.Optimistic, r.dnsOptions.Optimistic) {
+		return E.Extend(C.ErrRestartRequired, "optimistic DNS cache changed")
+	}
+	needGeoIPDatabase := hasRule(options.Rules, isGeoIPRule) || hasDNSRule(dnsOptions.Rules, isGeoIPDNSRule) || hasDNSFallbackRuleUseGeoIP(dnsOptions.Rules) || common.Any(inbounds, func(it option.Inbound) bool {
+		return hasRule(it.GetSniffOverrideRules(), isGeoIPRule)
+	})
+	if needGeoIPDatabase && r.geoIPReader == nil {
+		err := r.prepareGeoIPDatabase()
+		if err != nil {
+			return err
+		}
+		r.needGeoIPDatabase = true
+	}
+	return nil
+}
+
+func (r *Router) reloadRuleSets(oldState *routerState, ruleSetOptions []option.RuleSet, reloadedOutbounds map[string]bool) ([]adapter.RuleSet, map[string]adapter.RuleSet, map[string]bool, error) {
+	oldOptions := make(map[string]option.RuleSet)
+	for _, it := range r.options.RuleSet {
+		oldOptions[it.Tag] = it
+	}
+	var (
+		ruleSets    []adapter.RuleSet
+		newRuleSets []adapter.RuleSet
+		ruleSetMap  = make(map[string]adapter.RuleSet)
+		reloaded    = make(map[string]bool)
+	)
+	for i, it := range ruleSetOptions {
+		if _, exists := ruleSetMap[it.Tag]; exists {
+			return nil, nil, nil, E.New("duplicate rule-set tag: ", it.Tag)
+		}
+		if current, loaded := oldState.ruleSetMap[it.Tag]; loaded && reflect.DeepEqual(oldOptions[it.Tag], it) && !reloadedOutbounds[it.RemoteOptions.DownloadDetour] {
+			ruleSets = append(ruleSets, current)
+			ruleSetMap[it.Tag] = current
+			continue
+		}
+		ruleSet, err := NewRuleSet(r.ctx, r, r.logger, it)
+		if err != nil {
+			for _, created := range newRuleSets {
+				created.Close()
+			}
+			return nil, nil, nil, E.Cause(err, "parse rule-set[", i, "]")
+		}
+		ruleSets = append(ruleSets, ruleSet)
+		newRuleSets = append(newRuleSets, ruleSet)
+		ruleSetMap[it.Tag] = ruleSet
+		reloaded[it.Tag] = true
+	}
+	for tag := range oldState.ruleSetMap {
+		if _, loaded := ruleSetMap[tag]; !loaded {
+			reloaded[tag] = true
+		}
+	}
+	if len(newRuleSets) > 0 {
+		ruleSetStartContext := NewRuleSetStartContext()
+		var ruleSetStartGroup task.Group
+		for _, ruleSet := range newRuleSets {
+			ruleSetInPlace := ruleSet
+			ruleSetStartGroup.Append0(func(ctx context.Context) error {
+				err := ruleSetInPlace.StartContext(ctx, ruleSetStartContext)
+				if err != nil {
+					return E.Cause(err, "initialize rule-set[", ruleSetInPlace.Tag(), "]")
+				}
+				return nil
+			})
+		}
+		ruleSetStartGroup.Concurrency(5)
+		ruleSetStartGroup.FastFail()
+		err := ruleSetStartGroup.Run(r.ctx)
+		ruleSetStartContext.Close()
+		if err != nil {
+			for _, created := range newRuleSets {
+				created.Close()
+			}
+			return nil, nil, nil, err
+		}
+	}
+	return ruleSets, ruleSetMap, reloaded, nil
+}
+
+// reloadDNSHosts returns a new hosts store if its options changed, or the current one,
+// the previous store is closed by the caller once the reload succeeds.
+func (r *Router) reloadDNSHosts(oldState *routerState, options *option.DNSHostsOptions) (adapter.DNSHostsStore, error) {
+	if reflect.DeepEqual(options, r.dnsOptions.Hosts) {
+		return oldState.dnsHosts, nil
+	}
+	if options == nil {
+		return nil, nil
+	}
+	store, err := hosts.NewStore(r.ctx, r.dnsLogger, *options, r.clearDNSCache)
+	if err != nil {
+		return nil, E.Cause(err, "parse hosts")
+	}
+	err = store.Start()
+	if err != nil {
+		store.Close()
+		return nil, E.Cause(err, "initialize hosts")
+	}
+	return store, nil
+}
+
+// reloadDNSTransports rebuilds the DNS transports into state if their options changed and returns
+// the started new transports, the replaced transports are closed by the caller once the reload succeeds.
+func (r *Router) reloadDNSTransports(state *routerState, dnsOptions option.DNSOptions, reloadedOutbounds map[string]bool) ([]dns.Transport, error) {
+	if reflect.DeepEqual(dnsOptions.Servers, r.dnsOptions.Servers) && reflect.DeepEqual(dnsOptions.Final, r.dnsOptions.Final) && !common.Any(dnsOptions.Servers, func(it option.DNSServerOptions) bool {
+		return reloadedOutbounds[it.Detour]
+	}) {
+		return nil, nil
+	}
+	oldOptions := make(map[string]option.DNSServerOptions)
+	for i, server := range r.dnsOptions.Servers {
+		tag := server.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		oldOptions[tag] = server
+	}
+	oldTransports := state.transports
+	previous := make(map[string]dns.Transport)
+	for i, server := range dnsOptions.Servers {
+		tag := server.Tag
+		if tag == "" {
+			tag = F.ToString(i)
+		}
+		transport, loaded := state.transportByTag[tag]
+		if !loaded || !reflect.DeepEqual(oldOptions[tag], server) || reloadedOutbounds[server.Detour] {
+			continue
+		}
+		previous[tag] = transport
+	}
+	err := r.createDNSTransports(state, dnsOptions, previous)
+	if err != nil {
+		return nil, err
+	}
+	var started []dns.Transport
+	closeStarted := func() {
+		for _, it := range started {
+			it.Close()
+		}
+	}
+	for i, transport := range state.transports {
+		if common.Contains(oldTransports, transport) {
+			continue
+		}
+		err = transport.Start()
+		if err != nil {
+			closeStarted()
+			return nil, E.Cause(err, "initialize DNS server[", i, "]")
+		}
+		started = append(started, transport)
+	}
+	for _, transport := range state.defaultTransports {
+		if _, isRCode := transport.(*dns.RCodeTransport); isRCode && len(state.defaultTransports) > 1 {
+			closeStarted()
+			return nil, E.New("initialize default dns servers failed: rcode server can only be used stand-alone")
+		}
+	}
+	return started, nil
+}
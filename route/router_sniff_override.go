@@ -7,7 +7,8 @@ import (
 )
 
 func (r *Router) matchSniffOverride(ctx context.Context, metadata *adapter.InboundContext) bool {
-	rules := r.sniffOverrideRules[metadata.Inbound]
+	state := r.state.Load()
+	rules := state.sniffOverrideRules[metadata.Inbound]
 	if len(rules) == 0 {
 		r.overrideLogger.DebugContext(ctx, "match all")
 		return true
@@ -0,0 +1,43 @@
+package route
+
+import (
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+)
+
+// routerState is the part of the router replaced by reloads, it is published as a whole
+// so that connections and DNS queries never see a partially reloaded router.
+// A published state is never modified, reloads copy it and replace the fields they rebuild.
+type routerState struct {
+	inboundByTag                       map[string]adapter.Inbound
+	outbounds                          []adapter.Outbound
+	outboundByTag                      map[string]adapter.Outbound
+	outboundProviders                  []adapter.OutboundProvider
+	outboundProviderByTag              map[string]adapter.OutboundProvider
+	defaultDetour                      string
+	defaultOutboundForConnection       adapter.Outbound
+	defaultOutboundForPacketConnection adapter.Outbound
+	rules                              []adapter.Rule
+	routeRuleByUUID                    map[string]adapter.Rule
+	sniffOverrideRules                 map[string][]adapter.Rule
+	dnsRules                           []adapter.DNSRule
+	dnsRuleByUUID                      map[string]adapter.DNSRule
+	ruleSets                           []adapter.RuleSet
+	ruleSetMap                         map[string]adapter.RuleSet
+	defaultTransports                  []dns.Transport
+	transports                         []dns.Transport
+	transportMap                       map[string]dns.Transport
+	transportByTag                     map[string]dns.Transport
+	transportDomainStrategy            map[dns.Transport]dns.DomainStrategy
+	dnsHosts                           adapter.DNSHostsStore
+	trafficLimiter                     *trafficLimiter
+}
+
+// lookupState returns the state staged by a running reload if any, so that outbounds, providers
+// and rules being started by the reload find each other before they become routable.
+func (r *Router) lookupState() *routerState {
+	if state := r.stagedState.Load(); state != nil {
+		return state
+	}
+	return r.state.Load()
+}
@@ -69,6 +69,8 @@ type abstractRule struct {
 	invert        bool
 	ruleCount     int
 	outbound      string
+	action        string
+	rejectMethod  string
 	skipResolve   bool
 	fallbackRules []FallbackRule
 }
@@ -85,6 +87,20 @@ func (r *abstractRule) ChangeStatus() {
 	r.disabled = !r.disabled
 }
 
+func (r *abstractRule) Action() string {
+	if r.action == "" {
+		return C.RuleActionTypeRoute
+	}
+	return r.action
+}
+
+func (r *abstractRule) RejectMethod() string {
+	if r.rejectMethod == "" {
+		return C.RuleActionRejectMethodDefault
+	}
+	return r.rejectMethod
+}
+
 func (r *abstractRule) RuleCount() int {
 	return r.ruleCount
 }
@@ -15,16 +15,18 @@ func NewRule(router adapter.Router, logger log.ContextLogger, options option.Rul
 		if !options.DefaultOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if options.DefaultOptions.Outbound == "" && checkOutbound {
-			return nil, E.New("missing outbound field")
+		err := validateRuleAction(options.DefaultOptions.Action, options.DefaultOptions.Outbound, options.DefaultOptions.Method, checkOutbound)
+		if err != nil {
+			return nil, err
 		}
 		return NewDefaultRule(router, logger, options.DefaultOptions)
 	case C.RuleTypeLogical:
 		if !options.LogicalOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if options.LogicalOptions.Outbound == "" && checkOutbound {
-			return nil, E.New("missing outbound field")
+		err := validateRuleAction(options.LogicalOptions.Action, options.LogicalOptions.Outbound, options.LogicalOptions.Method, checkOutbound)
+		if err != nil {
+			return nil, err
 		}
 		return NewLogicalRule(router, logger, options.LogicalOptions)
 	default:
@@ -32,6 +34,31 @@ func NewRule(router adapter.Router, logger log.ContextLogger, options option.Rul
 	}
 }
 
+func validateRuleAction(action string, outbound string, method string, checkOutbound bool) error {
+	switch action {
+	case "", C.RuleActionTypeRoute:
+		if outbound == "" && checkOutbound {
+			return E.New("missing outbound field")
+		}
+	case C.RuleActionTypeReject, C.RuleActionTypeDrop, C.RuleActionTypeHijackDNS:
+		if outbound != "" {
+			return E.New("outbound is not allowed for ", action, " action")
+		}
+	default:
+		return E.New("unknown rule action: ", action)
+	}
+	switch method {
+	case "":
+	case C.RuleActionRejectMethodDefault, C.RuleActionRejectMethodReply:
+		if action != C.RuleActionTypeReject {
+			return E.New("method is only available for reject action")
+		}
+	default:
+		return E.New("unknown reject method: ", method)
+	}
+	return nil
+}
+
 var _ adapter.Rule = (*DefaultRule)(nil)
 
 type DefaultRule struct {
@@ -48,11 +75,13 @@ func NewDefaultRule(router adapter.Router, logger log.ContextLogger, options opt
 	rule := &DefaultRule{
 		abstractDefaultRule{
 			abstractRule: abstractRule{
-				uuid:        id.String(),
-				tag:         options.Tag,
-				invert:      options.Invert,
-				outbound:    options.Outbound,
-				skipResolve: options.SkipResolve,
+				uuid:         id.String(),
+				tag:          options.Tag,
+				invert:       options.Invert,
+				outbound:     options.Outbound,
+				action:       options.Action,
+				rejectMethod: options.Method,
+				skipResolve:  options.SkipResolve,
 			},
 		},
 	}
@@ -211,6 +240,25 @@ func NewDefaultRule(router adapter.Router, logger log.ContextLogger, options opt
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if options.Timezone != "" && len(options.TimeRange) == 0 && len(options.Weekday) == 0 {
+		return nil, E.New("timezone: missing time_range or weekday")
+	}
+	if len(options.TimeRange) > 0 {
+		item, err := NewTimeRangeItem(options.TimeRange, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "time_range")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.Weekday) > 0 {
+		item, err := NewWeekdayItem(options.Weekday, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "weekday")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(options.RuleSet) > 0 {
 		item := NewRuleSetItem(router, options.RuleSet, options.RuleSetIPCIDRMatchSource)
 		rule.items = append(rule.items, item)
@@ -231,11 +279,13 @@ func NewLogicalRule(router adapter.Router, logger log.ContextLogger, options opt
 	r := &LogicalRule{
 		abstractLogicalRule{
 			abstractRule: abstractRule{
-				uuid:        id.String(),
-				tag:         options.Tag,
-				invert:      options.Invert,
-				outbound:    options.Outbound,
-				skipResolve: options.SkipResolve,
+				uuid:         id.String(),
+				tag:          options.Tag,
+				invert:       options.Invert,
+				outbound:     options.Outbound,
+				action:       options.Action,
+				rejectMethod: options.Method,
+				skipResolve:  options.SkipResolve,
 			},
 			rules: make([]adapter.HeadlessRule, len(options.Rules)),
 		},
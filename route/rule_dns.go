@@ -2,6 +2,7 @@ package route
 
 import (
 	"net/netip"
+	"time"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/sagernet/sing-box/adapter"
@@ -58,16 +59,18 @@ func NewDNSRule(router adapter.Router, logger log.ContextLogger, options option.
 		if len(options.FallBackRules) == 0 && !options.DefaultOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if len(options.DefaultOptions.Server) == 0 && checkServer {
-			return nil, E.New("missing server field")
+		err = validateDNSRuleAction(defaultDNSRuleActionOptions(options.DefaultOptions), options.DefaultOptions.Server, checkServer)
+		if err != nil {
+			return nil, err
 		}
 		return NewDefaultDNSRule(router, logger, options.DefaultOptions, fallbackRules)
 	case C.RuleTypeLogical:
 		if !options.LogicalOptions.IsValid() {
 			return nil, E.New("missing conditions")
 		}
-		if len(options.LogicalOptions.Server) == 0 && checkServer {
-			return nil, E.New("missing server field")
+		err = validateDNSRuleAction(logicalDNSRuleActionOptions(options.LogicalOptions), options.LogicalOptions.Server, checkServer)
+		if err != nil {
+			return nil, err
 		}
 		return NewLogicalDNSRule(router, logger, options.LogicalOptions, fallbackRules)
 	default:
@@ -75,10 +78,39 @@ func NewDNSRule(router adapter.Router, logger log.ContextLogger, options option.
 	}
 }
 
+func defaultDNSRuleActionOptions(options option.DefaultDNSRule) dnsRuleActionOptions {
+	return dnsRuleActionOptions{
+		action:          options.Action,
+		rcode:           options.RCode,
+		stripType:       options.StripType,
+		dropIPCIDR:      options.DropIPCIDR,
+		dropIPIsPrivate: options.DropIPIsPrivate,
+		dropRuleSet:     options.DropRuleSet,
+		maxAddresses:    options.MaxAddresses,
+		strategy:        options.Strategy,
+		serverTimeout:   time.Duration(options.ServerTimeout),
+	}
+}
+
+func logicalDNSRuleActionOptions(options option.LogicalDNSRule) dnsRuleActionOptions {
+	return dnsRuleActionOptions{
+		action:          options.Action,
+		rcode:           options.RCode,
+		stripType:       options.StripType,
+		dropIPCIDR:      options.DropIPCIDR,
+		dropIPIsPrivate: options.DropIPIsPrivate,
+		dropRuleSet:     options.DropRuleSet,
+		maxAddresses:    options.MaxAddresses,
+		strategy:        options.Strategy,
+		serverTimeout:   time.Duration(options.ServerTimeout),
+	}
+}
+
 var _ adapter.DNSRule = (*DefaultDNSRule)(nil)
 
 type DefaultDNSRule struct {
 	abstractDefaultRule
+	dnsRuleAction
 	router       adapter.Router
 	disableCache bool
 	rewriteTTL   *uint32
@@ -88,19 +120,25 @@ type DefaultDNSRule struct {
 
 func NewDefaultDNSRule(router adapter.Router, logger log.ContextLogger, options option.DefaultDNSRule, fallbackRules []FallbackRule) (*DefaultDNSRule, error) {
 	id, _ := uuid.NewV4()
+	action, err := newDNSRuleAction(router, defaultDNSRuleActionOptions(options))
+	if err != nil {
+		return nil, err
+	}
 	rule := &DefaultDNSRule{
 		abstractDefaultRule: abstractDefaultRule{
 			abstractRule: abstractRule{
 				uuid:          id.String(),
 				invert:        options.Invert,
 				fallbackRules: fallbackRules,
+				action:        options.Action,
 			},
 		},
-		router:       router,
-		disableCache: options.DisableCache,
-		rewriteTTL:   options.RewriteTTL,
-		servers:      options.Server,
-		clientSubnet: (*netip.Addr)(options.ClientSubnet),
+		dnsRuleAction: action,
+		router:        router,
+		disableCache:  options.DisableCache,
+		rewriteTTL:    options.RewriteTTL,
+		servers:       options.Server,
+		clientSubnet:  (*netip.Addr)(options.ClientSubnet),
 	}
 	if len(options.Inbound) > 0 {
 		item := NewInboundRule(options.Inbound)
@@ -267,6 +305,25 @@ func NewDefaultDNSRule(router adapter.Router, logger log.ContextLogger, options
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if options.Timezone != "" && len(options.TimeRange) == 0 && len(options.Weekday) == 0 {
+		return nil, E.New("timezone: missing time_range or weekday")
+	}
+	if len(options.TimeRange) > 0 {
+		item, err := NewTimeRangeItem(options.TimeRange, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "time_range")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.Weekday) > 0 {
+		item, err := NewWeekdayItem(options.Weekday, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "weekday")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(options.RuleSet) > 0 {
 		item := NewRuleSetItem(router, options.RuleSet, options.RuleSetIPCIDRMatchSource)
 		rule.items = append(rule.items, item)
@@ -327,6 +384,10 @@ func (r *DefaultDNSRule) Start() error {
 			return err
 		}
 	}
+	err := r.dnsRuleAction.start()
+	if err != nil {
+		return err
+	}
 	for _, server := range r.servers {
 		transport, loaded := r.router.Transport(server)
 		if !loaded {
@@ -360,6 +421,7 @@ var _ adapter.DNSRule = (*LogicalDNSRule)(nil)
 
 type LogicalDNSRule struct {
 	abstractLogicalRule
+	dnsRuleAction
 	router       adapter.Router
 	disableCache bool
 	rewriteTTL   *uint32
@@ -369,19 +431,25 @@ type LogicalDNSRule struct {
 
 func NewLogicalDNSRule(router adapter.Router, logger log.ContextLogger, options option.LogicalDNSRule, fallbackRules []FallbackRule) (*LogicalDNSRule, error) {
 	id, _ := uuid.NewV4()
+	action, err := newDNSRuleAction(router, logicalDNSRuleActionOptions(options))
+	if err != nil {
+		return nil, err
+	}
 	r := &LogicalDNSRule{
 		abstractLogicalRule: abstractLogicalRule{
 			abstractRule: abstractRule{
 				uuid:          id.String(),
 				invert:        options.Invert,
 				fallbackRules: fallbackRules,
+				action:        options.Action,
 			},
 			rules: make([]adapter.HeadlessRule, len(options.Rules)),
 		},
-		router:       router,
-		disableCache: options.DisableCache,
-		rewriteTTL:   options.RewriteTTL,
-		servers:      options.Server,
+		dnsRuleAction: action,
+		router:        router,
+		disableCache:  options.DisableCache,
+		rewriteTTL:    options.RewriteTTL,
+		servers:       options.Server,
 	}
 	switch options.Mode {
 	case C.LogicalTypeAnd:
@@ -471,6 +539,10 @@ func (r *LogicalDNSRule) Start() error {
 			return err
 		}
 	}
+	err := r.dnsRuleAction.start()
+	if err != nil {
+		return err
+	}
 	for _, server := range r.servers {
 		transport, loaded := r.router.Transport(server)
 		if !loaded {
@@ -0,0 +1,215 @@
+package route
+
+import (
+	"net/netip"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+
+	mDNS "github.com/miekg/dns"
+)
+
+type dnsRuleActionOptions struct {
+	action          string
+	rcode           string
+	stripType       []option.DNSQueryType
+	dropIPCIDR      []string
+	dropIPIsPrivate bool
+	dropRuleSet     []string
+	maxAddresses    int
+	strategy        string
+	serverTimeout   time.Duration
+}
+
+func validateDNSRuleAction(options dnsRuleActionOptions, servers []string, checkServer bool) error {
+	switch options.action {
+	case "", C.RuleActionTypeRoute:
+		if len(servers) == 0 && checkServer {
+			return E.New("missing server field")
+		}
+		if options.rcode != "" {
+			return E.New("rcode is only available for reject action")
+		}
+	case C.RuleActionTypeReject:
+		if len(servers) > 0 {
+			return E.New("server is not allowed for reject action")
+		}
+		switch options.rcode {
+		case "", C.RuleActionRCodeNXDomain, C.RuleActionRCodeNoData, C.RuleActionRCodeRefused:
+		default:
+			return E.New("unknown rcode: ", options.rcode)
+		}
+	default:
+		return E.New("unknown rule action: ", options.action)
+	}
+	switch options.strategy {
+	case "", C.DNSStrategyRace, C.DNSStrategySequential, C.DNSStrategyFastest:
+	default:
+		return E.New("unknown strategy: ", options.strategy)
+	}
+	if options.serverTimeout != 0 && options.strategy != C.DNSStrategySequential {
+		return E.New("server_timeout is only available for sequential strategy")
+	}
+	if options.maxAddresses < 0 {
+		return E.New("invalid max_addresses: ", options.maxAddresses)
+	}
+	return nil
+}
+
+// dnsRuleAction holds what a DNS rule does to the query and the response besides choosing servers.
+type dnsRuleAction struct {
+	reject       bool
+	rejectRCode  int
+	stripTypes   []uint16
+	dropItems    []RuleItem
+	maxAddresses int
+	strategy     string
+	timeout      time.Duration
+}
+
+func newDNSRuleAction(router adapter.Router, options dnsRuleActionOptions) (dnsRuleAction, error) {
+	action := dnsRuleAction{
+		reject:       options.action == C.RuleActionTypeReject,
+		maxAddresses: options.maxAddresses,
+		strategy:     options.strategy,
+		timeout:      options.serverTimeout,
+	}
+	switch options.rcode {
+	case "", C.RuleActionRCodeNXDomain:
+		action.rejectRCode = mDNS.RcodeNameError
+	case C.RuleActionRCodeNoData:
+		action.rejectRCode = mDNS.RcodeSuccess
+	case C.RuleActionRCodeRefused:
+		action.rejectRCode = mDNS.RcodeRefused
+	}
+	for _, queryType := range options.stripType {
+		action.stripTypes = append(action.stripTypes, uint16(queryType))
+	}
+	if len(options.dropIPCIDR) > 0 {
+		item, err := NewIPCIDRItem(false, options.dropIPCIDR)
+		if err != nil {
+			return dnsRuleAction{}, E.Cause(err, "drop_ip_cidr")
+		}
+		action.dropItems = append(action.dropItems, item)
+	}
+	if options.dropIPIsPrivate {
+		action.dropItems = append(action.dropItems, NewIPIsPrivateItem(false))
+	}
+	if len(options.dropRuleSet) > 0 {
+		action.dropItems = append(action.dropItems, NewRuleSetItem(router, options.dropRuleSet, false))
+	}
+	return action, nil
+}
+
+func (a *dnsRuleAction) ServerStrategy() string {
+	if a.strategy == "" {
+		return C.DNSStrategyRace
+	}
+	return a.strategy
+}
+
+func (a *dnsRuleAction) ServerTimeout() time.Duration {
+	if a.timeout == 0 {
+		return C.DNSSequentialTimeout
+	}
+	return a.timeout
+}
+
+func (a *dnsRuleAction) start() error {
+	for _, item := range a.dropItems {
+		err := common.Start(item)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *dnsRuleAction) LocalResponse(message *mDNS.Msg) (*mDNS.Msg, bool) {
+	rcode := a.rejectRCode
+	if !a.reject {
+		if len(message.Question) == 0 || !common.Contains(a.stripTypes, message.Question[0].Qtype) {
+			return nil, false
+		}
+		rcode = mDNS.RcodeSuccess
+	}
+	response := new(mDNS.Msg)
+	response.SetRcode(message, rcode)
+	response.RecursionAvailable = true
+	return response, true
+}
+
+func (a *dnsRuleAction) WithResponseFilter() bool {
+	return len(a.stripTypes) > 0 || len(a.dropItems) > 0 || a.maxAddresses > 0
+}
+
+func (a *dnsRuleAction) FilterResponse(response *mDNS.Msg) {
+	if len(a.stripTypes) > 0 {
+		stripRecord := func(it mDNS.RR) bool {
+			return !common.Contains(a.stripTypes, it.Header().Rrtype)
+		}
+		response.Answer = common.Filter(response.Answer, stripRecord)
+		response.Ns = common.Filter(response.Ns, stripRecord)
+		response.Extra = common.Filter(response.Extra, stripRecord)
+	}
+	if len(a.dropItems) == 0 && a.maxAddresses == 0 {
+		return
+	}
+	var addressCount int
+	response.Answer = common.Filter(response.Answer, func(it mDNS.RR) bool {
+		var address netip.Addr
+		switch record := it.(type) {
+		case *mDNS.A:
+			address = M.AddrFromIP(record.A).Unmap()
+		case *mDNS.AAAA:
+			address = M.AddrFromIP(record.AAAA)
+		default:
+			return true
+		}
+		if !a.acceptAddress(address, addressCount) {
+			return false
+		}
+		addressCount++
+		return true
+	})
+}
+
+func (a *dnsRuleAction) FilterAddresses(addresses []netip.Addr) []netip.Addr {
+	if !a.WithResponseFilter() {
+		return addresses
+	}
+	var filtered []netip.Addr
+	for _, address := range addresses {
+		if address.Is4() && common.Contains(a.stripTypes, mDNS.TypeA) || address.Is6() && common.Contains(a.stripTypes, mDNS.TypeAAAA) {
+			continue
+		}
+		if a.acceptAddress(address, len(filtered)) {
+			filtered = append(filtered, address)
+		}
+	}
+	return filtered
+}
+
+func (a *dnsRuleAction) acceptAddress(address netip.Addr, addressCount int) bool {
+	if a.maxAddresses > 0 && addressCount >= a.maxAddresses {
+		return false
+	}
+	if len(a.dropItems) == 0 {
+		return true
+	}
+	metadata := &adapter.InboundContext{
+		DestinationAddresses: []netip.Addr{address},
+	}
+	for _, item := range a.dropItems {
+		if item.Match(metadata) {
+			return false
+		}
+		metadata.ResetRuleCache()
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package route
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/option"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func testDNSResponse() *mDNS.Msg {
+	header := func(recordType uint16) mDNS.RR_Header {
+		return mDNS.RR_Header{Name: "example.com.", Rrtype: recordType, Class: mDNS.ClassINET, Ttl: 60}
+	}
+	response := new(mDNS.Msg)
+	response.Answer = []mDNS.RR{
+		&mDNS.CNAME{Hdr: header(mDNS.TypeCNAME), Target: "example.com."},
+		&mDNS.A{Hdr: header(mDNS.TypeA), A: net.ParseIP("1.1.1.1")},
+		&mDNS.A{Hdr: header(mDNS.TypeA), A: net.ParseIP("10.0.0.1")},
+		&mDNS.A{Hdr: header(mDNS.TypeA), A: net.ParseIP("8.8.8.8")},
+		&mDNS.AAAA{Hdr: header(mDNS.TypeAAAA), AAAA: net.ParseIP("2001:db8::1")},
+		&mDNS.AAAA{Hdr: header(mDNS.TypeAAAA), AAAA: net.ParseIP("fd00::1")},
+	}
+	return response
+}
+
+func answerStrings(response *mDNS.Msg) []string {
+	var answers []string
+	for _, record := range response.Answer {
+		switch it := record.(type) {
+		case *mDNS.A:
+			answers = append(answers, it.A.String())
+		case *mDNS.AAAA:
+			answers = append(answers, it.AAAA.String())
+		case *mDNS.CNAME:
+			answers = append(answers, "CNAME")
+		}
+	}
+	return answers
+}
+
+func TestDNSRuleActionFilter(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name      string
+		options   dnsRuleActionOptions
+		filter    bool
+		answers   []string
+		addresses []string
+	}{
+		{
+			name:      "none",
+			answers:   []string{"CNAME", "1.1.1.1", "10.0.0.1", "8.8.8.8", "2001:db8::1", "fd00::1"},
+			addresses: []string{"1.1.1.1", "10.0.0.1", "8.8.8.8", "2001:db8::1", "fd00::1"},
+		},
+		{
+			name:      "strip AAAA",
+			options:   dnsRuleActionOptions{stripType: []option.DNSQueryType{option.DNSQueryType(mDNS.TypeAAAA)}},
+			filter:    true,
+			answers:   []string{"CNAME", "1.1.1.1", "10.0.0.1", "8.8.8.8"},
+			addresses: []string{"1.1.1.1", "10.0.0.1", "8.8.8.8"},
+		},
+		{
+			name:      "strip CNAME",
+			options:   dnsRuleActionOptions{stripType: []option.DNSQueryType{option.DNSQueryType(mDNS.TypeCNAME)}},
+			filter:    true,
+			answers:   []string{"1.1.1.1", "10.0.0.1", "8.8.8.8", "2001:db8::1", "fd00::1"},
+			addresses: []string{"1.1.1.1", "10.0.0.1", "8.8.8.8", "2001:db8::1", "fd00::1"},
+		},
+		{
+			name:      "drop CIDR",
+			options:   dnsRuleActionOptions{dropIPCIDR: []string{"8.8.8.0/24", "2001:db8::/32"}},
+			filter:    true,
+			answers:   []string{"CNAME", "1.1.1.1", "10.0.0.1", "fd00::1"},
+			addresses: []string{"1.1.1.1", "10.0.0.1", "fd00::1"},
+		},
+		{
+			name:      "drop private",
+			options:   dnsRuleActionOptions{dropIPIsPrivate: true},
+			filter:    true,
+			answers:   []string{"CNAME", "1.1.1.1", "8.8.8.8", "2001:db8::1"},
+			addresses: []string{"1.1.1.1", "8.8.8.8", "2001:db8::1"},
+		},
+		{
+			name:      "max addresses",
+			options:   dnsRuleActionOptions{maxAddresses: 2},
+			filter:    true,
+			answers:   []string{"CNAME", "1.1.1.1", "10.0.0.1"},
+			addresses: []string{"1.1.1.1", "10.0.0.1"},
+		},
+		{
+			name:      "drop then limit",
+			options:   dnsRuleActionOptions{dropIPIsPrivate: true, maxAddresses: 2},
+			filter:    true,
+			answers:   []string{"CNAME", "1.1.1.1", "8.8.8.8"},
+			addresses: []string{"1.1.1.1", "8.8.8.8"},
+		},
+	} {
+		action, err := newDNSRuleAction(nil, testCase.options)
+		require.NoError(t, err, testCase.name)
+		require.NoError(t, action.start(), testCase.name)
+		require.Equal(t, testCase.filter, action.WithResponseFilter(), testCase.name)
+
+		response := testDNSResponse()
+		action.FilterResponse(response)
+		require.Equal(t, testCase.answers, answerStrings(response), testCase.name)
+
+		var addresses []netip.Addr
+		for _, address := range []string{"1.1.1.1", "10.0.0.1", "8.8.8.8", "2001:db8::1", "fd00::1"} {
+			addresses = append(addresses, netip.MustParseAddr(address))
+		}
+		var filtered []string
+		for _, address := range action.FilterAddresses(addresses) {
+			filtered = append(filtered, address.String())
+		}
+		require.Equal(t, testCase.addresses, filtered, testCase.name)
+	}
+}
+
+func TestDNSRuleActionLocalResponse(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name      string
+		options   dnsRuleActionOptions
+		queryType uint16
+		local     bool
+		rcode     int
+	}{
+		{"route", dnsRuleActionOptions{}, mDNS.TypeA, false, 0},
+		{"reject", dnsRuleActionOptions{action: C.RuleActionTypeReject}, mDNS.TypeA, true, mDNS.RcodeNameError},
+		{"reject no data", dnsRuleActionOptions{action: C.RuleActionTypeReject, rcode: C.RuleActionRCodeNoData}, mDNS.TypeA, true, mDNS.RcodeSuccess},
+		{"reject refused", dnsRuleActionOptions{action: C.RuleActionTypeReject, rcode: C.RuleActionRCodeRefused}, mDNS.TypeA, true, mDNS.RcodeRefused},
+		{"strip queried type", dnsRuleActionOptions{stripType: []option.DNSQueryType{option.DNSQueryType(mDNS.TypeAAAA)}}, mDNS.TypeAAAA, true, mDNS.RcodeSuccess},
+		{"strip other type", dnsRuleActionOptions{stripType: []option.DNSQueryType{option.DNSQueryType(mDNS.TypeAAAA)}}, mDNS.TypeA, false, 0},
+	} {
+		action, err := newDNSRuleAction(nil, testCase.options)
+		require.NoError(t, err, testCase.name)
+		message := new(mDNS.Msg)
+		message.SetQuestion("example.com.", testCase.queryType)
+		response, local := action.LocalResponse(message)
+		require.Equal(t, testCase.local, local, testCase.name)
+		if local {
+			require.Equal(t, testCase.rcode, response.Rcode, testCase.name)
+			require.Empty(t, response.Answer, testCase.name)
+		}
+	}
+}
@@ -150,6 +150,25 @@ func NewDefaultHeadlessRule(router adapter.Router, options option.DefaultHeadles
 		rule.items = append(rule.items, item)
 		rule.allItems = append(rule.allItems, item)
 	}
+	if options.Timezone != "" && len(options.TimeRange) == 0 && len(options.Weekday) == 0 {
+		return nil, E.New("timezone: missing time_range or weekday")
+	}
+	if len(options.TimeRange) > 0 {
+		item, err := NewTimeRangeItem(options.TimeRange, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "time_range")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
+	if len(options.Weekday) > 0 {
+		item, err := NewWeekdayItem(options.Weekday, options.Timezone)
+		if err != nil {
+			return nil, E.Cause(err, "weekday")
+		}
+		rule.items = append(rule.items, item)
+		rule.allItems = append(rule.allItems, item)
+	}
 	if len(rule.items) > 0 {
 		rule.ruleCount = 1
 	} else {
@@ -215,5 +234,20 @@ func (r *LogicalHeadlessRule) Match(metadata *adapter.InboundContext) bool {
 	if metadata.DnsFallBack {
 		return false
 	}
-	return r.abstractLogicalRule.Match(metadata)
+	// Sub rules reset the rule cache, restore what the rule set item and the items of
+	// the outer rule have set, so that the following rules of the rule set are not affected.
+	ipcidrMatchSource := metadata.IPCIDRMatchSource
+	sourceAddressMatch := metadata.SourceAddressMatch
+	sourcePortMatch := metadata.SourcePortMatch
+	destinationAddressMatch := metadata.DestinationAddressMatch
+	destinationPortMatch := metadata.DestinationPortMatch
+	matched := r.abstractLogicalRule.Match(metadata)
+	metadata.IPCIDRMatchSource = ipcidrMatchSource
+	if !matched {
+		metadata.SourceAddressMatch = sourceAddressMatch
+		metadata.SourcePortMatch = sourcePortMatch
+		metadata.DestinationAddressMatch = destinationAddressMatch
+		metadata.DestinationPortMatch = destinationPortMatch
+	}
+	return matched
 }
@@ -0,0 +1,87 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/sagernet/sing-box/adapter"
+	C "github.com/sagernet/sing-box/constant"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testLogicalRuleSet holds a logical rule that does not match the test metadata, followed by other rules.
+func testLogicalRuleSet(t *testing.T, rules ...option.DefaultHeadlessRule) adapter.RuleSet {
+	plainRuleSet := option.PlainRuleSet{
+		Rules: []option.HeadlessRule{{
+			Type: C.RuleTypeLogical,
+			LogicalOptions: option.LogicalHeadlessRule{
+				Mode: C.LogicalTypeAnd,
+				Rules: []option.HeadlessRule{
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{IPCIDR: []string{"10.0.0.0/8"}}},
+					{Type: C.RuleTypeDefault, DefaultOptions: option.DefaultHeadlessRule{Network: []string{N.NetworkUDP}}},
+				},
+			},
+		}},
+	}
+	for _, rule := range rules {
+		plainRuleSet.Rules = append(plainRuleSet.Rules, option.HeadlessRule{Type: C.RuleTypeDefault, DefaultOptions: rule})
+	}
+	ruleSet := &LocalRuleSet{}
+	require.NoError(t, ruleSet.loadRules(nil, plainRuleSet))
+	return ruleSet
+}
+
+func testRuleMetadata() *adapter.InboundContext {
+	return &adapter.InboundContext{
+		Network:     N.NetworkTCP,
+		Source:      M.ParseSocksaddr("10.0.0.1:10000"),
+		Destination: M.ParseSocksaddr("1.1.1.1:443"),
+		Domain:      "www.example.com",
+	}
+}
+
+func TestRuleSetLogicalRuleIPCIDRMatchSource(t *testing.T) {
+	t.Parallel()
+	ruleSet := testLogicalRuleSet(t, option.DefaultHeadlessRule{IPCIDR: []string{"10.0.0.0/8"}})
+	for _, testCase := range []struct {
+		matchSource bool
+		match       bool
+	}{
+		{true, true},
+		{false, false},
+	} {
+		item := &RuleSetItem{setList: []adapter.RuleSet{ruleSet}, ipcidrMatchSource: testCase.matchSource}
+		metadata := testRuleMetadata()
+		require.Equal(t, testCase.match, item.Match(metadata), "match source %v", testCase.matchSource)
+		require.Equal(t, testCase.matchSource, metadata.IPCIDRMatchSource, "match source %v", testCase.matchSource)
+	}
+}
+
+func TestRuleSetLogicalRuleKeepsOuterMatch(t *testing.T) {
+	t.Parallel()
+	ruleSet := testLogicalRuleSet(t, option.DefaultHeadlessRule{Network: []string{N.NetworkTCP}})
+	for _, testCase := range []struct {
+		name    string
+		options option.DefaultRule
+		match   bool
+	}{
+		{"domain", option.DefaultRule{Domain: []string{"www.example.com"}}, true},
+		{"ip_cidr", option.DefaultRule{IPCIDR: []string{"1.1.1.0/24"}}, true},
+		{"source_ip_cidr", option.DefaultRule{SourceIPCIDR: []string{"10.0.0.0/8"}}, true},
+		{"port", option.DefaultRule{Port: []uint16{443}}, true},
+		{"other domain", option.DefaultRule{Domain: []string{"example.org"}}, false},
+	} {
+		testCase.options.RuleSet = []string{"test"}
+		rule, err := NewDefaultRule(nil, log.NewNOPFactory().Logger(), testCase.options)
+		require.NoError(t, err, testCase.name)
+		for _, item := range rule.ruleSetItems {
+			item.(*RuleSetItem).setList = []adapter.RuleSet{ruleSet}
+		}
+		metadata := testRuleMetadata()
+		require.Equal(t, testCase.match, rule.Match(metadata), testCase.name)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 
 	"go4.org/netipx"
@@ -13,7 +14,7 @@ import (
 var _ RuleItem = (*IPCIDRItem)(nil)
 
 type IPCIDRItem struct {
-	ipSet       *netipx.IPSet
+	ipSet       option.IPSet
 	isSource    bool
 	description string
 }
@@ -57,7 +58,7 @@ func NewIPCIDRItem(isSource bool, prefixStrings []string) (*IPCIDRItem, error) {
 	}, nil
 }
 
-func NewRawIPCIDRItem(isSource bool, ipSet *netipx.IPSet) *IPCIDRItem {
+func NewRawIPCIDRItem(isSource bool, ipSet option.IPSet) *IPCIDRItem {
 	var description string
 	if isSource {
 		description = "source_ip_cidr="
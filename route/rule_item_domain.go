@@ -4,13 +4,14 @@ import (
 	"strings"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
 	"github.com/sagernet/sing/common/domain"
 )
 
 var _ RuleItem = (*DomainItem)(nil)
 
 type DomainItem struct {
-	matcher     *domain.Matcher
+	matcher     option.DomainMatcher
 	description string
 }
 
@@ -43,7 +44,7 @@ func NewDomainItem(domains []string, domainSuffixes []string) *DomainItem {
 	}
 }
 
-func NewRawDomainItem(matcher *domain.Matcher) *DomainItem {
+func NewRawDomainItem(matcher option.DomainMatcher) *DomainItem {
 	return &DomainItem{
 		matcher,
 		"domain/domain_suffix=<binary>",
@@ -0,0 +1,107 @@
+package route
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var ErrBadTimeRange = E.New("bad time range")
+
+var _ RuleItem = (*TimeRangeItem)(nil)
+
+type TimeRangeItem struct {
+	timeRanges    []string
+	timeRangeList []timeRangeItem
+	location      *time.Location
+}
+
+// timeRangeItem holds offsets since midnight, a range with start after end wraps past midnight.
+type timeRangeItem struct {
+	start time.Duration
+	end   time.Duration
+}
+
+func NewTimeRangeItem(rangeList []string, timezone string) (*TimeRangeItem, error) {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	timeRangeList := make([]timeRangeItem, 0, len(rangeList))
+	for _, timeRange := range rangeList {
+		startString, endString, loaded := strings.Cut(timeRange, "-")
+		if !loaded {
+			return nil, E.Extend(ErrBadTimeRange, timeRange)
+		}
+		start, err := parseTimeOfDay(startString)
+		if err != nil {
+			return nil, E.Cause(err, E.Extend(ErrBadTimeRange, timeRange))
+		}
+		end, err := parseTimeOfDay(endString)
+		if err != nil {
+			return nil, E.Cause(err, E.Extend(ErrBadTimeRange, timeRange))
+		}
+		timeRangeList = append(timeRangeList, timeRangeItem{start, end})
+	}
+	return &TimeRangeItem{
+		timeRanges:    rangeList,
+		timeRangeList: timeRangeList,
+		location:      location,
+	}, nil
+}
+
+func loadTimezone(timezone string) (*time.Location, error) {
+	if timezone == "" {
+		return time.Local, nil
+	}
+	location, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, E.Cause(err, "load timezone")
+	}
+	return location, nil
+}
+
+func parseTimeOfDay(content string) (time.Duration, error) {
+	content = strings.TrimSpace(content)
+	layout := "15:04"
+	if strings.Count(content, ":") == 2 {
+		layout = "15:04:05"
+	}
+	if content == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	timeOfDay, err := time.Parse(layout, content)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(timeOfDay.Hour())*time.Hour + time.Duration(timeOfDay.Minute())*time.Minute + time.Duration(timeOfDay.Second())*time.Second, nil
+}
+
+func (r *TimeRangeItem) Match(metadata *adapter.InboundContext) bool {
+	return r.matchTime(time.Now())
+}
+
+func (r *TimeRangeItem) matchTime(now time.Time) bool {
+	now = now.In(r.location)
+	offset := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	for _, timeRange := range r.timeRangeList {
+		if timeRange.start <= timeRange.end {
+			if offset >= timeRange.start && offset < timeRange.end {
+				return true
+			}
+		} else if offset >= timeRange.start || offset < timeRange.end {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *TimeRangeItem) String() string {
+	if len(r.timeRanges) == 1 {
+		return F.ToString("time_range=", r.timeRanges[0])
+	}
+	return F.ToString("time_range=[", strings.Join(r.timeRanges, " "), "]")
+}
@@ -0,0 +1,148 @@
+package route
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	N "github.com/sagernet/sing/common/network"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRangeItem(t *testing.T) {
+	t.Parallel()
+	day := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, testCase := range []struct {
+		name      string
+		ranges    []string
+		timeOfDay time.Duration
+		match     bool
+	}{
+		{"inside", []string{"09:00-17:00"}, 12 * time.Hour, true},
+		{"start inclusive", []string{"09:00-17:00"}, 9 * time.Hour, true},
+		{"end exclusive", []string{"09:00-17:00"}, 17 * time.Hour, false},
+		{"before", []string{"09:00-17:00"}, 8*time.Hour + 59*time.Minute, false},
+		{"seconds", []string{"09:00:30-09:01"}, 9*time.Hour + 30*time.Second, true},
+		{"seconds before", []string{"09:00:30-09:01"}, 9*time.Hour + 29*time.Second, false},
+		{"midnight end", []string{"22:00-24:00"}, 23*time.Hour + 59*time.Minute, true},
+		{"wrap late", []string{"22:00-06:00"}, 23 * time.Hour, true},
+		{"wrap early", []string{"22:00-06:00"}, 5 * time.Hour, true},
+		{"wrap outside", []string{"22:00-06:00"}, 12 * time.Hour, false},
+		{"any of list", []string{"01:00-02:00", "12:00-13:00"}, 12*time.Hour + 30*time.Minute, true},
+		{"none of list", []string{"01:00-02:00", "12:00-13:00"}, 3 * time.Hour, false},
+	} {
+		item, err := NewTimeRangeItem(testCase.ranges, "UTC")
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, testCase.match, item.matchTime(day.Add(testCase.timeOfDay)), testCase.name)
+	}
+}
+
+func TestTimeRangeItemTimezone(t *testing.T) {
+	t.Parallel()
+	item, err := NewTimeRangeItem([]string{"08:00-09:00"}, "Asia/Shanghai")
+	require.NoError(t, err)
+	require.True(t, item.matchTime(time.Date(2024, 1, 1, 0, 30, 0, 0, time.UTC)))
+	require.False(t, item.matchTime(time.Date(2024, 1, 1, 8, 30, 0, 0, time.UTC)))
+}
+
+func TestTimeRangeItemInvalid(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		ranges   []string
+		timezone string
+	}{
+		{[]string{"09:00"}, ""},
+		{[]string{"9-17"}, ""},
+		{[]string{"09:00-25:00"}, ""},
+		{[]string{"09:60-10:00"}, ""},
+		{[]string{"09:00-17:00"}, "Mars/Olympus"},
+	} {
+		_, err := NewTimeRangeItem(testCase.ranges, testCase.timezone)
+		require.Error(t, err, "%v %s", testCase.ranges, testCase.timezone)
+	}
+}
+
+func TestWeekdayItem(t *testing.T) {
+	t.Parallel()
+	// 2024-01-01 is a Monday.
+	monday := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, testCase := range []struct {
+		name     string
+		weekdays []string
+		timezone string
+		now      time.Time
+		match    bool
+	}{
+		{"full name", []string{"monday"}, "UTC", monday, true},
+		{"short name", []string{"mon"}, "UTC", monday, true},
+		{"case and space", []string{" Monday "}, "UTC", monday, true},
+		{"other day", []string{"tuesday"}, "UTC", monday, false},
+		{"list", []string{"sat", "sun", "mon"}, "UTC", monday, true},
+		{"sunday", []string{"sun"}, "UTC", monday.AddDate(0, 0, 6), true},
+		{"timezone ahead", []string{"tue"}, "Asia/Tokyo", monday.Add(11 * time.Hour), true},
+		{"timezone behind", []string{"sun"}, "America/New_York", monday.Add(-8 * time.Hour), true},
+	} {
+		item, err := NewWeekdayItem(testCase.weekdays, testCase.timezone)
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, testCase.match, item.matchTime(testCase.now), testCase.name)
+	}
+}
+
+func TestWeekdayItemInvalid(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		weekdays []string
+		timezone string
+	}{
+		{[]string{"mo"}, ""},
+		{[]string{"someday"}, ""},
+		{[]string{""}, ""},
+		{[]string{"monday"}, "Mars/Olympus"},
+	} {
+		_, err := NewWeekdayItem(testCase.weekdays, testCase.timezone)
+		require.Error(t, err, "%v %s", testCase.weekdays, testCase.timezone)
+	}
+}
+
+func TestRuleTimezone(t *testing.T) {
+	t.Parallel()
+	logger := log.NewNOPFactory().Logger()
+	for _, testCase := range []struct {
+		name      string
+		timeRange []string
+		weekday   []string
+		network   []string
+		valid     bool
+	}{
+		{"timezone only", nil, nil, nil, false},
+		{"timezone without time items", nil, nil, []string{N.NetworkTCP}, false},
+		{"time_range", []string{"09:00-17:00"}, nil, nil, true},
+		{"weekday", nil, []string{"mon"}, []string{N.NetworkTCP}, true},
+	} {
+		_, err := NewRule(nil, logger, option.Rule{DefaultOptions: option.DefaultRule{
+			Network:   testCase.network,
+			TimeRange: testCase.timeRange,
+			Weekday:   testCase.weekday,
+			Timezone:  "Asia/Shanghai",
+			Outbound:  "direct",
+		}}, false)
+		require.Equal(t, testCase.valid, err == nil, "rule: %s: %v", testCase.name, err)
+		_, err = NewDNSRule(nil, logger, option.DNSRule{DefaultOptions: option.DefaultDNSRule{
+			Network:   testCase.network,
+			TimeRange: testCase.timeRange,
+			Weekday:   testCase.weekday,
+			Timezone:  "Asia/Shanghai",
+			Server:    []string{"local"},
+		}}, false)
+		require.Equal(t, testCase.valid, err == nil, "dns rule: %s: %v", testCase.name, err)
+		_, err = NewHeadlessRule(nil, option.HeadlessRule{DefaultOptions: option.DefaultHeadlessRule{
+			Network:   testCase.network,
+			TimeRange: testCase.timeRange,
+			Weekday:   testCase.weekday,
+			Timezone:  "Asia/Shanghai",
+		}})
+		require.Equal(t, testCase.valid, err == nil, "headless rule: %s: %v", testCase.name, err)
+	}
+}
@@ -0,0 +1,64 @@
+package route
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	E "github.com/sagernet/sing/common/exceptions"
+	F "github.com/sagernet/sing/common/format"
+)
+
+var _ RuleItem = (*WeekdayItem)(nil)
+
+type WeekdayItem struct {
+	weekdays   []string
+	weekdayMap map[time.Weekday]bool
+	location   *time.Location
+}
+
+func NewWeekdayItem(weekdays []string, timezone string) (*WeekdayItem, error) {
+	location, err := loadTimezone(timezone)
+	if err != nil {
+		return nil, err
+	}
+	weekdayMap := make(map[time.Weekday]bool)
+	for _, weekdayName := range weekdays {
+		weekday, loaded := parseWeekday(weekdayName)
+		if !loaded {
+			return nil, E.New("unknown weekday: ", weekdayName)
+		}
+		weekdayMap[weekday] = true
+	}
+	return &WeekdayItem{
+		weekdays:   weekdays,
+		weekdayMap: weekdayMap,
+		location:   location,
+	}, nil
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		fullName := strings.ToLower(weekday.String())
+		if name == fullName || name == fullName[:3] {
+			return weekday, true
+		}
+	}
+	return 0, false
+}
+
+func (r *WeekdayItem) Match(metadata *adapter.InboundContext) bool {
+	return r.matchTime(time.Now())
+}
+
+func (r *WeekdayItem) matchTime(now time.Time) bool {
+	return r.weekdayMap[now.In(r.location).Weekday()]
+}
+
+func (r *WeekdayItem) String() string {
+	if len(r.weekdays) == 1 {
+		return F.ToString("weekday=", r.weekdays[0])
+	}
+	return F.ToString("weekday=[", strings.Join(r.weekdays, " "), "]")
+}
@@ -18,7 +18,7 @@ import (
 func NewRuleSet(ctx context.Context, router adapter.Router, logger logger.ContextLogger, options option.RuleSet) (adapter.RuleSet, error) {
 	switch options.Type {
 	case C.RuleSetTypeLocal:
-		return NewLocalRuleSet(ctx, router, options)
+		return NewLocalRuleSet(ctx, router, logger, options)
 	case C.RuleSetTypeRemote:
 		return NewRemoteRuleSet(ctx, router, logger, options), nil
 	default:
@@ -1,17 +1,19 @@
 package route
 
 import (
-	"bytes"
 	"context"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/ruleprovider"
 	"github.com/sagernet/sing-box/common/srs"
 	C "github.com/sagernet/sing-box/constant"
 	"github.com/sagernet/sing-box/option"
 	E "github.com/sagernet/sing/common/exceptions"
 	"github.com/sagernet/sing/common/json"
+	"github.com/sagernet/sing/common/logger"
 	"github.com/sagernet/sing/common/rw"
 	"github.com/sagernet/sing/service/filemanager"
 )
@@ -20,15 +22,31 @@ type abstractRuleSet struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	tag         string
+	logger      logger.ContextLogger
 	path        string
 	pType       string
 	format      string
-	ruleCount   int
-	metadata    adapter.RuleSetMetadata
-	rules       []adapter.HeadlessRule
+	behavior    string
+	data        atomic.Pointer[ruleSetData]
 	updatedTime time.Time
 }
 
+// ruleSetData is the loaded content of a rule set, replaced as a whole on update
+// so that matching is never done against a partially loaded rule set.
+type ruleSetData struct {
+	ruleCount int
+	metadata  adapter.RuleSetMetadata
+	rules     []adapter.HeadlessRule
+}
+
+func (s *abstractRuleSet) loadedData() *ruleSetData {
+	data := s.data.Load()
+	if data == nil {
+		return &ruleSetData{}
+	}
+	return data
+}
+
 func (s *abstractRuleSet) Tag() string {
 	return s.tag
 }
@@ -46,15 +64,15 @@ func (s *abstractRuleSet) UpdatedTime() time.Time {
 }
 
 func (s *abstractRuleSet) RuleCount() int {
-	return s.ruleCount
+	return s.loadedData().ruleCount
 }
 
 func (s *abstractRuleSet) ContainsDestinationIPCIDRRule() bool {
-	return s.metadata.ContainsIPCIDRRule
+	return s.loadedData().metadata.ContainsIPCIDRRule
 }
 
 func (s *abstractRuleSet) Match(metadata *adapter.InboundContext) bool {
-	for _, rule := range s.rules {
+	for _, rule := range s.loadedData().rules {
 		if rule.Match(metadata) {
 			return true
 		}
@@ -63,7 +81,7 @@ func (s *abstractRuleSet) Match(metadata *adapter.InboundContext) bool {
 }
 
 func (s *abstractRuleSet) Metadata() adapter.RuleSetMetadata {
-	return s.metadata
+	return s.loadedData().metadata
 }
 
 func (s *abstractRuleSet) setPath() error {
@@ -75,6 +93,10 @@ func (s *abstractRuleSet) setPath() error {
 			path += ".json"
 		case C.RuleSetFormatBinary:
 			path += ".srs"
+		case C.RuleSetFormatClash:
+			path += ".yaml"
+		case C.RuleSetFormatDomainList, C.RuleSetFormatIPCIDRList, C.RuleSetFormatAdblock:
+			path += ".txt"
 		}
 		if foundPath, loaded := C.FindPath(path); loaded {
 			path = foundPath
@@ -111,16 +133,28 @@ func (s *abstractRuleSet) loadFromFile(router adapter.Router, firstLoad bool) er
 	if !firstLoad && modTime == s.updatedTime {
 		return nil
 	}
-	content, err := os.ReadFile(s.path)
+	err = s.loadFile(router)
 	if err != nil {
 		return err
 	}
-	err = s.loadData(router, content)
+	s.updatedTime = modTime
+	return nil
+}
+
+// loadFile loads the rule set from its path, binary files of local rule sets are memory-mapped.
+func (s *abstractRuleSet) loadFile(router adapter.Router) error {
+	if s.format == C.RuleSetFormatBinary && s.pType == C.RuleSetTypeLocal {
+		plainRuleSet, err := srs.ReadFile(s.path, false)
+		if err != nil {
+			return err
+		}
+		return s.loadRules(router, plainRuleSet)
+	}
+	content, err := os.ReadFile(s.path)
 	if err != nil {
 		return err
 	}
-	s.updatedTime = modTime
-	return nil
+	return s.loadData(router, content)
 }
 
 func (s *abstractRuleSet) loadData(router adapter.Router, content []byte) error {
@@ -137,11 +171,24 @@ func (s *abstractRuleSet) loadData(router adapter.Router, content []byte) error
 		}
 		plainRuleSet = compat.Upgrade()
 	case C.RuleSetFormatBinary:
-		plainRuleSet, err = srs.Read(bytes.NewReader(content), false)
+		plainRuleSet, err = srs.ReadBytes(content, false)
 		if err != nil {
 			return err
 		}
+	case C.RuleSetFormatClash, C.RuleSetFormatDomainList, C.RuleSetFormatIPCIDRList, C.RuleSetFormatAdblock:
+		var skipped ruleprovider.Skipped
+		plainRuleSet, skipped, err = ruleprovider.Read(content, s.format, s.behavior)
+		if err != nil {
+			return err
+		}
+		if len(skipped) > 0 {
+			s.logger.Warn("rule-set ", s.tag, ": skipped entries of unsupported types: ", skipped)
+		}
 	}
+	return s.loadRules(router, plainRuleSet)
+}
+
+func (s *abstractRuleSet) loadRules(router adapter.Router, plainRuleSet option.PlainRuleSet) error {
 	var ruleCount int
 	rules := make([]adapter.HeadlessRule, len(plainRuleSet.Rules))
 	for i, ruleOptions := range plainRuleSet.Rules {
@@ -152,10 +199,14 @@ func (s *abstractRuleSet) loadData(router adapter.Router, content []byte) error
 		rules[i] = rule
 		ruleCount += rule.RuleCount()
 	}
-	s.metadata.ContainsProcessRule = hasHeadlessRule(plainRuleSet.Rules, isProcessHeadlessRule)
-	s.metadata.ContainsWIFIRule = hasHeadlessRule(plainRuleSet.Rules, isWIFIHeadlessRule)
-	s.metadata.ContainsIPCIDRRule = hasHeadlessRule(plainRuleSet.Rules, isIPCIDRHeadlessRule)
-	s.ruleCount = ruleCount
-	s.rules = rules
+	s.data.Store(&ruleSetData{
+		ruleCount: ruleCount,
+		metadata: adapter.RuleSetMetadata{
+			ContainsProcessRule: hasHeadlessRule(plainRuleSet.Rules, isProcessHeadlessRule),
+			ContainsWIFIRule:    hasHeadlessRule(plainRuleSet.Rules, isWIFIHeadlessRule),
+			ContainsIPCIDRRule:  hasHeadlessRule(plainRuleSet.Rules, isIPCIDRHeadlessRule),
+		},
+		rules: rules,
+	})
 	return nil
 }
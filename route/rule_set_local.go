@@ -2,37 +2,125 @@ package route
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
 
 	"github.com/sagernet/sing-box/adapter"
 	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+
+	"github.com/fsnotify/fsnotify"
 )
 
+// localRuleSetReloadDelay is how long changes of a rule set file are collected before reloading,
+// writing a file usually emits several events.
+const localRuleSetReloadDelay = 200 * time.Millisecond
+
 var _ adapter.RuleSet = (*LocalRuleSet)(nil)
 
 type LocalRuleSet struct {
 	abstractRuleSet
+	router       adapter.Router
+	reloadAccess sync.Mutex
+	watcher      *fsnotify.Watcher
 }
 
-func NewLocalRuleSet(ctx context.Context, router adapter.Router, options option.RuleSet) (*LocalRuleSet, error) {
+func NewLocalRuleSet(ctx context.Context, router adapter.Router, logger logger.ContextLogger, options option.RuleSet) (*LocalRuleSet, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	ruleSet := LocalRuleSet{
 		abstractRuleSet: abstractRuleSet{
-			ctx:    ctx,
-			cancel: cancel,
-			tag:    options.Tag,
-			pType:  "local",
-			path:   options.Path,
-			format: options.Format,
+			ctx:      ctx,
+			cancel:   cancel,
+			tag:      options.Tag,
+			logger:   logger,
+			pType:    "local",
+			path:     options.Path,
+			format:   options.Format,
+			behavior: options.Behavior,
 		},
+		router: router,
 	}
 	return &ruleSet, ruleSet.loadFromFile(router, true)
 }
 
+// Update reloads the rule set from its file, even if the file has not been modified.
 func (s *LocalRuleSet) Update(router adapter.Router) error {
-	return s.loadFromFile(router, false)
+	return s.reload()
 }
 
 func (s *LocalRuleSet) StartContext(ctx context.Context, startContext adapter.RuleSetStartContext) error {
+	err := s.startWatcher()
+	if err != nil {
+		s.logger.Warn("create fsnotify watcher for rule-set ", s.tag, ": ", err)
+	}
+	return nil
+}
+
+// startWatcher watches the directory of the rule set file, so that the file
+// is still watched after being replaced by a rename.
+func (s *LocalRuleSet) startWatcher() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	err = watcher.Add(filepath.Dir(s.path))
+	if err != nil {
+		watcher.Close()
+		return err
+	}
+	s.watcher = watcher
+	go s.loopUpdate()
+	return nil
+}
+
+func (s *LocalRuleSet) loopUpdate() {
+	path := filepath.Clean(s.path)
+	reloadTimer := time.NewTimer(localRuleSetReloadDelay)
+	reloadTimer.Stop()
+	defer reloadTimer.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if filepath.Clean(event.Name) != path || event.Op&(fsnotify.Write|fsnotify.Create) == 0 {
+				continue
+			}
+			reloadTimer.Reset(localRuleSetReloadDelay)
+		case <-reloadTimer.C:
+			err := s.reload()
+			if err != nil {
+				s.logger.Error(E.Cause(err, "reload rule-set ", s.tag))
+			}
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Error(E.Cause(err, "fsnotify error"))
+		}
+	}
+}
+
+// reload loads the rule set file again, the loaded rule set is kept if the file is invalid.
+func (s *LocalRuleSet) reload() error {
+	s.reloadAccess.Lock()
+	defer s.reloadAccess.Unlock()
+	fileInfo, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	err = s.loadFile(s.router)
+	if err != nil {
+		return err
+	}
+	s.updatedTime = fileInfo.ModTime()
+	s.logger.Info("reloaded rule-set ", s.tag, ": ", s.RuleCount(), " rules")
 	return nil
 }
 
@@ -42,5 +130,8 @@ func (s *LocalRuleSet) PostStart() error {
 
 func (s *LocalRuleSet) Close() error {
 	s.cancel()
+	if s.watcher != nil {
+		return s.watcher.Close()
+	}
 	return nil
 }
@@ -25,7 +25,6 @@ var _ adapter.RuleSet = (*RemoteRuleSet)(nil)
 type RemoteRuleSet struct {
 	abstractRuleSet
 	router         adapter.Router
-	logger         logger.ContextLogger
 	options        option.RemoteRuleSet
 	lastUpdated    time.Time
 	lastEtag       string
@@ -45,15 +44,16 @@ func NewRemoteRuleSet(ctx context.Context, router adapter.Router, logger logger.
 	}
 	return &RemoteRuleSet{
 		abstractRuleSet: abstractRuleSet{
-			ctx:    ctx,
-			cancel: cancel,
-			tag:    options.Tag,
-			pType:  "remote",
-			path:   options.Path,
-			format: options.Format,
+			ctx:      ctx,
+			cancel:   cancel,
+			tag:      options.Tag,
+			logger:   logger,
+			pType:    "remote",
+			path:     options.Path,
+			format:   options.Format,
+			behavior: options.Behavior,
 		},
 		router:         router,
-		logger:         logger,
 		options:        options.RemoteOptions,
 		lastEtag:       "",
 		updateInterval: updateInterval,
@@ -61,15 +61,6 @@ func NewRemoteRuleSet(ctx context.Context, router adapter.Router, logger logger.
 	}
 }
 
-func (s *RemoteRuleSet) Match(metadata *adapter.InboundContext) bool {
-	for _, rule := range s.rules {
-		if rule.Match(metadata) {
-			return true
-		}
-	}
-	return false
-}
-
 func (s *RemoteRuleSet) Update(router adapter.Router) error {
 	return s.fetchOnce(s.ctx, nil)
 }
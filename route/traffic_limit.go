@@ -0,0 +1,531 @@
+package route
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/common/humanize"
+	"github.com/sagernet/sing-box/common/ratelimit"
+	"github.com/sagernet/sing-box/log"
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing/common/buf"
+	E "github.com/sagernet/sing/common/exceptions"
+	M "github.com/sagernet/sing/common/metadata"
+	N "github.com/sagernet/sing/common/network"
+	"github.com/sagernet/sing/service"
+)
+
+const (
+	QuotaPeriodTotal   = "total"
+	QuotaPeriodMonthly = "monthly"
+
+	trafficUsageSaveInterval = time.Minute
+)
+
+type trafficLimit struct {
+	key      string
+	name     string
+	options  option.TrafficLimitOptions
+	upload   *ratelimit.Limiter
+	download *ratelimit.Limiter
+	quota    uint64
+	monthly  bool
+
+	access      sync.Mutex
+	periodStart time.Time
+	usage       uint64
+	dirty       bool
+	loaded      bool
+	conns       map[*limitedConnState]struct{}
+}
+
+func newTrafficLimit(key string, name string, options option.TrafficLimitOptions) (*trafficLimit, error) {
+	limit := &trafficLimit{
+		key:     key,
+		name:    name,
+		options: options,
+		conns:   make(map[*limitedConnState]struct{}),
+	}
+	if options.Upload != "" {
+		upload, err := humanize.ParseBytes(options.Upload)
+		if err != nil {
+			return nil, E.Cause(err, "parse upload")
+		}
+		limit.upload = ratelimit.New(upload)
+	}
+	if options.Download != "" {
+		download, err := humanize.ParseBytes(options.Download)
+		if err != nil {
+			return nil, E.Cause(err, "parse download")
+		}
+		limit.download = ratelimit.New(download)
+	}
+	if options.Quota != "" {
+		quota, err := humanize.ParseBytes(options.Quota)
+		if err != nil {
+			return nil, E.Cause(err, "parse quota")
+		}
+		limit.quota = quota
+	}
+	switch options.QuotaPeriod {
+	case "", QuotaPeriodTotal:
+	case QuotaPeriodMonthly:
+		limit.monthly = true
+	default:
+		return nil, E.New("unknown quota period: ", options.QuotaPeriod)
+	}
+	limit.periodStart = limit.currentPeriodStart(time.Now())
+	return limit, nil
+}
+
+func (l *trafficLimit) currentPeriodStart(now time.Time) time.Time {
+	if !l.monthly {
+		return time.Time{}
+	}
+	return time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+}
+
+// rollover starts a new quota period if the current one is over, must be called with access held.
+func (l *trafficLimit) rollover() {
+	if periodStart := l.currentPeriodStart(time.Now()); periodStart.After(l.periodStart) {
+		l.periodStart = periodStart
+		l.usage = 0
+		l.dirty = true
+	}
+}
+
+func (l *trafficLimit) exhausted() bool {
+	if l.quota == 0 {
+		return false
+	}
+	l.access.Lock()
+	defer l.access.Unlock()
+	l.rollover()
+	return l.usage >= l.quota
+}
+
+// add records n transferred bytes and returns the connections to close if the quota just ran out.
+func (l *trafficLimit) add(n int) []*limitedConnState {
+	l.access.Lock()
+	defer l.access.Unlock()
+	l.rollover()
+	wasExhausted := l.quota > 0 && l.usage >= l.quota
+	l.usage += uint64(n)
+	l.dirty = true
+	if wasExhausted || l.quota == 0 || l.usage < l.quota {
+		return nil
+	}
+	conns := make([]*limitedConnState, 0, len(l.conns))
+	for conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	return conns
+}
+
+type trafficLimiter struct {
+	ctx           context.Context
+	logger        log.ContextLogger
+	cacheFile     adapter.CacheFile
+	inbounds      map[string]*trafficLimit
+	users         map[string]map[string]*trafficLimit
+	dynamicAccess sync.Mutex
+	dynamic       map[string]*trafficLimit
+	done          chan struct{}
+}
+
+// inheritTrafficLimit returns previous if its options did not change, so that its connections
+// and usage are kept, or a new limit carrying the usage of previous if any.
+func inheritTrafficLimit(previous *trafficLimit, key string, name string, options option.TrafficLimitOptions) (*trafficLimit, error) {
+	if previous != nil && previous.options == options {
+		return previous, nil
+	}
+	limit, err := newTrafficLimit(key, name, options)
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		previous.access.Lock()
+		if previous.monthly == limit.monthly {
+			limit.usage = previous.usage
+			limit.dirty = true
+		}
+		limit.loaded = previous.loaded
+		previous.access.Unlock()
+	}
+	return limit, nil
+}
+
+// newTrafficLimiter creates the limits of inbounds and their users,
+// limits of previous are kept with their usage if they still exist.
+func newTrafficLimiter(ctx context.Context, logger log.ContextLogger, inbounds []option.Inbound, previous *trafficLimiter) (*trafficLimiter, error) {
+	limiter := &trafficLimiter{
+		ctx:      ctx,
+		logger:   logger,
+		inbounds: make(map[string]*trafficLimit),
+		users:    make(map[string]map[string]*trafficLimit),
+		dynamic:  make(map[string]*trafficLimit),
+		done:     make(chan struct{}),
+	}
+	for i, inboundOptions := range inbounds {
+		listenOptions := inboundOptions.GetInboundOptions()
+		if listenOptions == nil || listenOptions.Limit == nil {
+			continue
+		}
+		if inboundOptions.Tag == "" {
+			return nil, E.New("parse inbound[", i, "] limit: missing inbound tag")
+		}
+		options := listenOptions.Limit
+		tag := inboundOptions.Tag
+		if options.TrafficLimitOptions != (option.TrafficLimitOptions{}) {
+			var previousLimit *trafficLimit
+			if previous != nil {
+				previousLimit = previous.inbounds[tag]
+			}
+			limit, err := inheritTrafficLimit(previousLimit, "inbound:"+tag, "inbound/"+tag, options.TrafficLimitOptions)
+			if err != nil {
+				return nil, E.Cause(err, "parse inbound[", tag, "] limit")
+			}
+			limiter.inbounds[tag] = limit
+		}
+		if len(options.Users) > 0 {
+			userLimits := make(map[string]*trafficLimit)
+			for j, userOptions := range options.Users {
+				if userOptions.Name == "" {
+					return nil, E.New("parse inbound[", tag, "] limit: missing name for user[", j, "]")
+				}
+				var previousLimit *trafficLimit
+				if previous != nil {
+					previousLimit = previous.users[tag][userOptions.Name]
+				}
+				limit, err := inheritTrafficLimit(previousLimit, "user:"+tag+":"+userOptions.Name, "user "+userOptions.Name+" on inbound/"+tag, userOptions.TrafficLimitOptions)
+				if err != nil {
+					return nil, E.Cause(err, "parse inbound[", tag, "] limit for user ", userOptions.Name)
+				}
+				userLimits[userOptions.Name] = limit
+			}
+			limiter.users[tag] = userLimits
+		}
+	}
+	if previous != nil {
+		previous.dynamicAccess.Lock()
+		for key, limit := range previous.dynamic {
+			limiter.dynamic[key] = limit
+		}
+		previous.dynamicAccess.Unlock()
+	}
+	return limiter, nil
+}
+
+func (l *trafficLimiter) Start() {
+	l.cacheFile = service.FromContext[adapter.CacheFile](l.ctx)
+	if l.cacheFile == nil {
+		return
+	}
+	l.forEach(l.loadUsage)
+	go l.loopSave()
+}
+
+// loadUsage loads the saved usage of a limit once, limits kept by a reload are already loaded.
+func (l *trafficLimiter) loadUsage(limit *trafficLimit) {
+	limit.access.Lock()
+	loaded := limit.loaded
+	limit.loaded = true
+	limit.access.Unlock()
+	if loaded {
+		return
+	}
+	savedUsage := l.cacheFile.LoadTrafficUsage(limit.key)
+	if savedUsage == nil {
+		return
+	}
+	limit.access.Lock()
+	if !limit.monthly || !savedUsage.PeriodStart.Before(limit.periodStart) {
+		limit.usage = savedUsage.Usage
+	}
+	limit.access.Unlock()
+}
+
+func (l *trafficLimiter) Close() {
+	select {
+	case <-l.done:
+		return
+	default:
+		close(l.done)
+	}
+	l.save()
+}
+
+func (l *trafficLimiter) forEach(f func(limit *trafficLimit)) {
+	for _, limit := range l.inbounds {
+		f(limit)
+	}
+	for _, userLimits := range l.users {
+		for _, limit := range userLimits {
+			f(limit)
+		}
+	}
+	l.dynamicAccess.Lock()
+	dynamicLimits := make([]*trafficLimit, 0, len(l.dynamic))
+	for _, limit := range l.dynamic {
+		dynamicLimits = append(dynamicLimits, limit)
+	}
+	l.dynamicAccess.Unlock()
+	for _, limit := range dynamicLimits {
+		f(limit)
+	}
+}
+
+func (l *trafficLimiter) loopSave() {
+	ticker := time.NewTicker(trafficUsageSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			l.save()
+		case <-l.done:
+			return
+		}
+	}
+}
+
+func (l *trafficLimiter) save() {
+	if l.cacheFile == nil {
+		return
+	}
+	l.forEach(func(limit *trafficLimit) {
+		limit.access.Lock()
+		if !limit.dirty {
+			limit.access.Unlock()
+			return
+		}
+		savedUsage := &adapter.SavedTrafficUsage{
+			PeriodStart: limit.periodStart,
+			Usage:       limit.usage,
+		}
+		limit.dirty = false
+		limit.access.Unlock()
+		err := l.cacheFile.SaveTrafficUsage(limit.key, savedUsage)
+		if err != nil {
+			l.logger.Error("save traffic usage of ", limit.name, ": ", err)
+		}
+	})
+}
+
+func (l *trafficLimiter) limitsFor(metadata adapter.InboundContext) ([]*trafficLimit, error) {
+	var limits []*trafficLimit
+	if limit, loaded := l.inbounds[metadata.Inbound]; loaded {
+		limits = append(limits, limit)
+	}
+	if metadata.User != "" {
+		if limit, loaded := l.users[metadata.Inbound][metadata.User]; loaded {
+			limits = append(limits, limit)
+		} else if metadata.UserLimit != nil {
+			limit, err := l.dynamicLimit(metadata.Inbound, metadata.User, *metadata.UserLimit)
+			if err != nil {
+				return nil, E.Cause(err, "parse limit of user ", metadata.User)
+			}
+			limits = append(limits, limit)
+		}
+	}
+	return limits, nil
+}
+
+// dynamicLimit returns the limit of a user authenticated externally, usage is kept if the limit changes.
+func (l *trafficLimiter) dynamicLimit(inbound string, user string, options option.TrafficLimitOptions) (*trafficLimit, error) {
+	key := "user:" + inbound + ":" + user
+	l.dynamicAccess.Lock()
+	defer l.dynamicAccess.Unlock()
+	previous := l.dynamic[key]
+	limit, err := inheritTrafficLimit(previous, key, "user "+user+" on inbound/"+inbound, options)
+	if err != nil {
+		return nil, err
+	}
+	if limit == previous {
+		return limit, nil
+	}
+	if l.cacheFile != nil {
+		l.loadUsage(limit)
+	}
+	l.dynamic[key] = limit
+	return limit, nil
+}
+
+func (l *trafficLimiter) newState(ctx context.Context, metadata adapter.InboundContext, closer func() error) (*limitedConnState, error) {
+	limits, err := l.limitsFor(metadata)
+	if err != nil || len(limits) == 0 {
+		return nil, err
+	}
+	for _, limit := range limits {
+		if limit.exhausted() {
+			return nil, E.New("traffic quota exhausted for ", limit.name)
+		}
+	}
+	state := &limitedConnState{
+		limiter: l,
+		limits:  limits,
+		closer:  closer,
+	}
+	state.ctx, state.cancel = context.WithCancel(ctx)
+	for _, limit := range limits {
+		limit.access.Lock()
+		limit.conns[state] = struct{}{}
+		limit.access.Unlock()
+	}
+	return state, nil
+}
+
+func (l *trafficLimiter) RoutedConnection(ctx context.Context, conn net.Conn, metadata adapter.InboundContext) (net.Conn, error) {
+	state, err := l.newState(ctx, metadata, conn.Close)
+	if err != nil || state == nil {
+		return conn, err
+	}
+	return &limitedConn{Conn: conn, state: state}, nil
+}
+
+func (l *trafficLimiter) RoutedPacketConnection(ctx context.Context, conn N.PacketConn, metadata adapter.InboundContext) (N.PacketConn, error) {
+	state, err := l.newState(ctx, metadata, conn.Close)
+	if err != nil || state == nil {
+		return conn, err
+	}
+	return &limitedPacketConn{PacketConn: conn, state: state}, nil
+}
+
+type limitedConnState struct {
+	limiter   *trafficLimiter
+	limits    []*trafficLimit
+	ctx       context.Context
+	cancel    context.CancelFunc
+	closer    func() error
+	closeOnce sync.Once
+}
+
+func (s *limitedConnState) uploaded(n int) error {
+	err := s.consume(n)
+	if err != nil {
+		return err
+	}
+	for _, limit := range s.limits {
+		if limit.upload != nil {
+			err := limit.upload.WaitN(s.ctx, n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *limitedConnState) download(n int) error {
+	for _, limit := range s.limits {
+		if limit.exhausted() {
+			return E.New("traffic quota exhausted for ", limit.name)
+		}
+		if limit.download != nil {
+			err := limit.download.WaitN(s.ctx, n)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return s.consume(n)
+}
+
+// consume records n transferred bytes, closes other connections sharing a quota that just ran out,
+// and returns an error if this connection has to stop.
+func (s *limitedConnState) consume(n int) error {
+	var exhausted error
+	for _, limit := range s.limits {
+		conns := limit.add(n)
+		if conns != nil {
+			s.limiter.logger.Warn("traffic quota exhausted for ", limit.name, ", closing ", len(conns), " connections")
+			for _, conn := range conns {
+				if conn != s {
+					go conn.Close()
+				}
+			}
+		}
+		if exhausted == nil && limit.exhausted() {
+			exhausted = E.New("traffic quota exhausted for ", limit.name)
+		}
+	}
+	return exhausted
+}
+
+func (s *limitedConnState) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		s.cancel()
+		for _, limit := range s.limits {
+			limit.access.Lock()
+			delete(limit.conns, s)
+			limit.access.Unlock()
+		}
+		err = s.closer()
+	})
+	return err
+}
+
+type limitedConn struct {
+	net.Conn
+	state *limitedConnState
+}
+
+func (c *limitedConn) Read(p []byte) (n int, err error) {
+	n, err = c.Conn.Read(p)
+	if n > 0 {
+		waitErr := c.state.uploaded(n)
+		if err == nil {
+			err = waitErr
+		}
+	}
+	return
+}
+
+func (c *limitedConn) Write(p []byte) (n int, err error) {
+	err = c.state.download(len(p))
+	if err != nil {
+		return
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *limitedConn) Close() error {
+	return c.state.Close()
+}
+
+func (c *limitedConn) Upstream() any {
+	return c.Conn
+}
+
+type limitedPacketConn struct {
+	N.PacketConn
+	state *limitedConnState
+}
+
+func (c *limitedPacketConn) ReadPacket(buffer *buf.Buffer) (destination M.Socksaddr, err error) {
+	destination, err = c.PacketConn.ReadPacket(buffer)
+	if err == nil {
+		err = c.state.uploaded(buffer.Len())
+	}
+	return
+}
+
+func (c *limitedPacketConn) WritePacket(buffer *buf.Buffer, destination M.Socksaddr) error {
+	err := c.state.download(buffer.Len())
+	if err != nil {
+		buffer.Release()
+		return err
+	}
+	return c.PacketConn.WritePacket(buffer, destination)
+}
+
+func (c *limitedPacketConn) Close() error {
+	return c.state.Close()
+}
+
+func (c *limitedPacketConn) Upstream() any {
+	return c.PacketConn
+}
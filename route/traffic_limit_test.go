@@ -0,0 +1,95 @@
+package route
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sagernet/sing-box/option"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrafficLimitQuota(t *testing.T) {
+	t.Parallel()
+	for _, testCase := range []struct {
+		name      string
+		options   option.TrafficLimitOptions
+		added     []int
+		closed    []bool
+		exhausted bool
+	}{
+		{"no quota", option.TrafficLimitOptions{Upload: "1 MB"}, []int{1 << 20, 1 << 20}, []bool{false, false}, false},
+		{"within quota", option.TrafficLimitOptions{Quota: "1 KB"}, []int{500, 499}, []bool{false, false}, false},
+		{"reach quota", option.TrafficLimitOptions{Quota: "1 KB"}, []int{500, 500}, []bool{false, true}, true},
+		{"over quota", option.TrafficLimitOptions{Quota: "1 KB"}, []int{600, 600, 600}, []bool{false, true, false}, true},
+	} {
+		limit, err := newTrafficLimit("test", testCase.name, testCase.options)
+		require.NoError(t, err, testCase.name)
+		state := &limitedConnState{}
+		limit.conns[state] = struct{}{}
+		for i, n := range testCase.added {
+			conns := limit.add(n)
+			if testCase.closed[i] {
+				require.Equal(t, []*limitedConnState{state}, conns, "%s add %d", testCase.name, i)
+			} else {
+				require.Nil(t, conns, "%s add %d", testCase.name, i)
+			}
+		}
+		require.Equal(t, testCase.exhausted, limit.exhausted(), testCase.name)
+	}
+}
+
+func TestTrafficLimitInvalid(t *testing.T) {
+	t.Parallel()
+	for _, options := range []option.TrafficLimitOptions{
+		{Upload: "fast"},
+		{Download: "1 XB"},
+		{Quota: "-1"},
+		{Quota: "1 GB", QuotaPeriod: "weekly"},
+	} {
+		_, err := newTrafficLimit("test", "test", options)
+		require.Error(t, err, "%+v", options)
+	}
+}
+
+func TestTrafficLimitMonthlyRollover(t *testing.T) {
+	t.Parallel()
+	limit, err := newTrafficLimit("test", "test", option.TrafficLimitOptions{Quota: "1 KB", QuotaPeriod: QuotaPeriodMonthly})
+	require.NoError(t, err)
+	limit.add(1000)
+	require.True(t, limit.exhausted())
+	limit.periodStart = limit.periodStart.AddDate(0, -1, 0)
+	require.False(t, limit.exhausted())
+	require.Zero(t, limit.usage)
+	require.Equal(t, limit.currentPeriodStart(time.Now()), limit.periodStart)
+}
+
+func TestInheritTrafficLimit(t *testing.T) {
+	t.Parallel()
+	total := option.TrafficLimitOptions{Quota: "1 KB"}
+	for _, testCase := range []struct {
+		name    string
+		options option.TrafficLimitOptions
+		same    bool
+		usage   uint64
+	}{
+		{"unchanged", total, true, 600},
+		{"rate changed", option.TrafficLimitOptions{Quota: "1 KB", Upload: "1 MB"}, false, 600},
+		{"quota changed", option.TrafficLimitOptions{Quota: "2 KB"}, false, 600},
+		{"period changed", option.TrafficLimitOptions{Quota: "1 KB", QuotaPeriod: QuotaPeriodMonthly}, false, 0},
+	} {
+		previous, err := newTrafficLimit("test", "test", total)
+		require.NoError(t, err, testCase.name)
+		previous.add(600)
+		previous.loaded = true
+		limit, err := inheritTrafficLimit(previous, "test", "test", testCase.options)
+		require.NoError(t, err, testCase.name)
+		require.Equal(t, testCase.same, limit == previous, testCase.name)
+		require.Equal(t, testCase.usage, limit.usage, testCase.name)
+		require.True(t, limit.loaded, testCase.name)
+	}
+	limit, err := inheritTrafficLimit(nil, "test", "test", total)
+	require.NoError(t, err)
+	require.Zero(t, limit.usage)
+	require.False(t, limit.loaded)
+}
@@ -0,0 +1,93 @@
+package hosts
+
+import (
+	"context"
+	"net/netip"
+	"os"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-dns"
+	E "github.com/sagernet/sing/common/exceptions"
+
+	mDNS "github.com/miekg/dns"
+)
+
+var _ dns.Transport = (*Transport)(nil)
+
+func init() {
+	dns.RegisterTransport([]string{"hosts"}, func(options dns.TransportOptions) (dns.Transport, error) {
+		return NewTransport(options)
+	})
+}
+
+// Transport answers queries from the hosts of the DNS router, unknown domains are answered with NXDOMAIN.
+type Transport struct {
+	name   string
+	router adapter.Router
+}
+
+func NewTransport(options dns.TransportOptions) (*Transport, error) {
+	router := adapter.RouterFromContext(options.Context)
+	if router == nil {
+		return nil, E.New("missing router in context")
+	}
+	return &Transport{
+		name:   options.Name,
+		router: router,
+	}, nil
+}
+
+func (t *Transport) Name() string {
+	return t.name
+}
+
+func (t *Transport) Start() error {
+	if t.router.DNSHosts() == nil {
+		return E.New("hosts not configured")
+	}
+	return nil
+}
+
+func (t *Transport) Reset() {
+}
+
+func (t *Transport) Close() error {
+	return nil
+}
+
+func (t *Transport) Raw() bool {
+	return true
+}
+
+func (t *Transport) Exchange(ctx context.Context, message *mDNS.Msg) (*mDNS.Msg, error) {
+	store := t.router.DNSHosts()
+	if store == nil {
+		return nil, E.New("hosts not configured")
+	}
+	response := &mDNS.Msg{
+		MsgHdr: mDNS.MsgHdr{
+			Id:                 message.Id,
+			Response:           true,
+			Authoritative:      true,
+			RecursionDesired:   message.RecursionDesired,
+			RecursionAvailable: true,
+			Rcode:              mDNS.RcodeSuccess,
+		},
+		Question: message.Question,
+	}
+	if len(message.Question) == 0 {
+		response.Rcode = mDNS.RcodeFormatError
+		return response, nil
+	}
+	answers, loaded := store.Resolve(message.Question[0])
+	if !loaded {
+		response.Rcode = mDNS.RcodeNameError
+		return response, nil
+	}
+	response.Answer = answers
+	return response, nil
+}
+
+func (t *Transport) Lookup(ctx context.Context, domain string, strategy dns.DomainStrategy) ([]netip.Addr, error) {
+	return nil, os.ErrInvalid
+}
@@ -0,0 +1,348 @@
+package hosts
+
+import (
+	"bufio"
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/sagernet/sing-box/adapter"
+	"github.com/sagernet/sing-box/option"
+	E "github.com/sagernet/sing/common/exceptions"
+	"github.com/sagernet/sing/common/logger"
+	"github.com/sagernet/sing/service/filemanager"
+
+	"github.com/fsnotify/fsnotify"
+	mDNS "github.com/miekg/dns"
+)
+
+const (
+	defaultTTL    = 60
+	maxCNAMEChain = 8
+)
+
+var _ adapter.DNSHostsStore = (*Store)(nil)
+
+type entry struct {
+	addresses []netip.Addr
+	cname     string
+	txt       []string
+	srv       []option.DNSHostsSRVRecord
+}
+
+type table struct {
+	domains   map[string]*entry
+	wildcards map[string]*entry
+}
+
+// Store answers queries from static records and hosts files, hosts files are reloaded when changed.
+type Store struct {
+	ctx      context.Context
+	logger   logger.Logger
+	ttl      uint32
+	paths    []string
+	records  []option.DNSHostsRecord
+	onUpdate func()
+	access   sync.RWMutex
+	table    *table
+	watcher  *fsnotify.Watcher
+}
+
+func NewStore(ctx context.Context, logger logger.Logger, options option.DNSHostsOptions, onUpdate func()) (*Store, error) {
+	store := &Store{
+		ctx:      ctx,
+		logger:   logger,
+		ttl:      options.TTL,
+		records:  options.Records,
+		onUpdate: onUpdate,
+	}
+	if store.ttl == 0 {
+		store.ttl = defaultTTL
+	}
+	for _, path := range options.Path {
+		store.paths = append(store.paths, filemanager.BasePath(ctx, path))
+	}
+	hostsTable, err := store.build()
+	if err != nil {
+		return nil, err
+	}
+	store.table = hostsTable
+	return store, nil
+}
+
+func (s *Store) Start() error {
+	if len(s.paths) > 0 {
+		err := s.startWatcher()
+		if err != nil {
+			s.logger.Warn("create fsnotify watcher: ", err)
+		}
+	}
+	return nil
+}
+
+func (s *Store) Close() error {
+	if s.watcher != nil {
+		return s.watcher.Close()
+	}
+	return nil
+}
+
+// startWatcher watches the directories of hosts files, as editors usually replace files instead of writing them.
+func (s *Store) startWatcher() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	directories := make(map[string]bool)
+	for _, path := range s.paths {
+		directory := filepath.Dir(path)
+		if directories[directory] {
+			continue
+		}
+		directories[directory] = true
+		err = watcher.Add(directory)
+		if err != nil {
+			watcher.Close()
+			return err
+		}
+	}
+	s.watcher = watcher
+	go s.loopUpdate()
+	return nil
+}
+
+func (s *Store) loopUpdate() {
+	for {
+		select {
+		case event, ok := <-s.watcher.Events:
+			if !ok {
+				return
+			}
+			if !s.isHostsFile(event.Name) || event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename|fsnotify.Remove) == 0 {
+				continue
+			}
+			hostsTable, err := s.build()
+			if err != nil {
+				s.logger.Error(E.Cause(err, "reload hosts"))
+				continue
+			}
+			s.access.Lock()
+			s.table = hostsTable
+			s.access.Unlock()
+			if s.onUpdate != nil {
+				s.onUpdate()
+			}
+			s.logger.Info("reloaded hosts from ", event.Name)
+		case err, ok := <-s.watcher.Errors:
+			if !ok {
+				return
+			}
+			s.logger.Error(E.Cause(err, "fsnotify error"))
+		}
+	}
+}
+
+func (s *Store) isHostsFile(name string) bool {
+	name = filepath.Clean(name)
+	for _, path := range s.paths {
+		if filepath.Clean(path) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// build merges static records with hosts files, static records take precedence.
+func (s *Store) build() (*table, error) {
+	hostsTable := &table{
+		domains:   make(map[string]*entry),
+		wildcards: make(map[string]*entry),
+	}
+	for i, record := range s.records {
+		if len(record.Domain) == 0 {
+			return nil, E.New("parse hosts record[", i, "]: missing domain")
+		}
+		if record.CNAME != "" && (len(record.Address) > 0 || len(record.TXT) > 0 || len(record.SRV) > 0) {
+			return nil, E.New("parse hosts record[", i, "]: cname can not be combined with other records")
+		}
+		for _, domain := range record.Domain {
+			recordEntry := hostsTable.load(domain, true)
+			if record.CNAME != "" {
+				if len(recordEntry.addresses) > 0 || len(recordEntry.txt) > 0 || len(recordEntry.srv) > 0 {
+					return nil, E.New("parse hosts record[", i, "]: cname can not be combined with other records of ", domain)
+				}
+				recordEntry.cname = normalizeDomain(record.CNAME)
+			} else if recordEntry.cname != "" {
+				return nil, E.New("parse hosts record[", i, "]: cname can not be combined with other records of ", domain)
+			}
+			recordEntry.addresses = append(recordEntry.addresses, record.Address...)
+			recordEntry.txt = append(recordEntry.txt, record.TXT...)
+			recordEntry.srv = append(recordEntry.srv, record.SRV...)
+		}
+	}
+	static := make(map[string]bool)
+	for domain := range hostsTable.domains {
+		static[domain] = true
+	}
+	for domain := range hostsTable.wildcards {
+		static["*."+domain] = true
+	}
+	for _, path := range s.paths {
+		err := readHostsFile(path, func(address netip.Addr, domain string) {
+			if static[normalizeDomain(domain)] {
+				return
+			}
+			fileEntry := hostsTable.load(domain, true)
+			fileEntry.addresses = append(fileEntry.addresses, address)
+		})
+		if err != nil {
+			return nil, E.Cause(err, "read hosts file ", path)
+		}
+	}
+	return hostsTable, nil
+}
+
+func readHostsFile(path string, add func(address netip.Addr, domain string)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.IndexByte(line, '#'); index != -1 {
+			line = line[:index]
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		address, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			continue
+		}
+		address = address.WithZone("")
+		for _, domain := range fields[1:] {
+			add(address, domain)
+		}
+	}
+	return scanner.Err()
+}
+
+func normalizeDomain(domain string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, "."))
+}
+
+func (t *table) load(domain string, create bool) *entry {
+	domain = normalizeDomain(domain)
+	entries := t.domains
+	if strings.HasPrefix(domain, "*.") {
+		entries = t.wildcards
+		domain = domain[2:]
+	}
+	domainEntry := entries[domain]
+	if domainEntry == nil && create {
+		domainEntry = new(entry)
+		entries[domain] = domainEntry
+	}
+	return domainEntry
+}
+
+// lookup finds the entry of domain, falling back to the most specific wildcard.
+func (t *table) lookup(domain string) *entry {
+	if domainEntry, loaded := t.domains[domain]; loaded {
+		return domainEntry
+	}
+	for index := strings.IndexByte(domain, '.'); index != -1; index = strings.IndexByte(domain, '.') {
+		domain = domain[index+1:]
+		if domainEntry, loaded := t.wildcards[domain]; loaded {
+			return domainEntry
+		}
+	}
+	return nil
+}
+
+func (s *Store) Resolve(question mDNS.Question) ([]mDNS.RR, bool) {
+	s.access.RLock()
+	hostsTable := s.table
+	s.access.RUnlock()
+	if hostsTable == nil {
+		return nil, false
+	}
+	name := normalizeDomain(question.Name)
+	var answers []mDNS.RR
+	for i := 0; i < maxCNAMEChain; i++ {
+		domainEntry := hostsTable.lookup(name)
+		if domainEntry == nil {
+			if i == 0 {
+				return nil, false
+			}
+			break
+		}
+		header := mDNS.RR_Header{
+			Name:  mDNS.Fqdn(name),
+			Class: mDNS.ClassINET,
+			Ttl:   s.ttl,
+		}
+		if domainEntry.cname != "" {
+			header.Rrtype = mDNS.TypeCNAME
+			answers = append(answers, &mDNS.CNAME{Hdr: header, Target: mDNS.Fqdn(domainEntry.cname)})
+			if question.Qtype == mDNS.TypeCNAME {
+				break
+			}
+			name = domainEntry.cname
+			continue
+		}
+		answers = append(answers, domainEntry.answers(header, question.Qtype)...)
+		break
+	}
+	return answers, true
+}
+
+func (e *entry) answers(header mDNS.RR_Header, queryType uint16) []mDNS.RR {
+	var answers []mDNS.RR
+	header.Rrtype = queryType
+	switch queryType {
+	case mDNS.TypeA:
+		for _, address := range e.addresses {
+			if address.Is4() || address.Is4In6() {
+				answers = append(answers, &mDNS.A{Hdr: header, A: address.Unmap().AsSlice()})
+			}
+		}
+	case mDNS.TypeAAAA:
+		for _, address := range e.addresses {
+			if address.Is6() && !address.Is4In6() {
+				answers = append(answers, &mDNS.AAAA{Hdr: header, AAAA: address.AsSlice()})
+			}
+		}
+	case mDNS.TypeTXT:
+		for _, txt := range e.txt {
+			answers = append(answers, &mDNS.TXT{Hdr: header, Txt: splitTXT(txt)})
+		}
+	case mDNS.TypeSRV:
+		for _, srv := range e.srv {
+			answers = append(answers, &mDNS.SRV{
+				Hdr:      header,
+				Priority: srv.Priority,
+				Weight:   srv.Weight,
+				Port:     srv.Port,
+				Target:   mDNS.Fqdn(srv.Target),
+			})
+		}
+	}
+	return answers
+}
+
+// splitTXT splits a TXT value into strings of at most 255 bytes.
+func splitTXT(value string) []string {
+	var txt []string
+	for len(value) > 255 {
+		txt = append(txt, value[:255])
+		value = value[255:]
+	}
+	return append(txt, value)
+}
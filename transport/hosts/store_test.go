@@ -0,0 +1,136 @@
+package hosts_test
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/sagernet/sing-box/option"
+	"github.com/sagernet/sing-box/transport/hosts"
+	"github.com/sagernet/sing/common/logger"
+
+	mDNS "github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func answerStrings(answers []mDNS.RR) []string {
+	var result []string
+	for _, answer := range answers {
+		result = append(result, strings.ReplaceAll(answer.String(), "\t", " "))
+	}
+	return result
+}
+
+func TestStoreResolve(t *testing.T) {
+	t.Parallel()
+	hostsFile := filepath.Join(t.TempDir(), "hosts")
+	require.NoError(t, os.WriteFile(hostsFile, []byte(""+
+		"# comment\n"+
+		"127.0.0.1 localhost file.example # trailing comment\n"+
+		"fe80::1%eth0 file.example\n"+
+		"10.0.0.9 static.example\n"+
+		"not-an-address ignored.example\n",
+	), 0o644))
+	store, err := hosts.NewStore(context.Background(), logger.NOP(), option.DNSHostsOptions{
+		Path: []string{hostsFile},
+		TTL:  300,
+		Records: []option.DNSHostsRecord{
+			{Domain: []string{"static.example", "Upper.Example."}, Address: []netip.Addr{netip.MustParseAddr("10.0.0.1"), netip.MustParseAddr("::ffff:10.0.0.2"), netip.MustParseAddr("2001:db8::1")}},
+			{Domain: []string{"*.wildcard.example"}, Address: []netip.Addr{netip.MustParseAddr("10.0.0.3")}},
+			{Domain: []string{"deep.sub.wildcard.example"}, Address: []netip.Addr{netip.MustParseAddr("10.0.0.4")}},
+			{Domain: []string{"alias.example"}, CNAME: "static.example"},
+			{Domain: []string{"loop-a.example"}, CNAME: "loop-b.example"},
+			{Domain: []string{"loop-b.example"}, CNAME: "loop-a.example"},
+			{Domain: []string{"dangling.example"}, CNAME: "missing.example"},
+			{Domain: []string{"txt.example"}, TXT: []string{"v=spf1 -all", strings.Repeat("a", 300)}},
+			{Domain: []string{"_sip._tcp.example"}, SRV: []option.DNSHostsSRVRecord{{Priority: 10, Weight: 5, Port: 5060, Target: "sip.example"}}},
+		},
+	}, nil)
+	require.NoError(t, err)
+	for _, testCase := range []struct {
+		name      string
+		queryType uint16
+		loaded    bool
+		answers   []string
+	}{
+		{"static.example.", mDNS.TypeA, true, []string{
+			"static.example. 300 IN A 10.0.0.1",
+			"static.example. 300 IN A 10.0.0.2",
+		}},
+		{"STATIC.example.", mDNS.TypeAAAA, true, []string{
+			"static.example. 300 IN AAAA 2001:db8::1",
+		}},
+		{"upper.example.", mDNS.TypeA, true, []string{
+			"upper.example. 300 IN A 10.0.0.1",
+			"upper.example. 300 IN A 10.0.0.2",
+		}},
+		{"static.example.", mDNS.TypeMX, true, nil},
+		{"file.example.", mDNS.TypeA, true, []string{"file.example. 300 IN A 127.0.0.1"}},
+		{"file.example.", mDNS.TypeAAAA, true, []string{"file.example. 300 IN AAAA fe80::1"}},
+		{"localhost.", mDNS.TypeA, true, []string{"localhost. 300 IN A 127.0.0.1"}},
+		{"ignored.example.", mDNS.TypeA, false, nil},
+		{"unknown.example.", mDNS.TypeA, false, nil},
+		{"wildcard.example.", mDNS.TypeA, false, nil},
+		{"a.wildcard.example.", mDNS.TypeA, true, []string{"a.wildcard.example. 300 IN A 10.0.0.3"}},
+		{"a.b.wildcard.example.", mDNS.TypeA, true, []string{"a.b.wildcard.example. 300 IN A 10.0.0.3"}},
+		{"deep.sub.wildcard.example.", mDNS.TypeA, true, []string{"deep.sub.wildcard.example. 300 IN A 10.0.0.4"}},
+		{"alias.example.", mDNS.TypeA, true, []string{
+			"alias.example. 300 IN CNAME static.example.",
+			"static.example. 300 IN A 10.0.0.1",
+			"static.example. 300 IN A 10.0.0.2",
+		}},
+		{"alias.example.", mDNS.TypeCNAME, true, []string{"alias.example. 300 IN CNAME static.example."}},
+		{"dangling.example.", mDNS.TypeA, true, []string{"dangling.example. 300 IN CNAME missing.example."}},
+		{"txt.example.", mDNS.TypeTXT, true, []string{
+			"txt.example. 300 IN TXT \"v=spf1 -all\"",
+			"txt.example. 300 IN TXT \"" + strings.Repeat("a", 255) + "\" \"" + strings.Repeat("a", 45) + "\"",
+		}},
+		{"_sip._tcp.example.", mDNS.TypeSRV, true, []string{"_sip._tcp.example. 300 IN SRV 10 5 5060 sip.example."}},
+	} {
+		answers, loaded := store.Resolve(mDNS.Question{Name: testCase.name, Qtype: testCase.queryType, Qclass: mDNS.ClassINET})
+		require.Equal(t, testCase.loaded, loaded, "%s %s", testCase.name, mDNS.TypeToString[testCase.queryType])
+		require.Equal(t, testCase.answers, answerStrings(answers), "%s %s", testCase.name, mDNS.TypeToString[testCase.queryType])
+	}
+	// A CNAME loop stops after a bounded number of answers.
+	answers, loaded := store.Resolve(mDNS.Question{Name: "loop-a.example.", Qtype: mDNS.TypeA, Qclass: mDNS.ClassINET})
+	require.True(t, loaded)
+	require.Len(t, answers, 8)
+}
+
+func TestStoreTTL(t *testing.T) {
+	t.Parallel()
+	store, err := hosts.NewStore(context.Background(), logger.NOP(), option.DNSHostsOptions{
+		Records: []option.DNSHostsRecord{{Domain: []string{"example.com"}, Address: []netip.Addr{netip.MustParseAddr("1.1.1.1")}}},
+	}, nil)
+	require.NoError(t, err)
+	answers, loaded := store.Resolve(mDNS.Question{Name: "example.com.", Qtype: mDNS.TypeA, Qclass: mDNS.ClassINET})
+	require.True(t, loaded)
+	require.Equal(t, []string{"example.com. 60 IN A 1.1.1.1"}, answerStrings(answers))
+}
+
+func TestStoreInvalid(t *testing.T) {
+	t.Parallel()
+	address := []netip.Addr{netip.MustParseAddr("1.1.1.1")}
+	for _, testCase := range []struct {
+		name    string
+		options option.DNSHostsOptions
+	}{
+		{"missing domain", option.DNSHostsOptions{Records: []option.DNSHostsRecord{{Address: address}}}},
+		{"cname with address", option.DNSHostsOptions{Records: []option.DNSHostsRecord{{Domain: []string{"a.com"}, CNAME: "b.com", Address: address}}}},
+		{"cname after address", option.DNSHostsOptions{Records: []option.DNSHostsRecord{
+			{Domain: []string{"a.com"}, Address: address},
+			{Domain: []string{"a.com"}, CNAME: "b.com"},
+		}}},
+		{"address after cname", option.DNSHostsOptions{Records: []option.DNSHostsRecord{
+			{Domain: []string{"a.com"}, CNAME: "b.com"},
+			{Domain: []string{"A.com."}, Address: address},
+		}}},
+		{"missing file", option.DNSHostsOptions{Path: []string{filepath.Join(t.TempDir(), "missing")}}},
+	} {
+		_, err := hosts.NewStore(context.Background(), logger.NOP(), testCase.options, nil)
+		require.Error(t, err, testCase.name)
+	}
+}
@@ -168,6 +168,19 @@ func Key(password string) [KeyLength]byte {
 	return key
 }
 
+// IsKey reports whether key is encoded like Key, lowercase hex of a SHA224 hash.
+func IsKey(key []byte) bool {
+	if len(key) != KeyLength {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
 func ClientHandshakeRaw(conn net.Conn, key [KeyLength]byte, command byte, destination M.Socksaddr, payload []byte) error {
 	_, err := conn.Write(key[:])
 	if err != nil {
@@ -3,6 +3,7 @@ package trojan
 import (
 	"context"
 	"net"
+	"sync"
 
 	"github.com/sagernet/sing/common/auth"
 	"github.com/sagernet/sing/common/buf"
@@ -19,11 +20,16 @@ type Handler interface {
 	E.Handler
 }
 
+// KeyAuthenticator verifies keys of unknown users, the key is the hex encoded SHA224 of the password.
+type KeyAuthenticator func(ctx context.Context, key string, metadata M.Metadata) (context.Context, error)
+
 type Service[K comparable] struct {
-	users           map[K][56]byte
-	keys            map[[56]byte]K
-	handler         Handler
-	fallbackHandler N.TCPConnectionHandler
+	userAccess       sync.RWMutex
+	users            map[K][56]byte
+	keys             map[[56]byte]K
+	handler          Handler
+	fallbackHandler  N.TCPConnectionHandler
+	keyAuthenticator KeyAuthenticator
 }
 
 func NewService[K comparable](handler Handler, fallbackHandler N.TCPConnectionHandler) *Service[K] {
@@ -51,11 +57,17 @@ func (s *Service[K]) UpdateUsers(userList []K, passwordList []string) error {
 		users[user] = key
 		keys[key] = user
 	}
+	s.userAccess.Lock()
 	s.users = users
 	s.keys = keys
+	s.userAccess.Unlock()
 	return nil
 }
 
+func (s *Service[K]) SetKeyAuthenticator(authenticator KeyAuthenticator) {
+	s.keyAuthenticator = authenticator
+}
+
 func (s *Service[K]) NewConnection(ctx context.Context, conn net.Conn, metadata M.Metadata) error {
 	var key [KeyLength]byte
 	n, err := conn.Read(key[:])
@@ -65,8 +77,17 @@ func (s *Service[K]) NewConnection(ctx context.Context, conn net.Conn, metadata
 		return s.fallback(ctx, conn, metadata, key[:n], E.New("bad request size"))
 	}
 
-	if user, loaded := s.keys[key]; loaded {
+	s.userAccess.RLock()
+	user, loaded := s.keys[key]
+	s.userAccess.RUnlock()
+	if loaded {
 		ctx = auth.ContextWithUser(ctx, user)
+	} else if s.keyAuthenticator != nil && IsKey(key[:]) {
+		userCtx, err := s.keyAuthenticator(ctx, string(key[:]), metadata)
+		if err != nil {
+			return s.fallback(ctx, conn, metadata, key[:], E.Cause(err, "bad request"))
+		}
+		ctx = userCtx
 	} else {
 		return s.fallback(ctx, conn, metadata, key[:], E.New("bad request"))
 	}